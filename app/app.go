@@ -16,6 +16,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrDuplicateNode is returned by App.Ready when a brand new node can't join
+// the cluster because a different node with the same ID or address is already
+// part of it. The stale node must be removed before retrying.
+var ErrDuplicateNode = errors.New("duplicate node")
+
 // App is a high-level helper for initializing a typical dqlite-based Go
 // application.
 //
@@ -29,6 +34,7 @@ type App struct {
 	nodeBindAddress string
 	listener        net.Listener
 	tls             *tlsSetup
+	localAffinity   bool
 	store           client.NodeStore
 	driver          *driver.Driver
 	driverName      string
@@ -37,6 +43,8 @@ type App struct {
 	proxyCh         chan struct{}      // Waits for App.proxy() to return.
 	runCh           chan struct{}      // Waits for App.run() to return.
 	readyCh         chan struct{}      // Waits for startup tasks
+	readyErr        error              // Startup failure reported by Ready()
+	maintenanceCh   chan struct{}      // Waits for App.maintain() to return.
 	voters          int
 	standbys        int
 	roles           RolesConfig
@@ -165,6 +173,8 @@ func New(dir string, options ...Option) (app *App, err error) {
 		dqlite.WithBindAddress(nodeBindAddress),
 		dqlite.WithDialFunc(nodeDial),
 		dqlite.WithFailureDomain(o.FailureDomain),
+		dqlite.WithDiskMode(o.DiskMode),
+		dqlite.WithSnapshotParams(o.SnapshotParams),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("create node: %w", err)
@@ -178,6 +188,9 @@ func New(dir string, options ...Option) (app *App, err error) {
 	driverDial := client.DefaultDialFunc
 	if o.TLS != nil {
 		driverDial = client.DialFuncWithTLS(driverDial, o.TLS.Dial)
+		if o.LocalAffinity {
+			driverDial = client.DialFuncWithLocalNode(driverDial, info.Address, nodeBindAddress)
+		}
 	}
 
 	driver, err := driver.New(store, driver.WithDialFunc(driverDial), driver.WithLogFunc(o.Log))
@@ -196,6 +209,12 @@ func New(dir string, options ...Option) (app *App, err error) {
 		return nil, fmt.Errorf("invalid stand-bys %d: must be an odd number", o.StandBys)
 	}
 
+	for _, task := range o.Maintenance {
+		if task.Interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %s for maintenance task %q", task.Interval, task.SQL)
+		}
+	}
+
 	ctx, stop := context.WithCancel(context.Background())
 
 	app = &App{
@@ -209,6 +228,7 @@ func New(dir string, options ...Option) (app *App, err error) {
 		driverName:      driverName,
 		log:             o.Log,
 		tls:             o.TLS,
+		localAffinity:   o.LocalAffinity,
 		stop:            stop,
 		runCh:           make(chan struct{}, 0),
 		readyCh:         make(chan struct{}, 0),
@@ -236,6 +256,11 @@ func New(dir string, options ...Option) (app *App, err error) {
 
 	go app.run(ctx, o.RolesAdjustmentFrequency, joinFileExists)
 
+	if len(o.Maintenance) > 0 {
+		app.maintenanceCh = make(chan struct{}, 0)
+		go app.maintain(ctx, o.Maintenance)
+	}
+
 	return app, nil
 }
 
@@ -314,6 +339,9 @@ func (a *App) Close() error {
 	// Stop the run goroutine.
 	a.stop()
 	<-a.runCh
+	if a.maintenanceCh != nil {
+		<-a.maintenanceCh
+	}
 
 	if a.listener != nil {
 		a.listener.Close()
@@ -347,11 +375,12 @@ func (a *App) Driver() string {
 //
 // If this method returns without error it means that those initial tasks have
 // succeeded and follow-up operations like Open() are more likely to succeeed
-// quickly.
+// quickly. Joining the cluster is retried until it succeeds, unless it fails
+// with ErrDuplicateNode, which is returned.
 func (a *App) Ready(ctx context.Context) error {
 	select {
 	case <-a.readyCh:
-		return nil
+		return a.readyErr
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -446,8 +475,14 @@ func (a *App) run(ctx context.Context, frequency time.Duration, join bool) {
 
 			// Attempt to join the cluster if this is a brand new node.
 			if join {
-				info := client.NodeInfo{ID: a.id, Address: a.address, Role: client.Spare}
-				if err := cli.Add(ctx, info); err != nil {
+				if err := a.join(ctx, cli); err != nil {
+					if errors.Is(err, ErrDuplicateNode) {
+						a.error("join cluster: %v", err)
+						a.readyErr = err
+						close(a.readyCh)
+						cli.Close()
+						return
+					}
 					a.warn("join cluster: %v", err)
 					delay = time.Second
 					cli.Close()
@@ -494,6 +529,29 @@ func (a *App) run(ctx context.Context, frequency time.Duration, join bool) {
 	}
 }
 
+// Add ourselves to the cluster as spare node, unless we're already part of it
+// because a previous attempt succeeded.
+func (a *App) join(ctx context.Context, cli *client.Client) error {
+	nodes, err := cli.Cluster(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.ID == a.id && node.Address == a.address {
+			return nil
+		}
+		if node.ID == a.id {
+			return fmt.Errorf("%w: ID %d already used by %s", ErrDuplicateNode, a.id, node.Address)
+		}
+		if node.Address == a.address {
+			return fmt.Errorf("%w: address %s already used by node %d", ErrDuplicateNode, a.address, node.ID)
+		}
+	}
+
+	info := client.NodeInfo{ID: a.id, Address: a.address, Role: client.Spare}
+	return cli.Add(ctx, info)
+}
+
 // Possibly change our own role at startup.
 func (a *App) maybePromoteOurselves(ctx context.Context, cli *client.Client, nodes []client.NodeInfo) error {
 	roles := a.makeRolesChanges(nodes)
@@ -594,6 +652,9 @@ func (a *App) clientOptions() []client.Option {
 	dial := client.DefaultDialFunc
 	if a.tls != nil {
 		dial = client.DialFuncWithTLS(dial, a.tls.Dial)
+		if a.localAffinity {
+			dial = client.DialFuncWithLocalNode(dial, a.address, a.nodeBindAddress)
+		}
 	}
 	return []client.Option{client.WithDialFunc(dial), client.WithLogFunc(a.log)}
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -75,6 +76,29 @@ func TestNew_JoinerRestart(t *testing.T) {
 	require.NoError(t, app2.Ready(context.Background()))
 }
 
+// A brand new node can't join using the address of a node that is already
+// part of the cluster.
+func TestNew_JoinerDuplicateAddress(t *testing.T) {
+	addr1 := "127.0.0.1:9001"
+	addr2 := "127.0.0.1:9002"
+
+	app1, cleanup := newApp(t, app.WithAddress(addr1))
+	defer cleanup()
+
+	require.NoError(t, app1.Ready(context.Background()))
+
+	app2, cleanup := newApp(t, app.WithAddress(addr2), app.WithCluster([]string{addr1}))
+	require.NoError(t, app2.Ready(context.Background()))
+	cleanup()
+
+	// Start a node with a fresh data directory, and hence a new ID.
+	app2, cleanup = newApp(t, app.WithAddress(addr2), app.WithCluster([]string{addr1}))
+	defer cleanup()
+
+	err := app2.Ready(context.Background())
+	assert.True(t, errors.Is(err, app.ErrDuplicateNode))
+}
+
 // The second joiner promotes itself and also the first joiner.
 func TestNew_SecondJoiner(t *testing.T) {
 	addr1 := "127.0.0.1:9001"
@@ -773,6 +797,29 @@ func TestOpen(t *testing.T) {
 }
 
 // Test client connections dropping uncleanly.
+// Maintenance tasks are run periodically on the leader.
+func TestMaintenance(t *testing.T) {
+	task := app.MaintenanceTask{Database: "test", SQL: "INSERT INTO runs VALUES(1)", Interval: 50 * time.Millisecond}
+	app, cleanup := newApp(t, app.WithMaintenance(task))
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, app.Ready(ctx))
+
+	db, err := app.Open(ctx, "test")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE runs(n INT)")
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM runs").Scan(&n))
+	assert.True(t, n > 0)
+}
+
 func TestProxy_Error(t *testing.T) {
 	cert, pool := loadCert(t)
 	dial := client.DialFuncWithTLS(client.DefaultDialFunc, app.SimpleDialTLSConfig(cert, pool))
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MaintenanceTask is a statement run periodically against a database, such as
+// VACUUM, ANALYZE or PRAGMA incremental_vacuum.
+type MaintenanceTask struct {
+	Database string
+	SQL      string
+	Interval time.Duration
+}
+
+// Run the given maintenance tasks until the context is done.
+//
+// Tasks are run one at a time, so they never overlap, and only while this node
+// is the leader, so that only one node of the cluster runs them.
+func (a *App) maintain(ctx context.Context, tasks []MaintenanceTask) {
+	defer close(a.maintenanceCh)
+
+	dbs := map[string]*sql.DB{}
+	defer func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+
+	next := make([]time.Time, len(tasks))
+	for i, task := range tasks {
+		next[i] = time.Now().Add(task.Interval)
+	}
+
+	for {
+		// Wait for the task due first.
+		due := 0
+		for i := range tasks {
+			if next[i].Before(next[due]) {
+				due = i
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next[due])):
+		}
+
+		task := tasks[due]
+		next[due] = time.Now().Add(task.Interval)
+
+		if !a.isLeader(ctx) {
+			continue
+		}
+
+		db, ok := dbs[task.Database]
+		if !ok {
+			var err error
+			db, err = sql.Open(a.driverName, task.Database)
+			if err != nil {
+				a.warn("maintenance of %s: %v", task.Database, err)
+				continue
+			}
+			dbs[task.Database] = db
+		}
+
+		start := time.Now()
+		if _, err := db.ExecContext(ctx, task.SQL); err != nil {
+			a.warn("maintenance of %s: %s: %v", task.Database, task.SQL, err)
+			continue
+		}
+		a.debug("maintenance of %s: %s took %s", task.Database, task.SQL, time.Since(start))
+	}
+}
+
+// Tell whether this node is currently the leader.
+func (a *App) isLeader(ctx context.Context) bool {
+	cli, err := a.Client(ctx)
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	return err == nil && leader.ID == a.id
+}
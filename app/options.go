@@ -7,6 +7,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/canonical/go-dqlite"
 	"github.com/canonical/go-dqlite/client"
 )
 
@@ -50,6 +51,11 @@ func WithCluster(cluster []string) Option {
 //
 // The "dial" parameter must hold the TLS configuration to use when
 // establishing outgoing connections to other application nodes.
+//
+// Both configurations are used for every new connection, so certificates
+// returned by callbacks like GetCertificate, GetClientCertificate or
+// GetConfigForClient can be rotated without restarting the application (see
+// client.CertificateLoader).
 func WithTLS(listen *tls.Config, dial *tls.Config) Option {
 	return func(options *options) {
 		options.TLS = &tlsSetup{
@@ -127,13 +133,49 @@ func WithLogFunc(log client.LogFunc) Option {
 // WithFailureDomain sets the node's failure domain.
 //
 // Failure domains are taken into account when deciding which nodes to promote
-// to Voter or StandBy when needed.
+// to Voter or StandBy when needed, and which ones to demote when there are too
+// many, so voters and stand-bys are spread across as many domains as possible
+// and the cluster survives the loss of a whole domain.
 func WithFailureDomain(code uint64) Option {
 	return func(options *options) {
 		options.FailureDomain = code
 	}
 }
 
+// WithSnapshotParams sets the raft snapshot parameters of the node.
+func WithSnapshotParams(params dqlite.SnapshotParams) Option {
+	return func(options *options) {
+		options.SnapshotParams = params
+	}
+}
+
+// WithDiskMode makes the node store databases on disk instead of in memory.
+//
+// All nodes of the cluster must be configured with the same mode.
+func WithDiskMode(disk bool) Option {
+	return func(options *options) {
+		options.DiskMode = disk
+	}
+}
+
+// WithMaintenance schedules statements such as VACUUM or ANALYZE to be run
+// periodically against a database, while this node is the leader.
+func WithMaintenance(tasks ...MaintenanceTask) Option {
+	return func(options *options) {
+		options.Maintenance = append(options.Maintenance, tasks...)
+	}
+}
+
+// WithLocalAffinity makes connections to the local node, from the driver and
+// from the clients returned by Leader, go through the unix socket the node
+// listens on behind the TLS proxy, skipping TCP and TLS. It has no effect
+// unless TLS is enabled with WithTLS.
+func WithLocalAffinity(enabled bool) Option {
+	return func(options *options) {
+		options.LocalAffinity = enabled
+	}
+}
+
 type tlsSetup struct {
 	Listen *tls.Config
 	Dial   *tls.Config
@@ -148,6 +190,10 @@ type options struct {
 	StandBys                 int
 	RolesAdjustmentFrequency time.Duration
 	FailureDomain            uint64
+	DiskMode                 bool
+	SnapshotParams           dqlite.SnapshotParams
+	Maintenance              []MaintenanceTask
+	LocalAffinity            bool
 }
 
 // Create a options object with sane defaults.
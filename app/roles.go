@@ -180,6 +180,7 @@ func (c *RolesChanges) Adjust(leader uint64) (client.NodeRole, []client.NodeInfo
 			}
 			nodes = append(nodes, node)
 		}
+		c.sortDemotions(nodes, onlineVoters)
 
 		return client.Spare, nodes
 	}
@@ -215,6 +216,7 @@ func (c *RolesChanges) Adjust(leader uint64) (client.NodeRole, []client.NodeInfo
 			}
 			nodes = append(nodes, node)
 		}
+		c.sortDemotions(nodes, onlineStandbys)
 
 		return client.Spare, nodes
 	}
@@ -299,6 +301,31 @@ func (c *RolesChanges) sortCandidates(candidates []client.NodeInfo, domains map[
 	sort.Slice(candidates, less)
 }
 
+// Sort the given nodes to be demoted according to their failure domain and
+// weight. Nodes whose failure domain is shared by more of the given peers take
+// precedence, so the remaining ones stay spread across domains.
+func (c *RolesChanges) sortDemotions(nodes []client.NodeInfo, peers []client.NodeInfo) {
+	counts := map[uint64]int{}
+	for _, node := range peers {
+		counts[c.metadata(node).FailureDomain]++
+	}
+
+	less := func(i, j int) bool {
+		metadata1 := c.metadata(nodes[i])
+		metadata2 := c.metadata(nodes[j])
+
+		count1 := counts[metadata1.FailureDomain]
+		count2 := counts[metadata2.FailureDomain]
+		if count1 != count2 {
+			return count1 > count2
+		}
+
+		return metadata1.Weight > metadata2.Weight
+	}
+
+	sort.Slice(nodes, less)
+}
+
 // Return the metadata of the given node, if any.
 func (c *RolesChanges) metadata(node client.NodeInfo) *client.NodeMetadata {
 	return c.State[node]
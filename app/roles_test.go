@@ -0,0 +1,41 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/canonical/go-dqlite/app"
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// When there are too many voters, the ones sharing their failure domain with
+// other voters are demoted first.
+func TestRolesChanges_AdjustDemoteHonorFailureDomain(t *testing.T) {
+	node := func(id uint64, role client.NodeRole) client.NodeInfo {
+		return client.NodeInfo{ID: id, Address: "@", Role: role}
+	}
+	metadata := func(domain, weight uint64) *client.NodeMetadata {
+		return &client.NodeMetadata{FailureDomain: domain, Weight: weight}
+	}
+
+	changes := app.RolesChanges{
+		Config: app.RolesConfig{Voters: 3, StandBys: 0},
+		State: map[client.NodeInfo]*client.NodeMetadata{
+			node(1, client.Voter): metadata(1, 0),
+			node(2, client.Voter): metadata(2, 0),
+			node(3, client.Voter): metadata(2, 1),
+			node(4, client.Voter): metadata(3, 5),
+			node(5, client.Voter): metadata(2, 0),
+		},
+	}
+
+	role, nodes := changes.Adjust(1)
+	assert.Equal(t, client.Spare, role)
+	ids := []uint64{}
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	assert.Equal(t, uint64(3), ids[0])
+	assert.ElementsMatch(t, []uint64{2, 5}, ids[1:3])
+	assert.Equal(t, uint64(4), ids[3])
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// DefaultExecWindow is the default maximum number of statements executed with
+// ExecAsync that are sent together.
+const DefaultExecWindow = 64
+
+// WithExecWindow sets the maximum number of statements executed with
+// ExecAsync that are sent together in a single round trip.
+func WithExecWindow(n int) Option {
+	return func(options *options) {
+		options.ExecWindow = n
+	}
+}
+
+// ExecResult is the result of a statement executed with ExecAsync.
+type ExecResult struct {
+	LastInsertID uint64
+	RowsAffected uint64
+}
+
+// ExecFuture is the pending result of a statement executed with ExecAsync.
+type ExecFuture struct {
+	client   *Client
+	request  *protocol.Message
+	response *protocol.Message
+	done     bool
+	result   ExecResult
+	err      error
+}
+
+// Wait returns the result of the statement, sending it along with all other
+// pending statements if it hasn't been sent yet.
+func (f *ExecFuture) Wait(ctx context.Context) (ExecResult, error) {
+	c := f.client
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if !f.done {
+		c.flushExec(ctx)
+	}
+
+	return f.result, f.err
+}
+
+// ExecAsync queues the given statement for execution against the database
+// with the given name, and returns a future resolving to its result.
+//
+// Queued statements are sent to the node without waiting for the response to
+// the previous one, so several writes cost a single network round trip. They
+// are sent when the window set with WithExecWindow is full, when the result of
+// any of them is awaited, when Flush is invoked, and before any other request
+// made with the client or closing it. Statements are executed in the order
+// they were queued, and a failed statement doesn't prevent the following ones
+// from being executed.
+//
+// This must be invoked on a client connected to the current leader. As for
+// DatabaseStats, dqlite allows only one database per connection, so all
+// statements must target the same database.
+func (c *Client) ExecAsync(ctx context.Context, dbname, sql string, args ...interface{}) (*ExecFuture, error) {
+	done, err := c.track()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	id, err := c.openDatabase(ctx, dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	future := &ExecFuture{
+		client:   c,
+		request:  &protocol.Message{},
+		response: &protocol.Message{},
+	}
+	future.request.Init(4096)
+	future.response.Init(64)
+	protocol.EncodeExecSQL(future.request, uint64(id), sql, values)
+
+	c.pending = append(c.pending, future)
+	if len(c.pending) >= c.execWindow {
+		c.flushExec(ctx)
+	}
+
+	return future, nil
+}
+
+// Flush sends all statements queued with ExecAsync and waits for their
+// results.
+func (c *Client) Flush(ctx context.Context) error {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	return c.flushExec(ctx)
+}
+
+// Send the pending statements and resolve their futures. It must be called
+// with the exec lock held.
+func (c *Client) flushExec(ctx context.Context) error {
+	pending := c.pending
+	c.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+
+	requests := make([]*protocol.Message, len(pending))
+	responses := make([]*protocol.Message, len(pending))
+	for i, future := range pending {
+		requests[i] = future.request
+		responses[i] = future.response
+	}
+
+	err := c.protocol.CallBatch(ctx, requests, responses)
+	if err != nil {
+		err = errors.Wrap(err, "failed to send Exec requests")
+	}
+
+	for _, future := range pending {
+		future.done = true
+		future.err = err
+		if err != nil {
+			continue
+		}
+		result, err := protocol.DecodeResult(future.response)
+		if err != nil {
+			future.err = errors.Wrap(err, "failed to execute statement")
+			continue
+		}
+		future.result = ExecResult{LastInsertID: result.LastInsertID, RowsAffected: result.RowsAffected}
+	}
+
+	return err
+}
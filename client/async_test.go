@@ -0,0 +1,135 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ExecAsync(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, sql)
+		if sql == "FAIL" {
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "boom"}
+		}
+		return clienttest.Result{LastInsertID: uint64(len(statements)), RowsAffected: 1}, nil
+	})
+	executed := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, statements...)
+	}
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithExecWindow(2))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	first, err := cli.ExecAsync(ctx, "test.db", "INSERT INTO t VALUES(?)", 1)
+	require.NoError(t, err)
+	assert.Empty(t, executed())
+
+	// The window is full, so both statements are sent.
+	second, err := cli.ExecAsync(ctx, "test.db", "FAIL")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"INSERT INTO t VALUES(?)", "FAIL"}, executed())
+
+	third, err := cli.ExecAsync(ctx, "test.db", "INSERT INTO t VALUES(?)", 3)
+	require.NoError(t, err)
+	assert.Len(t, executed(), 2)
+
+	result, err := third.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, client.ExecResult{LastInsertID: 3, RowsAffected: 1}, result)
+
+	result, err = first.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, client.ExecResult{LastInsertID: 1, RowsAffected: 1}, result)
+
+	_, err = second.Wait(ctx)
+	assert.EqualError(t, err, "failed to execute statement: boom (1)")
+
+	_, err = cli.ExecAsync(ctx, "other.db", "INSERT INTO t VALUES(1)")
+	assert.Error(t, err)
+}
+
+func TestClient_Flush(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	count := 0
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := cli.ExecAsync(ctx, "test.db", "INSERT INTO t VALUES(?)", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, cli.Flush(ctx))
+
+	mu.Lock()
+	assert.Equal(t, 3, count)
+	mu.Unlock()
+}
+
+// Queued statements are sent before any other request and when closing the
+// client.
+func TestClient_ExecAsyncOrdering(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, sql)
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithDatabase("test.db"))
+	require.NoError(t, err)
+
+	_, err = cli.ExecAsync(ctx, "test.db", "INSERT INTO t VALUES(1)")
+	require.NoError(t, err)
+	_, err = cli.Exec(ctx, "INSERT INTO t VALUES(2)")
+	require.NoError(t, err)
+	_, err = cli.ExecAsync(ctx, "test.db", "INSERT INTO t VALUES(3)")
+	require.NoError(t, err)
+	require.NoError(t, cli.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"INSERT INTO t VALUES(1)",
+		"INSERT INTO t VALUES(2)",
+		"INSERT INTO t VALUES(3)",
+	}, statements)
+}
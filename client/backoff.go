@@ -0,0 +1,34 @@
+package client
+
+import (
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// BackoffFunc returns how long a Connector waits before the given round of
+// attempts to find the leader, starting from 1.
+type BackoffFunc = protocol.BackoffFunc
+
+// Backoff strategies that can be passed to WithBackoffFunc.
+var (
+	// ExponentialBackoff waits factor times 2^attempt, up to cap. It's the
+	// strategy configured by WithBackoff.
+	ExponentialBackoff = protocol.ExponentialBackoff
+
+	// JitteredBackoff waits a random duration between zero and the one of
+	// ExponentialBackoff, so clients retrying at the same time spread out.
+	JitteredBackoff = protocol.JitteredBackoff
+
+	// ConstantBackoff always waits the given duration.
+	ConstantBackoff = protocol.ConstantBackoff
+)
+
+// WithBackoffFunc sets the backoff strategy used by FindLeader and Connector
+// between failed rounds of attempts, overriding WithBackoff.
+//
+// The wait is interrupted if the context passed to FindLeader or Connect is
+// done, or if the node store is a WatchableNodeStore and its nodes change.
+func WithBackoffFunc(backoff BackoffFunc) Option {
+	return func(options *options) {
+		options.Backoff = backoff
+	}
+}
@@ -0,0 +1,51 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	exponential := client.ExponentialBackoff(time.Millisecond, 10*time.Millisecond)
+	assert.Equal(t, 2*time.Millisecond, exponential(1))
+	assert.Equal(t, 8*time.Millisecond, exponential(3))
+	assert.Equal(t, 10*time.Millisecond, exponential(4))
+	assert.Equal(t, 10*time.Millisecond, exponential(100))
+
+	jittered := client.JitteredBackoff(time.Millisecond, 10*time.Millisecond)
+	for attempt := uint(1); attempt < 10; attempt++ {
+		assert.True(t, jittered(attempt) <= exponential(attempt))
+	}
+
+	assert.Equal(t, time.Second, client.ConstantBackoff(time.Second)(7))
+}
+
+// The backoff strategy is invoked between rounds of attempts, and the wait is
+// interrupted when the context is done.
+func TestWithBackoffFunc(t *testing.T) {
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{Address: "@not-there"}})
+
+	attempts := []uint{}
+	backoff := func(attempt uint) time.Duration {
+		attempts = append(attempts, attempt)
+		if attempt == 2 {
+			return time.Hour
+		}
+		return time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.FindLeader(ctx, store, client.WithBackoffFunc(backoff))
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, []uint{1, 2}, attempts)
+}
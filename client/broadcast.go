@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// BroadcastFunc is invoked by Broadcast with a client connected to one of the
+// nodes, returning a value for that node.
+type BroadcastFunc func(ctx context.Context, node NodeInfo, cli *Client) (interface{}, error)
+
+// BroadcastResult is the outcome of a BroadcastFunc on a single node.
+type BroadcastResult struct {
+	Node  NodeInfo
+	Value interface{} // Value returned by the function.
+	Err   error       // Set if the node couldn't be reached or the function failed.
+}
+
+// Broadcast connects to all the nodes in the given store concurrently, using
+// the given options, and invokes the given function on each of them, for
+// example to fetch metadata or ping every node of the cluster.
+//
+// The results are returned in the same order as the nodes in the store. The
+// returned error is only set if the nodes can't be read from the store, while
+// failures of individual nodes are reported in their results.
+func Broadcast(ctx context.Context, store NodeStore, f BroadcastFunc, options ...Option) ([]BroadcastResult, error) {
+	nodes, err := store.Get(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get nodes from store")
+	}
+
+	results := make([]BroadcastResult, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		i, node := i, node
+		results[i].Node = node
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli, err := New(ctx, node.Address, options...)
+			if err != nil {
+				results[i].Err = errors.Wrapf(err, "failed to connect to node %d", node.ID)
+				return
+			}
+			defer cli.Close()
+			results[i].Value, results[i].Err = f(ctx, node, cli)
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcast(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	server2 := clienttest.NewServer(2)
+	defer server2.Close()
+	server3 := clienttest.NewServer(3)
+	address3 := server3.Address()
+	server3.Close()
+
+	ctx := context.Background()
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, []client.NodeInfo{
+		{ID: 1, Address: server1.Address()},
+		{ID: 2, Address: server2.Address()},
+		{ID: 3, Address: address3},
+	}))
+	require.NoError(t, client.SetNodeWeight(ctx, store, 2, 4))
+
+	results, err := client.Broadcast(ctx, store, func(ctx context.Context, node client.NodeInfo, cli *client.Client) (interface{}, error) {
+		metadata, err := cli.Describe(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return metadata.Weight, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, uint64(1), results[0].Node.ID)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, uint64(0), results[0].Value)
+
+	assert.Equal(t, uint64(2), results[1].Node.ID)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, uint64(4), results[1].Value)
+
+	assert.Equal(t, uint64(3), results[2].Node.ID)
+	assert.Contains(t, results[2].Err.Error(), "failed to connect to node 3")
+	assert.Nil(t, results[2].Value)
+}
@@ -2,7 +2,12 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
 
+	"github.com/canonical/go-dqlite/internal/clientbridge"
 	"github.com/canonical/go-dqlite/internal/protocol"
 	"github.com/pkg/errors"
 )
@@ -10,17 +15,90 @@ import (
 // DialFunc is a function that can be used to establish a network connection.
 type DialFunc = protocol.DialFunc
 
+// ErrMessageTooLarge is returned when a node sends a response bigger than the
+// limit set with WithMaxMessageSize.
+type ErrMessageTooLarge = protocol.ErrMessageTooLarge
+
+// AuthFunc performs custom authentication right after the protocol handshake.
+// See WithAuth.
+type AuthFunc = protocol.AuthFunc
+
+// ErrAuthentication is returned when authentication against a node fails.
+type ErrAuthentication = protocol.ErrAuthentication
+
+// ErrMalformedMessage is returned when a node sends a response that can't be
+// decoded.
+var ErrMalformedMessage = protocol.ErrMalformedMessage
+
+// ErrNoAvailableLeader is returned by FindLeader if no leader could be found.
+var ErrNoAvailableLeader = protocol.ErrNoAvailableLeader
+
+// Error holds the SQLite result code and the message of a failed request. It
+// can be extracted from the errors returned by clients with errors.As.
+type Error = protocol.Error
+
+// Failure modes that the errors returned by clients can be matched against
+// with errors.Is.
+var (
+	// ErrNotLeader matches failures of requests sent to a node that is not
+	// the leader or lost leadership while serving them.
+	ErrNotLeader = protocol.ErrNotLeader
+
+	// ErrNodeBusy matches failures because the database is busy or locked.
+	ErrNodeBusy = protocol.ErrNodeBusy
+
+	// ErrConnectionDead matches failures caused by the network connection
+	// to the node being lost.
+	ErrConnectionDead = protocol.ErrConnectionDead
+)
+
 // Client speaks the dqlite wire protocol.
 type Client struct {
 	protocol *protocol.Protocol
+	borrowed bool          // Whether the protocol is owned by someone else.
+	database *openDatabase // Database opened on the connection, if any.
+
+	execMu     sync.Mutex    // Serializes ExecAsync and its futures.
+	execWindow int           // Maximum number of pending ExecAsync statements.
+	pending    []*ExecFuture // Statements queued by ExecAsync and not yet sent.
+
+	lifeMu   sync.Mutex    // Protects the fields below.
+	inflight int           // Number of requests in flight.
+	idle     chan struct{} // Set by Shutdown, closed once no request is in flight.
+	closed   bool          // Whether the connection was closed.
+
+	translate AddressTranslator // Maps the node addresses returned by the cluster.
 }
 
 // Option that can be used to tweak client parameters.
 type Option func(*options)
 
 type options struct {
-	DialFunc DialFunc
-	LogFunc  LogFunc
+	DialFunc         DialFunc
+	LogFunc          LogFunc
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	Limits           Limits
+	StrictDecoding   bool
+	TLSConfig        *tls.Config
+	Auth             AuthFunc
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	AttemptTimeout   time.Duration
+	RetryLimit       uint
+	BackoffFactor    time.Duration
+	BackoffCap       time.Duration
+	Backoff          BackoffFunc
+	LeaderChange     func(old, new NodeInfo)
+	UpdateStore      bool
+	Interceptor      Interceptor
+	CallTimeouts     CallTimeouts
+	CallStats        CallStatsFunc
+	AdaptiveTimeout  *protocol.AdaptiveTimeout
+	KeepAlive        time.Duration
+	Translate        AddressTranslator
+	ExecWindow       int
+	Database         string
 }
 
 // WithDialFunc sets a custom dial function for creating the client network
@@ -31,41 +109,366 @@ func WithDialFunc(dial DialFunc) Option {
 	}
 }
 
-// WithLogFunc sets a custom log function.
-// connection.
+// WithLogFunc sets a custom log function, used to report events such as
+// dials, connection attempts, leader changes and lost connections.
 func WithLogFunc(log LogFunc) Option {
 	return func(options *options) {
 		options.LogFunc = log
 	}
 }
 
+// WithReadTimeout sets the maximum amount of time to wait for each individual
+// read from the network connection.
+//
+// The timeout is refreshed every time some data is received, so it won't
+// abort a slow response that keeps streaming in. The deadline of the context
+// passed to each method is always honored as well.
+//
+// If not used, the default is 0 (no timeout).
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.ReadTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum amount of time to wait for a request to be
+// written to the network connection.
+//
+// If not used, the default is 0 (no timeout).
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.WriteTimeout = timeout
+	}
+}
+
+// WithMaxMessageSize sets the maximum size in bytes of a single response
+// message that a node is allowed to send.
+//
+// If a node sends a bigger message, the connection is aborted and an
+// ErrMessageTooLarge error is returned.
+//
+// If not used, the default is 0 (unlimited).
+func WithMaxMessageSize(size int) Option {
+	return func(options *options) {
+		options.Limits.MessageSize = size
+	}
+}
+
+// Limits bounds the data accepted from the responses of nodes: the size of a
+// message, the length of a string, the size of a blob, the number of columns
+// of a result set, and the number and size of the files of a dump. A zero
+// value means no limit.
+type Limits = protocol.Limits
+
+// WithLimits sets the limits enforced on the responses of nodes, replacing
+// the one set with WithMaxMessageSize. A response exceeding the message size
+// aborts the connection with ErrMessageTooLarge, while the other limits make
+// the response fail to decode with ErrMalformedMessage.
+//
+// If not used, there are no limits.
+func WithLimits(limits Limits) Option {
+	return func(options *options) {
+		options.Limits = limits
+	}
+}
+
+// WithStrictDecoding makes the client reject responses that contain more data
+// than expected, returning an ErrMalformedMessage error.
+//
+// Truncated responses are always rejected, regardless of this option.
+func WithStrictDecoding() Option {
+	return func(options *options) {
+		options.StrictDecoding = true
+	}
+}
+
+// WithTLS enables TLS encryption of the connection, trusting the node
+// certificates signed by the given pool and presenting the given client
+// certificate, if not nil, for mutual authentication.
+//
+// The TLS server name is taken from the node address. See NewTLSConfig for
+// details about the resulting configuration.
+func WithTLS(cert *tls.Certificate, pool *x509.CertPool) Option {
+	return func(options *options) {
+		options.TLSConfig = NewTLSConfig(cert, pool)
+	}
+}
+
+// WithTLSConfig enables TLS encryption of the connection using the given
+// configuration.
+//
+// The configuration is evaluated on every dial, so certificates returned by
+// callbacks like GetClientCertificate can be rotated without re-creating the
+// client (see CertificateLoader). If the configuration has no ServerName, the
+// host part of the node address is used.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *options) {
+		options.TLSConfig = config
+	}
+}
+
+// WithAuth sets a function that is invoked right after the protocol version is
+// sent, and that can exchange arbitrary data over the connection in order to
+// authenticate against a proxy fronting the node.
+//
+// If the function fails, an ErrAuthentication error is returned.
+func WithAuth(auth AuthFunc) Option {
+	return func(options *options) {
+		options.Auth = auth
+	}
+}
+
+// WithAuthToken authenticates against a proxy fronting the node by sending
+// the given token right after the protocol version.
+//
+// The token is sent as a little-endian 64-bit word holding its length,
+// followed by its bytes padded to a multiple of 8 bytes. The proxy must then
+// reply with a single 64-bit word, set to zero if the token is accepted.
+func WithAuthToken(token string) Option {
+	return WithAuth(protocol.TokenAuth(token))
+}
+
+// WithDialTimeout sets the maximum amount of time to wait for the network
+// connection to a node to be established.
+//
+// If not used, the default is 5 seconds when finding the leader with
+// FindLeader, and no timeout other than the context deadline with New.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.DialTimeout = timeout
+	}
+}
+
+// WithHandshakeTimeout sets the maximum amount of time to wait for the
+// protocol handshake with a node to complete, including authentication, once
+// the network connection is established.
+//
+// If not used, there's no timeout other than the context deadline with New,
+// and the attempt timeout with FindLeader.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.HandshakeTimeout = timeout
+	}
+}
+
+// WithAttemptTimeout sets the timeout for probing each individual node for
+// leadership when finding the leader with FindLeader, so a node which accepts
+// the connection but it's then unresponsive won't block the search.
+//
+// If not used, the default is 15 seconds.
+func WithAttemptTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.AttemptTimeout = timeout
+	}
+}
+
+// WithRetryLimit sets the maximum number of times FindLeader retries to probe
+// all nodes in the store before giving up with ErrNoAvailableLeader.
+//
+// If not used, the default is 0 (unlimited retries, until the context is
+// done).
+func WithRetryLimit(limit uint) Option {
+	return func(options *options) {
+		options.RetryLimit = limit
+	}
+}
+
+// WithBackoff sets the exponential backoff factor and the maximum backoff
+// value used by FindLeader between failed rounds of attempts.
+//
+// If not used, the defaults are 100 milliseconds and 1 second.
+func WithBackoff(factor, cap time.Duration) Option {
+	return func(options *options) {
+		options.BackoffFactor = factor
+		options.BackoffCap = cap
+	}
+}
+
+// WithLeaderChange sets a function that a Connector invokes when it connects
+// to a leader different from the one it previously connected to, for example
+// to log the event or to pre-warm connections to the new leader.
+//
+// The function is called synchronously from Connect, so it should not block.
+func WithLeaderChange(f func(old, new NodeInfo)) Option {
+	return func(options *options) {
+		options.LeaderChange = f
+	}
+}
+
+// WithUpdateStore makes a Connector save the cluster configuration in its node
+// store every time it connects to the leader, so the store follows membership
+// changes and doesn't need to be maintained by hand.
+//
+// The weight, failure domain and TLS parameters of nodes already in the store
+// are preserved.
+func WithUpdateStore(update bool) Option {
+	return func(options *options) {
+		options.UpdateStore = update
+	}
+}
+
+// Interceptor is invoked for each request sent to a node, with the name of the
+// request, like "leader" or "cluster", and a function sending it, which must
+// be invoked for the request to be performed, and can be invoked again to
+// retry it. Interceptors can be used to add logging, metrics or retries to all
+// requests uniformly.
+type Interceptor = protocol.Interceptor
+
+// WithInterceptor sets a function wrapping each request sent by a client, or
+// by the clients returned by a Connector once they are connected to the
+// leader.
+func WithInterceptor(interceptor Interceptor) Option {
+	return func(options *options) {
+		options.Interceptor = interceptor
+	}
+}
+
+// CallTimeouts holds the timeouts applied to requests whose context has no
+// deadline, by kind of request, so that slow requests like Dump can be given
+// more time than statements and queries.
+type CallTimeouts = protocol.CallTimeouts
+
+// WithCallTimeouts sets the timeouts applied to requests whose context has
+// no deadline. By default requests wait as long as their context allows.
+func WithCallTimeouts(timeouts CallTimeouts) Option {
+	return func(options *options) {
+		options.CallTimeouts = timeouts
+	}
+}
+
+// WithAdaptiveTimeout makes the timeout of requests whose context has no
+// deadline follow the latency of the recent requests, so that a cluster under
+// temporary load doesn't make them fail in cascade. The timeout is four times
+// the 99th percentile of the latest latencies of requests of the same kind,
+// bounded by the given values, and it's the maximum until enough requests
+// were observed.
+//
+// The latencies are shared by all the clients returned by a Connector. The
+// timeouts of bulk requests set with WithCallTimeouts are still applied,
+// while the others are replaced.
+func WithAdaptiveTimeout(min, max time.Duration) Option {
+	return func(options *options) {
+		options.AdaptiveTimeout = protocol.NewAdaptiveTimeout(min, max)
+	}
+}
+
+// WithKeepAlive makes the client ping the node each time the connection stays
+// idle for the given interval, so that a connection silently dropped by a NAT,
+// a load balancer or a dead node is detected early. Once a ping fails, all
+// requests fail right away with ErrConnectionDead, and the client should be
+// closed and replaced.
+//
+// If not used, the default is 0 (no pings).
+func WithKeepAlive(interval time.Duration) Option {
+	return func(options *options) {
+		options.KeepAlive = interval
+	}
+}
+
+// CallStats describes a request sent to a node: its type, the bytes written
+// and read and the time it took.
+type CallStats = protocol.CallStats
+
+// CallStatsFunc is invoked after each request, and must not block.
+type CallStatsFunc = protocol.CallStatsFunc
+
+// WithCallStats sets a function invoked after each request sent by a client,
+// or by the clients returned by a Connector, to collect metrics such as
+// latency histograms. Requests that are retried by an interceptor are
+// reported once per attempt.
+func WithCallStats(fn CallStatsFunc) Option {
+	return func(options *options) {
+		options.CallStats = fn
+	}
+}
+
+// AddressTranslator maps the address of a node, as advertised by the cluster,
+// to the address that should be dialed to reach it, for deployments where
+// nodes advertise addresses that are not reachable by clients, like internal
+// IPs behind a NAT. Addresses that need no translation must be returned
+// unchanged.
+type AddressTranslator = protocol.AddressTranslator
+
+// WithAddressTranslator sets a function applied to every node address learned
+// from the cluster: the leader addresses and cluster members returned by
+// clients, the leaders that nodes redirect to, and the cluster configuration
+// saved in the node store, see WithUpdateStore. The addresses in the node
+// store must be the translated ones.
+func WithAddressTranslator(translate AddressTranslator) Option {
+	return func(options *options) {
+		options.Translate = translate
+	}
+}
+
 // New creates a new client connected to the dqlite node with the given
 // address.
+//
+// Once the handshake is done, the node is asked who the leader is, to check
+// that it speaks the protocol. Failures can be matched against
+// ErrConnectionRefused, ErrTLSHandshake and ErrProtocolMismatch.
 func New(ctx context.Context, address string, options ...Option) (*Client, error) {
 	o := defaultOptions()
 
 	for _, option := range options {
 		option(o)
 	}
+
+	dialCtx := ctx
+	if o.DialTimeout != 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, o.DialTimeout)
+		defer cancel()
+	}
+
 	// Establish the connection.
-	conn, err := o.DialFunc(ctx, address)
+	o.LogFunc(LogDebug, "dial %s", address)
+	conn, err := o.dialFunc()(dialCtx, address)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to establish network connection")
+		o.LogFunc(LogWarn, "dial %s: %v", address, err)
+		return nil, classifyDial(errors.Wrap(err, "failed to establish network connection"))
 	}
 
-	protocol, err := protocol.Handshake(ctx, conn, protocol.VersionOne)
+	handshakeCtx := ctx
+	if o.HandshakeTimeout != 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, o.HandshakeTimeout)
+		defer cancel()
+	}
+
+	protocol, err := protocol.Handshake(handshakeCtx, conn, protocol.VersionOne)
 	if err != nil {
 		conn.Close()
-		return nil, err
+		return nil, classifyHandshake(err)
 	}
-
-	client := &Client{protocol: protocol}
-
-	return client, nil
+	if err := protocol.Authenticate(handshakeCtx, o.Auth); err != nil {
+		protocol.Close()
+		return nil, classifyHandshake(err)
+	}
+	if err := probeHandshake(handshakeCtx, protocol); err != nil {
+		protocol.Close()
+		return nil, classifyHandshake(err)
+	}
+	protocol.SetLogFunc(o.LogFunc)
+	protocol.SetInterceptor(o.Interceptor)
+	protocol.SetTimeouts(o.ReadTimeout, o.WriteTimeout)
+	protocol.SetCallTimeouts(o.CallTimeouts)
+	protocol.SetCallStats(o.CallStats)
+	protocol.SetAdaptiveTimeout(o.AdaptiveTimeout)
+	protocol.SetLimits(o.Limits)
+	protocol.SetStrictDecoding(o.StrictDecoding)
+	protocol.SetKeepAlive(o.KeepAlive)
+
+	return o.newClient(ctx, protocol)
 }
 
 // Leader returns information about the current leader, if any.
 func (c *Client) Leader(ctx context.Context) (*NodeInfo, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(16)
 	response := protocol.Message{}
@@ -82,13 +485,32 @@ func (c *Client) Leader(ctx context.Context) (*NodeInfo, error) {
 		return nil, errors.Wrap(err, "failed to parse Node response")
 	}
 
-	info := &NodeInfo{ID: id, Address: address}
+	info := &NodeInfo{ID: id, Address: c.translate.Translate(address)}
 
 	return info, nil
 }
 
+// Ping checks that the node is responsive by sending it a cheap request, and
+// returns the time it took to get a response.
+//
+// Driver connections can be validated by pinging the client returned by
+// driver.Conn.Client.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if _, err := c.Leader(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
 // Cluster returns information about all nodes in the cluster.
 func (c *Client) Cluster(ctx context.Context) ([]NodeInfo, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(16)
 	response := protocol.Message{}
@@ -104,6 +526,9 @@ func (c *Client) Cluster(ctx context.Context) ([]NodeInfo, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse Node response")
 	}
+	for i := range servers {
+		servers[i].Address = c.translate.Translate(servers[i].Address)
+	}
 
 	return servers, nil
 }
@@ -119,6 +544,12 @@ type File struct {
 // the database), the second is the WAL file (which has the same name as the
 // database plus the suffix "-wal").
 func (c *Client) Dump(ctx context.Context, dbname string) ([]File, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(16)
 	response := protocol.Message{}
@@ -145,6 +576,9 @@ func (c *Client) Dump(ctx context.Context, dbname string) ([]File, error) {
 		}
 		dump = append(dump, File{Name: name, Data: data})
 	}
+	if err := files.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to parse files response")
+	}
 
 	return dump, nil
 }
@@ -155,6 +589,12 @@ func (c *Client) Dump(ctx context.Context, dbname string) ([]File, error) {
 // desired role is Voter, the node being added must be online, since it will be
 // granted voting rights only once it catches up with the leader's log.
 func (c *Client) Add(ctx context.Context, node NodeInfo) error {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	response := protocol.Message{}
 
@@ -177,7 +617,7 @@ func (c *Client) Add(ctx context.Context, node NodeInfo) error {
 		return nil
 	}
 
-	return c.Assign(ctx, node.ID, node.Role)
+	return c.assign(ctx, node.ID, node.Role)
 }
 
 // Assign a role to a node.
@@ -191,6 +631,17 @@ func (c *Client) Add(ctx context.Context, node NodeInfo) error {
 // If the target node does not exist or has already the desired role, an error
 // is returned.
 func (c *Client) Assign(ctx context.Context, id uint64, role NodeRole) error {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return c.assign(ctx, id, role)
+}
+
+// Assign a role to a node, as part of a request already registered.
+func (c *Client) assign(ctx context.Context, id uint64, role NodeRole) error {
 	request := protocol.Message{}
 	response := protocol.Message{}
 
@@ -214,6 +665,12 @@ func (c *Client) Assign(ctx context.Context, id uint64, role NodeRole) error {
 //
 // This must be invoked one client connected to the current leader.
 func (c *Client) Transfer(ctx context.Context, id uint64) error {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	response := protocol.Message{}
 
@@ -235,6 +692,12 @@ func (c *Client) Transfer(ctx context.Context, id uint64) error {
 
 // Remove a node from the cluster.
 func (c *Client) Remove(ctx context.Context, id uint64) error {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(4096)
 	response := protocol.Message{}
@@ -261,6 +724,12 @@ type NodeMetadata struct {
 
 // Describe returns metadata about the node we're connected with.
 func (c *Client) Describe(ctx context.Context) (*NodeMetadata, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(4096)
 	response := protocol.Message{}
@@ -287,6 +756,12 @@ func (c *Client) Describe(ctx context.Context) (*NodeMetadata, error) {
 
 // Weight updates the weight associated to the node we're connected with.
 func (c *Client) Weight(ctx context.Context, weight uint64) error {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer done()
+
 	request := protocol.Message{}
 	request.Init(4096)
 	response := protocol.Message{}
@@ -305,15 +780,63 @@ func (c *Client) Weight(ctx context.Context, weight uint64) error {
 	return nil
 }
 
-// Close the client.
+// Close the client, interrupting any request in flight, see Shutdown for a
+// graceful alternative. The statements queued with ExecAsync are sent first,
+// so they are not lost. Closing a client more than once has no effect.
+//
+// Clients sharing the connection of a driver connection don't close it.
 func (c *Client) Close() error {
+	c.execMu.Lock()
+	c.flushExec(context.Background())
+	c.execMu.Unlock()
+
+	return c.close()
+}
+
+// Close the connection without sending the queued statements.
+func (c *Client) close() error {
+	if c.borrowed {
+		return nil
+	}
+
+	c.lifeMu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.lifeMu.Unlock()
+
+	if closed {
+		return nil
+	}
 	return c.protocol.Close()
 }
 
+func init() {
+	clientbridge.NewClient = func(protocol *protocol.Protocol) interface{} {
+		return newWithProtocol(protocol)
+	}
+}
+
+// Return a client sending requests through the given protocol object, which
+// stays owned by the caller and is not closed by the client. It's used by the
+// driver package to expose the network connection of its connections, see
+// clientbridge.NewClient.
+func newWithProtocol(protocol *protocol.Protocol) *Client {
+	return &Client{protocol: protocol, borrowed: true, execWindow: DefaultExecWindow}
+}
+
+// Return the dial function to use, wrapped with TLS if enabled.
+func (o *options) dialFunc() DialFunc {
+	if o.TLSConfig != nil {
+		return DialFuncWithTLS(o.DialFunc, o.TLSConfig)
+	}
+	return o.DialFunc
+}
+
 // Create a client options object with sane defaults.
 func defaultOptions() *options {
 	return &options{
-		DialFunc: DefaultDialFunc,
-		LogFunc:  DefaultLogFunc,
+		DialFunc:   DefaultDialFunc,
+		LogFunc:    DefaultLogFunc,
+		ExecWindow: DefaultExecWindow,
 	}
 }
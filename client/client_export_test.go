@@ -7,3 +7,9 @@ import (
 func (c *Client) Protocol() *protocol.Protocol {
 	return c.protocol
 }
+
+func (c *Client) ShuttingDown() bool {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+	return c.idle != nil
+}
@@ -2,6 +2,8 @@ package client_test
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -10,6 +12,8 @@ import (
 
 	dqlite "github.com/canonical/go-dqlite"
 	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/canonical/go-dqlite/internal/logging"
 	"github.com/canonical/go-dqlite/internal/protocol"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -225,3 +229,167 @@ func newDir(t *testing.T) (string, func()) {
 
 	return dir, cleanup
 }
+
+func TestClient_AuthToken(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.RequireToken("secret")
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithAuthToken("secret"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Leader(ctx)
+	require.NoError(t, err)
+
+	_, err = client.New(ctx, server.Address(), client.WithAuthToken("wrong"))
+	require.Error(t, err)
+	_, ok := err.(client.ErrAuthentication)
+	assert.True(t, ok)
+}
+
+// Ping fails once the node is gone.
+func TestClient_Ping(t *testing.T) {
+	server := clienttest.NewServer(1)
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	latency, err := cli.Ping(ctx)
+	require.NoError(t, err)
+	assert.True(t, latency > 0)
+
+	server.Close()
+
+	_, err = cli.Ping(ctx)
+	assert.Error(t, err)
+}
+
+// The log function receives the events of the connection.
+func TestClient_LogFunc(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	messages := []string{}
+	log := func(l client.LogLevel, format string, a ...interface{}) {
+		messages = append(messages, fmt.Sprintf(format, a...))
+	}
+
+	cli, err := client.New(context.Background(), server.Address(), client.WithLogFunc(log))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	assert.Equal(t, []string{"dial " + server.Address()}, messages)
+}
+
+// The interceptor wraps each request, and can retry it.
+func TestClient_Interceptor(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	requests := []string{}
+	interceptor := func(ctx context.Context, request string, invoke func(ctx context.Context) error) error {
+		requests = append(requests, request)
+		if err := invoke(ctx); err != nil {
+			return err
+		}
+		return invoke(ctx)
+	}
+
+	cli, err := client.New(context.Background(), server.Address(), client.WithInterceptor(interceptor))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Leader(context.Background())
+	require.NoError(t, err)
+	_, err = cli.Cluster(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"leader", "cluster"}, requests)
+}
+
+func TestClient_CallStats(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns:  []string{"n"},
+			Values:   [][]driver.Value{{int64(1)}, {int64(2)}},
+			PageSize: 1,
+		}, nil
+	})
+
+	calls := []client.CallStats{}
+	stats := func(call client.CallStats) {
+		calls = append(calls, call)
+	}
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address(),
+		client.WithDatabase("test.db"), client.WithCallStats(stats))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	calls = nil
+	_, err = cli.Leader(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, calls, 1)
+	assert.Equal(t, uint8(0), calls[0].Type)
+	assert.Equal(t, "leader", calls[0].Request)
+	assert.Equal(t, 1, calls[0].Requests)
+	assert.Equal(t, 16, calls[0].BytesSent)
+	assert.Equal(t, 16+len(server.Address())/8*8+8, calls[0].BytesReceived)
+	assert.True(t, calls[0].Duration > 0)
+	assert.NoError(t, calls[0].Err)
+
+	// The rows of the second page are read with More.
+	calls = nil
+	_, rows, err := cli.Query(ctx, "SELECT n")
+	require.NoError(t, err)
+	assert.Len(t, rows, 2)
+
+	requests := []string{}
+	for _, call := range calls {
+		requests = append(requests, fmt.Sprintf("%s/%d", call.Request, call.Requests))
+	}
+	assert.Equal(t, []string{"query-sql/1", "query/0"}, requests)
+}
+
+func TestClient_Limits(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.SetLeader(&client.NodeInfo{ID: 1, Address: server.Address()})
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address(), client.WithLimits(client.Limits{StringLength: 4}))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Leader(ctx)
+	assert.True(t, errors.Is(err, client.ErrMalformedMessage), err)
+}
+
+// FindLeader gives up right away if authentication fails.
+func TestFindLeader_AuthFailure(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.RequireToken("secret")
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{Address: server.Address()}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.FindLeader(ctx, store, client.WithAuthToken("wrong"), client.WithLogFunc(logging.Test(t)))
+	require.Error(t, err)
+	_, ok := err.(client.ErrAuthentication)
+	assert.True(t, ok)
+	assert.NoError(t, ctx.Err())
+}
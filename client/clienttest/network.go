@@ -0,0 +1,121 @@
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/canonical/go-dqlite/client"
+)
+
+// Network is an in-memory network, whose connections are backed by
+// net.Pipe. It can be used to exercise the full client stack
+// deterministically, without binding TCP ports or unix sockets.
+type Network struct {
+	mu        sync.Mutex
+	listeners map[string]*pipeListener
+}
+
+// NewNetwork creates a new in-memory network.
+func NewNetwork() *Network {
+	return &Network{listeners: map[string]*pipeListener{}}
+}
+
+// Listen returns a listener accepting the connections dialed to the given
+// address.
+func (n *Network) Listen(address string) (net.Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.listeners[address]; ok {
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: pipeAddr(address), Err: syscall.EADDRINUSE}
+	}
+
+	listener := &pipeListener{
+		network: n,
+		address: address,
+		conns:   make(chan net.Conn),
+		done:    make(chan struct{}),
+	}
+	n.listeners[address] = listener
+
+	return listener, nil
+}
+
+// NewServer starts a new server with the given ID, listening to the given
+// address of this network.
+func (n *Network) NewServer(id uint64, address string) *Server {
+	listener, err := n.Listen(address)
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: listen: %v", err))
+	}
+	return NewServerWithListener(id, listener)
+}
+
+// DialFunc returns a dial function connecting to the listeners of this
+// network. Dialing an address with no listener fails with a connection
+// refused error.
+func (n *Network) DialFunc() client.DialFunc {
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		n.mu.Lock()
+		listener, ok := n.listeners[address]
+		n.mu.Unlock()
+
+		refused := &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(address), Err: syscall.ECONNREFUSED}
+		if !ok {
+			return nil, refused
+		}
+
+		local, remote := net.Pipe()
+		select {
+		case listener.conns <- remote:
+			return local, nil
+		case <-listener.done:
+			local.Close()
+			return nil, refused
+		case <-ctx.Done():
+			local.Close()
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(address), Err: ctx.Err()}
+		}
+	}
+}
+
+var errListenerClosed = fmt.Errorf("listener closed")
+
+type pipeListener struct {
+	network *Network
+	address string
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.Addr(), Err: errListenerClosed}
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+		l.network.mu.Lock()
+		delete(l.network.listeners, l.address)
+		l.network.mu.Unlock()
+	})
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.address)
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
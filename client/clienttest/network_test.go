@@ -0,0 +1,68 @@
+package clienttest_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetwork_Client(t *testing.T) {
+	network := clienttest.NewNetwork()
+	server := network.NewServer(1, "node1")
+	defer server.Close()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, "node1", client.WithDialFunc(network.DialFunc()))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "node1", leader.Address)
+}
+
+func TestNetwork_FindLeader(t *testing.T) {
+	network := clienttest.NewNetwork()
+	server1 := network.NewServer(1, "node1")
+	defer server1.Close()
+	server2 := network.NewServer(2, "node2")
+	defer server2.Close()
+
+	server1.SetLeader(&client.NodeInfo{ID: 2, Address: "node2"})
+	server2.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{}, nil
+	})
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{Address: "node1"}, {Address: "node2"}})
+
+	cli, err := client.FindLeader(context.Background(), store, client.WithDialFunc(network.DialFunc()))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), leader.ID)
+}
+
+func TestNetwork_DialRefused(t *testing.T) {
+	network := clienttest.NewNetwork()
+
+	_, err := network.DialFunc()(context.Background(), "node1")
+	require.Error(t, err)
+	assert.Equal(t, syscall.ECONNREFUSED, err.(*net.OpError).Err)
+
+	server := network.NewServer(1, "node1")
+	server.Close()
+
+	_, err = network.DialFunc()(context.Background(), "node1")
+	require.Error(t, err)
+}
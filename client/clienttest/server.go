@@ -0,0 +1,491 @@
+// Package clienttest provides a scriptable in-process dqlite server, for
+// testing applications without running real dqlite nodes.
+//
+// The server speaks enough of the wire protocol for the client and driver
+// packages to connect to it, find the leader, open databases, execute
+// statements and run queries. The results of statements and queries are
+// provided by user-defined handlers, which can also return errors or drop the
+// connection to exercise failover and error paths.
+//
+// Servers can listen either on the loopback interface or on an in-memory
+// Network, whose DialFunc can be passed to the client and driver packages.
+package clienttest
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Result is the outcome of a statement executed by an ExecFunc.
+type Result struct {
+	LastInsertID uint64
+	RowsAffected uint64
+}
+
+// Rows is a result set returned by a QueryFunc.
+type Rows struct {
+	Columns []string
+	Values  [][]driver.Value
+
+	// PageSize is the number of rows sent in each response, as the dqlite
+	// server does with large result sets. If zero, all rows are sent in a
+	// single response.
+	PageSize int
+}
+
+// ExecFunc handles the execution of a statement that doesn't return rows.
+type ExecFunc func(database string, sql string, args []driver.Value) (Result, error)
+
+// QueryFunc handles the execution of a query.
+type QueryFunc func(database string, sql string, args []driver.Value) (*Rows, error)
+
+// DumpFunc handles a request to dump the files of a database.
+type DumpFunc func(database string) ([]client.File, error)
+
+// Error is a failure response sent back to the client. If a handler returns
+// an error of a different type, a generic SQLITE_ERROR failure is sent.
+type Error struct {
+	Code    uint64
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// ErrNotLeader is the failure returned by a node that is not the leader, or
+// that lost leadership while executing a statement.
+var ErrNotLeader = Error{Code: 10 | 40<<8, Message: "not leader"}
+
+// ErrDisconnect can be returned by a handler to make the server abruptly
+// close the connection instead of replying.
+var ErrDisconnect = fmt.Errorf("disconnect")
+
+// Server is a scriptable in-process dqlite server.
+type Server struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	leader  *client.NodeInfo
+	cluster []client.NodeInfo
+	exec    ExecFunc
+	query   QueryFunc
+	dump    DumpFunc
+	token   *string
+	weight  uint64
+	conns   map[net.Conn]struct{}
+	closed  bool
+	serving sync.WaitGroup
+}
+
+// NewServer starts a new server with the given node ID, listening on a
+// random TCP port on the loopback interface.
+//
+// Initially the server considers itself the leader of a cluster made of just
+// itself, and it fails all statements and queries.
+func NewServer(id uint64) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("clienttest: listen: %v", err))
+	}
+	return NewServerWithListener(id, listener)
+}
+
+// NewServerWithListener starts a new server with the given node ID, accepting
+// connections from the given listener.
+func NewServerWithListener(id uint64, listener net.Listener) *Server {
+	s := &Server{
+		listener: listener,
+		conns:    map[net.Conn]struct{}{},
+	}
+
+	info := client.NodeInfo{ID: id, Address: s.Address(), Role: client.Voter}
+	s.leader = &info
+	s.cluster = []client.NodeInfo{info}
+
+	s.serving.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Weight returns the weight of the node, as last set by a client.
+func (s *Server) Weight() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.weight
+}
+
+// Address returns the address the server is listening to.
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// SetLeader sets the node that the server reports as current leader. Pass nil
+// to simulate a cluster with no leader.
+func (s *Server) SetLeader(leader *client.NodeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if leader != nil {
+		info := *leader
+		leader = &info
+	}
+	s.leader = leader
+}
+
+// SetCluster sets the nodes that the server reports as cluster members.
+func (s *Server) SetCluster(nodes []client.NodeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cluster = append([]client.NodeInfo{}, nodes...)
+}
+
+// HandleExec sets the function handling statements.
+func (s *Server) HandleExec(f ExecFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exec = f
+}
+
+// HandleQuery sets the function handling queries.
+func (s *Server) HandleQuery(f QueryFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.query = f
+}
+
+// HandleDump sets the function handling dump requests.
+func (s *Server) HandleDump(f DumpFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dump = f
+}
+
+// RequireToken makes the server behave like an authenticating proxy, which
+// expects clients to send the given token right after the protocol version,
+// as done by client.WithAuthToken.
+func (s *Server) RequireToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = &token
+}
+
+// Disconnect abruptly closes all connections currently open, without
+// stopping the server.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and closes all its connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	err := s.listener.Close()
+	s.Disconnect()
+	s.serving.Wait()
+
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.serving.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.serving.Add(1)
+		go func() {
+			defer s.serving.Done()
+			s.handle(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// State of a single client connection.
+type session struct {
+	databases  []string
+	statements map[uint32]string
+	next       uint32
+}
+
+func (s *Server) handle(conn net.Conn) {
+	handshake := make([]byte, 8)
+	if _, err := io.ReadFull(conn, handshake); err != nil {
+		return
+	}
+	if binary.LittleEndian.Uint64(handshake) != protocol.VersionOne {
+		return
+	}
+
+	s.mu.Lock()
+	token := s.token
+	s.mu.Unlock()
+
+	if token != nil && !s.authenticate(conn, *token) {
+		return
+	}
+
+	session := &session{statements: map[uint32]string{}}
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, int(binary.LittleEndian.Uint32(header))*8)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		mtype, response, err := s.dispatch(session, header[4], &decoder{body: body, schema: header[5]})
+		if err == ErrDisconnect {
+			return
+		}
+		if err != nil {
+			failure, ok := err.(Error)
+			if !ok {
+				failure = Error{Code: 1, Message: err.Error()}
+			}
+			response = &encoder{}
+			response.uint64(failure.Code)
+			response.string(failure.Message)
+			mtype = protocol.ResponseFailure
+		}
+
+		for _, response := range append([]*encoder{response}, response.more...) {
+			message := make([]byte, 8, 8+len(response.body))
+			binary.LittleEndian.PutUint32(message, uint32(len(response.body)/8))
+			message[4] = mtype
+			message = append(message, response.body...)
+
+			if _, err := conn.Write(message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Check the token sent by the client, replying with the outcome.
+func (s *Server) authenticate(conn net.Conn, token string) bool {
+	word := make([]byte, 8)
+	if _, err := io.ReadFull(conn, word); err != nil {
+		return false
+	}
+	n := binary.LittleEndian.Uint64(word)
+	if n > 4096 {
+		return false
+	}
+	data := make([]byte, (n+7)/8*8)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return false
+	}
+
+	ok := string(data[:n]) == token
+	status := uint64(0)
+	if !ok {
+		status = 1
+	}
+	binary.LittleEndian.PutUint64(word, status)
+	if _, err := conn.Write(word); err != nil {
+		return false
+	}
+
+	return ok
+}
+
+// Handle a single request, returning the type and body of its response.
+func (s *Server) dispatch(session *session, mtype uint8, request *decoder) (uint8, *encoder, error) {
+	s.mu.Lock()
+	leader := s.leader
+	cluster := s.cluster
+	exec := s.exec
+	query := s.query
+	dump := s.dump
+	s.mu.Unlock()
+
+	response := &encoder{}
+
+	switch mtype {
+	case protocol.RequestLeader:
+		if leader == nil {
+			response.uint64(0)
+			response.string("")
+		} else {
+			response.uint64(leader.ID)
+			response.string(leader.Address)
+		}
+		return protocol.ResponseNode, response, nil
+	case protocol.RequestClient:
+		response.uint64(15000)
+		return protocol.ResponseWelcome, response, nil
+	case protocol.RequestCluster:
+		response.uint64(uint64(len(cluster)))
+		for _, node := range cluster {
+			response.uint64(node.ID)
+			response.string(node.Address)
+			response.uint64(uint64(node.Role))
+		}
+		return protocol.ResponseNodes, response, nil
+	case protocol.RequestOpen:
+		name := request.string()
+		session.databases = append(session.databases, name)
+		response.uint32(uint32(len(session.databases) - 1))
+		response.uint32(0)
+		return protocol.ResponseDb, response, request.err
+	case protocol.RequestPrepare:
+		db := uint32(request.uint64())
+		sql := request.string()
+		if _, err := session.database(db); err != nil {
+			return 0, nil, err
+		}
+		id := session.next
+		session.next++
+		session.statements[id] = sql
+		response.uint32(db)
+		response.uint32(id)
+		response.uint64(uint64(strings.Count(sql, "?")))
+		return protocol.ResponseStmt, response, request.err
+	case protocol.RequestFinalize:
+		request.uint32()
+		delete(session.statements, request.uint32())
+		response.uint64(0)
+		return protocol.ResponseEmpty, response, request.err
+	case protocol.RequestExec, protocol.RequestQuery:
+		db := request.uint32()
+		sql, ok := session.statements[request.uint32()]
+		if !ok {
+			return 0, nil, Error{Code: 1, Message: "no stmt with the given ID"}
+		}
+		return s.run(session, mtype == protocol.RequestQuery, exec, query, db, sql, request)
+	case protocol.RequestExecSQL, protocol.RequestQuerySQL:
+		db := uint32(request.uint64())
+		sql := request.string()
+		return s.run(session, mtype == protocol.RequestQuerySQL, exec, query, db, sql, request)
+	case protocol.RequestDump:
+		name := request.string()
+		if request.err != nil {
+			return 0, nil, request.err
+		}
+		if dump == nil {
+			return 0, nil, Error{Code: 1, Message: "no dump handler"}
+		}
+		files, err := dump(name)
+		if err != nil {
+			return 0, nil, err
+		}
+		response.uint64(uint64(len(files)))
+		for _, file := range files {
+			response.string(file.Name)
+			response.blob(file.Data)
+		}
+		return protocol.ResponseFiles, response, nil
+	case protocol.RequestInterrupt:
+		response.uint64(0)
+		return protocol.ResponseEmpty, response, nil
+	case protocol.RequestTransfer:
+		request.uint64()
+		response.uint64(0)
+		return protocol.ResponseEmpty, response, request.err
+	case protocol.RequestDescribe:
+		request.uint64()
+		response.uint64(0)
+		response.uint64(s.Weight())
+		return protocol.ResponseMetadata, response, request.err
+	case protocol.RequestWeight:
+		weight := request.uint64()
+		s.mu.Lock()
+		s.weight = weight
+		s.mu.Unlock()
+		response.uint64(0)
+		return protocol.ResponseEmpty, response, request.err
+	}
+
+	return 0, nil, Error{Code: 1, Message: "unrecognized request type"}
+}
+
+// Run a statement or a query through the configured handlers.
+func (s *Server) run(session *session, isQuery bool, exec ExecFunc, query QueryFunc, db uint32, sql string, request *decoder) (uint8, *encoder, error) {
+	database, err := session.database(db)
+	if err != nil {
+		return 0, nil, err
+	}
+	args := request.values()
+	if request.err != nil {
+		return 0, nil, request.err
+	}
+
+	response := &encoder{}
+
+	if !isQuery {
+		if exec == nil {
+			return 0, nil, Error{Code: 1, Message: "no exec handler"}
+		}
+		result, err := exec(database, sql, args)
+		if err != nil {
+			return 0, nil, err
+		}
+		response.uint64(result.LastInsertID)
+		response.uint64(result.RowsAffected)
+		return protocol.ResponseResult, response, nil
+	}
+
+	if query == nil {
+		return 0, nil, Error{Code: 1, Message: "no query handler"}
+	}
+	rows, err := query(database, sql, args)
+	if err != nil {
+		return 0, nil, err
+	}
+	if rows == nil {
+		rows = &Rows{}
+	}
+	for _, row := range rows.Values {
+		for i, value := range row {
+			if row[i], err = driver.DefaultParameterConverter.ConvertValue(value); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+	if err := response.rows(rows); err != nil {
+		return 0, nil, err
+	}
+	return protocol.ResponseRows, response, nil
+}
+
+// Return the name of the database with the given ID.
+func (s *session) database(id uint32) (string, error) {
+	if int(id) >= len(s.databases) {
+		return "", Error{Code: 1, Message: "no db with the given ID"}
+	}
+	return s.databases[id], nil
+}
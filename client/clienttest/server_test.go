@@ -0,0 +1,145 @@
+package clienttest_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlite "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Client(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), leader.ID)
+	assert.Equal(t, server.Address(), leader.Address)
+
+	nodes := []client.NodeInfo{
+		{ID: 1, Address: server.Address(), Role: client.Voter},
+		{ID: 2, Address: "1.2.3.4:666", Role: client.Spare},
+	}
+	server.SetCluster(nodes)
+
+	cluster, err := cli.Cluster(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, nodes, cluster)
+}
+
+func TestServer_ExecAndQuery(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		assert.Equal(t, "test.db", database)
+		assert.Equal(t, "INSERT INTO test VALUES(?, ?)", sql)
+		assert.Equal(t, []driver.Value{int64(1), "hello"}, args)
+		return clienttest.Result{LastInsertID: 1, RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns: []string{"n", "s"},
+			Values:  [][]driver.Value{{1, "hello"}, {2, nil}},
+		}, nil
+	})
+
+	db := openDB(t, server.Address())
+	defer db.Close()
+
+	result, err := db.Exec("INSERT INTO test VALUES(?, ?)", 1, "hello")
+	require.NoError(t, err)
+	id, err := result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	rows, err := db.Query("SELECT n, s FROM test")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	values := []sql.NullString{}
+	for rows.Next() {
+		var n int64
+		var s sql.NullString
+		require.NoError(t, rows.Scan(&n, &s))
+		values = append(values, s)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []sql.NullString{{String: "hello", Valid: true}, {}}, values)
+}
+
+func TestServer_Error(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{}, clienttest.Error{Code: 19, Message: "constraint failed"}
+	})
+
+	db := openDB(t, server.Address())
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO test VALUES(1)")
+	require.Error(t, err)
+	dqliteErr, ok := err.(dqlite.Error)
+	require.True(t, ok)
+	assert.Equal(t, 19, dqliteErr.Code)
+}
+
+// When the leader steps down the driver transparently reconnects to the new
+// leader.
+func TestServer_Failover(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	server2 := clienttest.NewServer(2)
+	defer server2.Close()
+
+	leader := client.NodeInfo{ID: 2, Address: server2.Address()}
+	server1.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		server1.SetLeader(&leader)
+		return clienttest.Result{}, clienttest.ErrNotLeader
+	})
+	server2.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	db := openDB(t, server1.Address(), server2.Address())
+	defer db.Close()
+
+	result, err := db.Exec("INSERT INTO test VALUES(1)")
+	require.NoError(t, err)
+	n, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func openDB(t *testing.T, addresses ...string) *sql.DB {
+	t.Helper()
+
+	infos := make([]client.NodeInfo, len(addresses))
+	for i, address := range addresses {
+		infos[i].Address = address
+	}
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), infos))
+
+	drv, err := dqlite.New(store, dqlite.WithLogFunc(func(client.LogLevel, string, ...interface{}) {}))
+	require.NoError(t, err)
+
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+
+	return sql.OpenDB(connector)
+}
@@ -0,0 +1,264 @@
+package clienttest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Decoder for the body of a request message.
+type decoder struct {
+	body   []byte
+	schema uint8 // Schema version of the request, from its header.
+	offset int
+	err    error
+}
+
+func (d *decoder) consume(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.body)-d.offset {
+		d.err = fmt.Errorf("truncated request")
+		return nil
+	}
+	b := d.body[d.offset : d.offset+n]
+	d.offset += n
+	return b
+}
+
+func (d *decoder) align() {
+	if trailing := d.offset % 8; trailing != 0 {
+		d.consume(8 - trailing)
+	}
+}
+
+func (d *decoder) uint8() uint8 {
+	b := d.consume(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.consume(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.consume(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *decoder) string() string {
+	if d.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(d.body[d.offset:], 0)
+	if i == -1 {
+		d.err = fmt.Errorf("unterminated string")
+		return ""
+	}
+	s := string(d.body[d.offset : d.offset+i])
+	d.consume(i + 1)
+	d.align()
+	return s
+}
+
+func (d *decoder) blob() []byte {
+	n := int(d.uint64())
+	b := d.consume(n)
+	d.align()
+	return append([]byte{}, b...)
+}
+
+// Decode the parameters of a statement, if any.
+func (d *decoder) values() []driver.Value {
+	if d.offset >= len(d.body) {
+		return nil
+	}
+
+	var n int
+	if d.schema == 1 {
+		n = int(d.uint32())
+	} else {
+		n = int(d.uint8())
+	}
+	types := make([]uint8, n)
+	for i := range types {
+		types[i] = d.uint8()
+	}
+	d.align()
+
+	values := make([]driver.Value, n)
+	for i, t := range types {
+		switch t {
+		case protocol.Integer:
+			values[i] = int64(d.uint64())
+		case protocol.Float:
+			values[i] = math.Float64frombits(d.uint64())
+		case protocol.Boolean:
+			values[i] = d.uint64() != 0
+		case protocol.Blob:
+			values[i] = d.blob()
+		case protocol.Text:
+			values[i] = d.string()
+		case protocol.Null:
+			d.uint64()
+			values[i] = nil
+		case protocol.ISO8601:
+			values[i] = d.string()
+		case protocol.UnixTime:
+			values[i] = time.Unix(int64(d.uint64()), 0)
+		default:
+			d.err = fmt.Errorf("unsupported parameter type %d", t)
+			return nil
+		}
+	}
+
+	return values
+}
+
+// Encoder for the body of a response message.
+type encoder struct {
+	body []byte
+	more []*encoder // Further responses to send after this one.
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.body = append(e.body, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(e.body[len(e.body)-4:], v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.body = append(e.body, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(e.body[len(e.body)-8:], v)
+}
+
+func (e *encoder) pad() {
+	for len(e.body)%8 != 0 {
+		e.body = append(e.body, 0)
+	}
+}
+
+func (e *encoder) string(v string) {
+	e.body = append(e.body, v...)
+	e.body = append(e.body, 0)
+	e.pad()
+}
+
+func (e *encoder) blob(v []byte) {
+	e.uint64(uint64(len(v)))
+	e.body = append(e.body, v...)
+	e.pad()
+}
+
+// Encode a result set, terminated by the EOF marker. If a page size is set,
+// the rows are split in multiple responses, all but the last one terminated by
+// the partial result set marker.
+func (e *encoder) rows(rows *Rows) error {
+	values := rows.Values
+	part := e
+	for {
+		n := len(values)
+		if rows.PageSize > 0 && n > rows.PageSize {
+			n = rows.PageSize
+		}
+		if err := part.page(rows.Columns, values[:n]); err != nil {
+			return err
+		}
+		values = values[n:]
+
+		if len(values) == 0 {
+			part.uint64(math.MaxUint64)
+			return nil
+		}
+
+		// Marker of a partial result set.
+		part.uint64(0xeeeeeeeeeeeeeeee)
+		part = &encoder{}
+		e.more = append(e.more, part)
+	}
+}
+
+// Encode the columns and values of a single page of rows.
+func (e *encoder) page(columns []string, values [][]driver.Value) error {
+	e.uint64(uint64(len(columns)))
+	for _, column := range columns {
+		e.string(column)
+	}
+
+	for _, row := range values {
+		if len(row) != len(columns) {
+			return fmt.Errorf("row has %d values, expected %d", len(row), len(columns))
+		}
+
+		// Each column type takes 4 bits, padded to a word boundary.
+		header := make([]byte, (len(row)*4+63)/64*8)
+		for i, value := range row {
+			t, err := valueType(value)
+			if err != nil {
+				return err
+			}
+			header[i/2] |= t << (4 * uint(i%2))
+		}
+		e.body = append(e.body, header...)
+
+		for _, value := range row {
+			switch v := value.(type) {
+			case int64:
+				e.uint64(uint64(v))
+			case float64:
+				e.uint64(math.Float64bits(v))
+			case bool:
+				if v {
+					e.uint64(1)
+				} else {
+					e.uint64(0)
+				}
+			case []byte:
+				e.blob(v)
+			case string:
+				e.string(v)
+			case nil:
+				e.uint64(0)
+			case time.Time:
+				e.string(v.Format("2006-01-02 15:04:05.999999999-07:00"))
+			}
+		}
+	}
+
+	return nil
+}
+
+func valueType(value driver.Value) (uint8, error) {
+	switch value.(type) {
+	case int64:
+		return protocol.Integer, nil
+	case float64:
+		return protocol.Float, nil
+	case bool:
+		return protocol.Boolean, nil
+	case []byte:
+		return protocol.Blob, nil
+	case string:
+		return protocol.Text, nil
+	case nil:
+		return protocol.Null, nil
+	case time.Time:
+		return protocol.ISO8601, nil
+	}
+	return 0, fmt.Errorf("unsupported value type %T", value)
+}
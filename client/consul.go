@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
+)
+
+// ConsulConfig holds the parameters of a ConsulNodeStore.
+type ConsulConfig struct {
+	Address         string        // URL of the Consul HTTP API, by default "http://127.0.0.1:8500".
+	Service         string        // Name of the service registered by the dqlite nodes.
+	Tag             string        // Only consider instances with this tag, if set.
+	Datacenter      string        // Datacenter to query, if not the agent's one.
+	Token           string        // ACL token, if any.
+	TLSConfig       *tls.Config   // TLS configuration for "https" addresses.
+	RefreshInterval time.Duration // Maximum time between refreshes, by default 10 seconds.
+	AllowStale      bool          // Allow any Consul server to answer, not just the leader.
+}
+
+// ConsulNodeStore uses the healthy instances of a Consul service as the list
+// of dqlite nodes.
+//
+// Each instance passing its health checks becomes a node, whose address is the
+// service address and port, falling back to the Consul node address. The node
+// ID and role can be set with the "dqlite-id" and "dqlite-role" service
+// metadata keys ("voter", "stand-by" or "spare"), and the rest of the node
+// metadata with the "dqlite-weight", "dqlite-failure-domain" and
+// "dqlite-tls-server-name" keys.
+//
+// The list is refreshed in the background using blocking queries, so changes
+// are picked up quickly. If Consul becomes unreachable, the last known list
+// keeps being returned. Since the list is owned by Consul, Set is a no-op.
+type ConsulNodeStore struct {
+	config ConsulConfig
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	nodes []NodeInfo
+	index string // Consul index of the last refresh.
+
+	watchers protocol.NodeStoreWatchers
+}
+
+// NewConsulNodeStore creates a new ConsulNodeStore, reading the current list
+// of nodes right away. Call Close to stop refreshing it.
+func NewConsulNodeStore(config ConsulConfig) (*ConsulNodeStore, error) {
+	if config.Service == "" {
+		return nil, fmt.Errorf("no Consul service given")
+	}
+	if config.Address == "" {
+		config.Address = "http://127.0.0.1:8500"
+	}
+	if config.RefreshInterval == 0 {
+		config.RefreshInterval = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ConsulNodeStore{
+		config: config,
+		client: &http.Client{Transport: &http.Transport{TLSClientConfig: config.TLSConfig}},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if err := s.refresh(ctx, false); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.run(ctx)
+
+	return s, nil
+}
+
+// Get the current servers.
+func (s *ConsulNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]NodeInfo{}, s.nodes...), nil
+}
+
+// Set does nothing, since the list of nodes is managed by Consul.
+func (s *ConsulNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	return nil
+}
+
+// Watch returns a channel notified whenever the list of nodes changes.
+func (s *ConsulNodeStore) Watch(ctx context.Context) <-chan struct{} {
+	return s.watchers.Watch(ctx)
+}
+
+// Close stops refreshing the list of nodes.
+func (s *ConsulNodeStore) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Refresh the list of nodes until the context is done.
+func (s *ConsulNodeStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		if err := s.refresh(ctx, true); err != nil {
+			// Keep the last known list, and retry later.
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.config.RefreshInterval):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Fetch the healthy instances of the service. If blocking is true, wait for
+// changes since the last refresh, up to the refresh interval.
+func (s *ConsulNodeStore) refresh(ctx context.Context, blocking bool) error {
+	query := url.Values{"passing": []string{"1"}}
+	if s.config.Tag != "" {
+		query.Set("tag", s.config.Tag)
+	}
+	if s.config.Datacenter != "" {
+		query.Set("dc", s.config.Datacenter)
+	}
+	if s.config.AllowStale {
+		query.Set("stale", "")
+	}
+
+	s.mu.Lock()
+	index := s.index
+	s.mu.Unlock()
+
+	timeout := s.config.RefreshInterval
+	if blocking && index != "" {
+		query.Set("index", index)
+		query.Set("wait", fmt.Sprintf("%dms", s.config.RefreshInterval/time.Millisecond))
+		// Consul adds some jitter to the wait time.
+		timeout += timeout/16 + 5*time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	endpoint := strings.TrimRight(s.config.Address, "/") + "/v1/health/service/" + url.PathEscape(s.config.Service)
+	request, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	if s.config.Token != "" {
+		request.Header.Set("X-Consul-Token", s.config.Token)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "query Consul")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("query Consul: %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var entries []struct {
+		Node struct {
+			Address string
+		}
+		Service struct {
+			Address string
+			Port    int
+			Meta    map[string]string
+		}
+	}
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		return errors.Wrap(err, "decode Consul response")
+	}
+
+	nodes := make([]NodeInfo, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		node := NodeInfo{Address: net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))}
+		if id, ok := entry.Service.Meta["dqlite-id"]; ok {
+			if node.ID, err = strconv.ParseUint(id, 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid dqlite-id of %s", node.Address)
+			}
+		}
+		if weight, ok := entry.Service.Meta["dqlite-weight"]; ok {
+			if node.Weight, err = strconv.ParseUint(weight, 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid dqlite-weight of %s", node.Address)
+			}
+		}
+		if domain, ok := entry.Service.Meta["dqlite-failure-domain"]; ok {
+			if node.FailureDomain, err = strconv.ParseUint(domain, 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid dqlite-failure-domain of %s", node.Address)
+			}
+		}
+		if name, ok := entry.Service.Meta["dqlite-tls-server-name"]; ok {
+			node.TLS = &NodeTLS{ServerName: name}
+		}
+		switch entry.Service.Meta["dqlite-role"] {
+		case "", "voter":
+			node.Role = Voter
+		case "stand-by":
+			node.Role = StandBy
+		case "spare":
+			node.Role = Spare
+		default:
+			return fmt.Errorf("invalid dqlite-role of %s", node.Address)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Address < nodes[j].Address })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !reflect.DeepEqual(nodes, s.nodes) {
+		s.watchers.Notify()
+	}
+	s.nodes = nodes
+
+	// Reset the index if it's invalid or goes backwards, as recommended by
+	// Consul.
+	s.index = ""
+	if value := response.Header.Get("X-Consul-Index"); value != "" {
+		current, err := strconv.ParseUint(value, 10, 64)
+		previous, _ := strconv.ParseUint(index, 10, 64)
+		if err == nil && current > 0 && current >= previous {
+			s.index = value
+		}
+	}
+
+	return nil
+}
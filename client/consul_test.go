@@ -0,0 +1,113 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulNodeStore(t *testing.T) {
+	consul := &fakeConsul{
+		index: 1,
+		entries: `[
+  {"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 9001, "Meta": {"dqlite-id": "1"}}},
+  {"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.2", "Port": 9001, "Meta": {"dqlite-id": "2", "dqlite-role": "spare", "dqlite-weight": "3", "dqlite-failure-domain": "1", "dqlite-tls-server-name": "node2"}}}
+]`,
+	}
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	store, err := client.NewConsulNodeStore(client.ConsulConfig{
+		Address:         server.URL,
+		Service:         "dqlite",
+		Token:           "secret",
+		RefreshInterval: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	nodes, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{
+		{ID: 1, Address: "10.0.0.1:9001", Role: client.Voter},
+		{ID: 2, Address: "10.0.0.2:9001", Role: client.Spare, Weight: 3, FailureDomain: 1, TLS: &client.NodeTLS{ServerName: "node2"}},
+	}, nodes)
+
+	// Changes are picked up.
+	consul.set(`[{"Node": {"Address": "10.0.0.3"}, "Service": {"Port": 9001}}]`)
+
+	expected := []client.NodeInfo{{Address: "10.0.0.3:9001", Role: client.Voter}}
+	require.Eventually(t, func() bool {
+		nodes, err := store.Get(context.Background())
+		return err == nil && assert.ObjectsAreEqual(expected, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// If Consul fails, the last known nodes are returned.
+	consul.set("")
+	time.Sleep(100 * time.Millisecond)
+
+	nodes, err = store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, nodes)
+}
+
+func TestConsulNodeStore_Unavailable(t *testing.T) {
+	_, err := client.NewConsulNodeStore(client.ConsulConfig{
+		Address: "http://127.0.0.1:1",
+		Service: "dqlite",
+	})
+	assert.Error(t, err)
+}
+
+// Minimal implementation of the Consul health endpoint, supporting blocking
+// queries.
+type fakeConsul struct {
+	mu      sync.Mutex
+	index   int
+	entries string // Empty to fail requests.
+}
+
+func (c *fakeConsul) set(entries string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index++
+	c.entries = entries
+}
+
+func (c *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/health/service/dqlite" || r.URL.Query().Get("passing") == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+
+	index := r.URL.Query().Get("index")
+	wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+	deadline := time.Now().Add(wait)
+	for {
+		c.mu.Lock()
+		current, entries := c.index, c.entries
+		c.mu.Unlock()
+		if index != fmt.Sprint(current) || time.Now().After(deadline) {
+			if entries == "" {
+				http.Error(w, "No cluster leader", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-Consul-Index", fmt.Sprint(current))
+			w.Write([]byte(entries))
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -3,26 +3,149 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
 )
 
 // DefaultDialFunc is the default dial function, which can handle plain TCP and
 // Unix socket endpoints. You can customize it with WithDialFunc()
+//
+// Addresses are either "host:port" or "@name" for an abstract Unix socket, or
+// have an explicit scheme: "tcp://host:port", "unix:///path/to/socket",
+// "unix://@name", or "tls://host:port", which establishes a TLS session
+// verified against the system roots, unless a TLS configuration is given
+// with WithTLS or WithTLSConfig.
 func DefaultDialFunc(ctx context.Context, address string) (net.Conn, error) {
 	return protocol.Dial(ctx, address)
 }
 
+// Endpoint is a node address broken down into its parts. See ParseAddress.
+type Endpoint = protocol.Endpoint
+
+// ParseAddress parses a node address in any of the forms understood by
+// DefaultDialFunc.
+func ParseAddress(address string) (Endpoint, error) {
+	return protocol.ParseAddress(address)
+}
+
+// SocketOptions holds tuning parameters for TCP and Unix sockets. See
+// DialFuncWithSocketOptions.
+type SocketOptions = protocol.SocketOptions
+
+// DialFuncWithSocketOptions returns a dial function behaving like
+// DefaultDialFunc, but applying the given options to the sockets it creates.
+func DialFuncWithSocketOptions(options SocketOptions) DialFunc {
+	return options.Dial
+}
+
+// DialFuncWithResolver returns a dial function that resolves the host name of
+// the address with the given resolver (net.DefaultResolver if nil), and
+// establishes a connection to the first resolved IP address that accepts it,
+// using the given dial function.
+//
+// The name is resolved on every dial, like DefaultDialFunc does, so nodes
+// behind dynamic DNS are reached at their current address. The IP address
+// that most recently accepted a connection is tried first. To verify TLS
+// certificates against the host name, wrap the returned function with
+// DialFuncWithTLS, not the other way around.
+func DialFuncWithResolver(dial DialFunc, resolver *net.Resolver) DialFunc {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	var mu sync.Mutex
+	preferred := map[string]string{} // Last IP that accepted a connection, by host.
+
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		endpoint, err := ParseAddress(address)
+		if err != nil || endpoint.Network != "tcp" {
+			return dial(ctx, address)
+		}
+		host, port, err := net.SplitHostPort(endpoint.Address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, address)
+		}
+		scheme := address[:len(address)-len(endpoint.Address)]
+
+		ips, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve %s", host)
+		}
+
+		mu.Lock()
+		last := preferred[host]
+		mu.Unlock()
+		for i, ip := range ips {
+			if ip == last {
+				ips[0], ips[i] = ips[i], ips[0]
+				break
+			}
+		}
+
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dial(ctx, scheme+net.JoinHostPort(ip, port))
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			preferred[host] = ip
+			mu.Unlock()
+			return conn, nil
+		}
+
+		return nil, err
+	}
+}
+
+// DialFuncWithLocalNode returns a dial function that connects to the node
+// with the given cluster address through the given local address instead,
+// typically a unix socket the node also listens on, using DefaultDialFunc.
+// Connections to other nodes are established with the given dial function.
+//
+// This cuts latency in deployments where the application and a node share a
+// host. Since dqlite serves both reads and writes on the leader only, the local
+// connection is used whenever the local node is the leader.
+func DialFuncWithLocalNode(dial DialFunc, address, local string) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		if addr == address {
+			return DefaultDialFunc(ctx, local)
+		}
+		return dial(ctx, addr)
+	}
+}
+
 // DialFuncWithTLS returns a dial function that uses TLS encryption.
 //
 // The given dial function will be used to establish the network connection,
-// and the given TLS config will be used for encryption.
+// and the given TLS config will be used for encryption. If the config has no
+// ServerName, the host part of the node address is used. The config is
+// cloned on every dial, so callbacks like GetClientCertificate and
+// VerifyPeerCertificate are evaluated for each new connection.
+//
+// The TLS handshake is performed before returning, honoring the deadline of
+// the given context, so node certificate errors are reported right away. They
+// match ErrTLSHandshake.
 func DialFuncWithTLS(dial DialFunc, config *tls.Config) DialFunc {
 	return func(ctx context.Context, addr string) (net.Conn, error) {
+		endpoint, err := ParseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		if endpoint.TLS {
+			// The session is established here, not by the given
+			// dial function.
+			addr = endpoint.Address
+		}
 		clonedConfig := config.Clone()
 		if len(clonedConfig.ServerName) == 0 {
-			remoteIP, _, err := net.SplitHostPort(addr)
+			remoteIP, _, err := net.SplitHostPort(endpoint.Address)
 			if err != nil {
 				return nil, err
 			}
@@ -32,6 +155,85 @@ func DialFuncWithTLS(dial DialFunc, config *tls.Config) DialFunc {
 		if err != nil {
 			return nil, err
 		}
-		return tls.Client(conn, clonedConfig), nil
+		tlsConn := tls.Client(conn, clonedConfig)
+		if deadline, ok := ctx.Deadline(); ok {
+			tlsConn.SetDeadline(deadline)
+			defer tlsConn.SetDeadline(time.Time{})
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, connectError{kind: ErrTLSHandshake, err: errors.Wrap(err, "TLS handshake")}
+		}
+		return tlsConn, nil
+	}
+}
+
+// DialFuncWithNodeTLS returns a dial function that uses TLS encryption, like
+// DialFuncWithTLS, honoring the TLS parameters of each node in the given
+// store.
+//
+// If the node being dialed has TLS parameters, its server name and CA
+// certificates replace the ones of the given config.
+func DialFuncWithNodeTLS(dial DialFunc, store NodeStore, config *tls.Config) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		nodes, err := store.Get(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "get servers")
+		}
+
+		nodeConfig := config
+		for _, node := range nodes {
+			if node.Address != addr || node.TLS == nil {
+				continue
+			}
+			nodeConfig = config.Clone()
+			if node.TLS.ServerName != "" {
+				nodeConfig.ServerName = node.TLS.ServerName
+			}
+			if node.TLS.CACert != "" {
+				pool := x509.NewCertPool()
+				if !pool.AppendCertsFromPEM([]byte(node.TLS.CACert)) {
+					return nil, fmt.Errorf("invalid CA certificate for %s", addr)
+				}
+				nodeConfig.RootCAs = pool
+			}
+			break
+		}
+
+		return DialFuncWithTLS(dial, nodeConfig)(ctx, addr)
+	}
+}
+
+// Capture records the raw protocol data exchanged over network connections,
+// for debugging purposes. See DialFuncWithCapture.
+type Capture = protocol.Capture
+
+// CaptureFrame is a single protocol frame read back from a capture.
+type CaptureFrame = protocol.CaptureFrame
+
+// NewCapture returns a new capture writing to the given writer.
+func NewCapture(w io.Writer) *Capture {
+	return protocol.NewCapture(w)
+}
+
+// ReadCapture reads back the frames recorded by a capture. Use the String()
+// method of each frame to get a decoded description of it.
+func ReadCapture(r io.Reader) ([]CaptureFrame, error) {
+	return protocol.ReadCapture(r)
+}
+
+// DialFuncWithCapture returns a dial function that records all data
+// exchanged over the connections it establishes into the given capture.
+//
+// The given dial function will be used to establish the network connection.
+// When using TLS, pass the dial function returned by DialFuncWithTLS, so the
+// unencrypted data gets recorded.
+func DialFuncWithCapture(dial DialFunc, capture *Capture) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return capture.Conn(conn), nil
 	}
 }
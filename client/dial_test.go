@@ -0,0 +1,364 @@
+package client_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Socket options are applied to the dialed TCP connections.
+func TestDialFuncWithSocketOptions(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	options := client.SocketOptions{
+		Nagle:       true,
+		KeepAlive:   time.Minute,
+		ReadBuffer:  64 * 1024,
+		WriteBuffer: 64 * 1024,
+	}
+	dial := client.DialFuncWithSocketOptions(options)
+
+	conn, err := dial(context.Background(), server.Address())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	require.NoError(t, err)
+
+	getsockopt := func(level, opt int) int {
+		var value int
+		var err error
+		require.NoError(t, raw.Control(func(fd uintptr) {
+			value, err = syscall.GetsockoptInt(int(fd), level, opt)
+		}))
+		require.NoError(t, err)
+		return value
+	}
+
+	assert.Equal(t, 0, getsockopt(syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+	assert.Equal(t, 1, getsockopt(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+	assert.GreaterOrEqual(t, getsockopt(syscall.SOL_SOCKET, syscall.SO_RCVBUF), options.ReadBuffer)
+	assert.GreaterOrEqual(t, getsockopt(syscall.SOL_SOCKET, syscall.SO_SNDBUF), options.WriteBuffer)
+
+	cli, err := client.New(context.Background(), server.Address(), client.WithDialFunc(dial))
+	require.NoError(t, err)
+	defer cli.Close()
+}
+
+func TestWithTLS(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithTLS(&cert, pool))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, server.Address(), leader.Address)
+}
+
+// If the node requires mutual TLS, a client without a certificate can't talk
+// to it. With TLS 1.3 the client completes its side of the handshake before
+// the server verifies the client certificate, so the failure might only be
+// detected on the first request.
+func TestWithTLS_NoClientCertificate(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithTLS(nil, pool))
+	if err == nil {
+		_, err = cli.Leader(ctx)
+		cli.Close()
+	}
+	assert.Error(t, err)
+}
+
+// Start a clienttest server requiring mutual TLS.
+func newTLSServer(t *testing.T, cert tls.Certificate, pool *x509.CertPool) *clienttest.Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+
+	return clienttest.NewServerWithListener(1, tls.NewListener(listener, config))
+}
+
+func loadTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	cert, err := tls.LoadX509KeyPair("../app/testdata/cluster.crt", "../app/testdata/cluster.key")
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile("../app/testdata/cluster.crt")
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(data))
+
+	return cert, pool
+}
+
+// The TLS parameters of a node in the store override the ones of the config.
+func TestDialFuncWithNodeTLS(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	data, err := ioutil.ReadFile("../app/testdata/cluster.crt")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	store := client.NewInmemNodeStore()
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, ServerName: "wrong.test"}
+	dial := client.DialFuncWithNodeTLS(client.DefaultDialFunc, store, config)
+
+	// Without node parameters the certificate can't be verified.
+	_, err = dial(ctx, server.Address())
+	assert.Error(t, err)
+
+	store.Set(ctx, []client.NodeInfo{{
+		ID:      1,
+		Address: server.Address(),
+		TLS:     &client.NodeTLS{ServerName: "local.test", CACert: string(data)},
+	}})
+
+	cli, err := client.New(ctx, server.Address(), client.WithDialFunc(dial))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Leader(ctx)
+	require.NoError(t, err)
+}
+
+// The TLS config callbacks are evaluated on every dial.
+func TestWithTLSConfig_Callbacks(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	calls := 0
+	config := &tls.Config{
+		RootCAs: pool,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			calls++
+			return &cert, nil
+		},
+	}
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		cli, err := client.New(ctx, server.Address(), client.WithTLSConfig(config))
+		require.NoError(t, err)
+		_, err = cli.Leader(ctx)
+		require.NoError(t, err)
+		cli.Close()
+	}
+
+	assert.Equal(t, 2, calls)
+}
+
+// The certificate loader picks up a certificate file that changed.
+func TestCertificateLoader_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-client-tls-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cluster.crt")
+	keyFile := filepath.Join(dir, "cluster.key")
+	copyFile(t, "../app/testdata/cluster.crt", certFile)
+	copyFile(t, "../app/testdata/cluster.key", keyFile)
+
+	loader, err := client.NewCertificateLoader(certFile, keyFile)
+	require.NoError(t, err)
+
+	cert1, err := loader.Certificate()
+	require.NoError(t, err)
+
+	cert2, err := loader.Certificate()
+	require.NoError(t, err)
+	assert.True(t, cert1 == cert2)
+
+	// Simulate a rotation by bumping the modification time.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	cert3, err := loader.Certificate()
+	require.NoError(t, err)
+	assert.False(t, cert1 == cert3)
+
+	// A broken certificate doesn't replace the last good one.
+	require.NoError(t, ioutil.WriteFile(certFile, []byte("garbage"), 0600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	cert4, err := loader.Certificate()
+	require.NoError(t, err)
+	assert.True(t, cert3 == cert4)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, data, 0600))
+}
+
+// Host names are resolved on every dial, trying first the address that last
+// accepted a connection.
+func TestDialFuncWithResolver(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Address())
+	require.NoError(t, err)
+
+	dialed := []string{}
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return client.DefaultDialFunc(ctx, address)
+	}
+	dial = client.DialFuncWithResolver(dial, nil)
+
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		dialed = dialed[:0]
+		conn, err := dial(ctx, net.JoinHostPort("localhost", port))
+		require.NoError(t, err)
+		conn.Close()
+		assert.Equal(t, server.Address(), dialed[len(dialed)-1])
+	}
+
+	// The second dial went straight to the address that worked.
+	assert.Equal(t, []string{server.Address()}, dialed)
+}
+
+// Connections to the local node go through its local address.
+func TestDialFuncWithLocalNode(t *testing.T) {
+	local := clienttest.NewServer(1)
+	defer local.Close()
+
+	dialed := []string{}
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return client.DefaultDialFunc(ctx, address)
+	}
+	dial = client.DialFuncWithLocalNode(dial, "10.0.0.1:9001", local.Address())
+
+	ctx := context.Background()
+
+	conn, err := dial(ctx, "10.0.0.1:9001")
+	require.NoError(t, err)
+	conn.Close()
+	assert.Empty(t, dialed)
+
+	conn, err = dial(ctx, local.Address())
+	require.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, []string{local.Address()}, dialed)
+}
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		Address  string
+		Endpoint client.Endpoint
+	}{
+		{"127.0.0.1:9001", client.Endpoint{Network: "tcp", Address: "127.0.0.1:9001"}},
+		{"@dqlite", client.Endpoint{Network: "unix", Address: "@dqlite"}},
+		{"tcp://node1:9001", client.Endpoint{Network: "tcp", Address: "node1:9001"}},
+		{"tls://node1:9001", client.Endpoint{Network: "tcp", Address: "node1:9001", TLS: true}},
+		{"unix:///run/dqlite.sock", client.Endpoint{Network: "unix", Address: "/run/dqlite.sock"}},
+		{"unix://@dqlite", client.Endpoint{Network: "unix", Address: "@dqlite"}},
+	}
+	for _, c := range cases {
+		t.Run(c.Address, func(t *testing.T) {
+			endpoint, err := client.ParseAddress(c.Address)
+			require.NoError(t, err)
+			assert.Equal(t, c.Endpoint, endpoint)
+		})
+	}
+
+	_, err := client.ParseAddress("http://node1:9001")
+	assert.EqualError(t, err, `unsupported scheme in address "http://node1:9001"`)
+	_, err = client.ParseAddress("unix://")
+	assert.EqualError(t, err, `empty address "unix://"`)
+}
+
+// Addresses with explicit schemes are dialed by both New and the connector.
+func TestDefaultDialFunc_Schemes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-dial-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "node.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	unixServer := clienttest.NewServerWithListener(1, listener)
+	defer unixServer.Close()
+
+	tcpServer := clienttest.NewServer(2)
+	defer tcpServer.Close()
+
+	ctx := context.Background()
+	for _, address := range []string{"unix://" + path, "tcp://" + tcpServer.Address()} {
+		cli, err := client.New(ctx, address)
+		require.NoError(t, err, address)
+		_, err = cli.Leader(ctx)
+		assert.NoError(t, err, address)
+		cli.Close()
+	}
+
+	// The nodes report their bare address as the leader, which is matched
+	// with the address in the store.
+	for _, address := range []string{"unix://" + path, "tcp://" + tcpServer.Address()} {
+		store := client.NewInmemNodeStore()
+		require.NoError(t, store.Set(ctx, []client.NodeInfo{{Address: address}}))
+
+		connector := client.NewConnector(store)
+		cli, err := connector.Connect(ctx)
+		require.NoError(t, err, address)
+		assert.Equal(t, address, connector.Leader())
+		cli.Close()
+	}
+}
+
+// With a TLS configuration, the tls:// scheme is handled by the TLS dialer.
+func TestWithTLS_Scheme(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, "tls://"+server.Address(), client.WithTLS(&cert, pool))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Leader(ctx)
+	require.NoError(t, err)
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DNSConfig holds the parameters of a DNSNodeStore.
+type DNSConfig struct {
+	// Name to resolve. Names starting with an underscore, like
+	// "_dqlite._tcp.example.com", are resolved as SRV records, and other
+	// names as A/AAAA records.
+	Name string
+
+	// Port of the nodes, used with A/AAAA records.
+	Port int
+
+	// RefreshInterval is the minimum time between lookups, by default 30
+	// seconds.
+	RefreshInterval time.Duration
+
+	// Resolver to use, by default net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// DNSNodeStore resolves a DNS name to the list of dqlite nodes, for
+// environments that publish their servers via DNS.
+//
+// The name is resolved on Get, at most once per refresh interval. If the lookup
+// fails, the last successfully resolved list is returned. Since the list is
+// owned by DNS, Set is a no-op.
+type DNSNodeStore struct {
+	config DNSConfig
+
+	mu      sync.Mutex
+	nodes   []NodeInfo
+	updated time.Time
+}
+
+// NewDNSNodeStore creates a new DNSNodeStore.
+func NewDNSNodeStore(config DNSConfig) (*DNSNodeStore, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("no DNS name given")
+	}
+	if !strings.HasPrefix(config.Name, "_") && config.Port == 0 {
+		return nil, fmt.Errorf("no port given for A/AAAA records of %s", config.Name)
+	}
+	if config.RefreshInterval == 0 {
+		config.RefreshInterval = 30 * time.Second
+	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+
+	return &DNSNodeStore{config: config}, nil
+}
+
+// Get the current servers.
+func (s *DNSNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nodes != nil && time.Since(s.updated) < s.config.RefreshInterval {
+		return append([]NodeInfo{}, s.nodes...), nil
+	}
+
+	nodes, err := s.lookup(ctx)
+	if err != nil {
+		if s.nodes != nil {
+			return append([]NodeInfo{}, s.nodes...), nil
+		}
+		return nil, err
+	}
+
+	s.nodes = nodes
+	s.updated = time.Now()
+
+	return append([]NodeInfo{}, s.nodes...), nil
+}
+
+// Set does nothing, since the list of nodes is managed by DNS.
+func (s *DNSNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	return nil
+}
+
+// Resolve the configured name.
+func (s *DNSNodeStore) lookup(ctx context.Context) ([]NodeInfo, error) {
+	nodes := []NodeInfo{}
+
+	if strings.HasPrefix(s.config.Name, "_") {
+		_, records, err := s.config.Resolver.LookupSRV(ctx, "", "", s.config.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "lookup SRV records of %s", s.config.Name)
+		}
+		// Records are already sorted by priority and randomized by
+		// weight.
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			address := net.JoinHostPort(host, strconv.Itoa(int(record.Port)))
+			nodes = append(nodes, NodeInfo{Address: address})
+		}
+		return nodes, nil
+	}
+
+	addrs, err := s.config.Resolver.LookupIPAddr(ctx, s.config.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "lookup addresses of %s", s.config.Name)
+	}
+	for _, addr := range addrs {
+		address := net.JoinHostPort(addr.IP.String(), strconv.Itoa(s.config.Port))
+		nodes = append(nodes, NodeInfo{Address: address})
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,224 @@
+package client_test
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSNodeStore_SRV(t *testing.T) {
+	dns := newFakeDNS(t)
+	defer dns.Close()
+
+	dns.set("_dqlite._tcp.example.test.", []fakeDNSRecord{
+		{srvTarget: "node1.example.test.", srvPort: 9001},
+		{srvTarget: "node2.example.test.", srvPort: 9002},
+	})
+
+	store, err := client.NewDNSNodeStore(client.DNSConfig{
+		Name:     "_dqlite._tcp.example.test.",
+		Resolver: dns.resolver(),
+	})
+	require.NoError(t, err)
+
+	nodes, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{
+		{Address: "node1.example.test:9001"},
+		{Address: "node2.example.test:9002"},
+	}, nodes)
+}
+
+func TestDNSNodeStore_A(t *testing.T) {
+	dns := newFakeDNS(t)
+	defer dns.Close()
+
+	dns.set("dqlite.example.test.", []fakeDNSRecord{{ip: net.IPv4(10, 0, 0, 1)}})
+
+	store, err := client.NewDNSNodeStore(client.DNSConfig{
+		Name:            "dqlite.example.test.",
+		Port:            9001,
+		RefreshInterval: time.Millisecond,
+		Resolver:        dns.resolver(),
+	})
+	require.NoError(t, err)
+
+	nodes, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{{Address: "10.0.0.1:9001"}}, nodes)
+
+	dns.set("dqlite.example.test.", []fakeDNSRecord{{ip: net.IPv4(10, 0, 0, 1)}, {ip: net.IPv4(10, 0, 0, 2)}})
+	time.Sleep(2 * time.Millisecond)
+
+	nodes, err = store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{{Address: "10.0.0.1:9001"}, {Address: "10.0.0.2:9001"}}, nodes)
+
+	// If the name can't be resolved anymore, the last known nodes are
+	// returned.
+	dns.set("dqlite.example.test.", nil)
+	time.Sleep(2 * time.Millisecond)
+
+	nodes, err = store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestDNSNodeStore_NotFound(t *testing.T) {
+	dns := newFakeDNS(t)
+	defer dns.Close()
+
+	store, err := client.NewDNSNodeStore(client.DNSConfig{
+		Name:     "_dqlite._tcp.missing.test.",
+		Resolver: dns.resolver(),
+	})
+	require.NoError(t, err)
+
+	_, err = store.Get(context.Background())
+	assert.Error(t, err)
+}
+
+// Minimal DNS server answering SRV, A and AAAA queries over UDP.
+type fakeDNS struct {
+	conn    net.PacketConn
+	mu      sync.Mutex
+	records map[string][]fakeDNSRecord
+}
+
+type fakeDNSRecord struct {
+	ip        net.IP
+	srvTarget string
+	srvPort   uint16
+}
+
+func newFakeDNS(t *testing.T) *fakeDNS {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	dns := &fakeDNS{conn: conn, records: map[string][]fakeDNSRecord{}}
+	go dns.serve()
+
+	return dns
+}
+
+func (d *fakeDNS) set(name string, records []fakeDNSRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if records == nil {
+		delete(d.records, name)
+		return
+	}
+	d.records[name] = records
+}
+
+func (d *fakeDNS) resolver() *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "udp", d.conn.LocalAddr().String())
+		},
+	}
+}
+
+func (d *fakeDNS) Close() {
+	d.conn.Close()
+}
+
+func (d *fakeDNS) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := d.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if response := d.answer(buf[:n]); response != nil {
+			d.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsTypeSRV  = 33
+)
+
+func (d *fakeDNS) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// Parse the question name.
+	labels := []string{}
+	offset := 12
+	for offset < len(query) && query[offset] != 0 {
+		size := int(query[offset])
+		if offset+1+size > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[offset+1:offset+1+size]))
+		offset += 1 + size
+	}
+	offset++
+	if offset+4 > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, ".")) + "."
+	qtype := binary.BigEndian.Uint16(query[offset:])
+	question := query[12 : offset+4]
+
+	d.mu.Lock()
+	records, ok := d.records[name]
+	d.mu.Unlock()
+
+	answers := [][]byte{}
+	for _, record := range records {
+		var rtype uint16
+		var rdata []byte
+		switch {
+		case qtype == dnsTypeSRV && record.srvTarget != "":
+			rtype = dnsTypeSRV
+			rdata = make([]byte, 6)
+			binary.BigEndian.PutUint16(rdata[4:], record.srvPort)
+			for _, label := range strings.Split(strings.TrimSuffix(record.srvTarget, "."), ".") {
+				rdata = append(rdata, byte(len(label)))
+				rdata = append(rdata, label...)
+			}
+			rdata = append(rdata, 0)
+		case qtype == dnsTypeA && record.ip.To4() != nil:
+			rtype = dnsTypeA
+			rdata = record.ip.To4()
+		default:
+			continue
+		}
+		answer := []byte{0xc0, 0x0c, 0, 0, 0, 1, 0, 0, 0, 60, 0, 0}
+		binary.BigEndian.PutUint16(answer[2:], rtype)
+		binary.BigEndian.PutUint16(answer[10:], uint16(len(rdata)))
+		answers = append(answers, append(answer, rdata...))
+	}
+
+	header := make([]byte, 12)
+	copy(header, query[:2])
+	flags := uint16(0x8180)
+	if !ok {
+		flags |= 3 // NXDOMAIN
+	}
+	binary.BigEndian.PutUint16(header[2:], flags)
+	binary.BigEndian.PutUint16(header[4:], 1)
+	binary.BigEndian.PutUint16(header[6:], uint16(len(answers)))
+
+	response := append(header, question...)
+	for _, answer := range answers {
+		response = append(response, answer...)
+	}
+
+	return response
+}
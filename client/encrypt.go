@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Encrypt returns a copy of the file whose data is encrypted with AES-GCM
+// using the given key, which must be 16, 24 or 32 bytes long. The file name is
+// authenticated too, so the data can't be decrypted under another name.
+//
+// The encrypted data starts with the random nonce used for the encryption.
+func (f File) Encrypt(key []byte) (File, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return File{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(f.Data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return File{}, errors.Wrap(err, "generate nonce")
+	}
+
+	data := aead.Seal(nonce, nonce, f.Data, []byte(f.Name))
+
+	return File{Name: f.Name, Data: data}, nil
+}
+
+// Decrypt returns a copy of a file encrypted with Encrypt, with its original
+// data.
+func (f File) Decrypt(key []byte) (File, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return File{}, err
+	}
+
+	if len(f.Data) < aead.NonceSize() {
+		return File{}, errors.Errorf("encrypted file %s is too short", f.Name)
+	}
+	nonce, ciphertext := f.Data[:aead.NonceSize()], f.Data[aead.NonceSize():]
+
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(f.Name))
+	if err != nil {
+		return File{}, errors.Wrapf(err, "decrypt file %s", f.Name)
+	}
+
+	return File{Name: f.Name, Data: data}, nil
+}
+
+// DumpEncrypted is like Dump, but the returned files are encrypted with the
+// given key, as per File.Encrypt.
+func (c *Client) DumpEncrypted(ctx context.Context, dbname string, key []byte) ([]File, error) {
+	files, err := c.Dump(ctx, dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, file := range files {
+		if files[i], err = file.Encrypt(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "create cipher")
+	}
+	return cipher.NewGCM(block)
+}
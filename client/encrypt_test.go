@@ -0,0 +1,37 @@
+package client_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Encrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	file := client.File{Name: "test.db", Data: []byte("SQLite format 3")}
+
+	encrypted, err := file.Encrypt(key)
+	require.NoError(t, err)
+	assert.Equal(t, "test.db", encrypted.Name)
+	assert.False(t, bytes.Contains(encrypted.Data, file.Data))
+
+	decrypted, err := encrypted.Decrypt(key)
+	require.NoError(t, err)
+	assert.Equal(t, file, decrypted)
+
+	// The wrong key or a different name can't decrypt the data.
+	_, err = encrypted.Decrypt(bytes.Repeat([]byte{2}, 32))
+	assert.Error(t, err)
+
+	renamed := client.File{Name: "test.db-wal", Data: encrypted.Data}
+	_, err = renamed.Decrypt(key)
+	assert.Error(t, err)
+}
+
+func TestFile_EncryptBadKey(t *testing.T) {
+	_, err := client.File{Name: "test.db"}.Encrypt([]byte("short"))
+	assert.Error(t, err)
+}
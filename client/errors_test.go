@@ -0,0 +1,54 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Failed requests carry the result code and the message sent by the node.
+func TestError(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	cli, err := client.New(context.Background(), server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	err = cli.Transfer(context.Background(), 2)
+	require.Error(t, err)
+
+	var e client.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, client.Error{Code: 1, Message: "unrecognized request type"}, e)
+}
+
+// Requests on a connection that was lost match ErrConnectionDead.
+func TestErrConnectionDead(t *testing.T) {
+	server := clienttest.NewServer(1)
+
+	cli, err := client.New(context.Background(), server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	server.Close()
+
+	// The first requests might only see the connection being closed by
+	// the server, before the local end notices that it's dead.
+	for i := 0; i < 10; i++ {
+		_, err = cli.Leader(context.Background())
+		require.Error(t, err)
+		if errors.Is(err, client.ErrConnectionDead) {
+			break
+		}
+	}
+	assert.True(t, errors.Is(err, client.ErrConnectionDead), err.Error())
+
+	_, err = cli.Leader(context.Background())
+	assert.True(t, errors.Is(err, client.ErrConnectionDead), err.Error())
+}
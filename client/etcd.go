@@ -0,0 +1,455 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
+)
+
+// EtcdConfig holds the parameters of an EtcdNodeStore.
+type EtcdConfig struct {
+	Endpoints []string      // URLs of the etcd members, e.g. "http://127.0.0.1:2379".
+	Prefix    string        // Key prefix under which nodes are stored.
+	Username  string        // User to authenticate as, if any.
+	Password  string        // Password of the user.
+	TLSConfig *tls.Config   // TLS configuration for "https" endpoints.
+	Timeout   time.Duration // Timeout of each request, by default 5 seconds.
+}
+
+// EtcdNodeStore keeps the list of dqlite nodes under a key prefix in etcd, so
+// deployments already using etcd for coordination can share the node list
+// with their clients.
+//
+// Each node is stored as a JSON-encoded NodeInfo, under the prefix followed by
+// the node ID. The store talks to the JSON gateway of the etcd v3 API, and
+// watches the prefix in the background, so Get returns the latest list
+// without hitting etcd. If the watch is interrupted, Get falls back to
+// reading from etcd until it's re-established.
+type EtcdNodeStore struct {
+	config EtcdConfig
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	token    string            // Authentication token, if any.
+	endpoint int               // Index of the endpoint in use.
+	nodes    map[string][]byte // Cached values, by key.
+	revision int64             // Revision of the cached values.
+	watching bool              // Whether the cache is up-to-date.
+
+	watchers protocol.NodeStoreWatchers
+}
+
+// NewEtcdNodeStore creates a new EtcdNodeStore, reading the current list of
+// nodes right away. Call Close to stop watching for changes.
+func NewEtcdNodeStore(config EtcdConfig) (*EtcdNodeStore, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("no etcd endpoints given")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &EtcdNodeStore{
+		config: config,
+		client: &http.Client{Transport: &http.Transport{TLSClientConfig: config.TLSConfig}},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if _, err := s.refresh(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.watch(ctx)
+
+	return s, nil
+}
+
+// Get the current servers.
+func (s *EtcdNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	s.mu.Lock()
+	watching := s.watching
+	nodes := s.nodes
+	s.mu.Unlock()
+
+	if !watching {
+		var err error
+		if nodes, err = s.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return decodeEtcdNodes(nodes)
+}
+
+// Set the servers addresses, replacing all keys under the prefix in a single
+// transaction.
+func (s *EtcdNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	prefix := []byte(s.config.Prefix)
+	operations := []interface{}{
+		map[string]interface{}{"requestDeleteRange": map[string][]byte{
+			"key":       prefix,
+			"range_end": etcdRangeEnd(prefix),
+		}},
+	}
+	for _, server := range servers {
+		value, err := json.Marshal(server)
+		if err != nil {
+			return errors.Wrap(err, "encode node")
+		}
+		operations = append(operations, map[string]interface{}{"requestPut": map[string][]byte{
+			"key":   []byte(s.config.Prefix + strconv.FormatUint(server.ID, 10)),
+			"value": value,
+		}})
+	}
+
+	request := map[string]interface{}{"success": operations}
+	if err := s.call(ctx, "/v3/kv/txn", request, nil); err != nil {
+		return errors.Wrap(err, "update nodes")
+	}
+
+	return nil
+}
+
+// Watch returns a channel notified whenever the watch reports a change.
+func (s *EtcdNodeStore) Watch(ctx context.Context) <-chan struct{} {
+	return s.watchers.Watch(ctx)
+}
+
+// Close stops watching for changes.
+func (s *EtcdNodeStore) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Read all nodes from etcd and update the cache.
+func (s *EtcdNodeStore) refresh(ctx context.Context) (map[string][]byte, error) {
+	prefix := []byte(s.config.Prefix)
+	request := map[string][]byte{"key": prefix, "range_end": etcdRangeEnd(prefix)}
+
+	var response struct {
+		Header etcdHeader `json:"header"`
+		Kvs    []etcdKV   `json:"kvs"`
+	}
+	if err := s.call(ctx, "/v3/kv/range", request, &response); err != nil {
+		return nil, errors.Wrap(err, "get nodes")
+	}
+
+	nodes := make(map[string][]byte, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		nodes[string(kv.Key)] = kv.Value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if response.Header.Revision >= s.revision {
+		s.nodes = nodes
+		s.revision = response.Header.Revision
+	}
+
+	return nodes, nil
+}
+
+// Keep the cache up-to-date by watching the prefix, until the context is
+// done.
+func (s *EtcdNodeStore) watch(ctx context.Context) {
+	defer close(s.done)
+
+	backoff := 100 * time.Millisecond
+	for {
+		err := s.watchOnce(ctx)
+
+		s.mu.Lock()
+		s.watching = false
+		s.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = 100 * time.Millisecond
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+
+		// Catch up with the changes made while the watch was down.
+		s.refresh(ctx)
+	}
+}
+
+// Establish a watch stream and apply the events it reports to the cache.
+func (s *EtcdNodeStore) watchOnce(ctx context.Context) error {
+	s.mu.Lock()
+	revision := s.revision
+	s.mu.Unlock()
+
+	prefix := []byte(s.config.Prefix)
+	request := map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            prefix,
+			"range_end":      etcdRangeEnd(prefix),
+			"start_revision": strconv.FormatInt(revision+1, 10),
+		},
+	}
+
+	response, err := s.post(ctx, "/v3/watch", request, false)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message struct {
+			Result struct {
+				Header  etcdHeader `json:"header"`
+				Created bool       `json:"created"`
+				Events  []struct {
+					Type string `json:"type"`
+					Kv   etcdKV `json:"kv"`
+				} `json:"events"`
+			} `json:"result"`
+			Error *etcdError `json:"error"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+
+		s.mu.Lock()
+		if message.Result.Created {
+			s.watching = true
+		}
+		nodes := make(map[string][]byte, len(s.nodes))
+		for key, value := range s.nodes {
+			nodes[key] = value
+		}
+		for _, event := range message.Result.Events {
+			if event.Type == "DELETE" {
+				delete(nodes, string(event.Kv.Key))
+			} else {
+				nodes[string(event.Kv.Key)] = event.Kv.Value
+			}
+			if event.Kv.ModRevision > s.revision {
+				s.revision = event.Kv.ModRevision
+			}
+		}
+		s.nodes = nodes
+		s.mu.Unlock()
+
+		if len(message.Result.Events) > 0 {
+			s.watchers.Notify()
+		}
+	}
+}
+
+// Send a request to the etcd gateway and decode its JSON response into the
+// given object, if not nil.
+func (s *EtcdNodeStore) call(ctx context.Context, path string, request, response interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	r, err := s.post(ctx, path, request, true)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if response == nil {
+		return nil
+	}
+
+	return errors.Wrap(json.NewDecoder(r.Body).Decode(response), "decode response")
+}
+
+// Post the given request, trying all endpoints in turn and authenticating if
+// needed.
+func (s *EtcdNodeStore) post(ctx context.Context, path string, request interface{}, retryAuth bool) (*http.Response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for i := 0; i < len(s.config.Endpoints); i++ {
+		s.mu.Lock()
+		index := (s.endpoint + i) % len(s.config.Endpoints)
+		token := s.token
+		s.mu.Unlock()
+
+		endpoint := strings.TrimRight(s.config.Endpoints[index], "/")
+
+		if token == "" && s.config.Username != "" {
+			if token, err = s.authenticate(ctx, endpoint); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		response, err := s.do(ctx, endpoint+path, body, token)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if response.StatusCode != http.StatusOK {
+			etcdErr := readEtcdError(response)
+			if etcdErr.expiredToken() && retryAuth && s.config.Username != "" {
+				s.mu.Lock()
+				s.token = ""
+				s.mu.Unlock()
+				return s.post(ctx, path, request, false)
+			}
+			return nil, etcdErr
+		}
+
+		s.mu.Lock()
+		s.endpoint = index
+		s.mu.Unlock()
+
+		return response, nil
+	}
+
+	return nil, lastErr
+}
+
+// Obtain an authentication token.
+func (s *EtcdNodeStore) authenticate(ctx context.Context, endpoint string) (string, error) {
+	body, err := json.Marshal(map[string]string{"name": s.config.Username, "password": s.config.Password})
+	if err != nil {
+		return "", err
+	}
+
+	response, err := s.do(ctx, endpoint+"/v3/auth/authenticate", body, "")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.Wrap(readEtcdError(response), "authenticate")
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", errors.Wrap(err, "decode authentication response")
+	}
+
+	s.mu.Lock()
+	s.token = result.Token
+	s.mu.Unlock()
+
+	return result.Token, nil
+}
+
+func (s *EtcdNodeStore) do(ctx context.Context, url string, body []byte, token string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+	return s.client.Do(request)
+}
+
+// Decode the given values, sorted by node ID.
+func decodeEtcdNodes(values map[string][]byte) ([]NodeInfo, error) {
+	nodes := make([]NodeInfo, 0, len(values))
+	for key, value := range values {
+		node := NodeInfo{}
+		if err := json.Unmarshal(value, &node); err != nil {
+			return nil, errors.Wrapf(err, "decode node at key %s", key)
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// Return the end of the key range matching all keys with the given prefix.
+func etcdRangeEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// The prefix is all 0xff bytes (or empty): match all keys.
+	return []byte{0}
+}
+
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type etcdKV struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+// Error returned by the etcd gateway.
+type etcdError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	status  string
+}
+
+func (e *etcdError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("etcd: %s", e.status)
+	}
+	return fmt.Sprintf("etcd: %s", e.Message)
+}
+
+func (e *etcdError) expiredToken() bool {
+	return strings.Contains(e.Message, "invalid auth token")
+}
+
+func readEtcdError(response *http.Response) *etcdError {
+	defer response.Body.Close()
+	err := &etcdError{status: response.Status}
+	data, _ := ioutil.ReadAll(response.Body)
+	json.Unmarshal(data, err)
+	if err.Message == "" {
+		var wrapped struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &wrapped) == nil {
+			err.Message = wrapped.Error
+		}
+	}
+	return err
+}
@@ -0,0 +1,257 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtcdNodeStore(t *testing.T) {
+	etcd := newFakeEtcd()
+	server := httptest.NewServer(etcd)
+	defer server.Close()
+
+	config := client.EtcdConfig{
+		Endpoints: []string{"http://127.0.0.1:1", server.URL},
+		Prefix:    "/dqlite/nodes/",
+		Username:  "root",
+		Password:  "secret",
+	}
+
+	store1, err := client.NewEtcdNodeStore(config)
+	require.NoError(t, err)
+	defer store1.Close()
+
+	nodes, err := store1.Get(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	store2, err := client.NewEtcdNodeStore(config)
+	require.NoError(t, err)
+	defer store2.Close()
+
+	set := []client.NodeInfo{
+		{ID: 1, Address: "1.2.3.4:9001", Role: client.Voter},
+		{ID: 2, Address: "1.2.3.4:9002", Role: client.StandBy},
+	}
+	require.NoError(t, store2.Set(context.Background(), set))
+
+	// The change is picked up by the other store.
+	require.Eventually(t, func() bool {
+		nodes, err := store1.Get(context.Background())
+		return err == nil && assert.ObjectsAreEqual(set, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, store1.Set(context.Background(), set[1:]))
+
+	require.Eventually(t, func() bool {
+		nodes, err := store2.Get(context.Background())
+		return err == nil && assert.ObjectsAreEqual(set[1:], nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Keys outside the prefix are left alone.
+	assert.Contains(t, etcd.keys(), "/other")
+}
+
+func TestEtcdNodeStore_AuthenticationFailure(t *testing.T) {
+	server := httptest.NewServer(newFakeEtcd())
+	defer server.Close()
+
+	_, err := client.NewEtcdNodeStore(client.EtcdConfig{
+		Endpoints: []string{server.URL},
+		Prefix:    "/dqlite/",
+		Username:  "root",
+		Password:  "wrong",
+	})
+	assert.EqualError(t, err, "get nodes: authenticate: etcd: authentication failed, invalid user ID or password")
+}
+
+// Minimal implementation of the etcd v3 JSON gateway.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	kv       map[string][]byte
+	modified map[string]int64 // Revision of the last change of each key.
+	revision int64
+	changed  chan struct{} // Closed and replaced on every change.
+}
+
+type fakeEtcdKV struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type fakeEtcdEvent struct {
+	Type string     `json:"type,omitempty"`
+	Kv   fakeEtcdKV `json:"kv"`
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{
+		kv:       map[string][]byte{"/other": []byte("x")},
+		modified: map[string]int64{"/other": 1},
+		revision: 1,
+		changed:  make(chan struct{}),
+	}
+}
+
+func (e *fakeEtcd) keys() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keys := []string{}
+	for key := range e.kv {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (e *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/auth/authenticate" {
+		var request struct{ Name, Password string }
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Name != "root" || request.Password != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"etcdserver: authentication failed, invalid user ID or password","code":3,"message":"authentication failed, invalid user ID or password"}`))
+			return
+		}
+		w.Write([]byte(`{"token":"token1"}`))
+		return
+	}
+
+	if r.Header.Get("Authorization") != "token1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":16,"message":"etcdserver: invalid auth token"}`))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var request struct {
+			Key      []byte
+			RangeEnd []byte `json:"range_end"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		e.mu.Lock()
+		kvs := []fakeEtcdKV{}
+		for key, value := range e.kv {
+			if key >= string(request.Key) && key < string(request.RangeEnd) {
+				kvs = append(kvs, fakeEtcdKV{Key: []byte(key), Value: value})
+			}
+		}
+		revision := e.revision
+		e.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"header": map[string]string{"revision": strconv.FormatInt(revision, 10)},
+			"kvs":    kvs,
+		})
+	case "/v3/kv/txn":
+		var request struct {
+			Success []struct {
+				RequestDeleteRange *struct {
+					Key      []byte
+					RangeEnd []byte `json:"range_end"`
+				} `json:"requestDeleteRange"`
+				RequestPut *struct{ Key, Value []byte } `json:"requestPut"`
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		e.mu.Lock()
+		e.revision++
+		for _, op := range request.Success {
+			if op.RequestDeleteRange != nil {
+				for key := range e.kv {
+					if key >= string(op.RequestDeleteRange.Key) && key < string(op.RequestDeleteRange.RangeEnd) {
+						delete(e.kv, key)
+					}
+				}
+			}
+			if op.RequestPut != nil {
+				e.kv[string(op.RequestPut.Key)] = op.RequestPut.Value
+				e.modified[string(op.RequestPut.Key)] = e.revision
+			}
+		}
+		close(e.changed)
+		e.changed = make(chan struct{})
+		e.mu.Unlock()
+		w.Write([]byte(`{"succeeded":true}`))
+	case "/v3/watch":
+		var request struct {
+			CreateRequest struct {
+				Key           []byte
+				RangeEnd      []byte `json:"range_end"`
+				StartRevision int64  `json:"start_revision,string"`
+			} `json:"create_request"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		inRange := func(key string) bool {
+			return key >= string(request.CreateRequest.Key) && key < string(request.CreateRequest.RangeEnd)
+		}
+
+		// Replay the puts made since the start revision.
+		e.mu.Lock()
+		snapshot := e.filter(inRange)
+		changed := e.changed
+		events := []fakeEtcdEvent{}
+		for key, value := range snapshot {
+			if revision := e.modified[key]; revision >= request.CreateRequest.StartRevision {
+				events = append(events, fakeEtcdEvent{Kv: fakeEtcdKV{Key: []byte(key), Value: value, ModRevision: strconv.FormatInt(revision, 10)}})
+			}
+		}
+		e.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true, "events": events}})
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-changed:
+			}
+
+			e.mu.Lock()
+			current := e.filter(inRange)
+			changed = e.changed
+			revision := strconv.FormatInt(e.revision, 10)
+			e.mu.Unlock()
+
+			events = []fakeEtcdEvent{}
+			for key := range snapshot {
+				if _, ok := current[key]; !ok {
+					events = append(events, fakeEtcdEvent{Type: "DELETE", Kv: fakeEtcdKV{Key: []byte(key), ModRevision: revision}})
+				}
+			}
+			for key, value := range current {
+				if old, ok := snapshot[key]; !ok || !bytes.Equal(old, value) {
+					events = append(events, fakeEtcdEvent{Kv: fakeEtcdKV{Key: []byte(key), Value: value, ModRevision: revision}})
+				}
+			}
+			snapshot = current
+
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"events": events}})
+			w.(http.Flusher).Flush()
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (e *fakeEtcd) filter(match func(string) bool) map[string][]byte {
+	kv := map[string][]byte{}
+	for key, value := range e.kv {
+		if match(key) {
+			kv[key] = value
+		}
+	}
+	return kv
+}
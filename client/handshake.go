@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Failure modes that the errors returned by New can be matched against with
+// errors.Is, to decide whether connecting again is worth it.
+var (
+	// ErrConnectionRefused matches failures because nothing is listening
+	// at the node address, for example since the node is restarting.
+	ErrConnectionRefused = errors.New("connection refused")
+
+	// ErrTLSHandshake matches failures to establish a TLS session with the
+	// node, such as certificate verification errors, which won't go away
+	// by retrying with the same configuration.
+	ErrTLSHandshake = errors.New("TLS handshake failed")
+
+	// ErrProtocolMismatch matches failures because the node closed the
+	// connection when receiving the first request after the handshake,
+	// which is what happens when it doesn't speak the requested protocol
+	// version, or isn't a dqlite node.
+	ErrProtocolMismatch = errors.New("protocol version not supported by the node")
+)
+
+// A connection failure matching one of the failure modes of New.
+type connectError struct {
+	kind error
+	err  error
+}
+
+func (e connectError) Error() string        { return e.err.Error() }
+func (e connectError) Cause() error         { return e.err }
+func (e connectError) Unwrap() error        { return e.err }
+func (e connectError) Is(target error) bool { return target == e.kind }
+
+// Classify a failure to establish the network connection to a node.
+func classifyDial(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCertificate x509.CertificateInvalidError
+	var hostname x509.HostnameError
+	var recordHeader tls.RecordHeaderError
+
+	switch {
+	case errors.Is(err, ErrTLSHandshake):
+		return err
+	case errors.As(err, &unknownAuthority), errors.As(err, &invalidCertificate),
+		errors.As(err, &hostname), errors.As(err, &recordHeader):
+		return connectError{kind: ErrTLSHandshake, err: err}
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return connectError{kind: ErrConnectionRefused, err: err}
+	}
+
+	return err
+}
+
+// Check that the node speaks the protocol version sent with the handshake,
+// which it tells only by closing the connection when receiving a request,
+// with a cheap Leader request.
+func probeHandshake(ctx context.Context, p *protocol.Protocol) error {
+	request := protocol.Message{}
+	request.Init(16)
+	response := protocol.Message{}
+	response.Init(512)
+
+	protocol.EncodeLeader(&request)
+
+	if err := p.Call(ctx, &request, &response); err != nil {
+		return err
+	}
+
+	_, _, err := protocol.DecodeNodeCompat(p, &response)
+	return err
+}
+
+// Classify a failure of the first exchanges with a node after the network
+// connection is established.
+func classifyHandshake(err error) error {
+	var auth ErrAuthentication
+	if errors.As(err, &auth) {
+		return err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return connectError{kind: ErrProtocolMismatch, err: err}
+	}
+
+	return err
+}
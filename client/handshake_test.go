@@ -0,0 +1,101 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, err = client.New(context.Background(), address)
+	assert.True(t, errors.Is(err, client.ErrConnectionRefused), err)
+	assert.False(t, errors.Is(err, client.ErrTLSHandshake))
+}
+
+func TestNew_TLSHandshakeFailure(t *testing.T) {
+	cert, pool := loadTestCert(t)
+	server := newTLSServer(t, cert, pool)
+	defer server.Close()
+
+	// The client doesn't trust the node certificate.
+	_, err := client.New(context.Background(), server.Address(), client.WithTLS(&cert, nil))
+	assert.True(t, errors.Is(err, client.ErrTLSHandshake), err)
+}
+
+func TestNew_ProtocolMismatch(t *testing.T) {
+	address := serveOnce(t, func(conn net.Conn) {
+		io.ReadFull(conn, make([]byte, 8))
+	})
+
+	_, err := client.New(context.Background(), address)
+	assert.True(t, errors.Is(err, client.ErrProtocolMismatch), err)
+}
+
+// Failures after the node answered the probe request are not mistaken for a
+// protocol mismatch.
+func TestNew_ConnectionLostAfterProbe(t *testing.T) {
+	address := serveOnce(t, func(conn net.Conn) {
+		io.ReadFull(conn, make([]byte, 8+16))
+		conn.Write([]byte{2, 0, 0, 0, protocol.ResponseNode, 0, 0, 0})
+		conn.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 'a', 0, 0, 0, 0, 0, 0, 0})
+		io.ReadFull(conn, make([]byte, 8))
+	})
+
+	_, err := client.New(context.Background(), address, client.WithDatabase("test.db"))
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, client.ErrProtocolMismatch), err)
+}
+
+func TestWithHandshakeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	address := serveOnce(t, func(conn net.Conn) {
+		<-release
+	})
+
+	// The authentication never completes, since the node doesn't reply.
+	auth := func(ctx context.Context, conn net.Conn) error {
+		_, err := conn.Read(make([]byte, 1))
+		return err
+	}
+
+	start := time.Now()
+	_, err := client.New(context.Background(), address,
+		client.WithAuth(auth), client.WithHandshakeTimeout(50*time.Millisecond))
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+// Accept a single connection, handing it to the given function and closing
+// it once the function returns.
+func serveOnce(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(conn)
+	}()
+
+	return listener.Addr().String()
+}
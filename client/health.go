@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+)
+
+// HasLeader tells whether the cluster currently has a leader, by asking the
+// nodes in the given store until one of them is reachable and knows the
+// leader. It's meant for readiness probes and pre-flight checks.
+func HasLeader(ctx context.Context, store NodeStore, options ...Option) (bool, error) {
+	nodes, err := store.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, node := range nodes {
+		if nodeOnline(ctx, node, options) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsVoterQuorate tells whether a majority of the voters of the cluster are
+// online, by fetching the cluster configuration from the leader and probing
+// each voter.
+//
+// If there's no leader the cluster is not quorate, but that's only detected
+// when the leader search fails, so the context should have a deadline or a
+// retry limit should be set with WithRetryLimit.
+func IsVoterQuorate(ctx context.Context, store NodeStore, options ...Option) (bool, error) {
+	cli, err := FindLeader(ctx, store, options...)
+	if err != nil {
+		return false, err
+	}
+	defer cli.Close()
+
+	cluster, err := cli.Cluster(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	voters, online := countOnlineVoters(ctx, cluster, 0, options)
+
+	return online >= voters/2+1, nil
+}
+
+// Return the number of voters in the given cluster and how many of them are
+// online, skipping the node with the given ID, if not zero.
+func countOnlineVoters(ctx context.Context, cluster []NodeInfo, skip uint64, options []Option) (int, int) {
+	voters := 0
+	online := 0
+	for _, node := range cluster {
+		if node.Role != Voter {
+			continue
+		}
+		voters++
+		if node.ID == skip {
+			continue
+		}
+		if nodeOnline(ctx, node, options) {
+			online++
+		}
+	}
+	return voters, online
+}
+
+// Tell whether the given node is reachable and knows the current leader.
+func nodeOnline(ctx context.Context, node NodeInfo, options []Option) bool {
+	cli, err := New(ctx, node.Address, options...)
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	return err == nil && leader.Address != ""
+}
@@ -0,0 +1,58 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasLeader(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	ctx := context.Background()
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, []client.NodeInfo{{ID: 1, Address: server.Address()}}))
+
+	ok, err := client.HasLeader(ctx, store)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	server.SetLeader(nil)
+
+	ok, err = client.HasLeader(ctx, store)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestIsVoterQuorate(t *testing.T) {
+	servers, nodes := newRestartCluster(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, nodes))
+
+	ok, err := client.IsVoterQuorate(ctx, store)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Two voters out of three are still a majority.
+	servers[2].Close()
+
+	ok, err = client.IsVoterQuorate(ctx, store)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	servers[1].Close()
+
+	ok, err = client.IsVoterQuorate(ctx, store)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
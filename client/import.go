@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ImportSQLite copies the schema and the content of the plain SQLite database
+// file at the given path into the database open on the client connection, see
+// WithDatabase, which must be empty. If a WAL file is next to the database
+// file, SQLite reads it as well.
+//
+// The file is checked with PRAGMA integrity_check first. Its content is then
+// loaded by executing the statements creating its tables, indexes, views and
+// triggers, and inserting its rows, in a single transaction, so that it goes
+// through the replication path like any other write, and the database is left
+// empty if the import fails. As with the .dump command of the sqlite3 shell,
+// the rowids of tables without an INTEGER PRIMARY KEY are not preserved.
+//
+// This must be invoked on a client connected to the current leader.
+func (c *Client) ImportSQLite(ctx context.Context, path string) error {
+	if c.database == nil {
+		return errors.New("no database open on the client connection")
+	}
+
+	source, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.Wrap(err, "failed to open source database")
+	}
+	defer source.Close()
+
+	var check string
+	if err := source.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&check); err != nil {
+		return errors.Wrap(err, "failed to check source database")
+	}
+	if check != "ok" {
+		return errors.Errorf("source database is corrupt: %s", check)
+	}
+
+	_, rows, err := c.Query(ctx, "SELECT count(*) FROM sqlite_master")
+	if err != nil {
+		return err
+	}
+	if len(rows) != 1 || rows[0][0] != int64(0) {
+		return errors.Errorf("database %q is not empty", c.database.name)
+	}
+
+	if _, err := c.Exec(ctx, "BEGIN"); err != nil {
+		return err
+	}
+	if err := c.importSQLite(ctx, source); err != nil {
+		c.Exec(ctx, "ROLLBACK")
+		return err
+	}
+	if _, err := c.Exec(ctx, "COMMIT"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Copy the schema and the rows of the source database.
+func (c *Client) importSQLite(ctx context.Context, source *sql.DB) error {
+	rows, err := source.QueryContext(ctx, `
+SELECT type, name, sql FROM sqlite_master
+WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+ORDER BY type != 'table', rowid`)
+	if err != nil {
+		return err
+	}
+	type object struct{ kind, name, sql string }
+	objects := []object{}
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.kind, &o.name, &o.sql); err != nil {
+			rows.Close()
+			return err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Create the tables and fill them, then create indexes, views and
+	// triggers, like the .dump command does.
+	for _, o := range objects {
+		if _, err := c.Exec(ctx, o.sql); err != nil {
+			return errors.Wrapf(err, "failed to create %s %q", o.kind, o.name)
+		}
+		if o.kind != "table" {
+			continue
+		}
+		if err := c.importTable(ctx, source, o.name); err != nil {
+			return errors.Wrapf(err, "failed to import table %q", o.name)
+		}
+	}
+
+	// The AUTOINCREMENT counters, which can be higher than the largest
+	// rowids inserted.
+	sequence, err := sqliteStrings(ctx, source, "SELECT name FROM sqlite_master WHERE name = 'sqlite_sequence'")
+	if err != nil {
+		return err
+	}
+	if len(sequence) == 1 {
+		if _, err := c.Exec(ctx, "DELETE FROM sqlite_sequence"); err != nil {
+			return err
+		}
+		if err := c.importTable(ctx, source, "sqlite_sequence"); err != nil {
+			return errors.Wrap(err, "failed to import AUTOINCREMENT counters")
+		}
+	}
+
+	return nil
+}
+
+// Insert the rows of the given table of the source database, pipelining the
+// statements with ExecAsync.
+func (c *Client) importTable(ctx context.Context, source *sql.DB, table string) error {
+	columns, err := sqliteStrings(ctx, source, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+
+	// Column names are prefixed with a unary plus, which doesn't change the
+	// values, but removes their declared type, so the bindings don't
+	// convert DATETIME values and the like.
+	names := make([]string, len(columns))
+	selected := make([]string, len(columns))
+	params := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(column)
+		selected[i] = "+" + names[i]
+		params[i] = "?"
+	}
+	query := "SELECT " + strings.Join(selected, ", ") + " FROM " + quoteIdentifier(table)
+	insert := "INSERT INTO " + quoteIdentifier(table) + " (" + strings.Join(names, ", ") +
+		") VALUES (" + strings.Join(params, ", ") + ")"
+
+	rows, err := source.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	futures := []*ExecFuture{}
+	wait := func() error {
+		for _, future := range futures {
+			if _, err := future.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		futures = futures[:0]
+		return nil
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		future, err := c.ExecAsync(ctx, c.database.name, insert, values...)
+		if err != nil {
+			return err
+		}
+		if futures = append(futures, future); len(futures) >= c.execWindow {
+			if err := wait(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return wait()
+}
+
+// Return the values of a single text column.
+func sqliteStrings(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,92 @@
+package client_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ImportSQLite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-import-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "source.db")
+	source, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = source.Exec(`
+CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, s TEXT, b BLOB, at DATETIME);
+INSERT INTO t VALUES (1, 'x', x'0102', '2024-01-02 03:04:05');
+INSERT INTO t VALUES (5, NULL, NULL, NULL);
+DELETE FROM t WHERE id = 5;
+INSERT INTO t (s) VALUES ('y');
+CREATE INDEX t_s ON t (s);`)
+	require.NoError(t, err)
+	require.NoError(t, source.Close())
+
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, fmt.Sprintf("%s %v", sql, args))
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(0)}}}, nil
+	})
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address(), client.WithDatabase("test.db"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	require.NoError(t, cli.ImportSQLite(ctx, path))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"BEGIN []",
+		"CREATE TABLE t (id INTEGER PRIMARY KEY AUTOINCREMENT, s TEXT, b BLOB, at DATETIME) []",
+		`INSERT INTO "t" ("id", "s", "b", "at") VALUES (?, ?, ?, ?) [1 x [1 2] 2024-01-02 03:04:05]`,
+		`INSERT INTO "t" ("id", "s", "b", "at") VALUES (?, ?, ?, ?) [6 y <nil> <nil>]`,
+		"CREATE INDEX t_s ON t (s) []",
+		"DELETE FROM sqlite_sequence []",
+		`INSERT INTO "sqlite_sequence" ("name", "seq") VALUES (?, ?) [t 6]`,
+		"COMMIT []",
+	}, statements)
+}
+
+func TestClient_ImportSQLite_Invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-import-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "source.db")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a database, just some text padding it out"), 0600))
+
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address(), client.WithDatabase("test.db"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	err = cli.ImportSQLite(ctx, path)
+	assert.Contains(t, err.Error(), "failed to check source database")
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// JSON wraps a Go value stored in a TEXT column as JSON. It can be passed as
+// a statement argument, to the driver or to ExecAsync, in which case V is
+// marshaled, or scanned from a query result, in which case V must be a
+// pointer and the column value is unmarshaled into it:
+//
+//	_, err := db.Exec("INSERT INTO docs(body) VALUES(?)", client.JSON{V: doc})
+//	err = db.QueryRow("SELECT body FROM docs").Scan(client.JSON{V: &doc})
+//
+// A nil V is stored as NULL, and NULL is unmarshaled like the JSON null.
+type JSON struct {
+	V interface{}
+}
+
+// Value implements driver.Valuer.
+func (j JSON) Value() (driver.Value, error) {
+	if j.V == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(j.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal JSON")
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (j JSON) Scan(value interface{}) error {
+	var data []byte
+	switch value := value.(type) {
+	case nil:
+		data = []byte("null")
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return errors.Errorf("can't unmarshal JSON from %T", value)
+	}
+	if err := json.Unmarshal(data, j.V); err != nil {
+		return errors.Wrap(err, "unmarshal JSON")
+	}
+	return nil
+}
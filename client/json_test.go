@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type document struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func TestJSON(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	doc := document{Title: "hello", Tags: []string{"a", "b"}}
+	future, err := cli.ExecAsync(ctx, "test.db", "INSERT INTO docs VALUES(?, ?)", client.JSON{V: doc}, client.JSON{})
+	require.NoError(t, err)
+	_, err = future.Wait(ctx)
+	require.NoError(t, err)
+
+	mu.Lock()
+	assert.Equal(t, []driver.Value{`{"title":"hello","tags":["a","b"]}`, nil}, bound)
+	mu.Unlock()
+
+	var scanned document
+	require.NoError(t, client.JSON{V: &scanned}.Scan(`{"title":"hello","tags":["a","b"]}`))
+	assert.Equal(t, doc, scanned)
+
+	var ptr *document
+	require.NoError(t, client.JSON{V: &ptr}.Scan(nil))
+	assert.Nil(t, ptr)
+
+	assert.Error(t, client.JSON{V: &scanned}.Scan(int64(1)))
+	assert.Error(t, client.JSON{V: &scanned}.Scan("{"))
+}
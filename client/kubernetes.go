@@ -0,0 +1,344 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
+)
+
+// Location of the service account credentials mounted in pods.
+const kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesConfig holds the parameters of a KubernetesNodeStore.
+type KubernetesConfig struct {
+	Service   string // Name of the Service selecting the dqlite pods.
+	Namespace string // Namespace of the Service, by default the one of the current pod.
+	PortName  string // Name of the Service port of the nodes, if it has more than one.
+
+	// IncludeNotReady makes endpoints that are not ready part of the node
+	// list as well. This is typically needed when the readiness of the pods
+	// depends on the dqlite cluster being formed.
+	IncludeNotReady bool
+
+	// API server URL, credentials and TLS configuration. By default, the
+	// in-cluster configuration of the pod's service account is used.
+	APIServer string
+	Token     string
+	TLSConfig *tls.Config
+}
+
+// KubernetesNodeStore keeps the list of dqlite nodes in sync with the
+// EndpointSlices of a Kubernetes Service, so StatefulSet-based deployments
+// don't need an external registry.
+//
+// The EndpointSlices are watched in the background, so Get returns the latest
+// list without hitting the API server. Node IDs are not known and always set
+// to zero. Since the list is owned by Kubernetes, Set is a no-op.
+type KubernetesNodeStore struct {
+	config KubernetesConfig
+	client *http.Client
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	slices  map[string]kubernetesEndpointSlice // By slice name.
+	version string                             // Resource version of the list.
+
+	watchers protocol.NodeStoreWatchers
+}
+
+// NewKubernetesNodeStore creates a new KubernetesNodeStore, listing the
+// current endpoints right away. Call Close to stop watching for changes.
+func NewKubernetesNodeStore(config KubernetesConfig) (*KubernetesNodeStore, error) {
+	if config.Service == "" {
+		return nil, fmt.Errorf("no Kubernetes service given")
+	}
+	if err := kubernetesInClusterConfig(&config); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &KubernetesNodeStore{
+		config: config,
+		client: &http.Client{Transport: &http.Transport{TLSClientConfig: config.TLSConfig}},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	if err := s.list(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.run(ctx)
+
+	return s, nil
+}
+
+// Fill the missing parameters from the in-cluster service account.
+func kubernetesInClusterConfig(config *KubernetesConfig) error {
+	read := func(name string) (string, error) {
+		data, err := ioutil.ReadFile(kubernetesServiceAccountDir + "/" + name)
+		if err != nil {
+			return "", errors.Wrap(err, "read service account")
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var err error
+	if config.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("not running in a Kubernetes cluster and no API server given")
+		}
+		config.APIServer = "https://" + net.JoinHostPort(host, port)
+
+		if config.TLSConfig == nil {
+			ca, err := read("ca.crt")
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(ca)) {
+				return fmt.Errorf("invalid service account CA certificate")
+			}
+			config.TLSConfig = &tls.Config{RootCAs: pool}
+		}
+		if config.Token == "" {
+			if config.Token, err = read("token"); err != nil {
+				return err
+			}
+		}
+	}
+	if config.Namespace == "" {
+		if config.Namespace, err = read("namespace"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get the current servers.
+func (s *KubernetesNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := map[string]bool{}
+	nodes := []NodeInfo{}
+	for _, slice := range s.slices {
+		port := 0
+		for _, p := range slice.Ports {
+			if s.config.PortName == "" || p.Name == s.config.PortName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if !s.config.IncludeNotReady && endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, ip := range endpoint.Addresses {
+				address := net.JoinHostPort(ip, strconv.Itoa(port))
+				if !seen[address] {
+					seen[address] = true
+					nodes = append(nodes, NodeInfo{Address: address})
+				}
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Address < nodes[j].Address })
+
+	return nodes, nil
+}
+
+// Set does nothing, since the list of nodes is managed by Kubernetes.
+func (s *KubernetesNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	return nil
+}
+
+// Watch returns a channel notified whenever the EndpointSlices change.
+func (s *KubernetesNodeStore) Watch(ctx context.Context) <-chan struct{} {
+	return s.watchers.Watch(ctx)
+}
+
+// Close stops watching for changes.
+func (s *KubernetesNodeStore) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Watch the EndpointSlices until the context is done, listing them again
+// whenever the watch can't be resumed.
+func (s *KubernetesNodeStore) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		err := s.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			s.list(ctx)
+		}
+	}
+}
+
+// Build the URL of the EndpointSlices of the service.
+func (s *KubernetesNodeStore) slicesURL(query url.Values) string {
+	query.Set("labelSelector", "kubernetes.io/service-name="+s.config.Service)
+	return fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?%s",
+		strings.TrimRight(s.config.APIServer, "/"), url.PathEscape(s.config.Namespace), query.Encode())
+}
+
+// List the EndpointSlices of the service.
+func (s *KubernetesNodeStore) list(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	response, err := s.get(ctx, s.slicesURL(url.Values{}))
+	if err != nil {
+		return errors.Wrap(err, "list endpoint slices")
+	}
+	defer response.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []kubernetesEndpointSlice `json:"items"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return errors.Wrap(err, "decode endpoint slices")
+	}
+
+	slices := make(map[string]kubernetesEndpointSlice, len(list.Items))
+	for _, slice := range list.Items {
+		slices[slice.Metadata.Name] = slice
+	}
+
+	s.mu.Lock()
+	s.slices = slices
+	s.version = list.Metadata.ResourceVersion
+	s.mu.Unlock()
+
+	s.watchers.Notify()
+
+	return nil
+}
+
+// Watch the EndpointSlices of the service from the last known version,
+// until the stream ends.
+func (s *KubernetesNodeStore) watch(ctx context.Context) error {
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+
+	query := url.Values{"watch": []string{"1"}, "resourceVersion": []string{version}, "allowWatchBookmarks": []string{"true"}}
+	response, err := s.get(ctx, s.slicesURL(query))
+	if err != nil {
+		return errors.Wrap(err, "watch endpoint slices")
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event struct {
+			Type   string                  `json:"type"`
+			Object kubernetesEndpointSlice `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				// The API server ended the watch: resume it.
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			s.slices[event.Object.Metadata.Name] = event.Object
+		case "DELETED":
+			delete(s.slices, event.Object.Metadata.Name)
+		case "ERROR":
+			// Typically the version is too old: list again.
+			s.mu.Unlock()
+			return fmt.Errorf("watch endpoint slices: error event")
+		}
+		if event.Object.Metadata.ResourceVersion != "" {
+			s.version = event.Object.Metadata.ResourceVersion
+		}
+		s.mu.Unlock()
+
+		if event.Type != "BOOKMARK" {
+			s.watchers.Notify()
+		}
+	}
+}
+
+func (s *KubernetesNodeStore) get(ctx context.Context, url string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Accept", "application/json")
+	if s.config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.config.Token)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return response, nil
+}
+
+type kubernetesEndpointSlice struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Endpoints []struct {
+		Addresses  []string `json:"addresses"`
+		Conditions struct {
+			Ready *bool `json:"ready"`
+		} `json:"conditions"`
+	} `json:"endpoints"`
+	Ports []struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	} `json:"ports"`
+}
@@ -0,0 +1,97 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesNodeStore(t *testing.T) {
+	events := make(chan string)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/discovery.k8s.io/v1/namespaces/db/endpointslices" ||
+			r.URL.Query().Get("labelSelector") != "kubernetes.io/service-name=dqlite" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("watch") == "" {
+			w.Write([]byte(`{"metadata": {"resourceVersion": "10"}, "items": [
+  {"metadata": {"name": "dqlite-abc"},
+   "endpoints": [
+     {"addresses": ["10.0.0.1"], "conditions": {"ready": true}},
+     {"addresses": ["10.0.0.2"], "conditions": {"ready": false}}
+   ],
+   "ports": [{"name": "metrics", "port": 8080}, {"name": "dqlite", "port": 9001}]}
+]}`))
+			return
+		}
+
+		assert.Equal(t, "10", r.URL.Query().Get("resourceVersion"))
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case event := <-events:
+				w.Write([]byte(event + "\n"))
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	store, err := client.NewKubernetesNodeStore(client.KubernetesConfig{
+		Service:   "dqlite",
+		Namespace: "db",
+		PortName:  "dqlite",
+		APIServer: server.URL,
+		Token:     "secret",
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	nodes, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{{Address: "10.0.0.1:9001"}}, nodes)
+
+	events <- `{"type": "MODIFIED", "object": {"metadata": {"name": "dqlite-abc", "resourceVersion": "11"},
+  "endpoints": [{"addresses": ["10.0.0.1"]}, {"addresses": ["10.0.0.2"], "conditions": {"ready": true}}],
+  "ports": [{"name": "dqlite", "port": 9001}]}}`
+	events <- `{"type": "ADDED", "object": {"metadata": {"name": "dqlite-def", "resourceVersion": "12"},
+  "endpoints": [{"addresses": ["10.0.0.3"]}],
+  "ports": [{"name": "dqlite", "port": 9001}]}}`
+
+	expected := []client.NodeInfo{{Address: "10.0.0.1:9001"}, {Address: "10.0.0.2:9001"}, {Address: "10.0.0.3:9001"}}
+	require.Eventually(t, func() bool {
+		nodes, err := store.Get(context.Background())
+		return err == nil && assert.ObjectsAreEqual(expected, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	events <- `{"type": "DELETED", "object": {"metadata": {"name": "dqlite-abc", "resourceVersion": "13"}}}`
+
+	expected = []client.NodeInfo{{Address: "10.0.0.3:9001"}}
+	require.Eventually(t, func() bool {
+		nodes, err := store.Get(context.Background())
+		return err == nil && assert.ObjectsAreEqual(expected, nodes)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestKubernetesNodeStore_NotInCluster(t *testing.T) {
+	defer setenv(t, "KUBERNETES_SERVICE_HOST", "")()
+
+	_, err := client.NewKubernetesNodeStore(client.KubernetesConfig{Service: "dqlite"})
+	assert.EqualError(t, err, "not running in a Kubernetes cluster and no API server given")
+}
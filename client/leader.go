@@ -11,8 +11,25 @@ import (
 // The function will iterate through to all nodes in the given store, and for
 // each of them check if it's the current leader. If no leader is found, the
 // function will keep retrying (with a capped exponential backoff) until the
-// given context is canceled.
+// given context is canceled or the limit set with WithRetryLimit is reached.
 func FindLeader(ctx context.Context, store NodeStore, options ...Option) (*Client, error) {
+	return NewConnector(store, options...).Connect(ctx)
+}
+
+// Connector creates clients connected to the current leader of a cluster.
+//
+// It remembers the last leader it connected to, and tries it first on the
+// next connection, falling back to probing all the nodes in its store if that
+// node is not the leader anymore. A Connector can be used concurrently by
+// multiple goroutines.
+type Connector struct {
+	connector *protocol.Connector
+	options   *options
+}
+
+// NewConnector returns a new connector looking for the leader among the nodes
+// in the given store.
+func NewConnector(store NodeStore, options ...Option) *Connector {
 	o := defaultOptions()
 
 	for _, option := range options {
@@ -20,15 +37,58 @@ func FindLeader(ctx context.Context, store NodeStore, options ...Option) (*Clien
 	}
 
 	config := protocol.Config{
-		Dial: o.DialFunc,
+		Dial:             o.dialFunc(),
+		DialTimeout:      o.DialTimeout,
+		HandshakeTimeout: o.HandshakeTimeout,
+		AttemptTimeout:   o.AttemptTimeout,
+		BackoffFactor:    o.BackoffFactor,
+		BackoffCap:       o.BackoffCap,
+		Backoff:          o.Backoff,
+		RetryLimit:       o.RetryLimit,
+		ReadTimeout:      o.ReadTimeout,
+		WriteTimeout:     o.WriteTimeout,
+		Limits:           o.Limits,
+		StrictDecoding:   o.StrictDecoding,
+		Auth:             o.Auth,
+		LeaderChange:     o.LeaderChange,
+		UpdateStore:      o.UpdateStore,
+		Interceptor:      o.Interceptor,
+		CallTimeouts:     o.CallTimeouts,
+		CallStats:        o.CallStats,
+		AdaptiveTimeout:  o.AdaptiveTimeout,
+		KeepAlive:        o.KeepAlive,
+		Translate:        o.Translate,
 	}
-	connector := protocol.NewConnector(0, store, config, o.LogFunc)
-	protocol, err := connector.Connect(ctx)
+
+	return &Connector{connector: protocol.NewConnector(0, store, config, o.LogFunc), options: o}
+}
+
+// Connect returns a Client connected to the current cluster leader, retrying
+// as described in FindLeader.
+func (c *Connector) Connect(ctx context.Context) (*Client, error) {
+	protocol, err := c.connector.Connect(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	client := &Client{protocol: protocol}
+	return c.options.newClient(ctx, protocol)
+}
+
+// Leader returns the address of the leader the last successful call to
+// Connect connected to, or an empty string if none.
+func (c *Connector) Leader() string {
+	return c.connector.Leader()
+}
+
+// WaitLeader blocks until a node in the given store reports a leader, and
+// returns it. It retries as described in FindLeader, so it's meant for
+// start-up code that needs the cluster to be available before proceeding.
+func WaitLeader(ctx context.Context, store NodeStore, options ...Option) (*NodeInfo, error) {
+	cli, err := FindLeader(ctx, store, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
 
-	return client, nil
+	return cli.Leader(ctx)
 }
@@ -3,11 +3,15 @@ package client_test
 import (
 	"context"
 	"fmt"
+	"net"
 	"testing"
 	"time"
 
 	dqlite "github.com/canonical/go-dqlite"
 	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/canonical/go-dqlite/internal/logging"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -44,3 +48,337 @@ func TestMembership(t *testing.T) {
 	err = client.Add(ctx, infos[1])
 	require.NoError(t, err)
 }
+
+// Nodes are tried concurrently, so an unresponsive node doesn't delay finding
+// the leader through the other ones.
+func TestFindLeader_UnresponsiveNode(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conns := []net.Conn{}
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	leader := clienttest.NewServer(1)
+	defer leader.Close()
+
+	follower := clienttest.NewServer(2)
+	defer follower.Close()
+	follower.SetLeader(&client.NodeInfo{ID: 1, Address: leader.Address()})
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{
+		{ID: 3, Address: listener.Addr().String()},
+		{ID: 2, Address: follower.Address()},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	cli, err := client.FindLeader(ctx, store, client.WithLogFunc(logging.Test(t)))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	info, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, leader.Address(), info.Address)
+}
+
+// The attempts still pending are aborted as soon as the leader is found.
+func TestFindLeader_CancelPendingAttempts(t *testing.T) {
+	leader := clienttest.NewServer(1)
+	defer leader.Close()
+
+	aborted := make(chan struct{})
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		if address != "slow" {
+			return client.DefaultDialFunc(ctx, address)
+		}
+		<-ctx.Done()
+		close(aborted)
+		return nil, ctx.Err()
+	}
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{
+		{ID: 2, Address: "slow"},
+		{ID: 1, Address: leader.Address()},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := client.FindLeader(
+		ctx, store,
+		client.WithDialFunc(dial),
+		client.WithAttemptTimeout(5*time.Second),
+		client.WithLogFunc(logging.Test(t)),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("pending attempt not aborted")
+	}
+}
+
+// FindLeader gives up after the configured number of retries.
+func TestFindLeader_RetryLimit(t *testing.T) {
+	network := clienttest.NewNetwork()
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{ID: 1, Address: "1"}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.FindLeader(
+		ctx, store,
+		client.WithDialFunc(network.DialFunc()),
+		client.WithDialTimeout(time.Second),
+		client.WithRetryLimit(2),
+		client.WithBackoff(time.Millisecond, 5*time.Millisecond),
+		client.WithLogFunc(logging.Test(t)),
+	)
+	assert.Equal(t, client.ErrNoAvailableLeader, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.NoError(t, ctx.Err())
+}
+
+// The connector goes straight to the last known leader.
+func TestConnector_LastKnownLeader(t *testing.T) {
+	leader := clienttest.NewServer(1)
+	defer leader.Close()
+
+	follower := clienttest.NewServer(2)
+	follower.SetLeader(&client.NodeInfo{ID: 1, Address: leader.Address()})
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{ID: 2, Address: follower.Address()}})
+
+	connector := client.NewConnector(store, client.WithRetryLimit(1), client.WithLogFunc(logging.Test(t)))
+	assert.Equal(t, "", connector.Leader())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	cli.Close()
+	assert.Equal(t, leader.Address(), connector.Leader())
+
+	// The follower is gone, but the leader is still reachable.
+	follower.Close()
+
+	cli, err = connector.Connect(ctx)
+	require.NoError(t, err)
+	cli.Close()
+
+	// The leader is gone too.
+	leader.Close()
+
+	_, err = connector.Connect(ctx)
+	assert.Equal(t, client.ErrNoAvailableLeader, err)
+	assert.Equal(t, "", connector.Leader())
+}
+
+// The leader change callback is invoked when leadership moves.
+func TestConnector_LeaderChange(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	info1 := client.NodeInfo{ID: 1, Address: server1.Address()}
+
+	server2 := clienttest.NewServer(2)
+	defer server2.Close()
+	info2 := client.NodeInfo{ID: 2, Address: server2.Address()}
+	server2.SetLeader(&info1)
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{info1, info2})
+
+	changes := [][2]client.NodeInfo{}
+	connector := client.NewConnector(
+		store,
+		client.WithLeaderChange(func(old, new client.NodeInfo) {
+			changes = append(changes, [2]client.NodeInfo{old, new})
+		}),
+		client.WithLogFunc(logging.Test(t)),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	cli.Close()
+	assert.Empty(t, changes)
+
+	server1.SetLeader(&info2)
+	server2.SetLeader(&info2)
+
+	cli, err = connector.Connect(ctx)
+	require.NoError(t, err)
+	cli.Close()
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, info1.Address, changes[0][0].Address)
+	assert.Equal(t, info2, changes[0][1])
+}
+
+func TestConnector_WatchableNodeStore(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	info := client.NodeInfo{ID: 1, Address: server.Address()}
+	server.SetLeader(&info)
+
+	store := client.NewInmemNodeStore()
+	connector := client.NewConnector(
+		store,
+		client.WithBackoff(10*time.Second, 10*time.Second),
+		client.WithLogFunc(logging.Test(t)),
+	)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		store.Set(context.Background(), []client.NodeInfo{info})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The new node is tried as soon as it's added, without waiting for the
+	// backoff to expire.
+	start := time.Now()
+	cli, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+// The connector saves the cluster configuration in the store, keeping the
+// metadata of known nodes.
+func TestConnector_UpdateStore(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	info1 := client.NodeInfo{ID: 1, Address: server1.Address(), Role: client.Voter}
+	info2 := client.NodeInfo{ID: 2, Address: "127.0.0.1:1", Role: client.StandBy}
+	server1.SetLeader(&info1)
+	server1.SetCluster([]client.NodeInfo{info1, info2})
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{Address: server1.Address(), Weight: 3}})
+
+	connector := client.NewConnector(
+		store,
+		client.WithUpdateStore(true),
+		client.WithLogFunc(logging.Test(t)),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	nodes, err := store.Get(ctx)
+	require.NoError(t, err)
+
+	info1.Weight = 3
+	assert.Equal(t, []client.NodeInfo{info1, info2}, nodes)
+}
+
+// Addresses advertised by the cluster are translated before being dialed,
+// returned or saved in the store.
+func TestConnector_AddressTranslator(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	info1 := client.NodeInfo{ID: 1, Address: "10.0.0.1:9001", Role: client.Voter}
+	info2 := client.NodeInfo{ID: 2, Address: "10.0.0.2:9001", Role: client.Voter}
+	server1.SetLeader(&info1)
+	server1.SetCluster([]client.NodeInfo{info1, info2})
+
+	translate := func(address string) string {
+		if address == "10.0.0.1:9001" {
+			return server1.Address()
+		}
+		return address
+	}
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{ID: 1, Address: server1.Address()}})
+
+	connector := client.NewConnector(
+		store,
+		client.WithAddressTranslator(translate),
+		client.WithUpdateStore(true),
+		client.WithLogFunc(logging.Test(t)),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := connector.Connect(ctx)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, server1.Address(), leader.Address)
+
+	info1.Address = server1.Address()
+	nodes, err := cli.Cluster(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{info1, info2}, nodes)
+
+	nodes, err = store.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []client.NodeInfo{info1, info2}, nodes)
+}
+
+// WaitLeader retries until a leader is elected.
+func TestWaitLeader(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.SetLeader(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, []client.NodeInfo{{ID: 1, Address: server.Address()}}))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		server.SetLeader(&client.NodeInfo{ID: 1, Address: server.Address()})
+	}()
+
+	leader, err := client.WaitLeader(ctx, store, client.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), leader.ID)
+	assert.Equal(t, server.Address(), leader.Address)
+
+	// The context bounds the wait.
+	server.SetLeader(nil)
+	short, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = client.WaitLeader(short, store, client.WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	assert.Error(t, err)
+}
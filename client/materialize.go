@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// MaterializeToFile dumps the database with the given name and writes it to
+// the given path as a standalone SQLite database file, which can be opened
+// with any SQLite library.
+//
+// The dumped WAL is merged into the database file locally, by switching the
+// copy to the rollback journal mode, and the result is checked with PRAGMA
+// integrity_check. The files are written next to the given path first, and
+// the database file is renamed to it only once it's verified, so the path
+// either holds a complete copy or is left untouched.
+//
+// The dqlite server sends the whole dump in a single response, so the files
+// are held in memory while they are written out.
+func (c *Client) MaterializeToFile(ctx context.Context, dbname string, path string) error {
+	files, err := c.Dump(ctx, dbname)
+	if err != nil {
+		return err
+	}
+
+	var data, wal []byte
+	found := false
+	for _, file := range files {
+		switch file.Name {
+		case dbname:
+			data = file.Data
+			found = true
+		case dbname + "-wal":
+			wal = file.Data
+		}
+	}
+	if !found {
+		return errors.Errorf("dump of %q has no database file", dbname)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create database file")
+	}
+	name := tmp.Name()
+	defer removeDatabaseFiles(name)
+
+	if err := writeFile(tmp, data); err != nil {
+		return errors.Wrap(err, "failed to write database file")
+	}
+	if len(wal) > 0 {
+		f, err := os.OpenFile(name+"-wal", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return errors.Wrap(err, "failed to create WAL file")
+		}
+		if err := writeFile(f, wal); err != nil {
+			return errors.Wrap(err, "failed to write WAL file")
+		}
+	}
+
+	if err := mergeWAL(ctx, name); err != nil {
+		return err
+	}
+	if err := checkDatabaseFile(ctx, name); err != nil {
+		return err
+	}
+
+	if err := os.Rename(name, path); err != nil {
+		return errors.Wrap(err, "failed to rename database file")
+	}
+
+	return nil
+}
+
+// Write the given data to the file, sync it and close it.
+func writeFile(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Checkpoint the WAL of the database file at the given path and switch it to
+// the rollback journal mode, which makes SQLite remove the WAL.
+func mergeWAL(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open database file")
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode=DELETE").Scan(&mode); err != nil {
+		return errors.Wrap(err, "failed to merge WAL")
+	}
+	if mode != "delete" {
+		return errors.Errorf("failed to merge WAL: journal mode is still %s", mode)
+	}
+
+	return db.Close()
+}
+
+// Check that the database file at the given path opens cleanly.
+func checkDatabaseFile(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.Wrap(err, "failed to open database file")
+	}
+	defer db.Close()
+
+	var check string
+	if err := db.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&check); err != nil {
+		return errors.Wrap(err, "failed to check database file")
+	}
+	if check != "ok" {
+		return errors.Errorf("database file is corrupt: %s", check)
+	}
+
+	return nil
+}
+
+// Remove the database file at the given path, if it still exists, along with
+// its WAL and shared memory files.
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+}
@@ -0,0 +1,103 @@
+package client_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_MaterializeToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-materialize-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Leave the rows in the WAL, as in a dqlite dump.
+	source, err := sql.Open("sqlite3", filepath.Join(dir, "source.db"))
+	require.NoError(t, err)
+	defer source.Close()
+	source.SetMaxOpenConns(1)
+	_, err = source.Exec(`
+PRAGMA journal_mode=WAL;
+PRAGMA wal_autocheckpoint=0;
+CREATE TABLE t (n INT);
+INSERT INTO t VALUES (1), (2), (3);`)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "source.db"))
+	require.NoError(t, err)
+	wal, err := ioutil.ReadFile(filepath.Join(dir, "source.db-wal"))
+	require.NoError(t, err)
+	require.NotEmpty(t, wal)
+
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.HandleDump(func(database string) ([]client.File, error) {
+		return []client.File{
+			{Name: database, Data: data},
+			{Name: database + "-wal", Data: wal},
+		}, nil
+	})
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	path := filepath.Join(dir, "copy.db")
+	require.NoError(t, cli.MaterializeToFile(ctx, "test.db", path))
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.NotContains(t, names, "copy.db-wal")
+	assert.Contains(t, names, "copy.db")
+	assert.Len(t, names, 4) // copy.db plus the source database, WAL and shm
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var mode string
+	require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "delete", mode)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM t").Scan(&count))
+	assert.Equal(t, 3, count)
+}
+
+func TestClient_MaterializeToFileCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dqlite-materialize-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	server.HandleDump(func(database string) ([]client.File, error) {
+		return []client.File{{Name: database, Data: make([]byte, 4096)}}, nil
+	})
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// SQLite treats a file full of zeros as not a database.
+	path := filepath.Join(dir, "copy.db")
+	assert.Error(t, cli.MaterializeToFile(ctx, "test.db", path))
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
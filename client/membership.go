@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MembershipEventType identifies the kind of a membership change.
+type MembershipEventType int
+
+// Membership event types.
+const (
+	NodeAdded MembershipEventType = iota
+	NodeRemoved
+	NodeRoleChanged
+)
+
+func (t MembershipEventType) String() string {
+	switch t {
+	case NodeAdded:
+		return "added"
+	case NodeRemoved:
+		return "removed"
+	case NodeRoleChanged:
+		return "role changed"
+	default:
+		return "unknown event"
+	}
+}
+
+// MembershipEvent describes a change in the cluster membership.
+type MembershipEvent struct {
+	Type    MembershipEventType
+	Node    NodeInfo // The node as currently configured, or as last seen if removed.
+	OldRole NodeRole // Previous role of the node, for NodeRoleChanged events.
+}
+
+func (e MembershipEvent) String() string {
+	switch e.Type {
+	case NodeRoleChanged:
+		return fmt.Sprintf("node %d (%s) %s: %s -> %s", e.Node.ID, e.Node.Address, e.Type, e.OldRole, e.Node.Role)
+	default:
+		return fmt.Sprintf("node %d (%s) %s", e.Node.ID, e.Node.Address, e.Type)
+	}
+}
+
+// DefaultMembershipInterval is the polling interval used by
+// SubscribeMembership when none is given.
+const DefaultMembershipInterval = time.Second
+
+// SubscribeMembership returns a channel emitting an event every time a node is
+// added to the cluster, removed from it or assigned a different role.
+//
+// Changes are detected by fetching the cluster configuration every interval
+// (DefaultMembershipInterval if zero) and comparing it with the previous one,
+// so changes reverted within the same interval are not reported. The initial
+// configuration is fetched before returning and doesn't generate any event.
+//
+// The channel is closed when the given context is done, or when fetching the
+// configuration fails, for example because the connection was lost.
+func (c *Client) SubscribeMembership(ctx context.Context, interval time.Duration) (<-chan MembershipEvent, error) {
+	if interval == 0 {
+		interval = DefaultMembershipInterval
+	}
+
+	nodes, err := c.Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan MembershipEvent)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := c.Cluster(ctx)
+			if err != nil {
+				return
+			}
+
+			for _, event := range diffMembership(nodes, current) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			nodes = current
+		}
+	}()
+
+	return events, nil
+}
+
+// Return the events turning the old configuration into the new one, sorted by
+// node ID.
+func diffMembership(old, new []NodeInfo) []MembershipEvent {
+	previous := make(map[uint64]NodeInfo, len(old))
+	for _, node := range old {
+		previous[node.ID] = node
+	}
+
+	events := []MembershipEvent{}
+	for _, node := range new {
+		before, ok := previous[node.ID]
+		delete(previous, node.ID)
+		switch {
+		case !ok:
+			events = append(events, MembershipEvent{Type: NodeAdded, Node: node})
+		case before.Role != node.Role:
+			events = append(events, MembershipEvent{Type: NodeRoleChanged, Node: node, OldRole: before.Role})
+		}
+	}
+	for _, node := range previous {
+		events = append(events, MembershipEvent{Type: NodeRemoved, Node: node})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Node.ID < events[j].Node.ID
+	})
+
+	return events
+}
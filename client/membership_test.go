@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SubscribeMembership(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	node1 := client.NodeInfo{ID: 1, Address: server.Address(), Role: client.Voter}
+	node2 := client.NodeInfo{ID: 2, Address: "2", Role: client.Spare}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	subCtx, subCancel := context.WithCancel(ctx)
+	events, err := cli.SubscribeMembership(subCtx, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	server.SetCluster([]client.NodeInfo{node1, node2})
+
+	event := <-events
+	assert.Equal(t, client.MembershipEvent{Type: client.NodeAdded, Node: node2}, event)
+
+	node2.Role = client.Voter
+	server.SetCluster([]client.NodeInfo{node2})
+
+	event = <-events
+	assert.Equal(t, client.MembershipEvent{Type: client.NodeRemoved, Node: node1}, event)
+
+	event = <-events
+	assert.Equal(t, client.MembershipEvent{Type: client.NodeRoleChanged, Node: node2, OldRole: client.Spare}, event)
+	assert.Equal(t, "node 2 (2) role changed: spare -> voter", event.String())
+
+	subCancel()
+	for range events {
+	}
+}
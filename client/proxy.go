@@ -0,0 +1,340 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DialFuncWithProxy returns a dial function that connects to nodes through
+// the proxy with the given URL.
+//
+// Supported schemes are "socks5" and "socks5h" for SOCKS5 proxies, and
+// "http" and "https" for proxies supporting the HTTP CONNECT method. Node host
+// names are always resolved by the proxy. User credentials in the URL are used
+// to authenticate against the proxy.
+//
+// The given dial function is used to connect to the proxy.
+func DialFuncWithProxy(dial DialFunc, proxy *url.URL) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialProxy(ctx, dial, proxy, addr)
+	}
+}
+
+// DialFuncWithProxyFromEnvironment returns a dial function that connects to
+// nodes through the proxy configured in the environment, if any.
+//
+// The ALL_PROXY variable is honored first, then HTTPS_PROXY, as well as their
+// lowercase versions. Nodes matching the NO_PROXY variable are connected to
+// directly. The environment is evaluated on every dial.
+//
+// The given dial function is used to connect either to the proxy or, if no
+// proxy is configured for a node, to the node itself.
+func DialFuncWithProxyFromEnvironment(dial DialFunc) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		proxy, err := proxyFromEnvironment(addr)
+		if err != nil {
+			return nil, err
+		}
+		if proxy == nil {
+			return dial(ctx, addr)
+		}
+		return dialProxy(ctx, dial, proxy, addr)
+	}
+}
+
+// Return the proxy to use for the given node address, or nil.
+func proxyFromEnvironment(addr string) (*url.URL, error) {
+	value := ""
+	for _, name := range []string{"ALL_PROXY", "all_proxy", "HTTPS_PROXY", "https_proxy"} {
+		if value = os.Getenv(name); value != "" {
+			break
+		}
+	}
+	if value == "" || noProxy(addr) {
+		return nil, nil
+	}
+
+	proxy, err := url.Parse(value)
+	if err != nil || proxy.Scheme == "" || proxy.Host == "" {
+		// Like curl, assume a plain "host:port" is an HTTP proxy.
+		proxy, err = url.Parse("http://" + value)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy environment")
+		}
+	}
+
+	return proxy, nil
+}
+
+// Check whether the given address matches the NO_PROXY environment variable.
+func noProxy(addr string) bool {
+	value := os.Getenv("NO_PROXY")
+	if value == "" {
+		value = os.Getenv("no_proxy")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if ip != nil {
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		if h := strings.ToLower(host); h == entry || strings.HasSuffix(h, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Establish a tunnel to the given address through the given proxy.
+func dialProxy(ctx context.Context, dial DialFunc, proxy *url.URL, addr string) (net.Conn, error) {
+	port := proxy.Port()
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		if port == "" {
+			port = "1080"
+		}
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+
+	conn, err := dial(ctx, net.JoinHostPort(proxy.Hostname(), port))
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to proxy")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	switch proxy.Scheme {
+	case "socks5", "socks5h":
+		err = socks5Connect(conn, proxy.User, addr)
+	case "https":
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+		conn = tlsConn
+		if err = tlsConn.Handshake(); err == nil {
+			conn, err = httpConnect(conn, proxy.User, addr)
+		}
+	default:
+		conn, err = httpConnect(conn, proxy.User, addr)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "proxy %s", proxy.Host)
+	}
+
+	return conn, nil
+}
+
+// Perform a SOCKS5 CONNECT handshake (RFC 1928), possibly using
+// username/password authentication (RFC 1929).
+func socks5Connect(conn net.Conn, user *url.Userinfo, addr string) error {
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return errors.Wrap(err, "invalid port")
+	}
+
+	const (
+		version    = 5
+		methodNone = 0
+		methodPass = 2
+	)
+
+	methods := []byte{methodNone}
+	if user != nil {
+		methods = []byte{methodPass}
+	}
+	greeting := append([]byte{version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != version {
+		return fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case methodNone:
+	case methodPass:
+		password, _ := user.Password()
+		username := user.Username()
+		if len(username) > 255 || len(password) > 255 {
+			return fmt.Errorf("SOCKS credentials too long")
+		}
+		auth := []byte{1, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("SOCKS authentication failed")
+		}
+	default:
+		return fmt.Errorf("no acceptable SOCKS authentication method")
+	}
+
+	request := []byte{version, 1, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(request, 1)
+			request = append(request, ip4...)
+		} else {
+			request = append(request, 4)
+			request = append(request, ip...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name too long")
+		}
+		request = append(request, 3, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = append(request, 0, 0)
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0 {
+		return fmt.Errorf("SOCKS connect failed with code %d", header[1])
+	}
+
+	// Skip the bound address and port.
+	var size int
+	switch header[3] {
+	case 1:
+		size = net.IPv4len
+	case 4:
+		size = net.IPv6len
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		size = int(length[0])
+	default:
+		return fmt.Errorf("unexpected SOCKS address type %d", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, size+2)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Perform an HTTP CONNECT handshake.
+func httpConnect(conn net.Conn, user *url.Userinfo, addr string) (net.Conn, error) {
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := request.Write(conn); err != nil {
+		return conn, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return conn, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return conn, fmt.Errorf("HTTP CONNECT failed: %s", response.Status)
+	}
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+
+	return conn, nil
+}
+
+// Connection whose initial data was already buffered.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialFuncWithProxy_SOCKS5(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	proxy, _ := newProxy(t, serveSOCKS5)
+	defer proxy.Close()
+
+	for _, user := range []*url.Userinfo{nil, url.UserPassword("user", "pass")} {
+		proxyURL := &url.URL{Scheme: "socks5", Host: proxy.Addr().String(), User: user}
+		dial := client.DialFuncWithProxy(client.DefaultDialFunc, proxyURL)
+		assertLeader(t, server.Address(), client.WithDialFunc(dial))
+	}
+}
+
+func TestDialFuncWithProxy_HTTPConnect(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	proxy, _ := newProxy(t, serveHTTPConnect)
+	defer proxy.Close()
+
+	proxyURL := &url.URL{Scheme: "http", Host: proxy.Addr().String(), User: url.UserPassword("user", "pass")}
+	dial := client.DialFuncWithProxy(client.DefaultDialFunc, proxyURL)
+	assertLeader(t, server.Address(), client.WithDialFunc(dial))
+}
+
+func TestDialFuncWithProxyFromEnvironment(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	proxy, count := newProxy(t, serveSOCKS5)
+	defer proxy.Close()
+
+	defer setenv(t, "ALL_PROXY", "socks5://"+proxy.Addr().String())()
+	defer setenv(t, "NO_PROXY", "")()
+
+	dial := client.DialFuncWithProxyFromEnvironment(client.DefaultDialFunc)
+	assertLeader(t, server.Address(), client.WithDialFunc(dial))
+	assert.Equal(t, int32(1), atomic.LoadInt32(count))
+
+	// Excluded addresses are dialed directly.
+	os.Setenv("NO_PROXY", "example.com,127.0.0.0/8")
+	assertLeader(t, server.Address(), client.WithDialFunc(dial))
+	assert.Equal(t, int32(1), atomic.LoadInt32(count))
+}
+
+// Connect to the given address and check that it reports itself as leader.
+func assertLeader(t *testing.T, address string, options ...client.Option) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, address, options...)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, address, leader.Address)
+}
+
+// Start a proxy serving each connection with the given function. Return the
+// listener and a counter of accepted connections.
+func newProxy(t *testing.T, serve func(net.Conn) (net.Conn, error)) (net.Listener, *int32) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	count := new(int32)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(count, 1)
+			go func() {
+				defer conn.Close()
+				target, err := serve(conn)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return listener, count
+}
+
+// Minimal SOCKS5 server, supporting IPv4 and domain name targets.
+func serveSOCKS5(conn net.Conn) (net.Conn, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	conn.Write([]byte{5, methods[0]})
+
+	if methods[0] == 2 {
+		buf := make([]byte, 2)
+		io.ReadFull(conn, buf)
+		io.ReadFull(conn, make([]byte, buf[1]))
+		io.ReadFull(conn, buf[:1])
+		io.ReadFull(conn, make([]byte, buf[0]))
+		conn.Write([]byte{1, 0})
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		size := make([]byte, 1)
+		io.ReadFull(conn, size)
+		name := make([]byte, size[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	}
+	port := make([]byte, 2)
+	io.ReadFull(conn, port)
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	return target, nil
+}
+
+// Minimal HTTP CONNECT proxy.
+func serveHTTPConnect(conn net.Conn) (net.Conn, error) {
+	request, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return nil, err
+	}
+	if request.Method != http.MethodConnect || request.Header.Get("Proxy-Authorization") == "" {
+		conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\n\r\n"))
+		return nil, io.EOF
+	}
+	target, err := net.Dial("tcp", request.Host)
+	if err != nil {
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+		return nil, err
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	return target, nil
+}
+
+// Set an environment variable, returning a function restoring it.
+func setenv(t *testing.T, name, value string) func() {
+	old, ok := os.LookupEnv(name)
+	require.NoError(t, os.Setenv(name, value))
+	return func() {
+		if ok {
+			os.Setenv(name, old)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// WithDatabase makes new clients open the database with the given name as
+// soon as they connect, so that Exec and Query can be used on it.
+//
+// The database is opened on every client created with the option, so clients
+// returned by FindLeader or Connector.Connect after a leader change have it
+// open again.
+func WithDatabase(name string) Option {
+	return func(options *options) {
+		options.Database = name
+	}
+}
+
+// Create a client using the given protocol connection, opening the database
+// set with WithDatabase, if any.
+func (o *options) newClient(ctx context.Context, p *protocol.Protocol) (*Client, error) {
+	client := &Client{protocol: p, execWindow: o.ExecWindow, translate: o.Translate}
+
+	if o.Database != "" {
+		if _, err := client.openDatabase(ctx, o.Database); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+// Exec executes the given statement against the database opened on the
+// client connection, see WithDatabase. This must be invoked on a client
+// connected to the current leader.
+func (c *Client) Exec(ctx context.Context, sql string, args ...interface{}) (ExecResult, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return ExecResult{}, err
+	}
+	defer done()
+
+	values, err := namedValues(args)
+	if err != nil {
+		return ExecResult{}, err
+	}
+	if c.database == nil {
+		return ExecResult{}, errors.New("no database open on the client connection")
+	}
+
+	request := protocol.Message{}
+	request.Init(4096)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	protocol.EncodeExecSQL(&request, uint64(c.database.id), sql, values)
+
+	if err := c.protocol.Call(ctx, &request, &response); err != nil {
+		return ExecResult{}, errors.Wrap(err, "failed to send ExecSQL request")
+	}
+
+	result, err := protocol.DecodeResult(&response)
+	if err != nil {
+		return ExecResult{}, errors.Wrap(err, "failed to execute statement")
+	}
+
+	return ExecResult{LastInsertID: result.LastInsertID, RowsAffected: result.RowsAffected}, nil
+}
+
+// Query runs the given query against the database opened on the client
+// connection, see WithDatabase, and returns the names of its columns and all
+// its rows. This must be invoked on a client connected to the current leader.
+func (c *Client) Query(ctx context.Context, sql string, args ...interface{}) ([]string, [][]driver.Value, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.database == nil {
+		return nil, nil, errors.New("no database open on the client connection")
+	}
+
+	request := protocol.Message{}
+	request.Init(4096)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	protocol.EncodeQuerySQL(&request, uint64(c.database.id), sql, values)
+
+	if err := c.protocol.Call(ctx, &request, &response); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to send QuerySQL request")
+	}
+
+	rows, err := protocol.DecodeRows(&response)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to run query")
+	}
+	defer rows.Close()
+
+	columns := append([]string{}, rows.Columns...)
+	result := [][]driver.Value{}
+	for {
+		row := make([]driver.Value, len(columns))
+		err := rows.Next(row)
+
+		// Fetch the next part of the result set, which might be empty.
+		for err == protocol.ErrRowsPart {
+			rows.Close()
+			if err := c.protocol.More(ctx, &response); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to fetch rows")
+			}
+			if rows, err = protocol.DecodeRows(&response); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to fetch rows")
+			}
+			err = rows.Next(row)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Stop the server from sending the rest of the result
+			// set, so the connection can still be used.
+			c.interrupt(ctx)
+			return nil, nil, errors.Wrap(err, "failed to read row")
+		}
+
+		// BLOBs point into the response buffer.
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[i] = append([]byte{}, b...)
+			}
+		}
+		result = append(result, row)
+	}
+
+	return columns, result, nil
+}
+
+// Interrupt stops the query whose result set is being streamed on the client
+// connection, if any, and discards the responses still pending, so that the
+// connection can be used again without reconnecting.
+//
+// The dqlite server handles one request at a time on each connection, so this
+// must not be invoked concurrently with other methods of the client.
+func (c *Client) Interrupt(ctx context.Context) error {
+	done, err := c.track()
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	return c.interrupt(ctx)
+}
+
+// Interrupt the current query, as part of a request already registered.
+func (c *Client) interrupt(ctx context.Context) error {
+	request := protocol.Message{}
+	request.Init(16)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	if err := c.protocol.Interrupt(ctx, &request, &response); err != nil {
+		return errors.Wrap(err, "failed to interrupt query")
+	}
+
+	return nil
+}
+
+// Convert statement arguments to driver values.
+func namedValues(args []interface{}) (protocol.NamedValues, error) {
+	values := make(protocol.NamedValues, len(args))
+	for i, arg := range args {
+		value, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i+1)
+		}
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return values, nil
+}
@@ -0,0 +1,116 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDatabase(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	databases := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		databases = append(databases, database)
+		return clienttest.Result{LastInsertID: 3, RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		databases = append(databases, database)
+		return &clienttest.Rows{
+			Columns: []string{"n", "b"},
+			Values:  [][]driver.Value{{args[0], []byte("x")}, {int64(2), nil}},
+		}, nil
+	})
+
+	ctx := context.Background()
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, []client.NodeInfo{{ID: 1, Address: server.Address()}}))
+
+	cli, err := client.FindLeader(ctx, store, client.WithDatabase("test.db"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	result, err := cli.Exec(ctx, "INSERT INTO t VALUES(?)", 1)
+	require.NoError(t, err)
+	assert.Equal(t, client.ExecResult{LastInsertID: 3, RowsAffected: 1}, result)
+
+	columns, rows, err := cli.Query(ctx, "SELECT n, b FROM t WHERE n >= ?", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n", "b"}, columns)
+	assert.Equal(t, [][]driver.Value{{int64(1), []byte("x")}, {int64(2), nil}}, rows)
+
+	mu.Lock()
+	assert.Equal(t, []string{"test.db", "test.db"}, databases)
+	mu.Unlock()
+}
+
+// Exec and Query fail if no database was opened.
+func TestClient_Exec_NoDatabase(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.Exec(ctx, "INSERT INTO t VALUES(1)")
+	assert.EqualError(t, err, "no database open on the client connection")
+
+	_, _, err = cli.Query(ctx, "SELECT 1")
+	assert.EqualError(t, err, "no database open on the client connection")
+}
+
+// Interrupting a query discards the rest of its result set, and the
+// connection can be used again.
+func TestClient_Interrupt(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns:  []string{"n"},
+			Values:   [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}},
+			PageSize: 1,
+		}, nil
+	})
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address(), client.WithDatabase("test.db"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// Read only the first part of the result set.
+	request := protocol.Message{}
+	request.Init(4096)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	protocol.EncodeQuerySQL(&request, 0, "SELECT n FROM t", nil)
+	require.NoError(t, cli.Protocol().Call(ctx, &request, &response))
+	rows, err := protocol.DecodeRows(&response)
+	require.NoError(t, err)
+	rows.Close()
+
+	require.NoError(t, cli.Interrupt(ctx))
+
+	_, values, err := cli.Query(ctx, "SELECT n FROM t")
+	require.NoError(t, err)
+	assert.Equal(t, [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}, values)
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
+)
+
+// RequestBody builds the body of a raw request sent with Client.Call,
+// following the encoding rules of the dqlite wire protocol.
+type RequestBody struct {
+	buf []byte
+}
+
+// PutUint8 appends a single byte.
+func (b *RequestBody) PutUint8(v uint8) {
+	b.buf = append(b.buf, v)
+}
+
+// PutUint32 appends a 32-bit integer.
+func (b *RequestBody) PutUint32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+// PutUint64 appends a 64-bit integer.
+func (b *RequestBody) PutUint64(v uint64) {
+	b.buf = append(b.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(b.buf[len(b.buf)-8:], v)
+}
+
+// PutInt64 appends a signed 64-bit integer.
+func (b *RequestBody) PutInt64(v int64) {
+	b.PutUint64(uint64(v))
+}
+
+// PutFloat64 appends a floating point number.
+func (b *RequestBody) PutFloat64(v float64) {
+	b.PutUint64(math.Float64bits(v))
+}
+
+// PutString appends a nul-terminated string, padded to the word size.
+func (b *RequestBody) PutString(v string) {
+	b.buf = append(b.buf, v...)
+	b.buf = append(b.buf, 0)
+	b.pad()
+}
+
+// PutBlob appends a blob prefixed with its length, padded to the word size.
+func (b *RequestBody) PutBlob(v []byte) {
+	b.PutUint64(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+	b.pad()
+}
+
+// Bytes returns the body, padded to the word size.
+func (b *RequestBody) Bytes() []byte {
+	b.pad()
+	return b.buf
+}
+
+func (b *RequestBody) pad() {
+	for len(b.buf)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// ResponseBody decodes the body of a raw response returned by Client.Call.
+//
+// Once a read fails because the body is too short, all subsequent reads
+// return zero values and Err returns the failure.
+type ResponseBody struct {
+	buf    []byte
+	offset int
+	err    error
+}
+
+// Len returns the number of bytes not read yet.
+func (b *ResponseBody) Len() int {
+	return len(b.buf) - b.offset
+}
+
+// Err returns the error of the first read that failed, if any.
+func (b *ResponseBody) Err() error {
+	return b.err
+}
+
+// Uint8 reads a single byte.
+func (b *ResponseBody) Uint8() uint8 {
+	v := b.consume(1)
+	if v == nil {
+		return 0
+	}
+	return v[0]
+}
+
+// Uint32 reads a 32-bit integer.
+func (b *ResponseBody) Uint32() uint32 {
+	v := b.consume(4)
+	if v == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(v)
+}
+
+// Uint64 reads a 64-bit integer.
+func (b *ResponseBody) Uint64() uint64 {
+	v := b.consume(8)
+	if v == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(v)
+}
+
+// Int64 reads a signed 64-bit integer.
+func (b *ResponseBody) Int64() int64 {
+	return int64(b.Uint64())
+}
+
+// Float64 reads a floating point number.
+func (b *ResponseBody) Float64() float64 {
+	return math.Float64frombits(b.Uint64())
+}
+
+// String reads a nul-terminated string and its padding.
+func (b *ResponseBody) String() string {
+	if b.err != nil {
+		return ""
+	}
+	index := bytes.IndexByte(b.buf[b.offset:], 0)
+	if index == -1 {
+		b.fail("string is not nul-terminated")
+		return ""
+	}
+	v := string(b.buf[b.offset : b.offset+index])
+	b.consume(padded(index + 1))
+	return v
+}
+
+// Blob reads a blob prefixed with its length, and its padding.
+func (b *ResponseBody) Blob() []byte {
+	size := b.Uint64()
+	if b.err != nil {
+		return nil
+	}
+	if size > uint64(b.Len()) {
+		b.fail("blob size %d exceeds remaining %d bytes", size, b.Len())
+		return nil
+	}
+	v := make([]byte, size)
+	copy(v, b.consume(padded(int(size))))
+	return v
+}
+
+func (b *ResponseBody) consume(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n > b.Len() {
+		b.fail("short body: off=%d need=%d", b.offset, n)
+		return nil
+	}
+	v := b.buf[b.offset : b.offset+n]
+	b.offset += n
+	return v
+}
+
+func (b *ResponseBody) fail(format string, a ...interface{}) {
+	b.err = errors.Wrapf(ErrMalformedMessage, format, a...)
+}
+
+// Round the given size up to the word size.
+func padded(n int) int {
+	if n%8 != 0 {
+		n += 8 - n%8
+	}
+	return n
+}
+
+// Call sends a raw request of the given type and returns the type and body of
+// the response, giving access to server RPCs that are not covered by the
+// rest of the API.
+//
+// The body must be a non-empty multiple of 8 bytes, as built by RequestBody.
+// If the node replies with a failure response, the returned error holds its
+// code and description. Only requests answered by a single response are
+// supported.
+func (c *Client) Call(ctx context.Context, mtype uint8, body []byte) (uint8, *ResponseBody, error) {
+	if len(body) == 0 || len(body)%8 != 0 {
+		return 0, nil, errors.Errorf("request body size %d is not a positive multiple of 8", len(body))
+	}
+
+	done, err := c.begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer done()
+
+	request := protocol.Message{}
+	request.Init(len(body))
+	response := protocol.Message{}
+	response.Init(4096)
+
+	protocol.EncodeRaw(&request, mtype, body)
+
+	if err := c.protocol.Call(ctx, &request, &response); err != nil {
+		return 0, nil, errors.Wrap(err, "failed to send request")
+	}
+
+	rtype, data, err := protocol.DecodeRaw(&response)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return rtype, &ResponseBody{buf: data}, nil
+}
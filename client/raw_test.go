@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Call(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+	info := client.NodeInfo{ID: 1, Address: server.Address()}
+	server.SetLeader(&info)
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// Leader request.
+	body := client.RequestBody{}
+	body.PutUint64(0)
+	mtype, response, err := cli.Call(ctx, 0, body.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, uint8(1), mtype)
+	assert.Equal(t, uint64(1), response.Uint64())
+	assert.Equal(t, server.Address(), response.String())
+	assert.NoError(t, response.Err())
+	assert.Equal(t, 0, response.Len())
+
+	// Weight request.
+	body = client.RequestBody{}
+	body.PutUint64(5)
+	_, _, err = cli.Call(ctx, 19, body.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), server.Weight())
+
+	// Reading past the end of the body.
+	response.Uint64()
+	assert.True(t, errors.Is(response.Err(), client.ErrMalformedMessage))
+	assert.Equal(t, "", response.String())
+}
+
+func TestClient_CallFailure(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	body := client.RequestBody{}
+	body.PutString("hello")
+	_, _, err = cli.Call(ctx, 200, body.Bytes())
+	var e client.Error
+	require.True(t, errors.As(err, &e), err)
+	assert.Equal(t, "unrecognized request type", e.Message)
+
+	_, _, err = cli.Call(ctx, 0, []byte{1, 2, 3})
+	assert.EqualError(t, err, "request body size 3 is not a positive multiple of 8")
+}
+
+func TestRequestBody(t *testing.T) {
+	body := client.RequestBody{}
+	body.PutUint8(1)
+	body.PutString("abc")
+	body.PutBlob([]byte{9})
+	assert.Equal(t, []byte{
+		1, 'a', 'b', 'c', 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		9, 0, 0, 0, 0, 0, 0, 0,
+	}, body.Bytes())
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RestartFunc restarts the given node, returning once the node has been
+// started again, or the restart failed.
+type RestartFunc func(ctx context.Context, node NodeInfo) error
+
+// ErrQuorumAtRisk is returned by RollingRestart when restarting the next node
+// would leave the cluster without a majority of online voters.
+var ErrQuorumAtRisk = errors.New("restarting node would lose quorum")
+
+// RollingRestartPollInterval is how often RollingRestart checks whether a
+// restarted node is back.
+var RollingRestartPollInterval = 250 * time.Millisecond
+
+// RollingRestart restarts the given nodes one at a time using the given
+// function, restarting the current leader last.
+//
+// Before each restart, the voters of the cluster are probed and the restart is
+// aborted with ErrQuorumAtRisk if the voters left online while the node is
+// down would not be a majority. Leadership is transferred away from the
+// leader before restarting it.
+//
+// After each restart, RollingRestart waits for the node to come back before
+// moving on to the next one. The dqlite wire protocol doesn't expose the
+// applied index of a node, so a node is considered caught up once it accepts
+// connections and knows the current leader.
+//
+// The given options are used for all connections to the nodes.
+func RollingRestart(ctx context.Context, nodes []NodeInfo, restart RestartFunc, options ...Option) error {
+	store := NewInmemNodeStore()
+	if err := store.Set(ctx, nodes); err != nil {
+		return err
+	}
+
+	leader, err := findLeaderInfo(ctx, store, options)
+	if err != nil {
+		return err
+	}
+
+	// Restart the leader last.
+	ordered := make([]NodeInfo, 0, len(nodes))
+	var last []NodeInfo
+	for _, node := range nodes {
+		if node.ID == leader.ID {
+			last = append(last, node)
+			continue
+		}
+		ordered = append(ordered, node)
+	}
+	ordered = append(ordered, last...)
+
+	for _, node := range ordered {
+		if err := restartNode(ctx, store, node, restart, options); err != nil {
+			return errors.Wrapf(err, "restart node %d (%s)", node.ID, node.Address)
+		}
+	}
+
+	return nil
+}
+
+// Restart a single node and wait for it to come back.
+func restartNode(ctx context.Context, store NodeStore, node NodeInfo, restart RestartFunc, options []Option) error {
+	cli, err := FindLeader(ctx, store, options...)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	cluster, err := cli.Cluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := checkQuorum(ctx, cluster, node, options); err != nil {
+		return err
+	}
+
+	leader, err := cli.Leader(ctx)
+	if err != nil {
+		return err
+	}
+	if leader.ID == node.ID {
+		if err := cli.Transfer(ctx, 0); err != nil {
+			return errors.Wrap(err, "transfer leadership")
+		}
+	}
+
+	if err := restart(ctx, node); err != nil {
+		return err
+	}
+
+	return waitNodeOnline(ctx, node, options)
+}
+
+// Fail with ErrQuorumAtRisk if the voters other than the given node that are
+// online are not a majority of the voters.
+func checkQuorum(ctx context.Context, cluster []NodeInfo, node NodeInfo, options []Option) error {
+	for _, other := range cluster {
+		if other.ID != node.ID || other.Role != Voter {
+			continue
+		}
+		voters, online := countOnlineVoters(ctx, cluster, node.ID, options)
+		if online < voters/2+1 {
+			return ErrQuorumAtRisk
+		}
+	}
+
+	return nil
+}
+
+// Wait for the given node to accept connections and know the leader.
+func waitNodeOnline(ctx context.Context, node NodeInfo, options []Option) error {
+	ticker := time.NewTicker(RollingRestartPollInterval)
+	defer ticker.Stop()
+
+	for !nodeOnline(ctx, node, options) {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "wait for node to come back")
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+// Return the information about the current leader.
+func findLeaderInfo(ctx context.Context, store NodeStore, options []Option) (*NodeInfo, error) {
+	cli, err := FindLeader(ctx, store, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	return cli.Leader(ctx)
+}
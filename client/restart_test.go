@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Create a cluster of three voters, with the first one being the leader.
+func newRestartCluster(t *testing.T) ([]*clienttest.Server, []client.NodeInfo) {
+	servers := make([]*clienttest.Server, 3)
+	nodes := make([]client.NodeInfo, 3)
+	for i := range servers {
+		server := clienttest.NewServer(uint64(i + 1))
+		t.Cleanup(func() { server.Close() })
+		servers[i] = server
+		nodes[i] = client.NodeInfo{ID: uint64(i + 1), Address: server.Address(), Role: client.Voter}
+	}
+	for _, server := range servers {
+		server.SetLeader(&nodes[0])
+		server.SetCluster(nodes)
+	}
+	return servers, nodes
+}
+
+// Nodes are restarted one at a time, with the leader last.
+func TestRollingRestart(t *testing.T) {
+	_, nodes := newRestartCluster(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	restarted := []uint64{}
+	restart := func(ctx context.Context, node client.NodeInfo) error {
+		restarted = append(restarted, node.ID)
+		return nil
+	}
+
+	require.NoError(t, client.RollingRestart(ctx, nodes, restart))
+	assert.Equal(t, []uint64{2, 3, 1}, restarted)
+}
+
+// The restart is aborted if it would leave too few voters online.
+func TestRollingRestart_QuorumAtRisk(t *testing.T) {
+	servers, nodes := newRestartCluster(t)
+	servers[2].Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	restarted := []uint64{}
+	restart := func(ctx context.Context, node client.NodeInfo) error {
+		restarted = append(restarted, node.ID)
+		return nil
+	}
+
+	err := client.RollingRestart(ctx, nodes, restart)
+	assert.True(t, errors.Is(err, client.ErrQuorumAtRisk))
+	assert.Empty(t, restarted)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrShutdown is returned by the methods of a client invoked after Shutdown.
+var ErrShutdown = errors.New("client is shutting down")
+
+// Shutdown gracefully closes the client.
+//
+// New requests are refused with ErrShutdown right away. The statements queued
+// with ExecAsync are sent, so that their futures resolve, and the requests in
+// flight are awaited, including queries whose result set is still being
+// streamed. Only then the connection is closed.
+//
+// If the context is done before all requests complete, the connection is
+// closed anyway, which makes the pending requests fail, and the context error
+// is returned. Operations made of several requests, such as ImportSQLite, are
+// stopped between two of them.
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.lifeMu.Lock()
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+		if c.inflight == 0 {
+			close(c.idle)
+		}
+	}
+	idle := c.idle
+	c.lifeMu.Unlock()
+
+	c.execMu.Lock()
+	err := c.flushExec(ctx)
+	c.execMu.Unlock()
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		c.close()
+		return errors.Wrap(ctx.Err(), "requests still in flight")
+	}
+
+	if e := c.close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// Register a request, failing if the client is shutting down, and send the
+// statements queued with ExecAsync, so that the request doesn't overtake
+// them. The returned function must be invoked once the request completes,
+// after its response was fully read.
+//
+// Failures of the queued statements are reported by their futures.
+func (c *Client) begin(ctx context.Context) (func(), error) {
+	done, err := c.track()
+	if err != nil {
+		return nil, err
+	}
+
+	c.execMu.Lock()
+	c.flushExec(ctx)
+	c.execMu.Unlock()
+
+	return done, nil
+}
+
+// Register a request like begin, without sending the queued statements.
+func (c *Client) track() (func(), error) {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+
+	if c.idle != nil {
+		return nil, ErrShutdown
+	}
+	c.inflight++
+
+	return c.end, nil
+}
+
+func (c *Client) end() {
+	c.lifeMu.Lock()
+	defer c.lifeMu.Unlock()
+
+	c.inflight--
+	if c.inflight == 0 && c.idle != nil {
+		close(c.idle)
+	}
+}
@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Start a server whose queries block until the returned channel is closed,
+// and a client connected to it. The entered channel receives a value when a
+// query starts.
+func newBlockingServer(t *testing.T) (*clienttest.Server, *client.Client, chan struct{}, chan struct{}) {
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+
+	server := clienttest.NewServer(1)
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		entered <- struct{}{}
+		<-release
+		return &clienttest.Rows{
+			Columns:  []string{"n"},
+			Values:   [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}},
+			PageSize: 1,
+		}, nil
+	})
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	cli, err := client.New(context.Background(), server.Address(), client.WithDatabase("test.db"))
+	require.NoError(t, err)
+
+	return server, cli, entered, release
+}
+
+func TestClient_ShutdownDrainsInFlight(t *testing.T) {
+	server, cli, entered, release := newBlockingServer(t)
+	defer server.Close()
+	defer cli.Close()
+
+	ctx := context.Background()
+	type result struct {
+		rows [][]driver.Value
+		err  error
+	}
+	queried := make(chan result, 1)
+	go func() {
+		_, rows, err := cli.Query(ctx, "SELECT n FROM test")
+		queried <- result{rows, err}
+	}()
+	<-entered
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- cli.Shutdown(ctx) }()
+
+	for !cli.ShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+	_, err := cli.Exec(ctx, "INSERT INTO test VALUES (1)")
+	assert.Equal(t, client.ErrShutdown, err)
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown didn't wait for the query")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	r := <-queried
+	require.NoError(t, r.err)
+	assert.Len(t, r.rows, 3)
+	assert.NoError(t, <-shutdown)
+}
+
+func TestClient_ShutdownTimeout(t *testing.T) {
+	server, cli, entered, release := newBlockingServer(t)
+	defer server.Close()
+	defer close(release)
+	defer cli.Close()
+
+	queried := make(chan error, 1)
+	go func() {
+		_, _, err := cli.Query(context.Background(), "SELECT n FROM test")
+		queried <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := cli.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Error(t, <-queried)
+}
+
+func TestClient_ShutdownFlushesExecAsync(t *testing.T) {
+	server, cli, _, _ := newBlockingServer(t)
+	defer server.Close()
+	defer cli.Close()
+
+	ctx := context.Background()
+	future, err := cli.ExecAsync(ctx, "test.db", "INSERT INTO test VALUES (1)")
+	require.NoError(t, err)
+
+	require.NoError(t, cli.Shutdown(ctx))
+
+	result, err := future.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), result.RowsAffected)
+}
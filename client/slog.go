@@ -0,0 +1,40 @@
+//go:build go1.21
+// +build go1.21
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// NewSlogFunc returns a LogFunc emitting messages through the given slog
+// logger, mapping each LogLevel to the corresponding slog level.
+//
+// Messages are formatted as with fmt.Sprintf. Attributes shared by all
+// messages, such as the address of the node the client connects to, can be
+// set on the logger with slog.Logger.With.
+func NewSlogFunc(logger *slog.Logger) LogFunc {
+	return func(l LogLevel, format string, a ...interface{}) {
+		var level slog.Level
+		switch l {
+		case LogDebug:
+			level = slog.LevelDebug
+		case LogInfo:
+			level = slog.LevelInfo
+		case LogWarn:
+			level = slog.LevelWarn
+		case LogError:
+			level = slog.LevelError
+		default:
+			return
+		}
+
+		ctx := context.Background()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+		logger.Log(ctx, level, fmt.Sprintf(format, a...))
+	}
+}
@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package client_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlogFunc(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	log := client.NewSlogFunc(slog.New(handler).With("node", "1.2.3.4:9001"))
+
+	log(client.LogDebug, "not %s", "emitted")
+	log(client.LogWarn, "leader changed to %s", "1.2.3.5:9001")
+	log(client.LogNone, "never emitted")
+
+	assert.Equal(t, "level=WARN msg=\"leader changed to 1.2.3.5:9001\" node=1.2.3.4:9001\n", buf.String())
+}
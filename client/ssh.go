@@ -0,0 +1,218 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SSHConfig holds the parameters of an SSH tunnel. See DialFuncWithSSH.
+type SSHConfig struct {
+	Host    string   // SSH server to connect to, either "host" or "host:port".
+	User    string   // Remote user name, if not the default one.
+	KeyFile string   // Private key file, if not the default one.
+	Options []string // Additional ssh options, in the "Name=value" form.
+	Command string   // SSH client executable, by default "ssh".
+}
+
+// DialFuncWithSSH returns a dial function that tunnels connections through an
+// SSH server, so nodes on private networks can be reached with the regular
+// client API.
+//
+// Each connection spawns the system ssh client in stdio forwarding mode (the
+// -W option), so the usual ssh configuration files, known hosts and agent are
+// honored. Password prompts are disabled: authentication must happen through
+// keys or the agent.
+//
+// Node addresses must be in the "host:port" form, and are resolved by the
+// SSH server.
+func DialFuncWithSSH(config SSHConfig) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, errors.Wrap(err, "invalid node address")
+		}
+
+		command := config.Command
+		if command == "" {
+			command = "ssh"
+		}
+
+		args := []string{"-W", addr, "-o", "BatchMode=yes"}
+		host := config.Host
+		if h, port, err := net.SplitHostPort(config.Host); err == nil {
+			host = h
+			args = append(args, "-p", port)
+		}
+		if config.User != "" {
+			args = append(args, "-l", config.User)
+		}
+		if config.KeyFile != "" {
+			args = append(args, "-i", config.KeyFile)
+		}
+		for _, option := range config.Options {
+			args = append(args, "-o", option)
+		}
+		args = append(args, "--", host)
+
+		return dialCommand(ctx, addr, command, args...)
+	}
+}
+
+// Spawn the given command and return a connection reading from its standard
+// output and writing to its standard input.
+func dialCommand(ctx context.Context, addr string, name string, args ...string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "create stdin pipe")
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, errors.Wrap(err, "create stdout pipe")
+	}
+
+	conn := &commandConn{
+		addr:   commandAddr(addr),
+		stdin:  stdinW,
+		stdout: stdoutR,
+	}
+
+	conn.cmd = exec.Command(name, args...)
+	conn.cmd.Stdin = stdinR
+	conn.cmd.Stdout = stdoutW
+	conn.cmd.Stderr = &conn.stderr
+
+	err = conn.cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, errors.Wrapf(err, "start %s", name)
+	}
+
+	conn.exited = make(chan struct{})
+	go func() {
+		conn.cmd.Wait()
+		close(conn.exited)
+	}()
+
+	return conn, nil
+}
+
+// Connection backed by the standard input and output of a command.
+type commandConn struct {
+	addr   commandAddr
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	stderr limitedBuffer
+	exited chan struct{}
+	once   sync.Once
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	n, err := c.stdout.Read(b)
+	if err == io.EOF {
+		// Give the command a chance to exit, so its diagnostics are
+		// available.
+		select {
+		case <-c.exited:
+		case <-time.After(time.Second):
+		}
+	}
+	if err != nil {
+		err = c.opError("read", err)
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	n, err := c.stdin.Write(b)
+	if err != nil {
+		err = c.opError("write", err)
+	}
+	return n, err
+}
+
+// Wrap the given error, including the command diagnostics, if any.
+func (c *commandConn) opError(op string, err error) error {
+	if timeout, ok := err.(interface{ Timeout() bool }); ok && timeout.Timeout() {
+		return &net.OpError{Op: op, Net: c.addr.Network(), Addr: c.addr, Err: err}
+	}
+	if stderr := strings.TrimSpace(c.stderr.String()); stderr != "" {
+		err = errors.Wrap(err, stderr)
+	}
+	return &net.OpError{Op: op, Net: c.addr.Network(), Addr: c.addr, Err: err}
+}
+
+func (c *commandConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		c.stdout.Close()
+		c.cmd.Process.Kill()
+		<-c.exited
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return c.addr }
+func (c *commandConn) RemoteAddr() net.Addr { return c.addr }
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error {
+	return c.stdout.SetReadDeadline(t)
+}
+
+func (c *commandConn) SetWriteDeadline(t time.Time) error {
+	return c.stdin.SetWriteDeadline(t)
+}
+
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
+
+// Buffer holding at most the first few KiB written to it.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := 4096 - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
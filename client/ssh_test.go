@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialFuncWithSSH(t *testing.T) {
+	dir, cleanup := newFakeSSH(t, `printf '%s\n' "$@" > "$(dirname "$0")/args"; exec cat`)
+	defer cleanup()
+
+	dial := client.DialFuncWithSSH(client.SSHConfig{
+		Host:    "bastion:2222",
+		User:    "admin",
+		KeyFile: "/path/to/key",
+		Options: []string{"StrictHostKeyChecking=yes"},
+		Command: filepath.Join(dir, "ssh"),
+	})
+
+	conn, err := dial(context.Background(), "10.0.0.1:9001")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The fake ssh command echoes back what it receives.
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-W", "10.0.0.1:9001", "-o", "BatchMode=yes", "-p", "2222", "-l", "admin",
+		"-i", "/path/to/key", "-o", "StrictHostKeyChecking=yes", "--", "bastion",
+	}, strings.Fields(string(args)))
+}
+
+// Failures of the ssh command are reported along with its diagnostics.
+func TestDialFuncWithSSH_Failure(t *testing.T) {
+	dir, cleanup := newFakeSSH(t, `echo "Permission denied (publickey)." >&2; exit 255`)
+	defer cleanup()
+
+	dial := client.DialFuncWithSSH(client.SSHConfig{Host: "bastion", Command: filepath.Join(dir, "ssh")})
+
+	conn, err := dial(context.Background(), "10.0.0.1:9001")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 8))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Permission denied")
+}
+
+// Create a fake ssh executable running the given shell script.
+func newFakeSSH(t *testing.T, script string) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "dqlite-client-ssh-")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "ssh")
+	require.NoError(t, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755))
+
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// DatabaseStats holds size information and statistics about a database.
+type DatabaseStats struct {
+	PageSize      uint64 // Size of a database page, in bytes.
+	PageCount     uint64 // Number of pages in the database file.
+	FreelistCount uint64 // Number of unused pages in the database file.
+	SchemaVersion uint64 // Incremented every time the schema changes.
+}
+
+// Size returns the size of the database file, in bytes.
+func (s DatabaseStats) Size() uint64 {
+	return s.PageSize * s.PageCount
+}
+
+// Query returning the database statistics, in the order of the DatabaseStats
+// fields.
+const databaseStatsSQL = `
+SELECT page_size, page_count, freelist_count, schema_version
+FROM pragma_page_size, pragma_page_count, pragma_freelist_count, pragma_schema_version`
+
+// DatabaseStats returns size information and statistics about the database
+// with the given name, without transferring its content like Dump does.
+//
+// This must be invoked on a client connected to the current leader. The
+// database is opened on the client connection, and dqlite allows only one
+// database per connection, so a client can only return the statistics of a
+// single database. The number of frames in the WAL is not available, since
+// dqlite doesn't expose it.
+func (c *Client) DatabaseStats(ctx context.Context, dbname string) (*DatabaseStats, error) {
+	done, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	request := protocol.Message{}
+	request.Init(4096)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	id, err := c.openDatabase(ctx, dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol.EncodeQuerySQL(&request, uint64(id), databaseStatsSQL, nil)
+
+	if err := c.protocol.Call(ctx, &request, &response); err != nil {
+		return nil, errors.Wrap(err, "failed to send Query request")
+	}
+
+	rows, err := protocol.DecodeRows(&response)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Rows response")
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, 4)
+	if err := rows.Next(values); err != nil {
+		return nil, errors.Wrap(err, "failed to read statistics")
+	}
+
+	stats := &DatabaseStats{}
+	fields := []*uint64{&stats.PageSize, &stats.PageCount, &stats.FreelistCount, &stats.SchemaVersion}
+	for i, value := range values {
+		n, ok := value.(int64)
+		if !ok {
+			return nil, errors.Errorf("unexpected statistic value %v", value)
+		}
+		*fields[i] = uint64(n)
+	}
+
+	return stats, nil
+}
+
+// Open the database with the given name on the client connection, unless
+// it's already open, and return its ID.
+func (c *Client) openDatabase(ctx context.Context, dbname string) (uint32, error) {
+	if c.database != nil {
+		if c.database.name != dbname {
+			return 0, errors.Errorf("client connection already bound to database %q", c.database.name)
+		}
+		return c.database.id, nil
+	}
+
+	request := protocol.Message{}
+	request.Init(4096)
+	response := protocol.Message{}
+	response.Init(4096)
+
+	protocol.EncodeOpen(&request, dbname, 0, "volatile")
+
+	if err := c.protocol.Call(ctx, &request, &response); err != nil {
+		return 0, errors.Wrap(err, "failed to send Open request")
+	}
+
+	id, err := protocol.DecodeDb(&response)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to open database")
+	}
+	c.database = &openDatabase{name: dbname, id: id}
+
+	return id, nil
+}
+
+// A database opened on the client connection.
+type openDatabase struct {
+	name string
+	id   uint32
+}
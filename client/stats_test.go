@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DatabaseStats(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	databases := []string{}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		databases = append(databases, database)
+		assert.True(t, strings.Contains(sql, "pragma_page_count"))
+		return &clienttest.Rows{
+			Columns: []string{"page_size", "page_count", "freelist_count", "schema_version"},
+			Values:  [][]driver.Value{{int64(4096), int64(10), int64(2), int64(3)}},
+		}, nil
+	})
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, server.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+
+	stats, err := cli.DatabaseStats(ctx, "test.db")
+	require.NoError(t, err)
+	assert.Equal(t, client.DatabaseStats{PageSize: 4096, PageCount: 10, FreelistCount: 2, SchemaVersion: 3}, *stats)
+	assert.Equal(t, uint64(40960), stats.Size())
+
+	_, err = cli.DatabaseStats(ctx, "test.db")
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []string{"test.db", "test.db"}, databases)
+	mu.Unlock()
+
+	_, err = cli.DatabaseStats(ctx, "other.db")
+	assert.Error(t, err)
+}
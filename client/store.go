@@ -3,9 +3,11 @@ package client
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -26,12 +28,20 @@ type NodeRole = protocol.NodeRole
 // NodeInfo holds information about a single server.
 type NodeInfo = protocol.NodeInfo
 
+// NodeTLS holds the TLS parameters of a single server. See
+// DialFuncWithNodeTLS.
+type NodeTLS = protocol.NodeTLS
+
 // InmemNodeStore keeps the list of target dqlite nodes in memory.
 type InmemNodeStore = protocol.InmemNodeStore
 
 // NewInmemNodeStore creates NodeStore which stores its data in-memory.
 var NewInmemNodeStore = protocol.NewInmemNodeStore
 
+// WatchableNodeStore is a NodeStore that can notify when its list of nodes
+// changes, so new nodes are tried right away when looking for the leader.
+type WatchableNodeStore = protocol.WatchableNodeStore
+
 // DatabaseNodeStore persists a list addresses of dqlite nodes in a SQL table.
 type DatabaseNodeStore struct {
 	db     *sql.DB // Database handle to use.
@@ -44,14 +54,18 @@ type DatabaseNodeStore struct {
 // DefaultNodeStore creates a new NodeStore using the given filename.
 //
 // If the filename ends with ".yaml" then the YamlNodeStore implementation will
-// be used. Otherwise the SQLite-based one will be picked, with default names
-// for the schema, table and column parameters.
+// be used, and if it ends with ".json" the FileNodeStore one. Otherwise the
+// SQLite-based one will be picked, with default names for the schema, table
+// and column parameters.
 //
 // It also creates the table if it doesn't exist yet.
 func DefaultNodeStore(filename string) (NodeStore, error) {
 	if strings.HasSuffix(filename, ".yaml") {
 		return NewYamlNodeStore(filename)
 	}
+	if strings.HasSuffix(filename, ".json") {
+		return NewFileNodeStore(filename)
+	}
 
 	// Open the database.
 	db, err := sql.Open("sqlite3", filename)
@@ -177,9 +191,10 @@ func (d *DatabaseNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
 
 // Persists a list addresses of dqlite nodes in a YAML file.
 type YamlNodeStore struct {
-	path    string
-	servers []NodeInfo
-	mu      sync.RWMutex
+	path     string
+	servers  []NodeInfo
+	mu       sync.RWMutex
+	watchers protocol.NodeStoreWatchers
 }
 
 // NewYamlNodeStore creates a new YamlNodeStore backed by the given YAML file.
@@ -233,6 +248,112 @@ func (s *YamlNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
 	}
 
 	s.servers = servers
+	s.watchers.Notify()
+
+	return nil
+}
+
+// Watch returns a channel notified whenever Set is called.
+func (s *YamlNodeStore) Watch(ctx context.Context) <-chan struct{} {
+	return s.watchers.Watch(ctx)
+}
+
+// FileNodeStore persists a list of dqlite nodes in a YAML or JSON file, which
+// can be shared by multiple processes.
+//
+// Unlike YamlNodeStore, the file is read again on every Get, so changes made
+// by other processes are picked up, and it's replaced atomically on every Set,
+// so readers never see a partially written file. Concurrent writers don't
+// corrupt the file: the last one wins.
+type FileNodeStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNodeStore creates a new FileNodeStore backed by the given file, which
+// is created on the first Set if it doesn't exist.
+//
+// The file is encoded as JSON if its name ends with ".json", and as YAML
+// otherwise.
+func NewFileNodeStore(path string) (*FileNodeStore, error) {
+	store := &FileNodeStore{path: path}
+
+	// Check that the file, if present, can be parsed.
+	if _, err := store.Get(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Get the current servers.
+func (s *FileNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	servers := []NodeInfo{}
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return servers, nil
+		}
+		return nil, errors.Wrap(err, "failed to read node store file")
+	}
+
+	// JSON is a subset of YAML, so both formats can be parsed the same way.
+	if err := yaml.Unmarshal(data, &servers); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse node store file %s", s.path)
+	}
+
+	return servers, nil
+}
+
+// Set the servers addresses.
+func (s *FileNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var data []byte
+	var err error
+	if strings.HasSuffix(s.path, ".json") {
+		data, err = json.MarshalIndent(servers, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(servers)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to encode nodes")
+	}
+
+	dir, name := filepath.Split(s.path)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Write to a temporary file in the same directory and rename it,
+	// which is atomic on POSIX systems.
+	tmp, err := ioutil.TempFile(dir, "."+name+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write temporary file")
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to sync temporary file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temporary file")
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errors.Wrap(err, "failed to replace node store file")
+	}
 
 	return nil
 }
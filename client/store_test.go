@@ -2,6 +2,8 @@ package client_test
 
 import (
 	"context"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/canonical/go-dqlite/client"
@@ -53,3 +55,59 @@ func TestDefaultNodeStore(t *testing.T) {
 		{ID: uint64(1), Address: "9.9.9.9:666"}},
 		servers)
 }
+
+// Nodes set in a FileNodeStore are visible to other stores using the same
+// file.
+func TestFileNodeStore(t *testing.T) {
+	for _, name := range []string{"nodes.yaml", "nodes.json"} {
+		t.Run(name, func(t *testing.T) {
+			dir, cleanup := newDir(t)
+			defer cleanup()
+
+			path := filepath.Join(dir, name)
+
+			store1, err := client.NewFileNodeStore(path)
+			require.NoError(t, err)
+
+			servers, err := store1.Get(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, servers)
+
+			nodes := []client.NodeInfo{
+				{ID: 1, Address: "1.2.3.4:666", Role: client.Voter},
+				{ID: 2, Address: "5.6.7.8:666", Role: client.Spare, Weight: 2, FailureDomain: 1, TLS: &client.NodeTLS{ServerName: "node2"}},
+			}
+			require.NoError(t, store1.Set(context.Background(), nodes))
+
+			store2, err := client.NewFileNodeStore(path)
+			require.NoError(t, err)
+
+			servers, err = store2.Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, nodes, servers)
+
+			require.NoError(t, store2.Set(context.Background(), nodes[:1]))
+
+			servers, err = store1.Get(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, nodes[:1], servers)
+
+			// No temporary file is left behind.
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+// A FileNodeStore can't be created from an invalid file.
+func TestFileNodeStore_Invalid(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "nodes.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{garbage"), 0600))
+
+	_, err := client.NewFileNodeStore(path)
+	assert.Error(t, err)
+}
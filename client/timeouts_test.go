@@ -0,0 +1,87 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCallTimeouts(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	delay := 100 * time.Millisecond
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		time.Sleep(delay)
+		return &clienttest.Rows{Columns: []string{"n"}}, nil
+	})
+	server.HandleDump(func(database string) ([]client.File, error) {
+		time.Sleep(delay)
+		return []client.File{{Name: database, Data: make([]byte, 8)}}, nil
+	})
+
+	ctx := context.Background()
+	timeouts := client.CallTimeouts{Statement: delay / 4, Bulk: 4 * delay}
+	cli, err := client.New(ctx, server.Address(),
+		client.WithDatabase("test.db"), client.WithCallTimeouts(timeouts))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// The slow dump gets its own timeout.
+	files, err := cli.Dump(ctx, "test.db")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// The deadline of the context takes precedence.
+	withDeadline, cancel := context.WithTimeout(ctx, 4*delay)
+	defer cancel()
+	_, _, err = cli.Query(withDeadline, "SELECT n")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, _, err = cli.Query(ctx, "SELECT n")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < delay)
+}
+
+func TestWithAdaptiveTimeout(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var delay int64
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		time.Sleep(time.Duration(atomic.LoadInt64(&delay)))
+		return &clienttest.Rows{Columns: []string{"n"}}, nil
+	})
+
+	ctx := context.Background()
+	cli, err := client.New(ctx, server.Address(),
+		client.WithDatabase("test.db"), client.WithAdaptiveTimeout(50*time.Millisecond, 2*time.Second))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	// Until enough queries are observed, the maximum timeout applies.
+	atomic.StoreInt64(&delay, int64(200*time.Millisecond))
+	_, _, err = cli.Query(ctx, "SELECT n")
+	require.NoError(t, err)
+
+	// Fast queries bring the timeout down to the minimum.
+	atomic.StoreInt64(&delay, 0)
+	for i := 0; i < 128; i++ {
+		_, _, err = cli.Query(ctx, "SELECT n")
+		require.NoError(t, err)
+	}
+
+	atomic.StoreInt64(&delay, int64(200*time.Millisecond))
+	start := time.Now()
+	_, _, err = cli.Query(ctx, "SELECT n")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+}
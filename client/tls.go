@@ -0,0 +1,99 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
+)
+
+// NewTLSConfig returns a client-side TLS configuration with sane defaults,
+// trusting the certificates signed by the given pool and optionally
+// presenting the given certificate to the node (mutual TLS). Pass a nil pool
+// to trust the system CAs and a nil certificate to disable mutual TLS.
+func NewTLSConfig(cert *tls.Certificate, pool *x509.CertPool) *tls.Config {
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: protocol.TLSCipherSuites,
+		RootCAs:      pool,
+	}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return config
+}
+
+// CertificateLoader loads a TLS key pair from disk and reloads it whenever
+// the certificate file changes, so rotated certificates are picked up by
+// long-lived processes without restarting them.
+//
+// Use its GetClientCertificate method as callback in client-side TLS
+// configurations, and its GetCertificate method in server-side ones. Both are
+// evaluated on every TLS handshake.
+type CertificateLoader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertificateLoader creates a loader for the given certificate and key
+// files, loading them right away.
+func NewCertificateLoader(certFile, keyFile string) (*CertificateLoader, error) {
+	loader := &CertificateLoader{certFile: certFile, keyFile: keyFile}
+	if _, err := loader.Certificate(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// Certificate returns the current key pair, reloading it from disk if the
+// certificate file was modified since the last load.
+//
+// If reloading fails, the previously loaded key pair keeps being used.
+func (l *CertificateLoader) Certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.certFile)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, errors.Wrap(err, "stat certificate")
+	}
+
+	if l.cert != nil && info.ModTime().Equal(l.modTime) {
+		return l.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		if l.cert != nil {
+			return l.cert, nil
+		}
+		return nil, errors.Wrap(err, "load key pair")
+	}
+
+	l.cert = &cert
+	l.modTime = info.ModTime()
+
+	return l.cert, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate
+// callback.
+func (l *CertificateLoader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return l.Certificate()
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate callback.
+func (l *CertificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return l.Certificate()
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Topology is a snapshot of the membership of a cluster, as returned by
+// SnapshotTopology.
+type Topology struct {
+	Leader uint64         `json:"leader"` // ID of the leader.
+	Nodes  []TopologyNode `json:"nodes"`
+}
+
+// TopologyNode describes a node of a Topology.
+type TopologyNode struct {
+	ID            uint64 `json:"id"`
+	Address       string `json:"address"`
+	Role          string `json:"role"`
+	Online        bool   `json:"online"`
+	FailureDomain uint64 `json:"failure_domain"`
+	Weight        uint64 `json:"weight"`
+}
+
+// SnapshotTopology fetches the cluster configuration from the leader, and
+// asks every node for its metadata. Nodes that can't be reached are reported
+// as offline. Replication lag is not included, since dqlite doesn't expose
+// the index of the entries applied by each node.
+func SnapshotTopology(ctx context.Context, store NodeStore, options ...Option) (*Topology, error) {
+	cli, err := FindLeader(ctx, store, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cluster, err := cli.Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	topology := &Topology{Leader: leader.ID, Nodes: make([]TopologyNode, len(cluster))}
+	for i, node := range cluster {
+		topology.Nodes[i] = TopologyNode{ID: node.ID, Address: node.Address, Role: node.Role.String()}
+		metadata, err := describeNode(ctx, node, options)
+		if err != nil {
+			continue
+		}
+		topology.Nodes[i].Online = true
+		topology.Nodes[i].FailureDomain = metadata.FailureDomain
+		topology.Nodes[i].Weight = metadata.Weight
+	}
+
+	return topology, nil
+}
+
+// WriteJSON writes the topology to the given writer as JSON.
+func (t *Topology) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
+
+// WriteDOT writes the topology to the given writer as a Graphviz graph, with
+// an edge from the leader to each node it replicates to. The leader is drawn
+// with a double circle and offline nodes are dashed.
+func (t *Topology) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph dqlite {\n")
+	for _, node := range t.Nodes {
+		shape := "circle"
+		if node.ID == t.Leader {
+			shape = "doublecircle"
+		}
+		style := "solid"
+		if !node.Online {
+			style = "dashed"
+		}
+		label := fmt.Sprintf("%d\\n%s\\n%s", node.ID, node.Address, node.Role)
+		fmt.Fprintf(&b, "\t%d [label=%q shape=%s style=%s];\n", node.ID, label, shape, style)
+	}
+	for _, node := range t.Nodes {
+		if node.ID != t.Leader && t.Leader != 0 {
+			fmt.Fprintf(&b, "\t%d -> %d;\n", t.Leader, node.ID)
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Return the metadata of the given node.
+func describeNode(ctx context.Context, node NodeInfo, options []Option) (*NodeMetadata, error) {
+	cli, err := New(ctx, node.Address, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	return cli.Describe(ctx)
+}
@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotTopology(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	server2 := clienttest.NewServer(2)
+	defer server2.Close()
+	server3 := clienttest.NewServer(3)
+	address3 := server3.Address()
+	server3.Close()
+
+	nodes := []client.NodeInfo{
+		{ID: 1, Address: server1.Address(), Role: client.Voter},
+		{ID: 2, Address: server2.Address(), Role: client.StandBy},
+		{ID: 3, Address: address3, Role: client.Spare},
+	}
+	server1.SetCluster(nodes)
+
+	ctx := context.Background()
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, nodes[:1]))
+	require.NoError(t, client.SetNodeWeight(ctx, store, 1, 5))
+
+	topology, err := client.SnapshotTopology(ctx, store)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), topology.Leader)
+	assert.Equal(t, []client.TopologyNode{
+		{ID: 1, Address: server1.Address(), Role: "voter", Online: true, Weight: 5},
+		{ID: 2, Address: server2.Address(), Role: "stand-by", Online: true},
+		{ID: 3, Address: address3, Role: "spare"},
+	}, topology.Nodes)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, topology.WriteJSON(&buf))
+	decoded := client.Topology{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, *topology, decoded)
+
+	buf.Reset()
+	require.NoError(t, topology.WriteDOT(&buf))
+	assert.Equal(t, fmt.Sprintf(`digraph dqlite {
+	1 [label="1\\n%s\\nvoter" shape=doublecircle style=solid];
+	2 [label="2\\n%s\\nstand-by" shape=circle style=solid];
+	3 [label="3\\n%s\\nspare" shape=circle style=dashed];
+	1 -> 2;
+	1 -> 3;
+}
+`, server1.Address(), server2.Address(), address3), buf.String())
+}
@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebSocketConfig holds the parameters of WebSocket connections. See
+// DialFuncWithWebSocket.
+type WebSocketConfig struct {
+	Path      string      // Request path used for "host:port" node addresses, by default "/".
+	Header    http.Header // Additional headers sent with the upgrade request.
+	TLSConfig *tls.Config // TLS configuration used for "wss" URLs.
+}
+
+// DialFuncWithWebSocket returns a dial function that carries the dqlite
+// protocol over a WebSocket connection, for environments where only HTTP(S)
+// egress is allowed and a gateway forwards the stream to the nodes.
+//
+// Node addresses can be either "ws://" or "wss://" URLs, or plain "host:port"
+// addresses, in which case a "ws://" URL with the configured path is used.
+// Protocol data is sent as binary messages.
+//
+// The given dial function is used to establish the network connection to the
+// gateway.
+func DialFuncWithWebSocket(dial DialFunc, config WebSocketConfig) DialFunc {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		target, err := webSocketURL(addr, config.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		port := target.Port()
+		if port == "" {
+			port = "80"
+			if target.Scheme == "wss" {
+				port = "443"
+			}
+		}
+
+		conn, err := dial(ctx, net.JoinHostPort(target.Hostname(), port))
+		if err != nil {
+			return nil, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+			defer conn.SetDeadline(time.Time{})
+		}
+
+		if target.Scheme == "wss" {
+			tlsConfig := config.TLSConfig.Clone()
+			if tlsConfig == nil {
+				tlsConfig = &tls.Config{}
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = target.Hostname()
+			}
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, errors.Wrap(err, "TLS handshake")
+			}
+			conn = tlsConn
+		}
+
+		wsConn, err := webSocketHandshake(conn, target, config.Header)
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "WebSocket handshake")
+		}
+
+		return wsConn, nil
+	}
+}
+
+// Return the WebSocket URL to use for the given node address.
+func webSocketURL(addr string, path string) (*url.URL, error) {
+	if strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://") {
+		target, err := url.Parse(addr)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid node address")
+		}
+		if target.Path == "" {
+			target.Path = "/"
+		}
+		return target, nil
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, errors.Wrap(err, "invalid node address")
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	return &url.URL{Scheme: "ws", Host: addr, Path: path}, nil
+}
+
+// GUID used to compute the Sec-WebSocket-Accept header (RFC 6455).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Perform the opening handshake of a client WebSocket connection.
+func webSocketHandshake(conn net.Conn, target *url.URL, header http.Header) (net.Conn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	request := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: target.Path, RawQuery: target.RawQuery},
+		Host:       target.Host,
+		Header:     http.Header{},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Protocol", "dqlite")
+
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("unexpected response: %s", response.Status)
+	}
+	if !strings.EqualFold(response.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("unexpected upgrade: %q", response.Header.Get("Upgrade"))
+	}
+
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	if response.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(hash[:]) {
+		return nil, fmt.Errorf("invalid Sec-WebSocket-Accept header")
+	}
+
+	return &webSocketConn{Conn: conn, reader: reader}, nil
+}
+
+// WebSocket opcodes.
+const (
+	webSocketContinuation = 0x0
+	webSocketText         = 0x1
+	webSocketBinary       = 0x2
+	webSocketClose        = 0x8
+	webSocketPing         = 0x9
+	webSocketPong         = 0xa
+)
+
+// Client-side WebSocket connection, exposing the payload of data messages as
+// a byte stream.
+type webSocketConn struct {
+	net.Conn
+	reader    *bufio.Reader
+	remaining uint64 // Payload bytes left in the current data frame.
+	mask      [4]byte
+	masked    bool
+	offset    int
+	closed    bool
+
+	writeMu sync.Mutex
+}
+
+func (c *webSocketConn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.closed {
+			return 0, io.EOF
+		}
+		if err := c.readHeader(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.reader.Read(b)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			b[i] ^= c.mask[(c.offset+i)%4]
+		}
+	}
+	c.offset += n
+	c.remaining -= uint64(n)
+
+	return n, err
+}
+
+// Read the next frame header, handling control frames.
+func (c *webSocketConn) readHeader() error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return err
+	}
+
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7f)
+
+	switch size {
+	case 126:
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return err
+		}
+		size = uint64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return err
+		}
+		size = binary.BigEndian.Uint64(buf)
+	}
+
+	c.masked = masked
+	c.offset = 0
+	if masked {
+		if _, err := io.ReadFull(c.reader, c.mask[:]); err != nil {
+			return err
+		}
+	}
+
+	switch opcode {
+	case webSocketContinuation, webSocketText, webSocketBinary:
+		c.remaining = size
+		return nil
+	}
+
+	// Control frames have a payload of at most 125 bytes.
+	if size > 125 {
+		return fmt.Errorf("WebSocket control frame too large")
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= c.mask[i%4]
+		}
+	}
+
+	switch opcode {
+	case webSocketPing:
+		_, err := c.writeFrame(webSocketPong, payload)
+		return err
+	case webSocketPong:
+		return nil
+	case webSocketClose:
+		c.closed = true
+		c.writeFrame(webSocketClose, payload)
+		return nil
+	default:
+		return fmt.Errorf("unexpected WebSocket opcode %d", opcode)
+	}
+}
+
+func (c *webSocketConn) Write(b []byte) (int, error) {
+	return c.writeFrame(webSocketBinary, b)
+}
+
+// Write a single masked frame with the given opcode and payload.
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch size := len(payload); {
+	case size < 126:
+		header[1] = 0x80 | byte(size)
+	case size <= 0xffff:
+		header[1] = 0x80 | 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(size))
+	default:
+		header[1] = 0x80 | 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(size))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return 0, err
+	}
+	header = append(header, mask[:]...)
+
+	frame := make([]byte, len(header)+len(payload))
+	copy(frame, header)
+	for i, c := range payload {
+		frame[len(header)+i] = c ^ mask[i%4]
+	}
+
+	if _, err := c.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+
+	return len(payload), nil
+}
+
+func (c *webSocketConn) Close() error {
+	c.writeFrame(webSocketClose, []byte{0x03, 0xe8}) // Normal closure.
+	return c.Conn.Close()
+}
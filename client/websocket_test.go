@@ -0,0 +1,137 @@
+package client_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialFuncWithWebSocket(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	gateway := newWebSocketGateway(t, server.Address())
+	defer gateway.Close()
+
+	address := strings.TrimPrefix(gateway.URL, "http://")
+	config := client.WebSocketConfig{
+		Path:   "/dqlite",
+		Header: http.Header{"Authorization": []string{"Bearer secret"}},
+	}
+	dial := client.DialFuncWithWebSocket(client.DefaultDialFunc, config)
+
+	ctx := context.Background()
+
+	cli, err := client.New(ctx, address, client.WithDialFunc(dial))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	leader, err := cli.Leader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, server.Address(), leader.Address)
+}
+
+func TestDialFuncWithWebSocket_Rejected(t *testing.T) {
+	gateway := newWebSocketGateway(t, "127.0.0.1:1")
+	defer gateway.Close()
+
+	address := "ws" + strings.TrimPrefix(gateway.URL, "http") + "/other"
+	dial := client.DialFuncWithWebSocket(client.DefaultDialFunc, client.WebSocketConfig{})
+
+	_, err := dial(context.Background(), address)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404")
+}
+
+// Start an HTTP server upgrading requests to /dqlite to WebSocket connections
+// and forwarding their binary messages to the given address.
+func newWebSocketGateway(t *testing.T, address string) *httptest.Server {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/dqlite", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		target, err := net.Dial("tcp", address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+
+		hash := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(hash[:]))
+		w.WriteHeader(http.StatusSwitchingProtocols)
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Forward unmasked binary frames back to the client.
+		go func() {
+			buf := make([]byte, 4096)
+			for {
+				n, err := target.Read(buf)
+				if err != nil {
+					return
+				}
+				header := []byte{0x82, 126, 0, 0}
+				binary.BigEndian.PutUint16(header[2:], uint16(n))
+				if _, err := conn.Write(append(header, buf[:n]...)); err != nil {
+					return
+				}
+			}
+		}()
+
+		// Unmask client frames and forward their payload.
+		for {
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(rw, header); err != nil {
+				return
+			}
+			size := uint64(header[1] & 0x7f)
+			switch size {
+			case 126:
+				buf := make([]byte, 2)
+				io.ReadFull(rw, buf)
+				size = uint64(binary.BigEndian.Uint16(buf))
+			case 127:
+				buf := make([]byte, 8)
+				io.ReadFull(rw, buf)
+				size = binary.BigEndian.Uint64(buf)
+			}
+			mask := make([]byte, 4)
+			io.ReadFull(rw, mask)
+			payload := make([]byte, size)
+			if _, err := io.ReadFull(rw, payload); err != nil {
+				return
+			}
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+			if header[0]&0x0f == 0x8 {
+				return
+			}
+			if _, err := target.Write(payload); err != nil {
+				return
+			}
+		}
+	})
+	return httptest.NewServer(handler)
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SetNodeWeight changes at runtime the weight of the node with the given ID,
+// found in the given store, which role assignment uses to pick the nodes to
+// promote. The weight is set on the node itself, which is connected to with
+// the given options.
+//
+// The failure domain of a node can't be changed at runtime, since dqlite only
+// accepts it before the node starts.
+func SetNodeWeight(ctx context.Context, store NodeStore, id uint64, weight uint64, options ...Option) error {
+	nodes, err := store.Get(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get nodes from store")
+	}
+
+	for _, node := range nodes {
+		if node.ID != id {
+			continue
+		}
+		cli, err := New(ctx, node.Address, options...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect to node %d", id)
+		}
+		defer cli.Close()
+
+		return cli.Weight(ctx, weight)
+	}
+
+	return errors.Errorf("no node with ID %d in store", id)
+}
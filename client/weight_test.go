@@ -0,0 +1,38 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetNodeWeight(t *testing.T) {
+	server1 := clienttest.NewServer(1)
+	defer server1.Close()
+	server2 := clienttest.NewServer(2)
+	defer server2.Close()
+
+	ctx := context.Background()
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(ctx, []client.NodeInfo{
+		{ID: 1, Address: server1.Address()},
+		{ID: 2, Address: server2.Address()},
+	}))
+
+	require.NoError(t, client.SetNodeWeight(ctx, store, 2, 7))
+	assert.Equal(t, uint64(0), server1.Weight())
+	assert.Equal(t, uint64(7), server2.Weight())
+
+	cli, err := client.New(ctx, server2.Address())
+	require.NoError(t, err)
+	defer cli.Close()
+	metadata, err := cli.Describe(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), metadata.Weight)
+
+	assert.EqualError(t, client.SetNodeWeight(ctx, store, 3, 1), "no node with ID 3 in store")
+}
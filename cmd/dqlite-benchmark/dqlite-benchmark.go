@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/driver"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var servers *[]string
+	var database string
+	var duration time.Duration
+	var workers int
+	var reads float64
+
+	cmd := &cobra.Command{
+		Use:   "dqlite-benchmark -s <servers>",
+		Short: "Run a read/write workload against a dqlite cluster",
+		Long: `Run a mix of single-row inserts and point queries against a dqlite cluster
+from concurrent workers, and report the throughput and latency percentiles of
+each kind of operation.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(*servers) == 0 {
+				return fmt.Errorf("no servers provided")
+			}
+			if workers < 1 {
+				return fmt.Errorf("invalid workers %d: must be at least 1", workers)
+			}
+			if reads < 0 || reads > 1 {
+				return fmt.Errorf("invalid reads ratio %g: must be between 0 and 1", reads)
+			}
+
+			infos := make([]client.NodeInfo, len(*servers))
+			for i, address := range *servers {
+				infos[i].Address = address
+			}
+			store := client.NewInmemNodeStore()
+			store.Set(context.Background(), infos)
+
+			connector, err := driver.NewConnector(store, database)
+			if err != nil {
+				return err
+			}
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			db.SetMaxOpenConns(workers)
+			db.SetMaxIdleConns(workers)
+
+			return run(db, duration, workers, reads)
+		},
+	}
+
+	flags := cmd.Flags()
+	servers = flags.StringSliceP("servers", "s", nil, "comma-separated list of db servers")
+	flags.StringVarP(&database, "database", "d", "benchmark", "name of the database to use")
+	flags.DurationVar(&duration, "duration", 10*time.Second, "how long to run the workload for")
+	flags.IntVarP(&workers, "workers", "w", 4, "number of concurrent workers")
+	flags.Float64Var(&reads, "reads", 0.8, "ratio of operations that are reads, between 0 and 1")
+
+	cmd.MarkFlagRequired("servers")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+const schema = "CREATE TABLE IF NOT EXISTS benchmark (id INTEGER PRIMARY KEY, value TEXT)"
+
+// Latencies of the operations of one kind.
+type samples struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func (s *samples) add(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+// Print the throughput and latency percentiles of the samples.
+func (s *samples) report(name string, elapsed time.Duration) {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	n := len(s.latencies)
+	if n == 0 {
+		fmt.Printf("%-6s ops=0 errors=%d\n", name, s.errors)
+		return
+	}
+	percentile := func(p float64) time.Duration {
+		return s.latencies[int(p*float64(n-1))]
+	}
+	fmt.Printf("%-6s ops=%d errors=%d ops/s=%.1f p50=%s p95=%s p99=%s max=%s\n",
+		name, n, s.errors, float64(n)/elapsed.Seconds(),
+		percentile(0.50), percentile(0.95), percentile(0.99), s.latencies[n-1])
+}
+
+func run(db *sql.DB, duration time.Duration, workers int, reads float64) error {
+	if _, err := db.Exec(schema); err != nil {
+		return fmt.Errorf("create schema: %w", err)
+	}
+
+	// Make sure there's at least one row to read.
+	result, err := db.Exec("INSERT INTO benchmark(value) VALUES(?)", "seed")
+	if err != nil {
+		return fmt.Errorf("insert seed row: %w", err)
+	}
+	maxID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	var mu sync.Mutex // Protects maxID.
+	readSamples := &samples{}
+	writeSamples := &samples{}
+
+	wg := sync.WaitGroup{}
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			random := rand.New(rand.NewSource(seed))
+			for ctx.Err() == nil {
+				begin := time.Now()
+				if random.Float64() < reads {
+					mu.Lock()
+					id := random.Int63n(maxID) + 1
+					mu.Unlock()
+					var value string
+					err := db.QueryRowContext(ctx, "SELECT value FROM benchmark WHERE id = ?", id).Scan(&value)
+					if err == sql.ErrNoRows {
+						err = nil
+					}
+					if ctx.Err() == nil {
+						readSamples.add(time.Since(begin), err)
+					}
+					continue
+				}
+				result, err := db.ExecContext(ctx, "INSERT INTO benchmark(value) VALUES(?)", fmt.Sprintf("value-%d", random.Int63()))
+				if ctx.Err() != nil {
+					continue
+				}
+				writeSamples.add(time.Since(begin), err)
+				if err != nil {
+					continue
+				}
+				if id, err := result.LastInsertId(); err == nil {
+					mu.Lock()
+					if id > maxID {
+						maxID = id
+					}
+					mu.Unlock()
+				}
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	readSamples.report("reads", elapsed)
+	writeSamples.report("writes", elapsed)
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/canonical/go-dqlite/raftdir"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	cmd := &cobra.Command{
+		Use:   "dqlite-raft",
+		Short: "Offline tools for the raft data directory of a dqlite node",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "inspect <dir>",
+		Short: "Report the segments and snapshots of a raft data directory and the problems found",
+		Long: "Report the segments and snapshots of a raft data directory and the problems found.\n\n" +
+			"The node owning the directory must be stopped. Exits with status 2 if problems are found.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventory, err := raftdir.Inspect(args[0])
+			if err != nil {
+				return err
+			}
+
+			if m := inventory.Metadata; m != nil {
+				fmt.Printf("metadata: %s (version %d, term %d, voted for %d)\n", m.Filename, m.Version, m.Term, m.VotedFor)
+			}
+			fmt.Printf("log: indexes %d-%d\n\n", inventory.FirstIndex(), inventory.LastIndex())
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "SEGMENT\tENTRIES\tINDEXES\tTERMS\tSIZE")
+			for _, s := range inventory.Segments {
+				fmt.Fprintf(w, "%s\t%d\t%d-%d\t%d-%d\t%d\n",
+					s.Filename, s.Entries, s.FirstIndex, s.LastIndex, s.FirstTerm, s.LastTerm, s.Size)
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "SNAPSHOT\tTERM\tINDEX\tCREATED\tSIZE")
+			for _, s := range inventory.Snapshots {
+				created := time.Unix(0, int64(s.Timestamp)*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\n", s.Filename, s.Term, s.Index, created, s.Size)
+			}
+			w.Flush()
+
+			problems := inventory.Problems()
+			if len(problems) == 0 {
+				return nil
+			}
+			fmt.Println("\nproblems:")
+			for _, problem := range problems {
+				fmt.Println("  " + problem)
+			}
+			os.Exit(2)
+
+			return nil
+		},
+	})
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var conn uint32
+
+	cmd := &cobra.Command{
+		Use:   "dqlite-replay <capture>",
+		Short: "Decode the wire traffic recorded with dqlite --capture",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("open capture file: %w", err)
+			}
+			defer f.Close()
+
+			frames, err := client.ReadCapture(f)
+			if err != nil {
+				return fmt.Errorf("read capture file: %w", err)
+			}
+
+			for _, frame := range frames {
+				if conn != 0 && frame.Conn != conn {
+					continue
+				}
+				fmt.Println(frame.String())
+			}
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Uint32VarP(&conn, "conn", "c", 0, "only show frames of the connection with the given ID")
+
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
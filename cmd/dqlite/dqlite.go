@@ -22,6 +22,7 @@ func main() {
 	var key string
 	var servers *[]string
 	var format string
+	var capture string
 
 	cmd := &cobra.Command{
 		Use:   "dqlite -s <servers> <database> [command]",
@@ -80,6 +81,15 @@ func main() {
 
 			}
 
+			if capture != "" {
+				f, err := os.Create(capture)
+				if err != nil {
+					return fmt.Errorf("create capture file: %w", err)
+				}
+				defer f.Close()
+				dial = client.DialFuncWithCapture(dial, client.NewCapture(f))
+			}
+
 			sh, err := shell.New(args[0], store, shell.WithDialFunc(dial), shell.WithFormat(format))
 			if err != nil {
 				return err
@@ -125,7 +135,8 @@ func main() {
 	servers = flags.StringSliceP("servers", "s", nil, "comma-separated list of db servers, or file://<store>")
 	flags.StringVarP(&crt, "cert", "c", "", "public TLS cert")
 	flags.StringVarP(&key, "key", "k", "", "private TLS key")
-	flags.StringVarP(&format, "format", "f", "tabular", "output format (tabular, json)")
+	flags.StringVarP(&format, "format", "f", "tabular", "output format (tabular, json, csv, ndjson)")
+	flags.StringVar(&capture, "capture", "", "record the wire traffic to the given file (see dqlite-replay)")
 
 	cmd.MarkFlagRequired("servers")
 
@@ -0,0 +1,252 @@
+// Package conformance holds a protocol conformance suite for dqlite servers
+// and proxies.
+//
+// Requests and Responses hold the encoding of well-known messages, which an
+// implementation of the wire protocol must produce and accept byte for byte.
+// Run executes behavioral tests against a running server.
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vector is the encoding of a single message.
+type Vector struct {
+	Name        string // Identifies the vector.
+	Description string // The content of the message.
+	Type        uint8  // Message type, from the message header.
+	Schema      uint8  // Schema version, from the message header.
+	Body        []byte // Message body, a multiple of 8 bytes.
+}
+
+// Message returns the whole message, header included.
+func (v Vector) Message() []byte {
+	message := make([]byte, 8+len(v.Body))
+	binary.LittleEndian.PutUint32(message, uint32(len(v.Body)/8))
+	message[4] = v.Type
+	message[5] = v.Schema
+	copy(message[8:], v.Body)
+	return message
+}
+
+// Requests holds the encoding of requests sent by clients.
+var Requests = []Vector{
+	{"leader", "Leader{}", 0, 0, decode(
+		"0000000000000000")},
+	{"client", "Client{id: 1}", 1, 0, decode(
+		"0100000000000000")},
+	{"open", `Open{name: "conformance.db", flags: 0, vfs: "volatile"}`, 3, 0, decode(
+		"636f6e666f726d616e63652e64620000000000000000000076" +
+			"6f6c6174696c650000000000000000")},
+	{"prepare", `Prepare{db: 0, sql: "SELECT ?"}`, 4, 0, decode(
+		"000000000000000053454c454354203f0000000000000000")},
+	{"exec", "Exec{db: 0, stmt: 1, params: [1]}", 5, 0, decode(
+		"000000000100000001010000000000000100000000000000")},
+	{"query", "Query{db: 0, stmt: 1, params: [1]}", 6, 0, decode(
+		"000000000100000001010000000000000100000000000000")},
+	{"finalize", "Finalize{db: 0, stmt: 1}", 7, 0, decode(
+		"0000000001000000")},
+	{"exec_sql", "ExecSQL{db: 0, sql: " + createSQL + "}", 8, 0, decode(
+		"0000000000000000435245415445205441424c45204946204e4f5420455849535453" +
+			"20636f6e666f726d616e636520286e20494e542c2066205245414c2c2074205445" +
+			"58542c206220424c4f422c207a2900000000000000")},
+	{"exec_sql_params", "ExecSQL{db: 0, sql: " + insertSQL + `, params: [-1, 1.5, "text", x'010203', NULL]}`, 8, 0, decode(
+		"0000000000000000494e5345525420494e544f20636f6e666f726d616e63652056414c" +
+			"554553283f2c203f2c203f2c203f2c203f290000000501020304050000ffffffff" +
+			"ffffffff000000000000f83f74657874000000000300000000000000010203000000" +
+			"00000000000000000000")},
+	{"query_sql", "QuerySQL{db: 0, sql: " + selectSQL + "}", 9, 0, decode(
+		"000000000000000053454c454354206e2c20662c20742c20622c207a2046524f4d20" +
+			"636f6e666f726d616e6365000000")},
+	{"interrupt", "Interrupt{db: 0}", 10, 0, decode(
+		"0000000000000000")},
+	{"add", `Add{id: 2, address: "127.0.0.1:9002"}`, 12, 0, decode(
+		"02000000000000003132372e302e302e313a393030320000")},
+	{"assign", "Assign{id: 2, role: 1}", 13, 0, decode(
+		"02000000000000000100000000000000")},
+	{"remove", "Remove{id: 2}", 14, 0, decode(
+		"0200000000000000")},
+	{"dump", `Dump{name: "conformance.db"}`, 15, 0, decode(
+		"636f6e666f726d616e63652e64620000")},
+	{"cluster", "Cluster{format: 1}", 16, 0, decode(
+		"0100000000000000")},
+	{"transfer", "Transfer{id: 2}", 17, 0, decode(
+		"0200000000000000")},
+	{"describe", "Describe{format: 0}", 18, 0, decode(
+		"0000000000000000")},
+	{"weight", "Weight{weight: 5}", 19, 0, decode(
+		"0500000000000000")},
+}
+
+// Responses holds the encoding of responses sent by servers.
+var Responses = []Vector{
+	{"failure", `Failure{code: 1, message: "error"}`, 0, 0, decode(
+		"01000000000000006572726f72000000")},
+	{"node", `Node{id: 1, address: "127.0.0.1:9001"}`, 1, 0, decode(
+		"01000000000000003132372e302e302e313a393030310000")},
+	{"nodes", `Nodes[{id: 1, address: "127.0.0.1:9001", role: 0}]`, 3, 0, decode(
+		"010000000000000001000000000000003132372e302e302e313a3930303100000000000000000000")},
+	{"db", "Db{id: 0}", 4, 0, decode(
+		"0000000000000000")},
+	{"stmt", "Stmt{db: 0, id: 1, params: 1}", 5, 0, decode(
+		"00000000010000000100000000000000")},
+	{"result", "Result{last_insert_id: 1, rows_affected: 1}", 6, 0, decode(
+		"01000000000000000100000000000000")},
+	{"rows", `Rows{columns: ["n", "t"], rows: [[1, "x"]], done}`, 7, 0, decode(
+		"02000000000000006e00000000000000740000000000000031000000000000000100000000000000" +
+			"7800000000000000ffffffffffffffff")},
+	{"empty", "Empty{}", 8, 0, decode(
+		"0000000000000000")},
+	{"metadata", "Metadata{failure_domain: 1, weight: 2}", 10, 0, decode(
+		"01000000000000000200000000000000")},
+}
+
+// Statements used by the vectors and by Run.
+const (
+	createSQL = "CREATE TABLE IF NOT EXISTS conformance (n INT, f REAL, t TEXT, b BLOB, z)"
+	insertSQL = "INSERT INTO conformance VALUES(?, ?, ?, ?, ?)"
+	selectSQL = "SELECT n, f, t, b, z FROM conformance"
+)
+
+// Request returns the request vector with the given name. It panics if
+// there's none.
+func Request(name string) Vector {
+	return lookup(Requests, name)
+}
+
+// Response returns the response vector with the given name. It panics if
+// there's none.
+func Response(name string) Vector {
+	return lookup(Responses, name)
+}
+
+func lookup(vectors []Vector, name string) Vector {
+	for _, vector := range vectors {
+		if vector.Name == name {
+			return vector
+		}
+	}
+	panic("no vector named " + name)
+}
+
+func decode(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Run executes the behavioral tests against the leader node with the given
+// address, which may be a TCP address or the path of a unix socket. The tests
+// create the conformance table in the conformance.db database, if missing,
+// and insert a row in it.
+func Run(t *testing.T, address string) {
+	t.Run("Leader", func(t *testing.T) {
+		conn := dial(t, address)
+		defer conn.Close()
+
+		body := call(t, conn, Request("leader"), Response("node").Type)
+		if len(body) < 16 || body[8] == 0 {
+			t.Fatalf("leader response without address: %x", body)
+		}
+	})
+
+	t.Run("Cluster", func(t *testing.T) {
+		conn := dial(t, address)
+		defer conn.Close()
+
+		body := call(t, conn, Request("cluster"), Response("nodes").Type)
+		if n := binary.LittleEndian.Uint64(body); n == 0 {
+			t.Fatalf("empty cluster")
+		}
+	})
+
+	t.Run("Statements", func(t *testing.T) {
+		conn := dial(t, address)
+		defer conn.Close()
+
+		call(t, conn, Request("open"), Response("db").Type)
+		call(t, conn, Request("exec_sql"), Response("result").Type)
+		call(t, conn, Request("exec_sql_params"), Response("result").Type)
+
+		body := call(t, conn, Request("query_sql"), Response("rows").Type)
+		columns := []string{"n", "f", "t", "b", "z"}
+		if n := binary.LittleEndian.Uint64(body); n != uint64(len(columns)) {
+			t.Fatalf("got %d columns, want %d", n, len(columns))
+		}
+		if len(body) < 8+len(columns)*8 {
+			t.Fatalf("truncated rows response: %x", body)
+		}
+		for i, column := range columns {
+			word := body[8+i*8 : 16+i*8]
+			if name := string(bytes.TrimRight(word, "\x00")); name != column {
+				t.Fatalf("got column %d named %q, want %q", i, name, column)
+			}
+		}
+	})
+
+	t.Run("Interrupt", func(t *testing.T) {
+		conn := dial(t, address)
+		defer conn.Close()
+
+		call(t, conn, Request("open"), Response("db").Type)
+		call(t, conn, Request("interrupt"), Response("empty").Type)
+	})
+}
+
+// Connect to the given address and perform the handshake.
+func dial(t *testing.T, address string) net.Conn {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") || strings.HasPrefix(address, "@") {
+		network = "unix"
+	}
+
+	conn, err := net.DialTimeout(network, address, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial %s: %v", address, err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	version := make([]byte, 8)
+	binary.LittleEndian.PutUint64(version, 1)
+	if _, err := conn.Write(version); err != nil {
+		conn.Close()
+		t.Fatalf("handshake: %v", err)
+	}
+
+	return conn
+}
+
+// Send the given request and return the body of the response, which must have
+// the given type.
+func call(t *testing.T, conn net.Conn, request Vector, mtype uint8) []byte {
+	if _, err := conn.Write(request.Message()); err != nil {
+		t.Fatalf("send %s: %v", request.Name, err)
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("receive %s response header: %v", request.Name, err)
+	}
+	body := make([]byte, int(binary.LittleEndian.Uint32(header))*8)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		t.Fatalf("receive %s response body: %v", request.Name, err)
+	}
+
+	if len(body) == 0 {
+		t.Fatalf("%s: empty response body", request.Name)
+	}
+	if header[4] != mtype {
+		t.Fatalf("%s: got response type %d, want %d (body %x)", request.Name, header[4], mtype, body)
+	}
+
+	return body
+}
@@ -0,0 +1,37 @@
+package conformance_test
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/canonical/go-dqlite/conformance"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns: []string{"n", "f", "t", "b", "z"},
+			Values:  [][]driver.Value{{int64(-1), 1.5, "text", []byte{1, 2, 3}, nil}},
+		}, nil
+	})
+
+	conformance.Run(t, server.Address())
+}
+
+// Vectors are complete messages once the header is added.
+func TestVector_Message(t *testing.T) {
+	message := conformance.Request("cluster").Message()
+	assert.Equal(t, []byte{1, 0, 0, 0, 16, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, message)
+
+	for _, vector := range append(conformance.Requests, conformance.Responses...) {
+		assert.Zero(t, len(vector.Body)%8, vector.Name)
+	}
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// A database attached to each new connection.
+type attachment struct {
+	database string
+	schema   string
+}
+
+var schemaName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WithAttach makes each new connection attach the dqlite database with the
+// given name under the given schema name, as with "ATTACH DATABASE
+// 'other.db' AS other", so its tables can be referred to as "other.table",
+// for example in joins with the tables of the main database.
+//
+// Databases are attached in the order they are given, after the pragmas set
+// with WithPragma are run. Since connections re-established after a failover
+// are new connections, they attach the databases again.
+func WithAttach(database, schema string) Option {
+	return func(options *options) {
+		options.Attachments = append(options.Attachments, attachment{database: database, schema: schema})
+	}
+}
+
+// Check that the schema name can be safely interpolated in a statement.
+func (a attachment) validate() error {
+	if a.database == "" {
+		return errors.Errorf("no database to attach as %s", a.schema)
+	}
+	if !schemaName.MatchString(a.schema) {
+		return errors.Errorf("invalid schema name %q", a.schema)
+	}
+	return nil
+}
+
+// Attach the given databases to the connection.
+func (c *Conn) attach(ctx context.Context, attachments []attachment) error {
+	for _, a := range attachments {
+		args := []driver.NamedValue{{Ordinal: 1, Value: a.database}}
+		if _, err := c.exec(ctx, "ATTACH DATABASE ? AS "+a.schema, args, true); err != nil {
+			return errors.Wrapf(err, "attach database %s", a.database)
+		}
+	}
+	return nil
+}
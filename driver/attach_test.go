@@ -0,0 +1,64 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The databases given in the data source name are attached to each new
+// connection, after setting the pragmas.
+func TestAttach(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, fmt.Sprint(sql, args))
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?attach=other.db:other&_foreign_keys=on")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	ctx := context.Background()
+	conn1, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"PRAGMA foreign_keys = on[]",
+		"ATTACH DATABASE ? AS other[other.db]",
+		"PRAGMA foreign_keys = on[]",
+		"ATTACH DATABASE ? AS other[other.db]",
+	}, statements)
+}
+
+func TestAttach_Invalid(t *testing.T) {
+	store := client.NewInmemNodeStore()
+
+	_, err := dqlitedriver.New(store, dqlitedriver.WithAttach("other.db", "other; DROP TABLE t"))
+	assert.EqualError(t, err, `invalid schema name "other; DROP TABLE t"`)
+
+	_, err = dqlitedriver.New(store, dqlitedriver.WithAttach("", "other"))
+	assert.EqualError(t, err, `no database to attach as other`)
+}
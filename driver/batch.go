@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// BatchStatement is a single statement of a batch executed with
+// Conn.ExecBatch.
+//
+// Args holds the statement arguments, with the same types accepted by
+// database/sql, or with a type registered with RegisterCodec. Arguments
+// created with sql.Named are bound by name.
+type BatchStatement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// BatchResult is the outcome of a single statement of a batch. Exactly one
+// of Result and Err is set.
+type BatchResult struct {
+	Result driver.Result
+	Err    error
+}
+
+// ExecBatch executes the given statements in a single network round trip,
+// returning the result of each statement.
+//
+// All statements are sent before any result is received, so the statements
+// following a failed one are still executed. To make the batch atomic, execute
+// it within a transaction.
+//
+// ExecBatch can be reached from database/sql through sql.Conn.Raw:
+//
+//	conn.Raw(func(c interface{}) error {
+//		results, err = c.(*driver.Conn).ExecBatch(ctx, statements)
+//		return err
+//	})
+//
+// The returned error is only set if the batch couldn't be executed as a
+// whole, for example because the connection was lost.
+func (c *Conn) ExecBatch(ctx context.Context, statements []BatchStatement) ([]BatchResult, error) {
+	ctx, cancel := c.statementContext(ctx)
+	defer cancel()
+
+	requests := make([]*protocol.Message, len(statements))
+	responses := make([]*protocol.Message, len(statements))
+	queries := make([]string, len(statements))
+
+	for i, statement := range statements {
+		queries[i] = tagSQL(ctx, statement.SQL)
+		args, err := c.batchArgs(statement.Args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "statement %d", i)
+		}
+		if args, err = bindNamedValues(queries[i], args); err != nil {
+			return nil, errors.Wrapf(err, "statement %d", i)
+		}
+
+		requests[i] = &protocol.Message{}
+		requests[i].Init(4096)
+		responses[i] = &protocol.Message{}
+		responses[i].Init(64)
+
+		protocol.EncodeExecSQL(requests[i], uint64(c.id), queries[i], args)
+	}
+
+	defer c.queries.invalidate()
+	err := c.call(ctx, "exec batch", strings.Join(queries, ";\n"), func() error {
+		return c.protocol.CallBatch(ctx, requests, responses)
+	})
+	if err != nil {
+		return nil, c.error(err, false)
+	}
+
+	results := c.batchResults(responses)
+
+	if c.tracing != client.LogNone {
+		for _, query := range queries {
+			c.log(c.tracing, "exec batch: %s", query)
+		}
+	}
+
+	return results, nil
+}
+
+// ExecMany prepares the given statement once and executes it with each of the
+// given argument lists, in a single network round trip, returning the result
+// of each execution. It's meant for bulk loads, where sending one statement at
+// a time would be dominated by network latency.
+//
+// As for ExecBatch, the executions following a failed one still take place,
+// the returned error is only set if the statement couldn't be prepared or the
+// executions couldn't be sent as a whole, and ExecMany can be reached from
+// database/sql through sql.Conn.Raw.
+func (c *Conn) ExecMany(ctx context.Context, query string, args [][]interface{}) ([]BatchResult, error) {
+	prepared, err := c.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	stmt := prepared.(*Stmt)
+	defer stmt.Close()
+
+	ctx, cancel := c.statementContext(ctx)
+	defer cancel()
+
+	requests := make([]*protocol.Message, len(args))
+	responses := make([]*protocol.Message, len(args))
+
+	for i := range args {
+		values, err := c.batchArgs(args[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "execution %d", i)
+		}
+		if values, err = bindNamedValues(stmt.sql, values); err != nil {
+			return nil, errors.Wrapf(err, "execution %d", i)
+		}
+
+		requests[i] = &protocol.Message{}
+		requests[i].Init(4096)
+		responses[i] = &protocol.Message{}
+		responses[i].Init(64)
+
+		protocol.EncodeExec(requests[i], stmt.db, stmt.id, values)
+	}
+
+	defer c.queries.invalidate()
+	err = c.call(ctx, "exec many", stmt.sql, func() error {
+		return c.protocol.CallBatch(ctx, requests, responses)
+	})
+	if err != nil {
+		return nil, c.error(err, false)
+	}
+
+	results := c.batchResults(responses)
+
+	if c.tracing != client.LogNone {
+		c.log(c.tracing, "exec many (%d): %s", len(args), stmt.sql)
+	}
+
+	return results, nil
+}
+
+// Decode the responses to the Exec requests of a batch.
+func (c *Conn) batchResults(responses []*protocol.Message) []BatchResult {
+	results := make([]BatchResult, len(responses))
+	for i, response := range responses {
+		result, err := protocol.DecodeResult(response)
+		if err != nil {
+			results[i].Err = driverError(c.log, err)
+			continue
+		}
+		results[i].Result = &Result{result: result}
+	}
+	return results
+}
+
+// Convert the arguments of a batch statement to driver values.
+func (c *Conn) batchArgs(args []interface{}) ([]driver.NamedValue, error) {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i].Ordinal = i + 1
+		if named, ok := arg.(sql.NamedArg); ok {
+			values[i].Name = named.Name
+			arg = named.Value
+		}
+		value, err := c.convertArg(arg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d", i+1)
+		}
+		values[i].Value = value
+	}
+	return values, nil
+}
@@ -0,0 +1,192 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_ExecBatch(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	executed := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, sql)
+		if strings.HasPrefix(sql, "FAIL") {
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "boom"}
+		}
+		return clienttest.Result{LastInsertID: uint64(args[0].(int64)), RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	statements := []dqlitedriver.BatchStatement{
+		{SQL: "INSERT INTO t VALUES(?)", Args: []interface{}{1}},
+		{SQL: "FAIL", Args: []interface{}{int64(2)}},
+		{SQL: "INSERT INTO t VALUES(:n)", Args: []interface{}{sql.Named("n", 3)}},
+	}
+
+	var results []dqlitedriver.BatchResult
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		results, err = c.(*dqlitedriver.Conn).ExecBatch(ctx, statements)
+		return err
+	}))
+
+	// Statements after a failed one are executed too.
+	mu.Lock()
+	assert.Equal(t, []string{"INSERT INTO t VALUES(?)", "FAIL", "INSERT INTO t VALUES(:n)"}, executed)
+	mu.Unlock()
+	require.Len(t, results, 3)
+
+	id, err := results[0].Result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	assert.Nil(t, results[1].Result)
+	assert.EqualError(t, results[1].Err, "boom")
+
+	id, err = results[2].Result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+
+	// The connection is still usable.
+	_, err = conn.ExecContext(ctx, "INSERT INTO t VALUES(?)", 4)
+	require.NoError(t, err)
+}
+
+// Big batches don't deadlock when the socket buffers fill up.
+func TestConn_ExecBatch_Big(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	statements := make([]dqlitedriver.BatchStatement, 5000)
+	for i := range statements {
+		statements[i] = dqlitedriver.BatchStatement{
+			SQL:  "INSERT INTO t VALUES(?)",
+			Args: []interface{}{strings.Repeat("x", 1024)},
+		}
+	}
+
+	var results []dqlitedriver.BatchResult
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		results, err = c.(*dqlitedriver.Conn).ExecBatch(ctx, statements)
+		return err
+	}))
+	assert.Len(t, results, len(statements))
+}
+
+func TestConn_ExecMany(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	executed := []int64{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		n := args[0].(int64)
+		executed = append(executed, n)
+		if n == 2 {
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "boom"}
+		}
+		return clienttest.Result{LastInsertID: uint64(n), RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	args := [][]interface{}{{1}, {2}, {sql.Named("n", 3)}}
+
+	var results []dqlitedriver.BatchResult
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		results, err = c.(*dqlitedriver.Conn).ExecMany(ctx, "INSERT INTO t VALUES(:n)", args)
+		return err
+	}))
+
+	mu.Lock()
+	assert.Equal(t, []int64{1, 2, 3}, executed)
+	mu.Unlock()
+	require.Len(t, results, 3)
+
+	id, err := results[0].Result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	assert.EqualError(t, results[1].Err, "boom")
+
+	id, err = results[2].Result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+// Empty batches succeed without sending anything.
+func TestConn_ExecBatch_Empty(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		t.Errorf("unexpected statement %q", sql)
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		results, err := c.(*dqlitedriver.Conn).ExecBatch(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+
+		results, err = c.(*dqlitedriver.Conn).ExecMany(ctx, "INSERT INTO t VALUES(?)", nil)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+		return nil
+	}))
+
+	// The connection is still usable.
+	require.NoError(t, conn.PingContext(ctx))
+}
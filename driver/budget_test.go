@@ -0,0 +1,63 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Connections wait for the buffer budget to hold large responses, and give
+// back their buffers when the rows are closed.
+func TestWithBufferBudget(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		size := 64 * 1024
+		if sql == "SELECT huge" {
+			size = 1024 * 1024
+		}
+		return &clienttest.Rows{Columns: []string{"b"}, Values: [][]driver.Value{{make([]byte, size)}}}, nil
+	})
+
+	drv, err := dqlitedriver.New(newStore(t, server.Address()), dqlitedriver.WithBufferBudget(100*1024))
+	require.NoError(t, err)
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, "SELECT b")
+	require.NoError(t, err)
+	assert.True(t, drv.Stats().BufferedBytes > 64*1024)
+
+	// The budget can't hold another response until the rows are closed.
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	var b []byte
+	assert.Error(t, db.QueryRowContext(timeout, "SELECT b").Scan(&b))
+
+	// A waiting connection gets the response once the rows are closed.
+	done := make(chan error, 1)
+	go func() {
+		var b []byte
+		done <- db.QueryRowContext(ctx, "SELECT b").Scan(&b)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, rows.Close())
+	require.NoError(t, <-done)
+	assert.Equal(t, uint64(0), drv.Stats().BufferedBytes)
+
+	// A response bigger than the whole budget fails right away.
+	err = db.QueryRowContext(ctx, "SELECT huge").Scan(&b)
+	assert.Contains(t, err.Error(), "exceeds limit of 102400 bytes")
+}
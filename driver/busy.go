@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Policy for retrying statements failing because the database is busy or
+// locked. See WithBusyRetry.
+type busyRetry struct {
+	timeout time.Duration // Give up after this long, zero to never retry.
+	factor  time.Duration // Base of the exponential backoff.
+	cap     time.Duration // Maximum backoff between attempts.
+}
+
+// Default backoff between attempts, see WithBusyBackoff.
+const (
+	defaultBusyBackoffFactor = 5 * time.Millisecond
+	defaultBusyBackoffCap    = 250 * time.Millisecond
+)
+
+// Tell whether the error means that the database is busy or locked, so the
+// statement can be run again later.
+func isBusy(err error) bool {
+	if err, ok := errors.Cause(err).(protocol.ErrRequest); ok {
+		code := err.Code & 0xff
+		return code == ErrBusy || code == errLocked
+	}
+	return false
+}
+
+// Invoke the given function until it doesn't fail because the database is
+// busy, waiting with a jittered exponential backoff between attempts. The
+// last error is returned if the retry timeout expires or the context is done.
+func (c *Conn) retryBusy(ctx context.Context, f func() error) error {
+	deadline := time.Now().Add(c.busy.timeout)
+
+	for attempt := uint(0); ; attempt++ {
+		err := f()
+		if err == nil || !isBusy(err) {
+			return err
+		}
+		atomic.AddUint64(&c.stats.busyErrors, 1)
+		if c.busy.timeout <= 0 {
+			return err
+		}
+
+		backoff := c.busy.factor << attempt
+		// The backoff might be negative in case of integer overflow.
+		if backoff > c.busy.cap || backoff <= 0 {
+			backoff = c.busy.cap
+		}
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if time.Now().Add(wait).After(deadline) {
+			return err
+		}
+		c.log(client.LogDebug, "database busy, retry in %s", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		atomic.AddUint64(&c.stats.busyRetries, 1)
+	}
+}
@@ -0,0 +1,98 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Statements failing because the database is busy or locked are retried.
+func TestBusyRetry(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	failures := 0
+	fail := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		// SQLITE_BUSY, SQLITE_LOCKED and SQLITE_BUSY_SNAPSHOT.
+		codes := []uint64{5, 6, 5 | 2<<8}
+		if failures > 0 {
+			failures--
+			return clienttest.Error{Code: codes[failures], Message: "database is locked"}
+		}
+		return nil
+	}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, fail()
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, fail()
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?busy_retry=5s")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	mu.Lock()
+	failures = 3
+	mu.Unlock()
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(1)")
+	require.NoError(t, err)
+
+	mu.Lock()
+	failures = 3
+	mu.Unlock()
+	var n int64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n FROM t").Scan(&n))
+	assert.Equal(t, int64(1), n)
+
+	stmt, err := db.PrepareContext(ctx, "INSERT INTO t VALUES(?)")
+	require.NoError(t, err)
+	defer stmt.Close()
+	mu.Lock()
+	failures = 3
+	mu.Unlock()
+	_, err = stmt.ExecContext(ctx, int64(2))
+	require.NoError(t, err)
+}
+
+// Busy errors are returned once the retry timeout expires.
+func TestBusyRetry_Timeout(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{}, clienttest.Error{Code: dqlitedriver.ErrBusy, Message: "database is locked"}
+	})
+
+	store := newStore(t, server.Address())
+	drv, err := dqlitedriver.New(
+		store,
+		dqlitedriver.WithBusyRetry(100*time.Millisecond),
+		dqlitedriver.WithBusyBackoff(time.Millisecond, 10*time.Millisecond))
+	require.NoError(t, err)
+
+	conn, err := drv.Open("test.db")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, err = conn.(driver.ExecerContext).ExecContext(context.Background(), "INSERT INTO t VALUES(1)", nil)
+	assert.EqualError(t, err, "database is locked")
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, dqlitedriver.ErrBusy, int(err.(dqlitedriver.Error).Code))
+	assert.True(t, errors.Is(err, dqlitedriver.ErrNodeBusy))
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"container/list"
+	"context"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// LRU cache of server-side prepared statements of a connection, keyed by SQL
+// text.
+type stmtCache struct {
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used at the front.
+}
+
+// A cached prepared statement.
+type stmtCacheEntry struct {
+	sql    string
+	db     uint32
+	id     uint32
+	params uint64
+	inUse  bool // Whether a Stmt referencing this entry is open.
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Return the cached statement for the given SQL text, if any and not in use,
+// marking it as in use.
+func (c *stmtCache) get(sql string) *stmtCacheEntry {
+	element, ok := c.entries[sql]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*stmtCacheEntry)
+	if entry.inUse {
+		return nil
+	}
+	entry.inUse = true
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// Add a statement in use to the cache, returning the statements evicted to
+// make room for it, which should be finalized. If the SQL text is already
+// cached, the statement is not added and false is returned.
+func (c *stmtCache) add(entry *stmtCacheEntry) ([]*stmtCacheEntry, bool) {
+	if _, ok := c.entries[entry.sql]; ok {
+		return nil, false
+	}
+	entry.inUse = true
+	c.entries[entry.sql] = c.lru.PushFront(entry)
+
+	// Statements in use can't be evicted, so the cache might temporarily
+	// exceed its size.
+	evicted := []*stmtCacheEntry{}
+	for element := c.lru.Back(); element != nil && c.lru.Len() > c.size; {
+		prev := element.Prev()
+		if victim := element.Value.(*stmtCacheEntry); !victim.inUse {
+			c.lru.Remove(element)
+			delete(c.entries, victim.sql)
+			evicted = append(evicted, victim)
+		}
+		element = prev
+	}
+
+	return evicted, true
+}
+
+// Finalize the given statements on the server.
+func (c *Conn) finalize(ctx context.Context, entries []*stmtCacheEntry) error {
+	for _, entry := range entries {
+		protocol.EncodeFinalize(&c.request, entry.db, entry.id)
+
+		if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
+			return err
+		}
+
+		if err := protocol.DecodeEmpty(&c.response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
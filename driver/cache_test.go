@@ -0,0 +1,94 @@
+package driver_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Prepared statements are reused until they get evicted from the cache.
+func TestWithStatementCacheSize(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	capture := client.NewCapture(buf)
+
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), []client.NodeInfo{{Address: server.Address()}})
+
+	drv, err := dqlitedriver.New(
+		store,
+		dqlitedriver.WithDialFunc(client.DialFuncWithCapture(client.DefaultDialFunc, capture)),
+		dqlitedriver.WithStatementCacheSize(2),
+	)
+	require.NoError(t, err)
+
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	prepare := func(query string) *sql.Stmt {
+		stmt, err := db.Prepare(query)
+		require.NoError(t, err)
+		return stmt
+	}
+
+	// Count the Prepare and Finalize requests sent since the last call.
+	seen := 0
+	count := func() (prepares, finalizes int) {
+		frames, err := client.ReadCapture(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		for _, frame := range frames[seen:] {
+			if frame.Direction != protocol.CaptureSent {
+				continue
+			}
+			switch frame.Type {
+			case protocol.RequestPrepare:
+				prepares++
+			case protocol.RequestFinalize:
+				finalizes++
+			}
+		}
+		seen = len(frames)
+		return prepares, finalizes
+	}
+
+	prepare("SELECT 1").Close()
+	prepare("SELECT 1").Close()
+	prepares, finalizes := count()
+	assert.Equal(t, 1, prepares)
+	assert.Equal(t, 0, finalizes)
+
+	// A statement in use is not shared.
+	stmt := prepare("SELECT 1")
+	prepare("SELECT 1").Close()
+	stmt.Close()
+	prepares, finalizes = count()
+	assert.Equal(t, 1, prepares)
+	assert.Equal(t, 1, finalizes)
+
+	// The least recently used statement is evicted.
+	prepare("SELECT 2").Close()
+	prepare("SELECT 3").Close()
+	prepare("SELECT 2").Close()
+	prepares, finalizes = count()
+	assert.Equal(t, 2, prepares)
+	assert.Equal(t, 1, finalizes)
+
+	prepare("SELECT 1").Close()
+	prepares, finalizes = count()
+	assert.Equal(t, 1, prepares)
+	assert.Equal(t, 1, finalizes)
+}
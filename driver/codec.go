@@ -0,0 +1,117 @@
+package driver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Codec converts values of a custom Go type to and from column values, for
+// types that don't implement driver.Valuer and sql.Scanner themselves, like
+// the ones defined by third party packages. See RegisterCodec.
+type Codec interface {
+	// Encode converts a value of the registered type to one of int64,
+	// float64, bool, []byte, string, time.Time or nil.
+	Encode(value interface{}) (driver.Value, error)
+
+	// Decode converts a column value, which has one of the types returned
+	// by Encode, and stores it in dest, which is a pointer to a value of
+	// the registered type.
+	Decode(value driver.Value, dest interface{}) error
+}
+
+var codecs = struct {
+	mu    sync.RWMutex
+	types map[reflect.Type]Codec
+}{types: map[reflect.Type]Codec{}}
+
+// RegisterCodec registers the codec to use for the type of the given value,
+// for example:
+//
+//	driver.RegisterCodec(uuid.UUID{}, uuidCodec{})
+//
+// Values of the registered type, or pointers to them, can then be passed as
+// statement arguments, and scanned from query results with Decode.
+func RegisterCodec(value interface{}, codec Codec) {
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	codecs.types[reflect.TypeOf(value)] = codec
+}
+
+// Return the codec registered for the given type, if any.
+func lookupCodec(typ reflect.Type) Codec {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	return codecs.types[typ]
+}
+
+// Decode returns a sql.Scanner storing a column value in dest, which must be
+// a pointer to a value of a type registered with RegisterCodec:
+//
+//	var id uuid.UUID
+//	err := row.Scan(driver.Decode(&id))
+func Decode(dest interface{}) sql.Scanner {
+	return decoder{dest: dest}
+}
+
+type decoder struct {
+	dest interface{}
+}
+
+func (d decoder) Scan(value interface{}) error {
+	typ := reflect.TypeOf(d.dest)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return errors.Errorf("decode destination must be a pointer, not %T", d.dest)
+	}
+	codec := lookupCodec(typ.Elem())
+	if codec == nil {
+		return errors.Errorf("no codec registered for %s", typ.Elem())
+	}
+	return codec.Decode(value, d.dest)
+}
+
+// Convert a statement argument to a driver value, using the registered codec
+// for its type, if any.
+func convertValue(value interface{}) (driver.Value, error) {
+	if value != nil {
+		typ := reflect.TypeOf(value)
+		if codec := lookupCodec(typ); codec != nil {
+			return encodeValue(codec, value)
+		}
+		if typ.Kind() == reflect.Ptr {
+			if codec := lookupCodec(typ.Elem()); codec != nil {
+				v := reflect.ValueOf(value)
+				if v.IsNil() {
+					return nil, nil
+				}
+				return encodeValue(codec, v.Elem().Interface())
+			}
+		}
+	}
+	return driver.DefaultParameterConverter.ConvertValue(value)
+}
+
+func encodeValue(codec Codec, value interface{}) (driver.Value, error) {
+	encoded, err := codec.Encode(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encode %T", value)
+	}
+	if !driver.IsValue(encoded) {
+		return nil, errors.Errorf("codec for %T returned unsupported type %T", value, encoded)
+	}
+	return encoded, nil
+}
+
+// CheckNamedValue converts the values of the types registered with
+// RegisterCodec, and of the types supported by database/sql.
+func (c *Conn) CheckNamedValue(value *driver.NamedValue) error {
+	v, err := c.convertArg(value.Value)
+	if err != nil {
+		return err
+	}
+	value.Value = v
+	return nil
+}
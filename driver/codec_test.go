@@ -0,0 +1,116 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A custom type, stored as text.
+type point struct {
+	X, Y int64
+}
+
+type pointCodec struct{}
+
+func (pointCodec) Encode(value interface{}) (driver.Value, error) {
+	p := value.(point)
+	return fmt.Sprintf("%d,%d", p.X, p.Y), nil
+}
+
+func (pointCodec) Decode(value driver.Value, dest interface{}) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("can't decode point from %T", value)
+	}
+	p := dest.(*point)
+	_, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y)
+	return err
+}
+
+func init() {
+	dqlitedriver.RegisterCodec(point{}, pointCodec{})
+}
+
+// Values of types with a registered codec can be used as arguments and
+// scanned from results.
+func TestCodec(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"p"}, Values: [][]driver.Value{{"3,-4"}}}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	var nilPoint *point
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(?, ?, ?, ?)", point{1, 2}, &point{5, 6}, nilPoint, int64(7))
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []driver.Value{"1,2", "5,6", nil, int64(7)}, bound)
+	mu.Unlock()
+
+	var p point
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT p FROM t").Scan(dqlitedriver.Decode(&p)))
+	assert.Equal(t, point{3, -4}, p)
+
+	var n int64
+	err = db.QueryRowContext(ctx, "SELECT p FROM t").Scan(dqlitedriver.Decode(&n))
+	assert.EqualError(t, err, `sql: Scan error on column index 0, name "p": no codec registered for int64`)
+}
+
+// JSON values are marshaled as arguments and unmarshaled when scanned.
+func TestJSON(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"p"}, Values: [][]driver.Value{{`{"X":3,"Y":-4}`}}}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(?)", client.JSON{V: point{1, 2}})
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []driver.Value{`{"X":1,"Y":2}`}, bound)
+	mu.Unlock()
+
+	var p point
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT p FROM t").Scan(client.JSON{V: &p}))
+	assert.Equal(t, point{3, -4}, p)
+}
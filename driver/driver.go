@@ -12,40 +12,87 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package driver implements a database/sql driver for dqlite.
+//
+// All requests, including read-only queries, are served by the cluster
+// leader, which the driver finds using its node store. Nodes that are not the
+// leader reject queries with a "not leader" error, and don't report how far
+// their copy of the data lags behind, so reads can't be routed to stand-by or
+// spare nodes.
+//
+// Before running a query the leader waits for all committed entries of its
+// log to be applied (a raft barrier), so queries observe the statements it
+// committed before they were issued. This doesn't make reads linearizable: a
+// leader that got partitioned from the rest of the cluster keeps serving
+// queries until it notices that it was deposed, and meanwhile a new leader
+// might commit statements that it can't see. Use WithQuorumReads to have the
+// other voters confirm the leader after each query, at the cost of one more
+// round trip to a majority of them over connections that the driver keeps
+// open. Stale reads served by other nodes would require support in the dqlite
+// wire protocol and are not available.
 package driver
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql/driver"
 	"io"
 	"net"
 	"reflect"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/clientbridge"
 	"github.com/canonical/go-dqlite/internal/protocol"
 )
 
 // Driver perform queries against a dqlite server.
 type Driver struct {
-	log               client.LogFunc   // Log function to use
-	store             client.NodeStore // Holds addresses of dqlite servers
-	context           context.Context  // Global cancellation context
-	connectionTimeout time.Duration    // Max time to wait for a new connection
-	contextTimeout    time.Duration    // Default client context timeout.
-	clientConfig      protocol.Config  // Configuration for dqlite client instances
-	tracing           client.LogLevel  // Whether to trace statements
+	log               client.LogFunc      // Log function to use
+	store             client.NodeStore    // Holds addresses of dqlite servers
+	context           context.Context     // Global cancellation context
+	connectionTimeout time.Duration       // Max time to wait for a new connection
+	contextTimeout    time.Duration       // Default client context timeout.
+	connector         *protocol.Connector // Finds the leader and creates dqlite client instances
+	tracing           client.LogLevel     // Whether to trace statements
+	stmtCacheSize     int                 // Prepared statements to cache per connection
+	failover          FailoverRetry       // Operations to retry when the leader is lost
+	txLock            TxLock              // Lock mode of write transactions
+	pragmas           []pragma            // Pragmas to set on new connections
+	attachments       []attachment        // Databases to attach to new connections
+	busy              busyRetry           // Retry policy for busy errors
+	timeFormat        TimeFormat          // Format of time.Time arguments
+	timeLocation      *time.Location      // Location of scanned timestamps
+	stats             *stats              // Activity counters
+	slowQueryHook     SlowQueryFunc       // Invoked for slow statements
+	slowQueryTime     time.Duration       // Threshold of slow statements
+	timingHook        TimingFunc          // Invoked with the timing of statements
+	metrics           Metrics             // Receiver of measurements, if any
+	spans             SpanFunc            // Starts trace spans, if set
+	statementTimeout  time.Duration       // Default timeout of statements
+	keepAlive         time.Duration       // Idle time after which connections are pinged
+	maxLifetime       time.Duration       // Age after which connections are replaced
+	maxIdleTime       time.Duration       // Idle time after which connections are replaced
+	resultLimits      ResultLimits        // Default limits of result sets
+	budget            *protocol.Budget    // Budget of response buffers
+	queries           *queryCache         // Cached result sets, if enabled
+	quorumReads       bool                // Whether queries confirm the leader
 }
 
-// Error is returned in case of database errors.
+// Error is returned in case of database errors. It holds the extended SQLite
+// result code, so failures like constraint violations can be told apart from
+// I/O errors with PrimaryCode, and the message provided by the server.
 type Error = protocol.Error
 
 // Error codes. Values here mostly overlap with native SQLite codes.
 const (
 	ErrBusy                = 5
+	errLocked              = 6
 	errIoErr               = 10
 	errIoErrNotLeader      = errIoErr | 40<<8
 	errIoErrLeadershipLost = errIoErr | (41 << 8)
@@ -68,7 +115,10 @@ type NodeInfo = client.NodeInfo
 // DefaultNodeStore is a convenience alias of client.DefaultNodeStore.
 var DefaultNodeStore = client.DefaultNodeStore
 
-// WithLogFunc sets a custom logging function.
+// WithLogFunc sets a custom logging function. Besides the events of the
+// connections with the cluster, like attempts to find the leader and leader
+// changes, it reports statements retried because of failovers or of busy
+// errors.
 func WithLogFunc(log client.LogFunc) Option {
 	return func(options *options) {
 		options.Log = log
@@ -98,6 +148,16 @@ func WithConnectionTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithDialTimeout sets the maximum amount of time to wait for the network
+// connection to a node to be established, when looking for the leader.
+//
+// If not used, the default is 5 seconds.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.DialTimeout = timeout
+	}
+}
+
 // WithConnectionBackoffFactor sets the exponential backoff factor for retrying
 // failed connection attempts.
 //
@@ -118,6 +178,15 @@ func WithConnectionBackoffCap(cap time.Duration) Option {
 	}
 }
 
+// WithConnectionBackoff sets the backoff strategy for retrying failed
+// connection attempts, overriding WithConnectionBackoffFactor and
+// WithConnectionBackoffCap. See client.WithBackoffFunc.
+func WithConnectionBackoff(backoff client.BackoffFunc) Option {
+	return func(options *options) {
+		options.ConnectionBackoff = backoff
+	}
+}
+
 // WithAttemptTimeout sets the timeout for each individual connection attempt.
 //
 // The Connector.Connect() and Driver.Open() methods try to find the current
@@ -142,6 +211,253 @@ func WithRetryLimit(limit uint) Option {
 	}
 }
 
+// WithReadTimeout sets the maximum amount of time to wait for each individual
+// read from the network connection with a dqlite node.
+//
+// The timeout is refreshed every time some data is received, so it won't
+// abort a large result set that keeps streaming in. Context deadlines are
+// always honored as well.
+//
+// If not used, the default is 0 (no timeout).
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.ReadTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum amount of time to wait for a request to be
+// written to the network connection with a dqlite node.
+//
+// If not used, the default is 0 (no timeout).
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.WriteTimeout = timeout
+	}
+}
+
+// WithMaxMessageSize sets the maximum size in bytes of a single response
+// message that a dqlite node is allowed to send.
+//
+// If a node sends a bigger message, the connection is aborted and an
+// ErrMessageTooLarge error is returned.
+//
+// If not used, the default is 0 (unlimited).
+func WithMaxMessageSize(size int) Option {
+	return func(options *options) {
+		options.Limits.MessageSize = size
+	}
+}
+
+// WithLimits sets the limits enforced on the responses of dqlite nodes,
+// replacing the one set with WithMaxMessageSize. See client.WithLimits.
+func WithLimits(limits client.Limits) Option {
+	return func(options *options) {
+		options.Limits = limits
+	}
+}
+
+// WithBufferBudget sets the maximum number of bytes that the buffers holding
+// the responses received by all the connections of the driver can take.
+//
+// Buffers are accounted for only when a response doesn't fit in the initial
+// buffer of a connection, and are given back when the rows decoded from them
+// are closed, or at the latest when the connection is returned to the pool.
+// A connection receiving a response that would exceed the budget waits for
+// other connections to give back their buffers, until the deadline of the
+// operation expires. A response bigger than the whole budget fails with an
+// ErrMessageTooLarge error.
+//
+// If not used, the default is 0 (unlimited). The number of bytes currently
+// held is reported in Stats either way.
+func WithBufferBudget(size int) Option {
+	return func(options *options) {
+		options.BufferBudget = size
+	}
+}
+
+// WithStrictDecoding makes the driver reject responses that contain more data
+// than expected, returning an ErrMalformedMessage error.
+//
+// Truncated responses are always rejected, regardless of this option.
+func WithStrictDecoding() Option {
+	return func(options *options) {
+		options.StrictDecoding = true
+	}
+}
+
+// WithTLS enables TLS encryption of the connections to dqlite nodes, trusting
+// the node certificates signed by the given pool and presenting the given
+// client certificate, if not nil, for mutual authentication.
+//
+// See client.NewTLSConfig for details.
+func WithTLS(cert *tls.Certificate, pool *x509.CertPool) Option {
+	return func(options *options) {
+		options.TLSConfig = client.NewTLSConfig(cert, pool)
+	}
+}
+
+// WithTLSConfig enables TLS encryption of the connections to dqlite nodes
+// using the given configuration.
+//
+// The configuration is evaluated on every new connection, so certificates
+// returned by callbacks like GetClientCertificate can be rotated without
+// re-creating the driver (see client.CertificateLoader).
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *options) {
+		options.TLSConfig = config
+	}
+}
+
+// WithAuth sets a function that is invoked right after the protocol version is
+// sent to a node, and that can exchange arbitrary data over the connection in
+// order to authenticate against a proxy fronting it.
+//
+// If authentication fails, connecting returns a client.ErrAuthentication
+// error without trying other nodes.
+func WithAuth(auth client.AuthFunc) Option {
+	return func(options *options) {
+		options.Auth = auth
+	}
+}
+
+// WithAuthToken authenticates against a proxy fronting dqlite nodes by sending
+// the given token right after the protocol version. See client.WithAuthToken
+// for the details of the exchange.
+func WithAuthToken(token string) Option {
+	return WithAuth(protocol.TokenAuth(token))
+}
+
+// WithLeaderChange sets a function invoked when the driver connects to a
+// leader different from the one it previously connected to.
+//
+// The function is called synchronously while opening a connection, so it
+// should not block.
+func WithLeaderChange(f func(old, new client.NodeInfo)) Option {
+	return func(options *options) {
+		options.LeaderChange = f
+	}
+}
+
+// WithUpdateStore makes the driver save the cluster configuration in its node
+// store every time it connects to the leader. See client.WithUpdateStore.
+func WithUpdateStore(update bool) Option {
+	return func(options *options) {
+		options.UpdateStore = update
+	}
+}
+
+// WithStandbyConnections makes the driver keep the given number of idle
+// connections to voters other than the leader, established in the background
+// after connecting to the leader.
+//
+// After a leader election, if one of these nodes became the leader, the
+// driver reuses its connection instead of dialing, which costs a single
+// request instead of a full connection handshake. Nodes are taken from the
+// node store, whose roles should be kept up to date, see WithUpdateStore.
+// The standby connections are closed by Connector.Close, which database/sql
+// invokes when closing the DB.
+func WithStandbyConnections(n int) Option {
+	return func(options *options) {
+		options.StandbyConnections = n
+	}
+}
+
+// WithStatementCacheSize sets the number of prepared statements that each
+// connection keeps on the server for reuse, keyed by SQL text.
+//
+// With a cache, preparing a statement that was prepared and closed before on
+// the same connection doesn't require a round trip, and closing a statement
+// doesn't release it on the server until it gets evicted. This helps with
+// frameworks that prepare every query they run.
+//
+// If not used, the default is 0, which disables the cache.
+func WithStatementCacheSize(size int) Option {
+	return func(options *options) {
+		options.StatementCacheSize = size
+	}
+}
+
+// WithFailoverRetry sets which operations the driver lets database/sql retry
+// on a new connection, when the connection to the leader is lost or the
+// leader steps down. See FailoverRetry.
+//
+// If not used, the default is RetryIdempotent.
+func WithFailoverRetry(retry FailoverRetry) Option {
+	return func(options *options) {
+		options.FailoverRetry = retry
+	}
+}
+
+// WithTxLock sets the lock mode of the transactions that are not read-only.
+// See TxLock.
+//
+// If not used, the default is TxDeferred.
+func WithTxLock(lock TxLock) Option {
+	return func(options *options) {
+		options.TxLock = lock
+	}
+}
+
+// WithBusyRetry makes the driver retry statements failing because the
+// database is busy or locked, with SQLITE_BUSY or SQLITE_LOCKED, until the
+// given timeout expires or the statement context is done. Attempts are spaced
+// with a jittered exponential backoff, see WithBusyBackoff.
+//
+// Retrying can't succeed when a deferred transaction fails to upgrade its read
+// lock to a write lock, since the transaction must be rolled back first. Use
+// TxImmediate transactions to avoid that.
+//
+// If not used, the default is to never retry.
+func WithBusyRetry(timeout time.Duration) Option {
+	return func(options *options) {
+		options.BusyRetry = timeout
+	}
+}
+
+// WithBusyBackoff sets the base and the maximum of the exponential backoff
+// between attempts of statements failing because the database is busy. See
+// WithBusyRetry.
+//
+// If not used, the defaults are 5 milliseconds and 250 milliseconds.
+func WithBusyBackoff(factor, cap time.Duration) Option {
+	return func(options *options) {
+		options.BusyBackoffFactor = factor
+		options.BusyBackoffCap = cap
+	}
+}
+
+// WithTimeFormat sets how time.Time arguments are stored. See TimeFormat.
+//
+// If not used, the default is TimeISO8601.
+func WithTimeFormat(format TimeFormat) Option {
+	return func(options *options) {
+		options.TimeFormat = format
+	}
+}
+
+// WithTimeLocation sets the location of the timestamps scanned from query
+// results. Text timestamps without a time zone are considered to be in that
+// location too.
+//
+// If not used, text timestamps without a time zone are considered UTC, and
+// all timestamps are returned in local time.
+func WithTimeLocation(location *time.Location) Option {
+	return func(options *options) {
+		options.TimeLocation = location
+	}
+}
+
+// WithPragma sets a PRAGMA to be run on each new connection, for example
+// WithPragma("foreign_keys", "on"). Pragmas are run in the order they are
+// given, right after the database is opened.
+//
+// The name and the value must be plain SQL words or numbers.
+func WithPragma(name, value string) Option {
+	return func(options *options) {
+		options.Pragmas = append(options.Pragmas, pragma{name: name, value: value})
+	}
+}
+
 // WithContext sets a global cancellation context.
 //
 // DEPRECATED: This API is no a no-op. Users should explicitly pass a context
@@ -163,6 +479,42 @@ func WithContextTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithCallTimeouts sets the timeouts applied to the requests sent by the
+// driver connections whose context has no deadline, by kind of request. See
+// client.WithCallTimeouts. WithStatementTimeout takes precedence for
+// statements and queries.
+func WithCallTimeouts(timeouts client.CallTimeouts) Option {
+	return func(options *options) {
+		options.CallTimeouts = timeouts
+	}
+}
+
+// WithAdaptiveTimeout makes the timeout of the requests sent by the driver
+// connections whose context has no deadline follow their recent latency,
+// within the given bounds. See client.WithAdaptiveTimeout.
+func WithAdaptiveTimeout(min, max time.Duration) Option {
+	return func(options *options) {
+		options.AdaptiveTimeoutMin = min
+		options.AdaptiveTimeoutMax = max
+	}
+}
+
+// WithCallStats sets a function invoked after each request sent by the
+// driver connections. See client.WithCallStats.
+func WithCallStats(fn client.CallStatsFunc) Option {
+	return func(options *options) {
+		options.CallStats = fn
+	}
+}
+
+// WithAddressTranslator sets a function applied to every node address learned
+// from the cluster. See client.WithAddressTranslator.
+func WithAddressTranslator(translate client.AddressTranslator) Option {
+	return func(options *options) {
+		options.Translate = translate
+	}
+}
+
 // WithTracing will emit a log message at the given level every time a
 // statement gets executed.
 func WithTracing(level client.LogLevel) Option {
@@ -180,6 +532,62 @@ func New(store client.NodeStore, options ...Option) (*Driver, error) {
 		option(o)
 	}
 
+	for _, p := range o.Pragmas {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range o.Attachments {
+		if err := a.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	dial := o.Dial
+	if o.TLSConfig != nil {
+		dial = client.DialFuncWithTLS(dial, o.TLSConfig)
+	}
+
+	counters := &stats{}
+	budget := protocol.NewBudget(o.BufferBudget)
+
+	var queries *queryCache
+	if o.QueryCacheTTL > 0 && o.QueryCacheSize > 0 {
+		queries = newQueryCache(o.QueryCacheTTL, o.QueryCacheSize)
+	}
+
+	var adaptive *protocol.AdaptiveTimeout
+	if o.AdaptiveTimeoutMax > 0 {
+		adaptive = protocol.NewAdaptiveTimeout(o.AdaptiveTimeoutMin, o.AdaptiveTimeoutMax)
+	}
+
+	config := protocol.Config{
+		Dial:            dial,
+		DialTimeout:     o.DialTimeout,
+		AttemptTimeout:  o.AttemptTimeout,
+		BackoffFactor:   o.ConnectionBackoffFactor,
+		BackoffCap:      o.ConnectionBackoffCap,
+		Backoff:         o.ConnectionBackoff,
+		RetryLimit:      o.RetryLimit,
+		ReadTimeout:     o.ReadTimeout,
+		WriteTimeout:    o.WriteTimeout,
+		Limits:          o.Limits,
+		StrictDecoding:  o.StrictDecoding,
+		Auth:            o.Auth,
+		LeaderChange:    o.LeaderChange,
+		UpdateStore:     o.UpdateStore,
+		Stats:           &counters.protocol,
+		Budget:          budget,
+		Standby:         o.StandbyConnections,
+		CallTimeouts:    o.CallTimeouts,
+		CallStats:       o.CallStats,
+		AdaptiveTimeout: adaptive,
+		KeepAlive:       o.KeepAlive,
+		MaxLifetime:     o.MaxLifetime,
+		MaxIdleTime:     o.MaxIdleTime,
+		Translate:       o.Translate,
+	}
+
 	driver := &Driver{
 		log:               o.Log,
 		store:             store,
@@ -187,13 +595,34 @@ func New(store client.NodeStore, options ...Option) (*Driver, error) {
 		connectionTimeout: o.ConnectionTimeout,
 		contextTimeout:    o.ContextTimeout,
 		tracing:           o.Tracing,
-		clientConfig: protocol.Config{
-			Dial:           o.Dial,
-			AttemptTimeout: o.AttemptTimeout,
-			BackoffFactor:  o.ConnectionBackoffFactor,
-			BackoffCap:     o.ConnectionBackoffCap,
-			RetryLimit:     o.RetryLimit,
+		stmtCacheSize:     o.StatementCacheSize,
+		failover:          o.FailoverRetry,
+		txLock:            o.TxLock,
+		pragmas:           o.Pragmas,
+		attachments:       o.Attachments,
+		timeFormat:        o.TimeFormat,
+		timeLocation:      o.TimeLocation,
+		stats:             counters,
+		budget:            budget,
+		queries:           queries,
+		quorumReads:       o.QuorumReads,
+		slowQueryHook:     o.SlowQueryHook,
+		slowQueryTime:     o.SlowQueryThreshold,
+		timingHook:        o.TimingHook,
+		statementTimeout:  o.StatementTimeout,
+		keepAlive:         o.KeepAlive,
+		maxLifetime:       o.MaxLifetime,
+		maxIdleTime:       o.MaxIdleTime,
+		resultLimits:      o.ResultLimits,
+		metrics:           o.Metrics,
+		spans:             o.Spans,
+		busy: busyRetry{
+			timeout: o.BusyRetry,
+			factor:  o.BusyBackoffFactor,
+			cap:     o.BusyBackoffCap,
 		},
+		// TODO: generate a client ID.
+		connector: protocol.NewConnector(0, store, config, o.Log),
 	}
 
 	return driver, nil
@@ -203,12 +632,52 @@ func New(store client.NodeStore, options ...Option) (*Driver, error) {
 type options struct {
 	Log                     client.LogFunc
 	Dial                    protocol.DialFunc
+	DialTimeout             time.Duration
 	AttemptTimeout          time.Duration
 	ConnectionTimeout       time.Duration
 	ContextTimeout          time.Duration
 	ConnectionBackoffFactor time.Duration
 	ConnectionBackoffCap    time.Duration
+	ConnectionBackoff       client.BackoffFunc
 	RetryLimit              uint
+	ReadTimeout             time.Duration
+	WriteTimeout            time.Duration
+	Limits                  client.Limits
+	BufferBudget            int
+	StrictDecoding          bool
+	TLSConfig               *tls.Config
+	Auth                    client.AuthFunc
+	LeaderChange            func(old, new client.NodeInfo)
+	UpdateStore             bool
+	StandbyConnections      int
+	CallTimeouts            client.CallTimeouts
+	CallStats               client.CallStatsFunc
+	AdaptiveTimeoutMin      time.Duration
+	AdaptiveTimeoutMax      time.Duration
+	Translate               client.AddressTranslator
+	StatementCacheSize      int
+	FailoverRetry           FailoverRetry
+	TxLock                  TxLock
+	Pragmas                 []pragma
+	Attachments             []attachment
+	BusyRetry               time.Duration
+	BusyBackoffFactor       time.Duration
+	BusyBackoffCap          time.Duration
+	TimeFormat              TimeFormat
+	TimeLocation            *time.Location
+	SlowQueryThreshold      time.Duration
+	SlowQueryHook           SlowQueryFunc
+	TimingHook              TimingFunc
+	StatementTimeout        time.Duration
+	KeepAlive               time.Duration
+	MaxLifetime             time.Duration
+	MaxIdleTime             time.Duration
+	ResultLimits            ResultLimits
+	QueryCacheTTL           time.Duration
+	QueryCacheSize          int
+	QuorumReads             bool
+	Metrics                 Metrics
+	Spans                   SpanFunc
 	Context                 context.Context
 	Tracing                 client.LogLevel
 }
@@ -216,9 +685,11 @@ type options struct {
 // Create a options object with sane defaults.
 func defaultOptions() *options {
 	return &options{
-		Log:     client.DefaultLogFunc,
-		Dial:    client.DefaultDialFunc,
-		Tracing: client.LogNone,
+		Log:               client.DefaultLogFunc,
+		Dial:              client.DefaultDialFunc,
+		BusyBackoffFactor: defaultBusyBackoffFactor,
+		BusyBackoffCap:    defaultBusyBackoffCap,
+		Tracing:           client.LogNone,
 	}
 }
 
@@ -229,6 +700,25 @@ type Connector struct {
 	driver *Driver
 }
 
+// NewConnector creates a new driver with the given node store and options,
+// and returns a Connector opening the given database. It's meant to be used
+// with sql.OpenDB, configuring the driver programmatically instead of through
+// a data source name:
+//
+//	connector, err := driver.NewConnector(store, "test.db", driver.WithLogFunc(log))
+//	...
+//	db := sql.OpenDB(connector)
+//
+// New connections are established with the context passed to Connect, so
+// finding the leader is interrupted when the context is done.
+func NewConnector(store client.NodeStore, database string, options ...Option) (*Connector, error) {
+	d, err := New(store, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{uri: database, driver: d}, nil
+}
+
 // Connect returns a connection to the database.
 func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
 	if c.driver.context != nil {
@@ -241,17 +731,44 @@ func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
 		defer cancel()
 	}
 
-	// TODO: generate a client ID.
-	connector := protocol.NewConnector(0, c.driver.store, c.driver.clientConfig, c.driver.log)
-
 	conn := &Conn{
-		log:            c.driver.log,
-		contextTimeout: c.driver.contextTimeout,
-		tracing:        c.driver.tracing,
+		log:              c.driver.log,
+		contextTimeout:   c.driver.contextTimeout,
+		tracing:          c.driver.tracing,
+		failover:         c.driver.failover,
+		txLock:           c.driver.txLock,
+		busy:             c.driver.busy,
+		timeFormat:       c.driver.timeFormat,
+		timeLocation:     c.driver.timeLocation,
+		stats:            c.driver.stats,
+		slowQueryHook:    c.driver.slowQueryHook,
+		slowQueryTime:    c.driver.slowQueryTime,
+		timingHook:       c.driver.timingHook,
+		statementTimeout: c.driver.statementTimeout,
+		keepAlive:        c.driver.keepAlive,
+		maxLifetime:      c.driver.maxLifetime,
+		maxIdleTime:      c.driver.maxIdleTime,
+		resultLimits:     c.driver.resultLimits,
+		metrics:          c.driver.metrics,
+		spans:            c.driver.spans,
+		queries:          c.driver.queries,
+		connector:        c.driver.connector,
+		quorumReads:      c.driver.quorumReads,
+		database:         c.uri,
+	}
+	if c.driver.stmtCacheSize > 0 {
+		conn.stmts = newStmtCache(c.driver.stmtCacheSize)
 	}
 
 	var err error
-	conn.protocol, err = connector.Connect(ctx)
+	start := time.Now()
+	conn.protocol, err = c.driver.connector.Connect(ctx)
+	if err == nil {
+		conn.address = conn.protocol.Address()
+	}
+	if c.driver.metrics != nil {
+		c.driver.metrics.ObserveConnect(conn.address, time.Since(start), err)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create dqlite connection")
 	}
@@ -272,9 +789,29 @@ func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
 		return nil, errors.Wrap(err, "failed to open database")
 	}
 
+	if err := conn.setPragmas(ctx, c.driver.pragmas); err != nil {
+		conn.protocol.Close()
+		return nil, err
+	}
+
+	if err := conn.attach(ctx, c.driver.attachments); err != nil {
+		conn.protocol.Close()
+		return nil, err
+	}
+
+	conn.created = time.Now()
+	atomic.AddUint64(&conn.stats.openConns, 1)
+
 	return conn, nil
 }
 
+// Close closes the standby connections kept by the driver, see
+// WithStandbyConnections. Connections already opened are not affected.
+func (c *Connector) Close() error {
+	c.driver.connector.Close()
+	return nil
+}
+
 // Driver returns the underlying Driver of the Connector,
 func (c *Connector) Driver() driver.Driver {
 	return c.driver
@@ -315,47 +852,110 @@ func (d *Driver) Open(uri string) (driver.Conn, error) {
 // if they wish to cancel their requests, or use the WithContextTimeout option.
 func (d *Driver) SetContextTimeout(timeout time.Duration) {}
 
+// ErrMessageTooLarge is returned when a node sends a response bigger than the
+// limit set with WithMaxMessageSize.
+type ErrMessageTooLarge = protocol.ErrMessageTooLarge
+
+// ErrMalformedMessage is returned when a node sends a response that can't be
+// decoded.
+var ErrMalformedMessage = protocol.ErrMalformedMessage
+
 // ErrNoAvailableLeader is returned as root cause of Open() if there's no
 // leader available in the cluster.
 var ErrNoAvailableLeader = protocol.ErrNoAvailableLeader
 
+// ErrNodeBusy matches with errors.Is the Error values of statements failed
+// because the database is busy or locked. See also WithBusyRetry.
+var ErrNodeBusy = protocol.ErrNodeBusy
+
+// ErrAuthentication is returned as root cause of Open() if authentication
+// against a node fails. See WithAuth.
+type ErrAuthentication = protocol.ErrAuthentication
+
 // Conn implements the sql.Conn interface.
 type Conn struct {
-	log            client.LogFunc
-	protocol       *protocol.Protocol
-	request        protocol.Message
-	response       protocol.Message
-	id             uint32 // Database ID.
-	contextTimeout time.Duration
-	tracing        client.LogLevel
+	log              client.LogFunc
+	protocol         *protocol.Protocol
+	request          protocol.Message
+	response         protocol.Message
+	id               uint32 // Database ID.
+	contextTimeout   time.Duration
+	tracing          client.LogLevel
+	stmts            *stmtCache  // Prepared statements cache, if enabled.
+	queries          *queryCache // Result sets cache, if enabled.
+	connector        *protocol.Connector
+	quorumReads      bool
+	failover         FailoverRetry
+	txLock           TxLock
+	busy             busyRetry
+	timeFormat       TimeFormat
+	timeLocation     *time.Location
+	stats            *stats
+	slowQueryHook    SlowQueryFunc
+	slowQueryTime    time.Duration
+	timingHook       TimingFunc
+	timing           Timing // Timing of the last statement.
+	statementTimeout time.Duration
+	keepAlive        time.Duration
+	maxLifetime      time.Duration
+	maxIdleTime      time.Duration
+	resultLimits     ResultLimits
+	created          time.Time // When the connection to the leader was established.
+	idleSince        time.Time // When the connection was last put back in the pool.
+	metrics          Metrics
+	spans            SpanFunc
+	database         string // Name of the database, as given to Open.
+	address          string // Address of the leader connected to.
+	txDepth          int    // Number of open transactions, including nested ones.
+	bad              bool   // Whether the connection to the leader was lost.
 }
 
 // PrepareContext returns a prepared statement, bound to this connection.
 // context is for the preparation of the statement, it must not store the
 // context within the statement itself.
 func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = tagSQL(ctx, query)
 	stmt := &Stmt{
+		conn:     c,
 		protocol: c.protocol,
 		request:  &c.request,
 		response: &c.response,
 		log:      c.log,
+		sql:      query,
 		tracing:  c.tracing,
 	}
 
+	if c.stmts != nil {
+		if entry := c.stmts.get(query); entry != nil {
+			atomic.AddUint64(&c.stats.cacheHits, 1)
+			stmt.db, stmt.id, stmt.params = entry.db, entry.id, entry.params
+			stmt.cached = entry
+			return stmt, nil
+		}
+		atomic.AddUint64(&c.stats.cacheMisses, 1)
+	}
+
 	protocol.EncodePrepare(&c.request, uint64(c.id), query)
 
-	if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
-		return nil, driverError(c.log, err)
+	done := c.instrument(ctx, "prepare", query)
+	err := c.protocol.Call(ctx, &c.request, &c.response)
+	if err == nil {
+		stmt.db, stmt.id, stmt.params, err = protocol.DecodeStmt(&c.response)
 	}
-
-	var err error
-	stmt.db, stmt.id, stmt.params, err = protocol.DecodeStmt(&c.response)
+	done(err)
 	if err != nil {
-		return nil, driverError(c.log, err)
+		return nil, c.error(err, true)
 	}
 
-	if c.tracing != client.LogNone {
-		stmt.sql = query
+	if c.stmts != nil {
+		entry := &stmtCacheEntry{sql: query, db: stmt.db, id: stmt.id, params: stmt.params}
+		if evicted, ok := c.stmts.add(entry); ok {
+			if err := c.finalize(ctx, evicted); err != nil {
+				entry.inUse = false
+				return nil, c.error(err, true)
+			}
+			stmt.cached = entry
+		}
 	}
 
 	return stmt, nil
@@ -368,22 +968,42 @@ func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 
 // ExecContext is an optional interface that may be implemented by a Conn.
 func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	protocol.EncodeExecSQL(&c.request, uint64(c.id), query, args)
+	return c.exec(ctx, query, args, false)
+}
 
-	if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
-		return nil, driverError(c.log, err)
+// Execute a statement, telling whether it can be safely executed again if the
+// connection to the leader is lost.
+func (c *Conn) exec(ctx context.Context, query string, args []driver.NamedValue, idempotent bool) (driver.Result, error) {
+	ctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	defer c.queries.invalidate()
+
+	query = tagSQL(ctx, query)
+	args, err := bindNamedValues(query, args)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := protocol.DecodeResult(&c.response)
+	var result protocol.Result
+	err = c.call(ctx, "exec", query, func() error {
+		protocol.EncodeExecSQL(&c.request, uint64(c.id), query, args)
+
+		if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
+			return err
+		}
+
+		result, err = protocol.DecodeResult(&c.response)
+		return err
+	})
 	if err != nil {
-		return nil, driverError(c.log, err)
+		return nil, c.error(err, idempotent)
 	}
 
 	if c.tracing != client.LogNone {
 		c.log(c.tracing, "exec: %s", query)
 	}
 
-	return &Result{result: result}, nil
+	return &Result{result: result, timing: c.timing}, nil
 }
 
 // Query is an optional interface that may be implemented by a Conn.
@@ -392,29 +1012,80 @@ func (c *Conn) Query(query string, args []driver.Value) (driver.Rows, error) {
 }
 
 // QueryContext is an optional interface that may be implemented by a Conn.
+//
+// If the query is made of multiple statements, only the first one is run
+// right away, and the following ones are run as the returned rows advance to
+// the next result set. See sql.Rows.NextResultSet.
 func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	protocol.EncodeQuerySQL(&c.request, uint64(c.id), query, args)
+	statements := splitStatements(query)
+	if len(statements) <= 1 {
+		return c.cachedQuery(ctx, query, args, func() (*Rows, error) {
+			return c.query(ctx, query, args, isSelect(query))
+		})
+	}
+	defer c.queries.invalidate()
 
-	if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
-		return nil, driverError(c.log, err)
+	pending, err := splitArgs(statements, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.query(ctx, pending[0].sql, pending[0].args, false)
+	if err != nil {
+		return nil, err
 	}
+	rows.pending = pending[1:]
 
-	rows, err := protocol.DecodeRows(&c.response)
+	return rows, nil
+}
+
+// Run a query made of a single statement. It's retried after a failover only
+// if idempotent, see FailoverRetry.
+func (c *Conn) query(parent context.Context, query string, args []driver.NamedValue, idempotent bool) (*Rows, error) {
+	ctx, cancel := c.statementContext(parent)
+
+	query = tagSQL(ctx, query)
+	args, err := bindNamedValues(query, args)
 	if err != nil {
-		return nil, driverError(c.log, err)
+		cancel()
+		return nil, err
 	}
 
+	var rows protocol.Rows
+	err = c.call(ctx, "query", query, func() error {
+		protocol.EncodeQuerySQL(&c.request, uint64(c.id), query, args)
+
+		if err := c.protocol.Call(ctx, &c.request, &c.response); err != nil {
+			return err
+		}
+
+		rows, err = protocol.DecodeRows(&c.response)
+		return err
+	})
+	if err == nil {
+		err = c.verifyRead(ctx)
+	}
+	if err != nil {
+		cancel()
+		return nil, c.error(err, idempotent)
+	}
+	rows.Location = c.timeLocation
+
 	if c.tracing != client.LogNone {
 		c.log(c.tracing, "query: %s", query)
 	}
 
 	return &Rows{
 		ctx:      ctx,
+		parent:   parent,
+		cancel:   cancel,
+		conn:     c,
 		request:  &c.request,
 		response: &c.response,
 		protocol: c.protocol,
 		rows:     rows,
 		log:      c.log,
+		limits:   c.limits(parent),
+		timing:   c.timing,
 	}, nil
 }
 
@@ -423,6 +1094,22 @@ func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
 	return c.ExecContext(context.Background(), query, valuesToNamedValues(args))
 }
 
+// Client returns a client sharing the network connection to the leader of
+// this connection, to issue cluster requests like Cluster or Transfer. It can
+// be reached from database/sql through sql.Conn.Raw:
+//
+//	conn.Raw(func(c interface{}) error {
+//		nodes, err = c.(*driver.Conn).Client().Cluster(ctx)
+//		return err
+//	})
+//
+// The client must not be used after the Raw callback returns, since
+// database/sql might use the connection again. Closing the client doesn't
+// close the connection.
+func (c *Conn) Client() *client.Client {
+	return clientbridge.NewClient(c.protocol).(*client.Client)
+}
+
 // Close invalidates and potentially stops any current prepared statements and
 // transactions, marking this connection as no longer in use.
 //
@@ -430,6 +1117,8 @@ func (c *Conn) Exec(query string, args []driver.Value) (driver.Result, error) {
 // Close when there's a surplus of idle connections, it shouldn't be necessary
 // for drivers to do their own connection caching.
 func (c *Conn) Close() error {
+	atomic.AddUint64(&c.stats.openConns, ^uint64(0))
+	c.response.Release()
 	return c.protocol.Close()
 }
 
@@ -445,8 +1134,27 @@ func (c *Conn) Close() error {
 // This must also check opts.ReadOnly to determine if the read-only value is
 // true to either set the read-only transaction property if supported or return
 // an error if it is not supported.
+//
+// Read-only transactions are started as deferred transactions. Other
+// transactions use the lock mode set with ContextWithTxLock, or else the one
+// set with WithTxLock.
+//
+// If a transaction is already open on the connection, a nested transaction is
+// started using a savepoint. See Tx.
 func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if _, err := c.ExecContext(ctx, "BEGIN", nil); err != nil {
+	if c.txDepth > 0 {
+		return c.beginSavepoint(ctx)
+	}
+
+	lock := c.txLock
+	if value, ok := ctx.Value(txLockKey{}).(TxLock); ok {
+		lock = value
+	}
+	if opts.ReadOnly {
+		lock = TxDeferred
+	}
+
+	if _, err := c.exec(ctx, lock.begin(), nil, true); err != nil {
 		return nil, err
 	}
 
@@ -454,6 +1162,7 @@ func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 		conn: c,
 		log:  c.log,
 	}
+	c.txDepth = 1
 
 	return tx, nil
 }
@@ -473,37 +1182,10 @@ func (c *Conn) Begin() (driver.Tx, error) {
 	return c.BeginTx(ctx, driver.TxOptions{})
 }
 
-// Tx is a transaction.
-type Tx struct {
-	conn *Conn
-	log  client.LogFunc
-}
-
-// Commit the transaction.
-func (tx *Tx) Commit() error {
-	ctx := context.Background()
-
-	if _, err := tx.conn.ExecContext(ctx, "COMMIT", nil); err != nil {
-		return driverError(tx.log, err)
-	}
-
-	return nil
-}
-
-// Rollback the transaction.
-func (tx *Tx) Rollback() error {
-	ctx := context.Background()
-
-	if _, err := tx.conn.ExecContext(ctx, "ROLLBACK", nil); err != nil {
-		return driverError(tx.log, err)
-	}
-
-	return nil
-}
-
 // Stmt is a prepared statement. It is bound to a Conn and not
 // used by multiple goroutines concurrently.
 type Stmt struct {
+	conn     *Conn
 	protocol *protocol.Protocol
 	request  *protocol.Message
 	response *protocol.Message
@@ -511,12 +1193,19 @@ type Stmt struct {
 	id       uint32
 	params   uint64
 	log      client.LogFunc
-	sql      string // Prepared SQL, only set when tracing
+	sql      string // Prepared SQL
 	tracing  client.LogLevel
+	cached   *stmtCacheEntry // Cache entry of the statement, if cached.
 }
 
 // Close closes the statement.
 func (s *Stmt) Close() error {
+	if s.cached != nil {
+		// Keep the statement on the server for reuse.
+		s.cached.inUse = false
+		return nil
+	}
+
 	protocol.EncodeFinalize(s.request, s.db, s.id)
 
 	ctx := context.Background()
@@ -542,22 +1231,35 @@ func (s *Stmt) NumInput() int {
 //
 // ExecContext must honor the context timeout and return when it is canceled.
 func (s *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	protocol.EncodeExec(s.request, s.db, s.id, args)
+	ctx, cancel := s.conn.statementContext(ctx)
+	defer cancel()
+	defer s.conn.queries.invalidate()
 
-	if err := s.protocol.Call(ctx, s.request, s.response); err != nil {
-		return nil, driverError(s.log, err)
+	args, err := bindNamedValues(s.sql, args)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := protocol.DecodeResult(s.response)
+	var result protocol.Result
+	err = s.conn.call(ctx, "exec", s.sql, func() error {
+		protocol.EncodeExec(s.request, s.db, s.id, args)
+
+		if err := s.protocol.Call(ctx, s.request, s.response); err != nil {
+			return err
+		}
+
+		result, err = protocol.DecodeResult(s.response)
+		return err
+	})
 	if err != nil {
-		return nil, driverError(s.log, err)
+		return nil, s.conn.error(err, false)
 	}
 
 	if s.tracing != client.LogNone {
 		s.log(s.tracing, "exec prepared: %s", s.sql)
 	}
 
-	return &Result{result: result}, nil
+	return &Result{result: result, timing: s.conn.timing}, nil
 }
 
 // Exec executes a query that doesn't return rows, such
@@ -569,23 +1271,47 @@ func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
 // SELECT.
 //
 // QueryContext must honor the context timeout and return when it is canceled.
-func (s *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	protocol.EncodeQuery(s.request, s.db, s.id, args)
+func (s *Stmt) QueryContext(parent context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.cachedQuery(parent, s.sql, args, func() (*Rows, error) {
+		return s.query(parent, args)
+	})
+}
 
-	if err := s.protocol.Call(ctx, s.request, s.response); err != nil {
-		return nil, driverError(s.log, err)
+// Run the prepared query.
+func (s *Stmt) query(parent context.Context, args []driver.NamedValue) (*Rows, error) {
+	ctx, cancel := s.conn.statementContext(parent)
+
+	args, err := bindNamedValues(s.sql, args)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
 
-	rows, err := protocol.DecodeRows(s.response)
+	var rows protocol.Rows
+	err = s.conn.call(ctx, "query", s.sql, func() error {
+		protocol.EncodeQuery(s.request, s.db, s.id, args)
+
+		if err := s.protocol.Call(ctx, s.request, s.response); err != nil {
+			return err
+		}
+
+		rows, err = protocol.DecodeRows(s.response)
+		return err
+	})
+	if err == nil {
+		err = s.conn.verifyRead(ctx)
+	}
 	if err != nil {
-		return nil, driverError(s.log, err)
+		cancel()
+		return nil, s.conn.error(err, isReadOnly(s.sql))
 	}
+	rows.Location = s.conn.timeLocation
 
 	if s.tracing != client.LogNone {
 		s.log(s.tracing, "query prepared: %s", s.sql)
 	}
 
-	return &Rows{ctx: ctx, request: s.request, response: s.response, protocol: s.protocol, rows: rows}, nil
+	return &Rows{ctx: ctx, parent: parent, cancel: cancel, conn: s.conn, request: s.request, response: s.response, protocol: s.protocol, rows: rows, log: s.log, limits: s.conn.limits(parent), timing: s.conn.timing}, nil
 }
 
 // Query executes a query that may return rows, such as a
@@ -596,6 +1322,7 @@ func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
 // Result is the result of a query execution.
 type Result struct {
 	result protocol.Result
+	timing Timing
 }
 
 // LastInsertId returns the database's auto-generated ID
@@ -612,8 +1339,20 @@ func (r *Result) RowsAffected() (int64, error) {
 }
 
 // Rows is an iterator over an executed query's results.
+//
+// Large result sets are sent by the server in multiple responses, which are
+// received one at a time as the rows are consumed, reusing the same buffer, so
+// memory usage doesn't grow with the size of the result set. Closing the rows
+// before consuming them all interrupts the query.
+//
+// BLOB values point into the response buffer, so scanning them into
+// sql.RawBytes doesn't copy them. As usual with sql.RawBytes, they are valid
+// only until the next call to Next, Scan or Close.
 type Rows struct {
-	ctx      context.Context
+	ctx      context.Context    // Context of the query, with the statement timeout applied.
+	parent   context.Context    // Context the query was run with.
+	cancel   context.CancelFunc // Release the resources of ctx.
+	conn     *Conn
 	protocol *protocol.Protocol
 	request  *protocol.Message
 	response *protocol.Message
@@ -621,6 +1360,10 @@ type Rows struct {
 	consumed bool
 	types    []string
 	log      client.LogFunc
+	pending  []pendingStatement // Statements to run for the next result sets.
+	limits   ResultLimits       // Limits of the result set.
+	read     ResultLimits       // Number of rows and bytes read so far.
+	timing   Timing             // Timing of the query.
 }
 
 // Columns returns the names of the columns. The number of
@@ -633,6 +1376,8 @@ func (r *Rows) Columns() []string {
 
 // Close closes the rows iterator.
 func (r *Rows) Close() error {
+	defer r.cancel()
+
 	err := r.rows.Close()
 
 	// If we consumed the whole result set, there's nothing to do as
@@ -648,11 +1393,46 @@ func (r *Rows) Close() error {
 
 	// Let's issue an interrupt request and wait until we get an empty
 	// response, signalling that the query was interrupted.
-	if err := r.protocol.Interrupt(r.ctx, r.request, r.response); err != nil {
-		return driverError(r.log, err)
+	return r.interrupt()
+}
+
+// RowsAffected closes the rows and returns the number of rows modified by the
+// statement that returned them, like an INSERT, UPDATE or DELETE statement
+// with a RETURNING clause. The rows should be consumed first.
+//
+// RowsAffected can be reached from database/sql through sql.Conn.Raw:
+//
+//	conn.Raw(func(c interface{}) error {
+//		rows, err := c.(*driver.Conn).QueryContext(ctx, "DELETE FROM t RETURNING id", nil)
+//		...
+//		n, err = rows.(*driver.Rows).RowsAffected()
+//		return err
+//	})
+//
+// With database/sql alone, statements with a RETURNING clause can be run with
+// Query, or with Exec to discard the returned rows. Unlike SELECT queries,
+// they're not retried after a failover, see RetryIdempotent.
+func (r *Rows) RowsAffected() (int64, error) {
+	if err := r.Close(); err != nil {
+		return 0, err
 	}
 
-	return nil
+	rows, err := r.conn.query(r.parent, "SELECT changes()", nil, false)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 1)
+	if err := rows.Next(dest); err != nil {
+		return 0, driverError(r.log, err)
+	}
+	n, ok := dest[0].(int64)
+	if !ok {
+		return 0, errors.Errorf("unexpected changes() value %v", dest[0])
+	}
+
+	return n, nil
 }
 
 // Next is called to populate the next row of data into
@@ -663,22 +1443,36 @@ func (r *Rows) Close() error {
 func (r *Rows) Next(dest []driver.Value) error {
 	err := r.rows.Next(dest)
 
-	if err == protocol.ErrRowsPart {
+	// Fetch the next part of the result set, which might be empty.
+	for err == protocol.ErrRowsPart {
 		r.rows.Close()
+		// Don't wait for more rows if the query was cancelled.
+		if err := r.ctx.Err(); err != nil {
+			if interruptErr := r.interrupt(); interruptErr != nil {
+				return interruptErr
+			}
+			return err
+		}
 		if err := r.protocol.More(r.ctx, r.response); err != nil {
+			// The response might have been read only partially.
+			r.conn.bad = true
 			return driverError(r.log, err)
 		}
-		rows, err := protocol.DecodeRows(r.response)
-		if err != nil {
-			return driverError(r.log, err)
+		rows, decodeErr := protocol.DecodeRows(r.response)
+		if decodeErr != nil {
+			return driverError(r.log, decodeErr)
 		}
+		rows.Location = r.rows.Location
 		r.rows = rows
-		return r.rows.Next(dest)
+		err = r.rows.Next(dest)
 	}
 
 	if err == io.EOF {
 		r.consumed = true
 	}
+	if err == nil {
+		err = r.count(dest)
+	}
 
 	return err
 }
@@ -732,6 +1526,15 @@ type unwrappable interface {
 }
 
 func driverError(log client.LogFunc, err error) error {
+	if cause := errors.Cause(err); cause == io.EOF || cause == io.ErrUnexpectedEOF {
+		log(client.LogDebug, "network connection lost: %v", err)
+		return driver.ErrBadConn
+	}
+	if errors.Is(err, protocol.ErrLeaderNotConfirmed) {
+		log(client.LogDebug, "%v", err)
+		return driver.ErrBadConn
+	}
+
 	switch err := errors.Cause(err).(type) {
 	case syscall.Errno:
 		log(client.LogDebug, "network connection lost: %v", err)
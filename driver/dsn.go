@@ -0,0 +1,415 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/client"
+)
+
+// DriverName is the scheme of data source names, and the usual name to
+// register a driver configured through them with, see RegisterDSN.
+const DriverName = "dqlite"
+
+// RegisterDSN registers with database/sql, under the given name, a driver
+// configured through the data source names passed to sql.Open. See ParseDSN.
+//
+// It's not done at import time, since programs commonly register a Driver of
+// their own as "dqlite". As for sql.Register, it panics if called twice with
+// the same name:
+//
+//	driver.RegisterDSN(driver.DriverName)
+//	db, err := sql.Open(driver.DriverName, "dqlite://host1:9001,host2:9001/mydb")
+func RegisterDSN(name string) {
+	sql.Register(name, dsnDriver{})
+}
+
+// DSN holds the parameters of a data source name.
+//
+// A data source name has the form:
+//
+//	dqlite://host1:9001,host2:9001/mydb?dial_timeout=5s&tls=true
+//
+// The host part lists the addresses of the nodes to connect to. It can be left
+// empty if the "store" parameter is given instead, in which case the nodes are
+// read from the given file, as per client.DefaultNodeStore.
+//
+// The path is the name of the database. Query parameters starting with an
+// underscore set a pragma on each connection, like "_foreign_keys=on" or
+// "_busy_timeout=5000", see WithPragma. The "attach" parameter, which can be
+// repeated, attaches a database to each connection, see WithAttach. Its value
+// has the form "other.db:other", with the name of the database followed by
+// the schema name. The following query parameters configure the driver, and
+// all other ones are passed along with the database name:
+//
+//	store               Path of a node store file
+//	dial_timeout        See WithDialTimeout
+//	attempt_timeout     See WithAttemptTimeout
+//	connection_timeout  See WithConnectionTimeout
+//	context_timeout     See WithContextTimeout
+//	statement_timeout   See WithStatementTimeout
+//	keep_alive          See WithKeepAlive
+//	max_lifetime        See WithMaxLifetime
+//	max_idle_time       See WithMaxIdleTime
+//	read_timeout        See WithReadTimeout
+//	write_timeout       See WithWriteTimeout
+//	retry_limit         See WithRetryLimit
+//	statement_cache     See WithStatementCacheSize
+//	failover_retry      See WithFailoverRetry: "idempotent", "all" or "none"
+//	tx_lock             See WithTxLock: "deferred", "immediate" or "exclusive"
+//	busy_retry          See WithBusyRetry
+//	time_format         See WithTimeFormat: "iso8601" or "unix"
+//	time_location       See WithTimeLocation: "UTC", "Local" or a name like "Europe/Rome"
+//	tls                 Use TLS, "true" or "false"
+//	tls_ca              Path of a PEM file with the CA certificates to trust
+//	tls_cert, tls_key   Paths of the PEM client certificate and key
+//	tls_server_name     Name to verify the node certificates against
+//
+// Setting any of the "tls_" parameters implies "tls=true".
+type DSN struct {
+	Nodes    []string
+	Store    string
+	Database string
+	Pragmas  map[string]string
+	Attach   map[string]string // Database names by schema name
+
+	DialTimeout       time.Duration
+	AttemptTimeout    time.Duration
+	ConnectionTimeout time.Duration
+	ContextTimeout    time.Duration
+	StatementTimeout  time.Duration
+	KeepAlive         time.Duration
+	MaxLifetime       time.Duration
+	MaxIdleTime       time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	RetryLimit        uint
+	StatementCache    int
+	FailoverRetry     FailoverRetry
+	TxLock            TxLock
+	BusyRetry         time.Duration
+	TimeFormat        TimeFormat
+	TimeLocation      *time.Location
+
+	TLS           bool
+	TLSCA         string
+	TLSCert       string
+	TLSKey        string
+	TLSServerName string
+}
+
+// ParseDSN parses a data source name.
+func ParseDSN(dsn string) (*DSN, error) {
+	const scheme = DriverName + "://"
+	if !strings.HasPrefix(dsn, scheme) {
+		return nil, errors.Errorf("invalid data source name %q: must start with %q", dsn, scheme)
+	}
+	rest := dsn[len(scheme):]
+
+	i := strings.Index(rest, "/")
+	if i == -1 {
+		return nil, errors.Errorf("invalid data source name %q: no database given", dsn)
+	}
+	hosts, path := rest[:i], rest[i+1:]
+
+	raw := ""
+	if i := strings.Index(path, "?"); i != -1 {
+		path, raw = path[:i], path[i+1:]
+	}
+	database, err := url.PathUnescape(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid database name")
+	}
+	if database == "" {
+		return nil, errors.Errorf("invalid data source name %q: no database given", dsn)
+	}
+
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid data source name parameters")
+	}
+
+	d := &DSN{}
+	if hosts != "" {
+		d.Nodes = strings.Split(hosts, ",")
+	}
+
+	durations := map[string]*time.Duration{
+		"dial_timeout":       &d.DialTimeout,
+		"attempt_timeout":    &d.AttemptTimeout,
+		"connection_timeout": &d.ConnectionTimeout,
+		"context_timeout":    &d.ContextTimeout,
+		"statement_timeout":  &d.StatementTimeout,
+		"keep_alive":         &d.KeepAlive,
+		"max_lifetime":       &d.MaxLifetime,
+		"max_idle_time":      &d.MaxIdleTime,
+		"read_timeout":       &d.ReadTimeout,
+		"write_timeout":      &d.WriteTimeout,
+		"busy_retry":         &d.BusyRetry,
+	}
+	strs := map[string]*string{
+		"store":           &d.Store,
+		"tls_ca":          &d.TLSCA,
+		"tls_cert":        &d.TLSCert,
+		"tls_key":         &d.TLSKey,
+		"tls_server_name": &d.TLSServerName,
+	}
+
+	for key, values := range query {
+		value := values[len(values)-1]
+		switch {
+		case durations[key] != nil:
+			if *durations[key], err = time.ParseDuration(value); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", key)
+			}
+		case strs[key] != nil:
+			*strs[key] = value
+		case key == "retry_limit":
+			limit, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", key)
+			}
+			d.RetryLimit = uint(limit)
+		case key == "statement_cache":
+			if d.StatementCache, err = strconv.Atoi(value); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", key)
+			}
+		case key == "failover_retry":
+			retries := map[string]FailoverRetry{"idempotent": RetryIdempotent, "all": RetryAll, "none": RetryNone}
+			retry, ok := retries[value]
+			if !ok {
+				return nil, errors.Errorf("invalid %s %q", key, value)
+			}
+			d.FailoverRetry = retry
+		case key == "tx_lock":
+			locks := map[string]TxLock{"deferred": TxDeferred, "immediate": TxImmediate, "exclusive": TxExclusive}
+			lock, ok := locks[value]
+			if !ok {
+				return nil, errors.Errorf("invalid %s %q", key, value)
+			}
+			d.TxLock = lock
+		case key == "time_format":
+			formats := map[string]TimeFormat{"iso8601": TimeISO8601, "unix": TimeUnix}
+			format, ok := formats[value]
+			if !ok {
+				return nil, errors.Errorf("invalid %s %q", key, value)
+			}
+			d.TimeFormat = format
+		case key == "time_location":
+			if d.TimeLocation, err = time.LoadLocation(value); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", key)
+			}
+		case key == "tls":
+			if d.TLS, err = strconv.ParseBool(value); err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", key)
+			}
+		case key == "attach":
+			for _, value := range values {
+				i := strings.LastIndex(value, ":")
+				if i == -1 {
+					return nil, errors.Errorf("invalid %s %q: no schema name given", key, value)
+				}
+				if d.Attach == nil {
+					d.Attach = map[string]string{}
+				}
+				d.Attach[value[i+1:]] = value[:i]
+			}
+		case strings.HasPrefix(key, "_"):
+			if d.Pragmas == nil {
+				d.Pragmas = map[string]string{}
+			}
+			d.Pragmas[key[1:]] = value
+		default:
+			continue
+		}
+		query.Del(key)
+	}
+
+	if d.TLSCA != "" || d.TLSCert != "" || d.TLSKey != "" || d.TLSServerName != "" {
+		d.TLS = true
+	}
+	if (d.TLSCert == "") != (d.TLSKey == "") {
+		return nil, errors.Errorf("tls_cert and tls_key must be given together")
+	}
+	if len(d.Nodes) == 0 && d.Store == "" {
+		return nil, errors.Errorf("invalid data source name %q: no nodes or store given", dsn)
+	}
+	if len(d.Nodes) > 0 && d.Store != "" {
+		return nil, errors.Errorf("invalid data source name %q: both nodes and store given", dsn)
+	}
+
+	d.Database = database
+	if len(query) > 0 {
+		d.Database += "?" + query.Encode()
+	}
+
+	return d, nil
+}
+
+// NodeStore returns the node store described by the data source name.
+func (d *DSN) NodeStore() (client.NodeStore, error) {
+	if d.Store != "" {
+		return client.DefaultNodeStore(d.Store)
+	}
+
+	nodes := make([]client.NodeInfo, len(d.Nodes))
+	for i, address := range d.Nodes {
+		nodes[i] = client.NodeInfo{Address: address}
+	}
+	store := client.NewInmemNodeStore()
+	store.Set(context.Background(), nodes)
+
+	return store, nil
+}
+
+// Options returns the driver options described by the data source name,
+// loading the TLS certificates if needed.
+func (d *DSN) Options() ([]Option, error) {
+	options := []Option{}
+
+	if d.DialTimeout != 0 {
+		options = append(options, WithDialTimeout(d.DialTimeout))
+	}
+	if d.AttemptTimeout != 0 {
+		options = append(options, WithAttemptTimeout(d.AttemptTimeout))
+	}
+	if d.ConnectionTimeout != 0 {
+		options = append(options, WithConnectionTimeout(d.ConnectionTimeout))
+	}
+	if d.ContextTimeout != 0 {
+		options = append(options, WithContextTimeout(d.ContextTimeout))
+	}
+	if d.StatementTimeout != 0 {
+		options = append(options, WithStatementTimeout(d.StatementTimeout))
+	}
+	if d.KeepAlive != 0 {
+		options = append(options, WithKeepAlive(d.KeepAlive))
+	}
+	if d.MaxLifetime != 0 {
+		options = append(options, WithMaxLifetime(d.MaxLifetime))
+	}
+	if d.MaxIdleTime != 0 {
+		options = append(options, WithMaxIdleTime(d.MaxIdleTime))
+	}
+	if d.ReadTimeout != 0 {
+		options = append(options, WithReadTimeout(d.ReadTimeout))
+	}
+	if d.WriteTimeout != 0 {
+		options = append(options, WithWriteTimeout(d.WriteTimeout))
+	}
+	if d.RetryLimit != 0 {
+		options = append(options, WithRetryLimit(d.RetryLimit))
+	}
+
+	if d.StatementCache != 0 {
+		options = append(options, WithStatementCacheSize(d.StatementCache))
+	}
+
+	if d.FailoverRetry != RetryIdempotent {
+		options = append(options, WithFailoverRetry(d.FailoverRetry))
+	}
+
+	if d.TxLock != TxDeferred {
+		options = append(options, WithTxLock(d.TxLock))
+	}
+
+	if d.BusyRetry != 0 {
+		options = append(options, WithBusyRetry(d.BusyRetry))
+	}
+
+	if d.TimeFormat != TimeISO8601 {
+		options = append(options, WithTimeFormat(d.TimeFormat))
+	}
+	if d.TimeLocation != nil {
+		options = append(options, WithTimeLocation(d.TimeLocation))
+	}
+
+	names := make([]string, 0, len(d.Pragmas))
+	for name := range d.Pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options = append(options, WithPragma(name, d.Pragmas[name]))
+	}
+
+	schemas := make([]string, 0, len(d.Attach))
+	for schema := range d.Attach {
+		schemas = append(schemas, schema)
+	}
+	sort.Strings(schemas)
+	for _, schema := range schemas {
+		options = append(options, WithAttach(d.Attach[schema], schema))
+	}
+
+	if d.TLS {
+		config := &tls.Config{ServerName: d.TLSServerName}
+		if d.TLSCA != "" {
+			data, err := ioutil.ReadFile(d.TLSCA)
+			if err != nil {
+				return nil, errors.Wrap(err, "read CA certificates")
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(data) {
+				return nil, errors.Errorf("invalid CA certificates in %s", d.TLSCA)
+			}
+		}
+		if d.TLSCert != "" {
+			cert, err := tls.LoadX509KeyPair(d.TLSCert, d.TLSKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "load client certificate")
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		options = append(options, WithTLSConfig(config))
+	}
+
+	return options, nil
+}
+
+// Driver registered with database/sql by RegisterDSN, creating a new Driver
+// for each data source name.
+type dsnDriver struct{}
+
+func (dsnDriver) Open(dsn string) (driver.Conn, error) {
+	connector, err := dsnDriver{}.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+func (dsnDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	d, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := d.NodeStore()
+	if err != nil {
+		return nil, errors.Wrap(err, "create node store")
+	}
+
+	options, err := d.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	drv, err := New(store, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return drv.OpenConnector(d.Database)
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes the given rows as CSV to the given writer, starting with a
+// header holding the column names. It consumes all rows, but doesn't close
+// them.
+//
+// NULL values are written as empty fields, BLOBs are encoded in base64 and
+// times are formatted as RFC 3339.
+func WriteCSV(w io.Writer, rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	err = scanRows(rows, len(columns), func(values []interface{}) error {
+		for i, value := range values {
+			record[i] = formatCSV(value)
+		}
+		return writer.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteNDJSON writes the given rows to the given writer as newline-delimited
+// JSON, one object per row, keyed by column name. It consumes all rows, but
+// doesn't close them.
+//
+// NULL values are written as null, BLOBs are encoded in base64 and times are
+// formatted as RFC 3339.
+func WriteNDJSON(w io.Writer, rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	object := make(map[string]interface{}, len(columns))
+	return scanRows(rows, len(columns), func(values []interface{}) error {
+		for i, value := range values {
+			object[columns[i]] = value
+		}
+		return encoder.Encode(object)
+	})
+}
+
+// Scan each row and pass its values to the given function.
+func scanRows(rows *sql.Rows, n int, f func([]interface{}) error) error {
+	values := make([]interface{}, n)
+	pointers := make([]interface{}, n)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		if err := f(values); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func formatCSV(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value)
+	case string:
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,65 @@
+package driver_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	db := newExportDB(t)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM t")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var b bytes.Buffer
+	require.NoError(t, dqlitedriver.WriteCSV(&b, rows))
+	assert.Equal(t, "n,f,s,b\n1,1.5,\"a,b\",AQI=\n,,,\n", b.String())
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	db := newExportDB(t)
+
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM t")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var b bytes.Buffer
+	require.NoError(t, dqlitedriver.WriteNDJSON(&b, rows))
+	assert.Equal(t, `{"b":"AQI=","f":1.5,"n":1,"s":"a,b"}
+{"b":null,"f":null,"n":null,"s":null}
+`, b.String())
+}
+
+// Return a database whose queries return a row with a value of each type and
+// a row of NULLs.
+func newExportDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	server := clienttest.NewServer(1)
+	t.Cleanup(func() { server.Close() })
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns: []string{"n", "f", "s", "b"},
+			Values: [][]driver.Value{
+				{int64(1), 1.5, "a,b", []byte{1, 2}},
+				{nil, nil, nil, nil},
+			},
+		}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
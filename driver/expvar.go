@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"expvar"
+
+	"github.com/pkg/errors"
+)
+
+// PublishExpvar publishes the driver counters returned by Stats as an expvar
+// variable with the given name, so they show up under /debug/vars when the
+// expvar handler is served.
+//
+// Since expvar variables can't be removed, the counters stay published for
+// the whole life of the process. An error is returned if a variable with the
+// same name already exists.
+func (d *Driver) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return errors.Errorf("expvar variable %q already exists", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return d.Stats()
+	}))
+	return nil
+}
@@ -0,0 +1,50 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The driver counters can be published as an expvar variable.
+func TestDriver_PublishExpvar(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	drv, err := dqlitedriver.New(newStore(t, server.Address()))
+	require.NoError(t, err)
+	require.NoError(t, drv.PublishExpvar("dqlite_test"))
+	assert.EqualError(t, drv.PublishExpvar("dqlite_test"), `expvar variable "dqlite_test" already exists`)
+
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	_, err = conn.ExecContext(context.Background(), "DELETE FROM t")
+	require.NoError(t, err)
+
+	stats := dqlitedriver.Stats{}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("dqlite_test").String()), &stats))
+	assert.Equal(t, uint64(1), stats.OpenConnections)
+	assert.Equal(t, uint64(0), stats.InFlightRequests)
+	assert.NotZero(t, stats.RoundTrips)
+
+	require.NoError(t, conn.Close())
+	require.NoError(t, db.Close())
+	assert.Equal(t, uint64(0), drv.Stats().OpenConnections)
+}
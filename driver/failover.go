@@ -0,0 +1,121 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// FailoverRetry tells which operations can be retried when the connection to
+// the leader is lost or the leader steps down.
+//
+// Retried operations fail with driver.ErrBadConn, which makes database/sql
+// run them again on a new connection, finding the current leader through the
+// node store. Operations in a transaction are never retried, since the
+// transaction is bound to the connection.
+type FailoverRetry int
+
+// Failover retry policies.
+const (
+	// RetryIdempotent retries operations that can be safely repeated:
+	// preparing statements, beginning transactions and queries made of a
+	// single SELECT statement. Queries that might modify the database,
+	// like "DELETE ... RETURNING" or multiple statements, are not.
+	// Statements that certainly weren't executed, because the node was not
+	// the leader anymore, are retried too. Other operations fail with
+	// ErrConnectionLost.
+	RetryIdempotent FailoverRetry = iota
+
+	// RetryAll retries all operations, so statements might be applied
+	// twice.
+	RetryAll
+
+	// RetryNone never retries. All failed operations return
+	// ErrConnectionLost.
+	RetryNone
+)
+
+// ErrConnectionLost is returned when the connection to the leader is lost or
+// the leader steps down while running an operation that is not retried, as
+// per the configured FailoverRetry. The operation might or might not have
+// been applied.
+var ErrConnectionLost = errors.New("connection to the leader lost")
+
+// Convert the error of an operation on this connection. When the leader is
+// lost, the connection is marked as bad and driver.ErrBadConn is returned only
+// if the operation can be retried.
+func (c *Conn) error(err error, idempotent bool) error {
+	notExecuted := false
+	if err, ok := errors.Cause(err).(protocol.ErrRequest); ok {
+		notExecuted = err.Code == errIoErrNotLeader || err.Code == errIoErrNotLeaderLegacy
+	}
+
+	// Errors like ErrMessageTooLarge leave the connection out of sync with
+	// the server, without being network errors.
+	if errors.Is(err, protocol.ErrConnectionDead) {
+		c.bad = true
+	}
+
+	err = driverError(c.log, err)
+	if err != driver.ErrBadConn {
+		return err
+	}
+
+	c.bad = true
+
+	if c.txDepth > 0 {
+		// The transaction is gone along with the connection.
+		c.log(client.LogDebug, "not retrying operation in a transaction after failover")
+		return ErrConnectionLost
+	}
+
+	retry := false
+	switch c.failover {
+	case RetryAll:
+		retry = true
+	case RetryIdempotent:
+		retry = idempotent || notExecuted
+	}
+
+	if !retry {
+		c.log(client.LogDebug, "not retrying operation after failover")
+		return ErrConnectionLost
+	}
+
+	c.log(client.LogDebug, "retrying operation after failover")
+	atomic.AddUint64(&c.stats.failoverRetries, 1)
+
+	return driver.ErrBadConn
+}
+
+// ResetSession makes database/sql discard the connection if the connection to
+// the leader was lost, if it's too old or has been idle for too long, as per
+// WithMaxLifetime and WithMaxIdleTime, or if it's found dead after being idle,
+// as per WithKeepAlive.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	if c.bad || c.expired() {
+		return driver.ErrBadConn
+	}
+	err := c.keepAliveCheck(ctx)
+
+	// The connection is not idle anymore.
+	c.idleSince = time.Time{}
+
+	return err
+}
+
+// IsValid tells database/sql whether the connection can be reused. It's
+// invoked before putting the connection back in the pool, and gives back the
+// response buffer to the budget set with WithBufferBudget.
+func (c *Conn) IsValid() bool {
+	c.response.Release()
+	valid := !c.bad && !c.protocol.Dead() && !c.expired()
+	c.idleSince = time.Now()
+	return valid
+}
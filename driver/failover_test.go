@@ -0,0 +1,131 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverRetry(t *testing.T) {
+	cases := []struct {
+		name   string
+		retry  string
+		fail   error  // Returned by the first attempt of a statement.
+		query  string // Query to run, or empty to run a statement.
+		err    error
+		called int
+	}{
+		{"query after disconnect", "idempotent", clienttest.ErrDisconnect, "SELECT n FROM t", nil, 2},
+		{"exec after disconnect", "idempotent", clienttest.ErrDisconnect, "", dqlitedriver.ErrConnectionLost, 1},
+		{"exec on ex-leader", "idempotent", clienttest.ErrNotLeader, "", nil, 2},
+		{"exec after disconnect, retry all", "all", clienttest.ErrDisconnect, "", nil, 2},
+		{"query after disconnect, retry none", "none", clienttest.ErrDisconnect, "SELECT n FROM t", dqlitedriver.ErrConnectionLost, 1},
+		{"returning query after disconnect", "idempotent", clienttest.ErrDisconnect, "DELETE FROM t RETURNING n", dqlitedriver.ErrConnectionLost, 1},
+		{"returning query on ex-leader", "idempotent", clienttest.ErrNotLeader, "DELETE FROM t RETURNING n", nil, 2},
+		{"multi-statement query after disconnect", "idempotent", clienttest.ErrDisconnect, "SELECT n FROM t; DELETE FROM t", dqlitedriver.ErrConnectionLost, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := clienttest.NewServer(1)
+			defer server.Close()
+
+			var mu sync.Mutex
+			called := 0
+			attempt := func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				called++
+				if called == 1 {
+					return c.fail
+				}
+				return nil
+			}
+			server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+				return clienttest.Result{RowsAffected: 1}, attempt()
+			})
+			server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+				return &clienttest.Rows{Columns: []string{"n"}}, attempt()
+			})
+
+			db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?failover_retry="+c.retry)
+			require.NoError(t, err)
+			defer db.Close()
+
+			ctx := context.Background()
+
+			// Open a connection upfront, so the failure happens on an
+			// established one.
+			require.NoError(t, db.PingContext(ctx))
+
+			if c.query != "" {
+				var rows *sql.Rows
+				rows, err = db.QueryContext(ctx, c.query)
+				if err == nil {
+					rows.Close()
+				}
+			} else {
+				_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(1)")
+			}
+			assert.Equal(t, c.err, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, c.called, called)
+		})
+	}
+}
+
+// Operations in a transaction are never retried, since the transaction is
+// lost along with the connection to the leader.
+func TestFailoverRetry_Transaction(t *testing.T) {
+	for _, query := range []bool{false, true} {
+		server := clienttest.NewServer(1)
+		defer server.Close()
+
+		var mu sync.Mutex
+		called := 0
+		attempt := func(sql string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if sql == "BEGIN" {
+				return nil
+			}
+			called++
+			return clienttest.ErrDisconnect
+		}
+		server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+			return clienttest.Result{}, attempt(sql)
+		})
+		server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+			return &clienttest.Rows{Columns: []string{"n"}}, attempt(sql)
+		})
+
+		db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?failover_retry=all")
+		require.NoError(t, err)
+		defer db.Close()
+
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		if query {
+			_, err = tx.QueryContext(ctx, "SELECT n FROM t")
+		} else {
+			_, err = tx.ExecContext(ctx, "INSERT INTO t VALUES(1)")
+		}
+		assert.Equal(t, dqlitedriver.ErrConnectionLost, err)
+		tx.Rollback()
+
+		mu.Lock()
+		assert.Equal(t, 1, called)
+		mu.Unlock()
+	}
+}
@@ -310,6 +310,8 @@ func TestOptions(t *testing.T) {
 		driver.WithConnectionBackoffCap(1*time.Second),
 		driver.WithAttemptTimeout(5*time.Second),
 		driver.WithRetryLimit(0),
+		driver.WithStatementCacheSize(16),
+		driver.WithFailoverRetry(driver.RetryIdempotent),
 	)
 	require.NoError(t, err)
 }
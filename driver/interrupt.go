@@ -0,0 +1,37 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// Maximum time to wait for the server to acknowledge the interruption of a
+// query whose context is done.
+const interruptTimeout = 5 * time.Second
+
+// Interrupt the query of the rows and drain the responses still pending, so
+// the connection stays in sync with the server and can be reused.
+//
+// If the context of the query is done, for example because it was cancelled
+// while rows were being fetched, the interrupt request is sent with a fresh
+// context. If the interruption fails, the connection is marked as bad.
+func (r *Rows) interrupt() error {
+	if r.conn.bad {
+		return nil
+	}
+
+	ctx := r.ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), interruptTimeout)
+		defer cancel()
+	}
+
+	if err := r.protocol.Interrupt(ctx, r.request, r.response); err != nil {
+		r.conn.bad = true
+		return driverError(r.log, err)
+	}
+	r.consumed = true
+
+	return nil
+}
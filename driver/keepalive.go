@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+)
+
+// WithKeepAlive sets the keep-alive interval of connections, after which a
+// connection left idle in the database/sql pool might have been silently
+// dropped by a NAT, a load balancer or a dead node.
+//
+// Each connection, including the stand-by ones, pings its node every time it
+// stays idle for the interval, in the background. A connection found dead
+// this way is discarded as soon as it's taken out of or put back into the
+// pool, instead of failing the next operation.
+//
+// Connections idle for longer than the interval are also pinged before being
+// reused. If the ping fails the connection is discarded, and database/sql runs
+// the operation on a new one, instead of failing it.
+//
+// The TCP keep-alive probes enabled by default, whose period can be set with
+// client.SocketOptions, help keeping idle connections open in the first place.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(options *options) {
+		options.KeepAlive = interval
+	}
+}
+
+// WithMaxLifetime makes connections older than the given age get closed and
+// replaced, instead of being reused, so that long-lived sockets behind load
+// balancers get renewed. Connections are checked when put back in the pool
+// and when taken out of it.
+//
+// Unlike sql.DB.SetConnMaxLifetime, which closes expired connections of the
+// database/sql pool in the background, it also applies to the stand-by
+// connections, see WithStandbyConnections.
+func WithMaxLifetime(lifetime time.Duration) Option {
+	return func(options *options) {
+		options.MaxLifetime = lifetime
+	}
+}
+
+// WithMaxIdleTime makes connections that have been idle in the database/sql
+// pool for longer than the given time get closed and replaced, instead of
+// being reused.
+//
+// Unlike sql.DB.SetConnMaxIdleTime, which closes expired connections of the
+// database/sql pool in the background, it also applies to the stand-by
+// connections, which are replaced once they have been unused for that long.
+func WithMaxIdleTime(idle time.Duration) Option {
+	return func(options *options) {
+		options.MaxIdleTime = idle
+	}
+}
+
+// Tell whether the connection should be replaced, because it's older than the
+// max lifetime or has been idle for longer than the max idle time.
+func (c *Conn) expired() bool {
+	now := time.Now()
+	if c.maxLifetime > 0 && now.Sub(c.created) >= c.maxLifetime {
+		c.log(client.LogDebug, "discarding connection older than %s", c.maxLifetime)
+		return true
+	}
+	if c.maxIdleTime > 0 && !c.idleSince.IsZero() && now.Sub(c.idleSince) >= c.maxIdleTime {
+		c.log(client.LogDebug, "discarding connection idle for more than %s", c.maxIdleTime)
+		return true
+	}
+	return false
+}
+
+// Ping the leader if the connection has been idle for longer than the
+// keep-alive interval, returning driver.ErrBadConn if it's dead.
+func (c *Conn) keepAliveCheck(ctx context.Context) error {
+	if c.protocol.Dead() {
+		c.log(client.LogDebug, "discarding dead connection")
+		c.bad = true
+		return driver.ErrBadConn
+	}
+
+	if c.keepAlive <= 0 || c.idleSince.IsZero() || time.Since(c.idleSince) < c.keepAlive {
+		return nil
+	}
+
+	if _, err := c.Client().Ping(ctx); err != nil {
+		c.log(client.LogDebug, "discarding idle connection: %v", err)
+		c.bad = true
+		return driver.ErrBadConn
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Idle connections to a dead leader are discarded before being reused.
+func TestWithKeepAlive(t *testing.T) {
+	for _, keepAlive := range []time.Duration{0, time.Millisecond} {
+		t.Run(keepAlive.String(), func(t *testing.T) {
+			server1 := clienttest.NewServer(1)
+			defer server1.Close()
+			server2 := clienttest.NewServer(2)
+			defer server2.Close()
+
+			node1 := client.NodeInfo{ID: 1, Address: server1.Address(), Role: client.Voter}
+			node2 := client.NodeInfo{ID: 2, Address: server2.Address(), Role: client.Voter}
+			server2.SetLeader(&node1)
+
+			exec := func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+				return clienttest.Result{RowsAffected: 1}, nil
+			}
+			server1.HandleExec(exec)
+			server2.HandleExec(exec)
+
+			ctx := context.Background()
+			store := client.NewInmemNodeStore()
+			require.NoError(t, store.Set(ctx, []client.NodeInfo{node1, node2}))
+
+			connector, err := dqlitedriver.NewConnector(store, "test.db", dqlitedriver.WithKeepAlive(keepAlive))
+			require.NoError(t, err)
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(1)")
+			require.NoError(t, err)
+
+			// The leader dies while the connection is idle.
+			server1.Close()
+			server2.SetLeader(&node2)
+			time.Sleep(10 * time.Millisecond)
+
+			_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(2)")
+			if keepAlive == 0 {
+				assert.Equal(t, dqlitedriver.ErrConnectionLost, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// Connections too old or idle for too long are replaced instead of reused.
+func TestWithMaxLifetime(t *testing.T) {
+	cases := map[string]dqlitedriver.Option{
+		"lifetime": dqlitedriver.WithMaxLifetime(time.Millisecond),
+		"idle":     dqlitedriver.WithMaxIdleTime(time.Millisecond),
+	}
+	for name, option := range cases {
+		option := option
+		t.Run(name, func(t *testing.T) {
+			server := clienttest.NewServer(1)
+			defer server.Close()
+
+			ctx := context.Background()
+			store := client.NewInmemNodeStore()
+			node := client.NodeInfo{ID: 1, Address: server.Address(), Role: client.Voter}
+			require.NoError(t, store.Set(ctx, []client.NodeInfo{node}))
+
+			connector, err := dqlitedriver.NewConnector(store, "test.db", option)
+			require.NoError(t, err)
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			raw := func() interface{} {
+				conn, err := db.Conn(ctx)
+				require.NoError(t, err)
+				defer conn.Close()
+				var raw interface{}
+				require.NoError(t, conn.Raw(func(c interface{}) error {
+					raw = c
+					return nil
+				}))
+				return raw
+			}
+
+			first := raw()
+			time.Sleep(10 * time.Millisecond)
+			assert.True(t, first != raw())
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// ResultLimits bounds the size of the result sets of queries. A zero field
+// means no limit.
+type ResultLimits struct {
+	Rows  int64 // Maximum number of rows.
+	Bytes int64 // Maximum size of the values of all rows, in bytes.
+}
+
+// WithResultLimits sets the default limits of the result sets of queries, so
+// that an unexpectedly large result set can't exhaust the memory of the
+// process. Reading a row beyond the limits fails with ErrResultTooLarge, and
+// the rest of the result set is discarded.
+//
+// The size of TEXT and BLOB values is their length, other values count as 8
+// bytes. For queries made of multiple statements, the limits apply to each
+// result set.
+func WithResultLimits(limits ResultLimits) Option {
+	return func(options *options) {
+		options.ResultLimits = limits
+	}
+}
+
+type resultLimitsKey struct{}
+
+// ContextWithResultLimits returns a context making the queries run with it use
+// the given result set limits, instead of the ones set with WithResultLimits.
+func ContextWithResultLimits(ctx context.Context, limits ResultLimits) context.Context {
+	return context.WithValue(ctx, resultLimitsKey{}, limits)
+}
+
+// ErrResultTooLarge is returned when reading a row of a result set that
+// exceeds its limits, see WithResultLimits. Only the limit that was exceeded
+// is set.
+type ErrResultTooLarge struct {
+	Rows  int64 // Maximum number of rows.
+	Bytes int64 // Maximum size of the values of all rows, in bytes.
+}
+
+func (e ErrResultTooLarge) Error() string {
+	if e.Rows > 0 {
+		return fmt.Sprintf("result set exceeds %d rows", e.Rows)
+	}
+	return fmt.Sprintf("result set exceeds %d bytes", e.Bytes)
+}
+
+// Return the result set limits of queries run with the given context.
+func (c *Conn) limits(ctx context.Context) ResultLimits {
+	if limits, ok := ctx.Value(resultLimitsKey{}).(ResultLimits); ok {
+		return limits
+	}
+	return c.resultLimits
+}
+
+// Account for the given row, failing if the result set exceeds its limits.
+func (r *Rows) count(row []driver.Value) error {
+	if r.limits == (ResultLimits{}) {
+		return nil
+	}
+
+	r.read.Rows++
+	for _, value := range row {
+		switch value := value.(type) {
+		case string:
+			r.read.Bytes += int64(len(value))
+		case []byte:
+			r.read.Bytes += int64(len(value))
+		default:
+			r.read.Bytes += 8
+		}
+	}
+
+	if r.limits.Rows > 0 && r.read.Rows > r.limits.Rows {
+		return ErrResultTooLarge{Rows: r.limits.Rows}
+	}
+	if r.limits.Bytes > 0 && r.read.Bytes > r.limits.Bytes {
+		return ErrResultTooLarge{Bytes: r.limits.Bytes}
+	}
+
+	return nil
+}
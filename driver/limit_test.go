@@ -0,0 +1,67 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Reading a result set beyond its limits fails, and the connection can still
+// be used.
+func TestWithResultLimits(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	values := make([][]driver.Value, 1000)
+	for i := range values {
+		values[i] = []driver.Value{int64(i), strings.Repeat("x", 10)}
+	}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n", "s"}, Values: values, PageSize: 100}, nil
+	})
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db",
+		dqlitedriver.WithResultLimits(dqlitedriver.ResultLimits{Rows: 150}))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	count := func(ctx context.Context) (int, error) {
+		rows, err := db.QueryContext(ctx, "SELECT n, s FROM t")
+		require.NoError(t, err)
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	}
+
+	ctx := context.Background()
+
+	n, err := count(ctx)
+	assert.Equal(t, 150, n)
+	assert.Equal(t, dqlitedriver.ErrResultTooLarge{Rows: 150}, err)
+	assert.EqualError(t, err, "result set exceeds 150 rows")
+
+	// Each row takes 18 bytes.
+	n, err = count(dqlitedriver.ContextWithResultLimits(ctx, dqlitedriver.ResultLimits{Bytes: 180}))
+	assert.Equal(t, 10, n)
+	var tooLarge dqlitedriver.ErrResultTooLarge
+	require.True(t, errors.As(err, &tooLarge))
+	assert.Equal(t, int64(180), tooLarge.Bytes)
+
+	n, err = count(dqlitedriver.ContextWithResultLimits(ctx, dqlitedriver.ResultLimits{}))
+	require.NoError(t, err)
+	assert.Equal(t, 1000, n)
+}
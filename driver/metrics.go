@@ -0,0 +1,70 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Metrics receives measurements of the driver activity, for example to export
+// them as Prometheus collectors. Errors are reported as Error values if they
+// come from the database, so they can be counted by code.
+//
+// The utilization of the connection pool is tracked by database/sql itself,
+// see sql.DB.Stats.
+//
+// Methods may be invoked concurrently from multiple connections.
+type Metrics interface {
+	// ObserveConnect is invoked after each attempt to connect to the
+	// leader, including reconnections after a failover, with the address
+	// of the leader or an empty string if none was found.
+	ObserveConnect(address string, duration time.Duration, err error)
+
+	// ObserveRequest is invoked after each request, with its kind: "exec",
+	// "query", "prepare" or "exec batch". The duration includes the time
+	// spent retrying the request if the database was busy. For queries,
+	// only the time until the first rows are received is measured.
+	ObserveRequest(request string, duration time.Duration, err error)
+}
+
+// WithMetrics sets the receiver of the driver measurements.
+func WithMetrics(metrics Metrics) Option {
+	return func(options *options) {
+		options.Metrics = metrics
+	}
+}
+
+// Convert the error of a request to the one reported to hooks and metrics.
+func reportedError(err error) error {
+	if e, ok := errors.Cause(err).(protocol.ErrRequest); ok {
+		return Error{Code: int(e.Code), Message: e.Description}
+	}
+	return err
+}
+
+// Start a request of the given kind, returning a function to invoke with its
+// outcome, which reports it to the metrics receiver and ends its trace span.
+func (c *Conn) instrument(ctx context.Context, request, sql string) func(err error) {
+	if c.metrics == nil && c.spans == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	var end func(err error)
+	if c.spans != nil {
+		end = c.spans(ctx, Span{Request: request, SQL: sql, Address: c.address, Database: c.database})
+	}
+
+	return func(err error) {
+		err = reportedError(err)
+		if c.metrics != nil {
+			c.metrics.ObserveRequest(request, time.Since(start), err)
+		}
+		if end != nil {
+			end(err)
+		}
+	}
+}
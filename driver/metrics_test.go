@@ -0,0 +1,77 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type metrics struct {
+	mu       sync.Mutex
+	connects []string
+	requests []string
+	codes    []int
+}
+
+func (m *metrics) ObserveConnect(address string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connects = append(m.connects, address)
+}
+
+func (m *metrics) ObserveRequest(request string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, request)
+	if err, ok := err.(dqlitedriver.Error); ok {
+		m.codes = append(m.codes, err.Code)
+	}
+}
+
+// Connections and requests are reported to the metrics receiver.
+func TestWithMetrics(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		if sql == "FAIL" {
+			return clienttest.Result{}, clienttest.Error{Code: 19, Message: "constraint failed"}
+		}
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	m := &metrics{}
+	connector, err := dqlitedriver.NewConnector(newStore(t, server.Address()), "test.db", dqlitedriver.WithMetrics(m))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "DELETE FROM t")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "FAIL")
+	require.Error(t, err)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT n FROM t")
+	require.NoError(t, err)
+	defer stmt.Close()
+	var n int64
+	require.NoError(t, stmt.QueryRowContext(ctx).Scan(&n))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assert.Equal(t, []string{server.Address()}, m.connects)
+	assert.Equal(t, []string{"exec", "exec", "prepare", "query"}, m.requests)
+	assert.Equal(t, []int{19}, m.codes)
+}
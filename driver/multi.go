@@ -0,0 +1,181 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// A statement of a query made of multiple statements, with its arguments.
+type pendingStatement struct {
+	sql  string
+	args []driver.NamedValue
+}
+
+// Split the given SQL text into its statements, trimming spaces and dropping
+// empty ones.
+//
+// As with sqlite3_complete, semicolons in the body of a CREATE TRIGGER
+// statement don't end it, unless they follow END.
+func splitStatements(sql string) []string {
+	statements := []string{}
+
+	start := 0
+	empty := true       // Whether the current statement has no tokens yet.
+	words := []string{} // First words of the current statement.
+	last := ""          // Last word of the current statement.
+
+	for i := 0; i < len(sql); {
+		if j := skipLiteral(sql, i); j > i {
+			if c := sql[i]; c != '-' && c != '/' {
+				empty = false
+				last = ""
+			}
+			i = j
+			continue
+		}
+
+		c := sql[i]
+		switch {
+		case c == ';':
+			if isCreateTrigger(words) && !strings.EqualFold(last, "END") {
+				i++
+				continue
+			}
+			if !empty {
+				statements = append(statements, strings.TrimSpace(sql[start:i+1]))
+			}
+			start = i + 1
+			empty = true
+			words = words[:0]
+			last = ""
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(sql) && isWordByte(sql[j]) {
+				j++
+			}
+			last = sql[i:j]
+			if len(words) < 4 {
+				words = append(words, last)
+			}
+			empty = false
+			i = j
+		case unicode.IsSpace(rune(c)):
+			i++
+		default:
+			empty = false
+			last = ""
+			i++
+		}
+	}
+
+	if !empty {
+		statements = append(statements, strings.TrimSpace(sql[start:]))
+	}
+
+	return statements
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Whether the given first words of a statement start a CREATE TRIGGER one.
+func isCreateTrigger(words []string) bool {
+	if len(words) < 2 || !strings.EqualFold(words[0], "CREATE") {
+		return false
+	}
+	if strings.EqualFold(words[1], "TEMP") || strings.EqualFold(words[1], "TEMPORARY") {
+		words = words[1:]
+	}
+	return len(words) >= 2 && strings.EqualFold(words[1], "TRIGGER")
+}
+
+// Distribute the given arguments among the given statements. Positional
+// arguments are consumed in order by the statements, as many as their "?" and
+// "?NNN" parameters, while named arguments are passed to all the statements
+// with a parameter of that name.
+func splitArgs(statements []string, args []driver.NamedValue) ([]pendingStatement, error) {
+	pending := make([]pendingStatement, len(statements))
+	positional := []driver.NamedValue{}
+	named := []driver.NamedValue{}
+	for _, arg := range args {
+		if arg.Name == "" {
+			positional = append(positional, arg)
+		} else {
+			named = append(named, arg)
+		}
+	}
+	used := make([]bool, len(named))
+
+	for i, sql := range statements {
+		params := sqlParameters(sql)
+		statementArgs := []driver.NamedValue{}
+		for _, name := range params {
+			if name != "" {
+				continue
+			}
+			if len(positional) == 0 {
+				break
+			}
+			statementArgs = append(statementArgs, positional[0])
+			positional = positional[1:]
+		}
+		for j, arg := range named {
+			for _, name := range params {
+				if name == arg.Name {
+					statementArgs = append(statementArgs, arg)
+					used[j] = true
+					break
+				}
+			}
+		}
+		for j := range statementArgs {
+			statementArgs[j].Ordinal = j + 1
+		}
+		pending[i] = pendingStatement{sql: sql, args: statementArgs}
+	}
+
+	if len(positional) > 0 {
+		return nil, errors.Errorf("too many positional arguments")
+	}
+	for j, arg := range named {
+		if !used[j] {
+			return nil, errors.Errorf("no parameter named %q", arg.Name)
+		}
+	}
+
+	return pending, nil
+}
+
+// HasNextResultSet is called at the end of the current result set and
+// reports whether there is another result set after the current one, which
+// is the case for queries made of multiple statements.
+func (r *Rows) HasNextResultSet() bool {
+	return len(r.pending) > 0
+}
+
+// NextResultSet runs the next statement of the query, advancing to its result
+// set.
+func (r *Rows) NextResultSet() error {
+	if len(r.pending) == 0 {
+		return io.EOF
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	statement := r.pending[0]
+	rows, err := r.conn.query(r.parent, statement.sql, statement.args, false)
+	if err != nil {
+		return err
+	}
+	rows.pending = r.pending[1:]
+	*r = *rows
+
+	return nil
+}
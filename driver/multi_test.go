@@ -0,0 +1,113 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Each statement of a query yields its own result set.
+func TestRows_NextResultSet(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	queries := []string{}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, fmt.Sprintf("%s %v", sql, args))
+		return &clienttest.Rows{
+			Columns: []string{"n"},
+			Values:  [][]driver.Value{{int64(len(queries))}, {int64(len(queries) * 10)}},
+		}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := `SELECT a FROM t WHERE b = ? AND c = :c; -- first
+		SELECT ';' FROM u WHERE d = ?;
+		SELECT e FROM v WHERE f = :c;
+		-- done`
+	rows, err := db.QueryContext(context.Background(), query, int64(1), int64(2), sql.Named("c", "x"))
+	require.NoError(t, err)
+	defer rows.Close()
+
+	sets := [][]int64{}
+	for {
+		set := []int64{}
+		for rows.Next() {
+			var n int64
+			require.NoError(t, rows.Scan(&n))
+			set = append(set, n)
+		}
+		sets = append(sets, set)
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(t, rows.Err())
+
+	assert.Equal(t, [][]int64{{1, 10}, {2, 20}, {3, 30}}, sets)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"SELECT a FROM t WHERE b = ? AND c = :c; [1 x]",
+		"-- first\n\t\tSELECT ';' FROM u WHERE d = ?; [2]",
+		"SELECT e FROM v WHERE f = :c; [x]",
+	}, queries)
+}
+
+// The statements of a query can't be given more arguments than parameters.
+func TestRows_NextResultSet_TooManyArgs(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.QueryContext(context.Background(), "SELECT ?; SELECT ?", int64(1), int64(2), int64(3))
+	assert.EqualError(t, err, "too many positional arguments")
+}
+
+// Semicolons in trigger bodies don't split statements.
+func TestRows_NextResultSet_Trigger(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	queries := []string{}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, sql)
+		return &clienttest.Rows{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	trigger := "CREATE TEMP TRIGGER r AFTER INSERT ON t BEGIN INSERT INTO u VALUES(1); DELETE FROM v; END;"
+	rows, err := db.QueryContext(context.Background(), trigger+" SELECT 1")
+	require.NoError(t, err)
+	for rows.NextResultSet() {
+	}
+	require.NoError(t, rows.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{trigger, "SELECT 1"}, queries)
+}
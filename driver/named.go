@@ -0,0 +1,164 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Bind the given arguments to the parameters of the given SQL text.
+//
+// Arguments passed with sql.Named are bound to all the parameters with that
+// name, using any of the ":name", "@name" and "$name" styles. Other arguments
+// are bound in order to the "?" and "?NNN" parameters. The returned values are
+// ordered by parameter index, with missing parameters set to NULL, as dqlite
+// binds parameters by position.
+func bindNamedValues(sql string, args []driver.NamedValue) ([]driver.NamedValue, error) {
+	named := false
+	for _, arg := range args {
+		if arg.Name != "" {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return args, nil
+	}
+
+	params := sqlParameters(sql)
+	values := make([]driver.NamedValue, len(params))
+	for i := range values {
+		values[i].Ordinal = i + 1
+	}
+
+	next := 0 // Index of the next unnamed parameter.
+	for _, arg := range args {
+		if arg.Name == "" {
+			for next < len(params) && params[next] != "" {
+				next++
+			}
+			if next == len(params) {
+				return nil, errors.Errorf("too many positional arguments")
+			}
+			values[next].Value = arg.Value
+			next++
+			continue
+		}
+
+		found := false
+		for i, name := range params {
+			if name == arg.Name {
+				values[i].Value = arg.Value
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("no parameter named %q", arg.Name)
+		}
+	}
+
+	return values, nil
+}
+
+// Return the parameters of the given SQL text, numbered as SQLite does: the
+// element at index i holds the name of parameter i+1 without its prefix, or
+// the empty string for "?" and "?NNN" parameters.
+func sqlParameters(sql string) []string {
+	params := []string{}
+	seen := map[string]bool{} // Named parameters, by full token.
+
+	for i := 0; i < len(sql); {
+		if j := skipLiteral(sql, i); j > i {
+			i = j
+			continue
+		}
+		c := sql[i]
+		switch {
+		case c == '?':
+			j := i + 1
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if j > i+1 {
+				if n, err := strconv.Atoi(sql[i+1 : j]); err == nil && n > 0 {
+					for len(params) < n {
+						params = append(params, "")
+					}
+				}
+			} else {
+				params = append(params, "")
+			}
+			i = j
+		case c == ':' || c == '@' || c == '$':
+			j := i + 1
+			for j < len(sql) {
+				r, size := utf8.DecodeRuneInString(sql[j:])
+				if !isIdentifierRune(r) {
+					break
+				}
+				j += size
+			}
+			if j == i+1 {
+				i++
+				continue
+			}
+			token := sql[i:j]
+			if !seen[token] {
+				params = append(params, token[1:])
+				seen[token] = true
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+
+	return params
+}
+
+// If a quoted string or identifier, or a comment, starts at the given index of
+// the SQL text, return the index right after it. Otherwise return i.
+func skipLiteral(sql string, i int) int {
+	c := sql[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`' || c == '[':
+		end := c
+		if c == '[' {
+			end = ']'
+		}
+		i++
+		for i < len(sql) {
+			if sql[i] == end {
+				// Quotes are escaped by doubling them.
+				if end != ']' && i+1 < len(sql) && sql[i+1] == end {
+					i += 2
+					continue
+				}
+				break
+			}
+			i++
+		}
+		i++
+	case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+		for i < len(sql) && sql[i] != '\n' {
+			i++
+		}
+	case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+		i += 2
+		for i+1 < len(sql) && !(sql[i] == '*' && sql[i+1] == '/') {
+			i++
+		}
+		i += 2
+	}
+	if i > len(sql) {
+		i = len(sql)
+	}
+	return i
+}
+
+// Whether the given rune can be part of a parameter name, as per SQLite.
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= 0x80
+}
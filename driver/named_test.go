@@ -0,0 +1,106 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Named arguments are bound to the parameters with the same name, whatever
+// their prefix.
+func TestNamedParameters(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	cases := []struct {
+		query string
+		args  []interface{}
+		bound []driver.Value
+	}{{
+		"INSERT INTO t VALUES(:a, @b, $c)",
+		[]interface{}{sql.Named("c", "x"), sql.Named("a", int64(1)), sql.Named("b", 2.5)},
+		[]driver.Value{int64(1), 2.5, "x"},
+	}, {
+		"UPDATE t SET a = :a WHERE b = :b OR c = :a",
+		[]interface{}{sql.Named("b", int64(2)), sql.Named("a", int64(1))},
+		[]driver.Value{int64(1), int64(2)},
+	}, {
+		"UPDATE t SET a = ?, b = ':b' /* :c */ WHERE c = :c -- :d\n AND d = ?",
+		[]interface{}{int64(1), sql.Named("c", int64(3)), int64(4)},
+		[]driver.Value{int64(1), int64(3), int64(4)},
+	}, {
+		"DELETE FROM t WHERE a = ?1 OR b = :b OR c = ?1",
+		[]interface{}{sql.Named("b", int64(2)), int64(1)},
+		[]driver.Value{int64(1), int64(2)},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			_, err := db.ExecContext(ctx, c.query, c.args...)
+			require.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, c.bound, bound)
+		})
+	}
+
+	_, err = db.ExecContext(ctx, "DELETE FROM t WHERE a = :a", sql.Named("b", int64(1)))
+	assert.EqualError(t, err, `no parameter named "b"`)
+}
+
+// Statements with more than 255 parameters are encoded in the wider format.
+func TestManyParameters(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	args := make([]interface{}, 300)
+	values := make([]driver.Value, len(args))
+	for i := range args {
+		args[i] = int64(i)
+		values[i] = int64(i)
+	}
+	query := "INSERT INTO t VALUES(?" + strings.Repeat(", ?", len(args)-1) + ")"
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, query, args...)
+	require.NoError(t, err)
+
+	mu.Lock()
+	assert.Equal(t, values, bound)
+	mu.Unlock()
+}
@@ -0,0 +1,190 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Uint64 is an unsigned 64-bit integer that can be stored in an INTEGER
+// column and scanned back without losing its high bit.
+//
+// SQLite integers are signed, and database/sql refuses uint64 arguments
+// larger than math.MaxInt64. Uint64 values are stored with the same bits as
+// an int64, so values larger than math.MaxInt64 are seen as negative numbers
+// by SQL expressions, and sort before the smaller ones.
+type Uint64 uint64
+
+// Value implements driver.Valuer.
+func (u Uint64) Value() (driver.Value, error) {
+	return int64(u), nil
+}
+
+// Scan implements sql.Scanner. Besides integers, it accepts the decimal
+// representation of the value, as stored in a TEXT column.
+func (u *Uint64) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case int64:
+		*u = Uint64(value)
+	case string:
+		return u.parse(value)
+	case []byte:
+		return u.parse(string(value))
+	case float64:
+		if value < 0 || value >= math.MaxUint64 || value != math.Trunc(value) {
+			return errors.Errorf("can't convert %v to uint64", value)
+		}
+		*u = Uint64(value)
+	default:
+		return errors.Errorf("can't convert %T to uint64", value)
+	}
+	return nil
+}
+
+func (u *Uint64) parse(s string) error {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "can't convert %q to uint64", s)
+	}
+	*u = Uint64(n)
+	return nil
+}
+
+// BigInt is an arbitrary-precision integer, stored as its decimal
+// representation in a TEXT column. A nil Int is stored as NULL.
+//
+// The column must have TEXT or BLOB affinity: with INTEGER, REAL or NUMERIC
+// affinity, SQLite converts large values to floating point numbers and loses
+// precision.
+type BigInt struct {
+	Int *big.Int
+}
+
+// Value implements driver.Valuer.
+func (b BigInt) Value() (driver.Value, error) {
+	if b.Int == nil {
+		return nil, nil
+	}
+	return b.Int.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (b *BigInt) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		b.Int = nil
+	case int64:
+		b.Int = big.NewInt(value)
+	case string:
+		return b.parse(value)
+	case []byte:
+		return b.parse(string(value))
+	default:
+		return errors.Errorf("can't convert %T to big integer", value)
+	}
+	return nil
+}
+
+func (b *BigInt) parse(s string) error {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return errors.Errorf("can't convert %q to big integer", s)
+	}
+	b.Int = n
+	return nil
+}
+
+// Decimal is an arbitrary-precision decimal number, equal to Unscaled ×
+// 10^-Scale, stored in its canonical text form, like "-12.340" for an
+// unscaled value of -12340 and a scale of 3. The scale is preserved, so
+// trailing zeros survive a round trip. A nil Unscaled value is stored as
+// NULL.
+//
+// As for BigInt, the column must have TEXT or BLOB affinity.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int
+}
+
+// ParseDecimal parses the decimal representation of a number, like "12.34"
+// or "-0.5".
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		digits = s[:i] + s[i+1:]
+		scale = len(s) - i - 1
+	}
+	if digits == "" || strings.ContainsAny(digits, ".eE") || strings.HasSuffix(s, ".") {
+		return Decimal{}, errors.Errorf("invalid decimal %q", s)
+	}
+
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, errors.Errorf("invalid decimal %q", s)
+	}
+
+	return Decimal{Unscaled: unscaled, Scale: scale}, nil
+}
+
+// String returns the canonical text form of the number.
+func (d Decimal) String() string {
+	if d.Unscaled == nil {
+		return "<nil>"
+	}
+
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Unscaled.Sign() < 0 {
+		digits = "-" + digits
+	}
+
+	return digits
+}
+
+// Rat returns the number as a rational, or nil if Unscaled is nil.
+func (d Decimal) Rat() *big.Rat {
+	if d.Unscaled == nil {
+		return nil
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil)
+	return new(big.Rat).SetFrac(d.Unscaled, scale)
+}
+
+// Value implements driver.Valuer.
+func (d Decimal) Value() (driver.Value, error) {
+	if d.Unscaled == nil {
+		return nil, nil
+	}
+	if d.Scale < 0 {
+		return nil, errors.Errorf("negative decimal scale %d", d.Scale)
+	}
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (d *Decimal) Scan(value interface{}) error {
+	var err error
+	switch value := value.(type) {
+	case nil:
+		*d = Decimal{}
+	case int64:
+		*d = Decimal{Unscaled: big.NewInt(value)}
+	case string:
+		*d, err = ParseDecimal(value)
+	case []byte:
+		*d, err = ParseDecimal(string(value))
+	default:
+		err = errors.Errorf("can't convert %T to decimal", value)
+	}
+	return err
+}
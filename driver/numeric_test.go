@@ -0,0 +1,132 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Unsigned integers larger than math.MaxInt64 survive a round trip.
+func TestUint64(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var stored driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stored = args[0]
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &clienttest.Rows{
+			Columns: []string{"n", "s"},
+			Values:  [][]driver.Value{{stored, "18446744073709551615"}},
+		}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(?)", dqlitedriver.Uint64(math.MaxUint64-1))
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, int64(-2), stored)
+	mu.Unlock()
+
+	var n, s dqlitedriver.Uint64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n, s FROM t").Scan(&n, &s))
+	assert.Equal(t, dqlitedriver.Uint64(math.MaxUint64-1), n)
+	assert.Equal(t, dqlitedriver.Uint64(math.MaxUint64), s)
+}
+
+func TestUint64_ScanError(t *testing.T) {
+	var n dqlitedriver.Uint64
+	assert.EqualError(t, n.Scan(-1.5), "can't convert -1.5 to uint64")
+	assert.Error(t, n.Scan("-1"))
+	assert.EqualError(t, n.Scan(nil), "can't convert <nil> to uint64")
+}
+
+// Big integers and decimals are stored as text, without losing precision.
+func TestBigInt_Decimal(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var stored []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stored = args
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return &clienttest.Rows{Columns: []string{"i", "d", "n"}, Values: [][]driver.Value{stored}}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	i, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	require.True(t, ok)
+	d, err := dqlitedriver.ParseDecimal("1234567890.123456789000")
+	require.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES(?, ?, ?)", dqlitedriver.BigInt{Int: i}, d, dqlitedriver.Decimal{})
+	require.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, []driver.Value{"-123456789012345678901234567890", "1234567890.123456789000", nil}, stored)
+	mu.Unlock()
+
+	var bi dqlitedriver.BigInt
+	var bd, null dqlitedriver.Decimal
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT i, d, n FROM t").Scan(&bi, &bd, &null))
+	assert.Equal(t, 0, i.Cmp(bi.Int))
+	assert.Equal(t, "1234567890.123456789000", bd.String())
+	assert.Equal(t, 12, bd.Scale)
+	assert.Nil(t, null.Unscaled)
+}
+
+func TestParseDecimal(t *testing.T) {
+	cases := map[string]string{
+		"12.34":  "12.34",
+		"-0.05":  "-0.05",
+		"-.5":    "-0.5",
+		"100":    "100",
+		"+7.250": "7.250",
+	}
+	for in, out := range cases {
+		d, err := dqlitedriver.ParseDecimal(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, out, d.String(), in)
+	}
+
+	d, err := dqlitedriver.ParseDecimal("-0.05")
+	require.NoError(t, err)
+	assert.Equal(t, big.NewRat(-1, 20), d.Rat())
+
+	for _, in := range []string{"", ".", "1.", "1e5", "1.2.3", "abc", "-"} {
+		_, err := dqlitedriver.ParseDecimal(in)
+		assert.Error(t, err, in)
+	}
+}
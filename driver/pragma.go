@@ -0,0 +1,40 @@
+package driver
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// A PRAGMA statement run on each new connection.
+type pragma struct {
+	name  string
+	value string
+}
+
+var (
+	pragmaName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	pragmaValue = regexp.MustCompile(`^[-+]?[A-Za-z0-9_.]+$`)
+)
+
+// Check that the pragma can be safely interpolated in a statement.
+func (p pragma) validate() error {
+	if !pragmaName.MatchString(p.name) {
+		return errors.Errorf("invalid pragma name %q", p.name)
+	}
+	if !pragmaValue.MatchString(p.value) {
+		return errors.Errorf("invalid value %q for pragma %s", p.value, p.name)
+	}
+	return nil
+}
+
+// Run the given pragmas on the connection.
+func (c *Conn) setPragmas(ctx context.Context, pragmas []pragma) error {
+	for _, p := range pragmas {
+		if _, err := c.exec(ctx, "PRAGMA "+p.name+" = "+p.value, nil, true); err != nil {
+			return errors.Wrapf(err, "set pragma %s", p.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The pragmas given in the data source name are set on each new connection.
+func TestPragmas(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, sql)
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?_foreign_keys=on&_busy_timeout=5000")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	ctx := context.Background()
+	conn1, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"PRAGMA busy_timeout = 5000",
+		"PRAGMA foreign_keys = on",
+		"PRAGMA busy_timeout = 5000",
+		"PRAGMA foreign_keys = on",
+	}, statements)
+}
+
+func TestPragmas_Invalid(t *testing.T) {
+	store := client.NewInmemNodeStore()
+
+	_, err := dqlitedriver.New(store, dqlitedriver.WithPragma("foreign_keys", "on; DROP TABLE t"))
+	assert.EqualError(t, err, `invalid value "on; DROP TABLE t" for pragma foreign_keys`)
+
+	_, err = dqlitedriver.New(store, dqlitedriver.WithPragma("foreign keys", "on"))
+	assert.EqualError(t, err, `invalid pragma name "foreign keys"`)
+}
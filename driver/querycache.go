@@ -0,0 +1,274 @@
+package driver
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// WithQueryCache enables caching the result sets of read queries for the
+// given time, keeping up to the given number of them. It's meant for
+// read-mostly data, like configuration tables, queried much more often than
+// it changes.
+//
+// The cache is shared by all the connections of the driver and keyed by
+// database, SQL text and arguments. Only queries made of a single SELECT
+// statement and run outside of transactions are cached, and their result set
+// is read entirely before being returned. Any other statement run through the
+// driver, including the ones starting a transaction, empties the cache.
+//
+// Changes made through other drivers or processes are not noticed, so the
+// result of a cached query can be stale for up to the given time. Queries
+// whose result changes by itself, like "SELECT random()", should not be run
+// with a cache.
+//
+// If not used, the default is to not cache queries.
+func WithQueryCache(ttl time.Duration, size int) Option {
+	return func(options *options) {
+		options.QueryCacheTTL = ttl
+		options.QueryCacheSize = size
+	}
+}
+
+// LRU cache of result sets of read queries, shared by the connections of a
+// driver.
+type queryCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	size       int
+	generation uint64 // Incremented by each write.
+	entries    map[string]*list.Element
+	lru        *list.List // Most recently used at the front.
+}
+
+// A cached result set.
+type queryCacheEntry struct {
+	key     string
+	columns []string
+	types   []string
+	values  [][]driver.Value
+	expires time.Time
+}
+
+func newQueryCache(ttl time.Duration, size int) *queryCache {
+	return &queryCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Return the cached result set with the given key, if any and not expired.
+func (c *queryCache) get(key string) *queryCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*queryCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+// Return the current write generation, to be passed to add.
+func (c *queryCache) current() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Add a result set read during the given write generation, unless a write
+// happened since then.
+func (c *queryCache) add(entry *queryCacheEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	if element, ok := c.entries[entry.key]; ok {
+		c.lru.Remove(element)
+	}
+	entry.expires = time.Now().Add(c.ttl)
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+}
+
+// Drop all the cached result sets, as the database might have changed.
+func (c *queryCache) invalidate() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// Return the cache key of a query.
+func queryCacheKey(database, query string, args []driver.NamedValue) string {
+	var b strings.Builder
+	b.WriteString(database)
+	b.WriteByte(0)
+	b.WriteString(query)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\x00%s:%d:%T:%#v", arg.Name, arg.Ordinal, arg.Value, arg.Value)
+	}
+	return b.String()
+}
+
+// Whether the given single statement is a SELECT one.
+func isSelect(sql string) bool {
+	i := 0
+	for i < len(sql) {
+		if c := sql[i]; unicode.IsSpace(rune(c)) {
+			i++
+			continue
+		}
+		if c := sql[i]; c != '-' && c != '/' {
+			break
+		}
+		j := skipLiteral(sql, i)
+		if j == i {
+			return false
+		}
+		i = j
+	}
+	j := i
+	for j < len(sql) && isWordByte(sql[j]) {
+		j++
+	}
+	return strings.EqualFold(sql[i:j], "SELECT")
+}
+
+// Whether the given query is made of a single SELECT statement, so that it
+// can't modify the database.
+func isReadOnly(sql string) bool {
+	return isSelect(sql) && len(splitStatements(sql)) <= 1
+}
+
+// Run the given single-statement query through the query cache, if enabled.
+func (c *Conn) cachedQuery(ctx context.Context, query string, args []driver.NamedValue, run func() (*Rows, error)) (driver.Rows, error) {
+	if c.queries == nil {
+		return run()
+	}
+	if !isSelect(query) {
+		// The statement might write, like DELETE ... RETURNING.
+		defer c.queries.invalidate()
+		return run()
+	}
+	if c.txDepth > 0 {
+		return run()
+	}
+
+	key := queryCacheKey(c.database, tagSQL(ctx, query), args)
+	if entry := c.queries.get(key); entry != nil {
+		atomic.AddUint64(&c.stats.queryCacheHits, 1)
+		return &cachedRows{entry: entry}, nil
+	}
+	atomic.AddUint64(&c.stats.queryCacheMisses, 1)
+
+	generation := c.queries.current()
+	rows, err := run()
+	if err != nil {
+		return nil, err
+	}
+	entry, err := readResultSet(rows)
+	if err != nil {
+		return nil, err
+	}
+	entry.key = key
+	c.queries.add(entry, generation)
+
+	return &cachedRows{entry: entry}, nil
+}
+
+// Read all the rows of a result set and close it.
+func readResultSet(rows *Rows) (*queryCacheEntry, error) {
+	entry := &queryCacheEntry{columns: rows.Columns()}
+	entry.types, _ = rows.rows.ColumnTypes()
+
+	for {
+		row := make([]driver.Value, len(entry.columns))
+		err := rows.Next(row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for i, value := range row {
+			if value, ok := value.([]byte); ok {
+				row[i] = append([]byte{}, value...)
+			}
+		}
+		entry.values = append(entry.values, row)
+	}
+
+	return entry, rows.Close()
+}
+
+// Rows of a cached result set.
+type cachedRows struct {
+	entry *queryCacheEntry
+	next  int
+}
+
+// Columns returns the names of the columns.
+func (r *cachedRows) Columns() []string {
+	return r.entry.columns
+}
+
+// Close closes the rows iterator.
+func (r *cachedRows) Close() error {
+	return nil
+}
+
+// Next populates the next row of data into the provided slice.
+func (r *cachedRows) Next(dest []driver.Value) error {
+	if r.next == len(r.entry.values) {
+		return io.EOF
+	}
+	for i, value := range r.entry.values[r.next] {
+		// Don't let sql.RawBytes destinations alias the cache.
+		if value, ok := value.([]byte); ok {
+			dest[i] = append([]byte{}, value...)
+			continue
+		}
+		dest[i] = value
+	}
+	r.next++
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName implements RowsColumnTypeDatabaseTypeName.
+func (r *cachedRows) ColumnTypeDatabaseTypeName(i int) string {
+	if i >= len(r.entry.types) {
+		return ""
+	}
+	return r.entry.types[i]
+}
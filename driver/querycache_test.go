@@ -0,0 +1,112 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Read queries are answered from the cache until they expire or a statement
+// is executed.
+func TestWithQueryCache(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var queries int64
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		n := atomic.AddInt64(&queries, 1)
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{n}}}, nil
+	})
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{}, nil
+	})
+
+	ttl := 200 * time.Millisecond
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db", dqlitedriver.WithQueryCache(ttl, 2))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	query := func(sql string, args ...interface{}) int64 {
+		var n int64
+		require.NoError(t, db.QueryRow(sql, args...).Scan(&n))
+		return n
+	}
+
+	assert.Equal(t, int64(1), query("SELECT n FROM t WHERE k = ?", 1))
+	assert.Equal(t, int64(1), query("SELECT n FROM t WHERE k = ?", 1))
+	assert.Equal(t, int64(2), query("SELECT n FROM t WHERE k = ?", 2))
+	assert.Equal(t, int64(2), query("SELECT n FROM t WHERE k = ?", 2))
+
+	// Queries that aren't a SELECT are not cached.
+	assert.Equal(t, int64(3), query("DELETE FROM t RETURNING n"))
+	assert.Equal(t, int64(4), query("DELETE FROM t RETURNING n"))
+
+	// Writes empty the cache.
+	assert.Equal(t, int64(5), query("SELECT n FROM t WHERE k = ?", 1))
+	assert.Equal(t, int64(5), query("SELECT n FROM t WHERE k = ?", 1))
+	_, err = db.Exec("UPDATE t SET n = 0")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), query("SELECT n FROM t WHERE k = ?", 1))
+
+	// Queries in transactions are not cached.
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	var n int64
+	require.NoError(t, tx.QueryRow("SELECT n FROM t WHERE k = ?", 1).Scan(&n))
+	assert.Equal(t, int64(7), n)
+	require.NoError(t, tx.Commit())
+
+	// Cached result sets expire.
+	assert.Equal(t, int64(8), query("SELECT n FROM t WHERE k = ?", 1))
+	time.Sleep(ttl)
+	assert.Equal(t, int64(9), query("SELECT n FROM t WHERE k = ?", 1))
+
+	stats := connector.Driver().(*dqlitedriver.Driver).Stats()
+	assert.Equal(t, uint64(3), stats.QueryCacheHits)
+	assert.Equal(t, uint64(6), stats.QueryCacheMisses)
+}
+
+// Prepared statements go through the cache too.
+func TestWithQueryCache_Prepared(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var queries int64
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		atomic.AddInt64(&queries, 1)
+		return &clienttest.Rows{Columns: []string{"b"}, Values: [][]driver.Value{{[]byte("blob")}}}, nil
+	})
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db", dqlitedriver.WithQueryCache(time.Minute, 10))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT b FROM t")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		var b sql.RawBytes
+		rows, err := stmt.QueryContext(ctx)
+		require.NoError(t, err)
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(&b))
+		assert.Equal(t, "blob", string(b))
+		b[0] = 'x'
+		require.NoError(t, rows.Close())
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&queries))
+}
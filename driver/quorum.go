@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"context"
+)
+
+// WithQuorumReads makes queries confirm, once the leader has answered, that
+// it's still the leader, by asking the other voters in the node store who the
+// leader is and checking that a majority of them agree. This guards against
+// stale reads from a leader that was deposed without noticing yet, for
+// example because it got partitioned from the rest of the cluster.
+//
+// Each query then waits, after its first rows, for a Leader request to be
+// answered by enough voters to make a majority, so its latency grows by the
+// round trip time to the slowest of the voters needed. The driver keeps a
+// connection open to each voter, shared by all connections of the driver,
+// and only dials a voter again if its connection fails or exceeds the max
+// lifetime, see WithMaxLifetime.
+//
+// A query that can't be confirmed fails as if the connection to the leader
+// was lost, so it's retried on a new connection according to FailoverRetry.
+// Results served by the query cache are not confirmed again.
+//
+// If not used, the default is to trust the node the connection is open with
+// to be the leader.
+func WithQuorumReads() Option {
+	return func(options *options) {
+		options.QuorumReads = true
+	}
+}
+
+type quorumReadsKey struct{}
+
+// ContextWithQuorumReads returns a context making the queries run with it
+// confirm the leader or not, regardless of WithQuorumReads.
+func ContextWithQuorumReads(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, quorumReadsKey{}, enabled)
+}
+
+// Confirm the leader that answered a query run with the given context, if
+// quorum reads are enabled.
+func (c *Conn) verifyRead(ctx context.Context) error {
+	enabled := c.quorumReads
+	if value, ok := ctx.Value(quorumReadsKey{}).(bool); ok {
+		enabled = value
+	}
+	if !enabled {
+		return nil
+	}
+
+	return c.connector.VerifyLeader(ctx, c.address)
+}
@@ -0,0 +1,130 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Queries fail unless a majority of the voters confirm the leader.
+func TestWithQuorumReads(t *testing.T) {
+	servers := make([]*clienttest.Server, 3)
+	nodes := make([]client.NodeInfo, 3)
+	for i := range servers {
+		servers[i] = clienttest.NewServer(uint64(i + 1))
+		defer servers[i].Close()
+		nodes[i] = client.NodeInfo{ID: uint64(i + 1), Address: servers[i].Address()}
+	}
+	for _, server := range servers {
+		server.SetLeader(&nodes[0])
+	}
+	servers[0].HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), nodes))
+
+	var mu sync.Mutex
+	dials := map[string]int{}
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		mu.Lock()
+		dials[address]++
+		mu.Unlock()
+		return client.DefaultDialFunc(ctx, address)
+	}
+	count := func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		counts := map[string]int{}
+		for address, n := range dials {
+			counts[address] = n
+		}
+		return counts
+	}
+
+	connector, err := dqlitedriver.NewConnector(store, "test.db",
+		dqlitedriver.WithQuorumReads(), dqlitedriver.WithFailoverRetry(dqlitedriver.RetryNone),
+		dqlitedriver.WithDialFunc(dial))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	var n int64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+
+	// The connections to the voters are reused by the next queries.
+	dialed := count()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	}
+	assert.Equal(t, dialed, count())
+
+	// One voter following another leader still leaves a majority.
+	servers[1].SetLeader(&nodes[1])
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+
+	servers[2].SetLeader(&nodes[1])
+	err = db.QueryRowContext(ctx, "SELECT n").Scan(&n)
+	assert.Equal(t, dqlitedriver.ErrConnectionLost, err)
+
+	// Confirmation can be disabled for a single query.
+	ctx = dqlitedriver.ContextWithQuorumReads(ctx, false)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	assert.Equal(t, int64(1), n)
+}
+
+// Each connection confirms the node it was opened to, even after other
+// connections followed a new leader.
+func TestWithQuorumReads_Deposed(t *testing.T) {
+	servers := make([]*clienttest.Server, 3)
+	nodes := make([]client.NodeInfo, 3)
+	for i := range servers {
+		servers[i] = clienttest.NewServer(uint64(i + 1))
+		defer servers[i].Close()
+		nodes[i] = client.NodeInfo{ID: uint64(i + 1), Address: servers[i].Address()}
+		servers[i].HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+			return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+		})
+	}
+	for _, server := range servers {
+		server.SetLeader(&nodes[0])
+	}
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), nodes))
+
+	connector, err := dqlitedriver.NewConnector(store, "test.db",
+		dqlitedriver.WithQuorumReads(), dqlitedriver.WithFailoverRetry(dqlitedriver.RetryNone))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	deposed, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer deposed.Close()
+
+	for _, server := range servers {
+		server.SetLeader(&nodes[1])
+	}
+	current, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer current.Close()
+
+	var n int64
+	require.NoError(t, current.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	err = deposed.QueryRowContext(ctx, "SELECT n").Scan(&n)
+	assert.Equal(t, dqlitedriver.ErrConnectionLost, err)
+}
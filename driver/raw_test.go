@@ -0,0 +1,54 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Cluster requests can be sent through the connection of a database/sql
+// connection.
+func TestConn_Client(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	nodes := []client.NodeInfo{
+		{ID: 1, Address: server.Address(), Role: client.Voter},
+		{ID: 2, Address: "1.2.3.4:666", Role: client.StandBy},
+	}
+	server.SetCluster(nodes)
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var cluster []client.NodeInfo
+	err = conn.Raw(func(c interface{}) error {
+		cli := c.(*dqlitedriver.Conn).Client()
+		defer cli.Close()
+		cluster, err = cli.Cluster(ctx)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, nodes, cluster)
+
+	// The connection is still usable.
+	var n int64
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,68 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Statements with a RETURNING clause can be run as queries, and the number of
+// modified rows retrieved along with the returned ones.
+func TestRows_RowsAffected(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	queries := []string{}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, sql)
+		if sql == "SELECT changes()" {
+			return &clienttest.Rows{Columns: []string{"changes()"}, Values: [][]driver.Value{{int64(3)}}}, nil
+		}
+		return &clienttest.Rows{
+			Columns: []string{"id"},
+			Values:  [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}},
+		}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ids := []int64{}
+	var n int64
+	err = conn.Raw(func(c interface{}) error {
+		rows, err := c.(*dqlitedriver.Conn).QueryContext(ctx, "DELETE FROM t RETURNING id", nil)
+		if err != nil {
+			return err
+		}
+		dest := make([]driver.Value, 1)
+		for rows.Next(dest) == nil {
+			ids = append(ids, dest[0].(int64))
+		}
+		n, err = rows.(*dqlitedriver.Rows).RowsAffected()
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, int64(3), n)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"DELETE FROM t RETURNING id", "SELECT changes()"}, queries)
+}
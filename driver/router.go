@@ -0,0 +1,160 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Tenant describes the database of a tenant and the limits of its connection
+// pool, see sql.DB.SetMaxOpenConns and sql.DB.SetMaxIdleConns. A zero limit
+// keeps the database/sql default.
+type Tenant struct {
+	Database     string // Name of the database of the tenant.
+	MaxOpenConns int    // Maximum number of open connections.
+	MaxIdleConns int    // Maximum number of idle connections.
+}
+
+// TenantFunc returns the database of the tenant with the given key, or an
+// error if there's no such tenant.
+type TenantFunc func(tenant string) (Tenant, error)
+
+// ErrRouterClosed is returned by Router.DB after the router was closed.
+var ErrRouterClosed = errors.New("router is closed")
+
+// Router hands out database handles bound to the databases of tenants, all
+// sharing the same driver, and so its connections to the cluster and its
+// configuration.
+//
+// For example, with one database per tenant and at most 4 connections each:
+//
+//	router := driver.NewRouter(drv, func(tenant string) (driver.Tenant, error) {
+//		return driver.Tenant{Database: "tenant-" + tenant + ".db", MaxOpenConns: 4}, nil
+//	})
+//	defer router.Close()
+//	...
+//	db, err := router.DB(tenant)
+type Router struct {
+	driver  *Driver
+	tenants TenantFunc
+
+	mu     sync.Mutex
+	dbs    map[string]*sql.DB // Handles by tenant key.
+	closed bool
+}
+
+// NewRouter returns a router handing out handles to the databases returned by
+// the given function, using the given driver.
+func NewRouter(d *Driver, tenants TenantFunc) *Router {
+	return &Router{
+		driver:  d,
+		tenants: tenants,
+		dbs:     map[string]*sql.DB{},
+	}
+}
+
+// DB returns the handle of the database of the given tenant, creating it the
+// first time. The handle is owned by the router and must not be closed.
+//
+// The tenant function is invoked without holding the router lock, so it can
+// be slow or use the router itself. If concurrent calls create a handle for
+// the same tenant, only one is kept.
+func (r *Router) DB(tenant string) (*sql.DB, error) {
+	r.mu.Lock()
+	closed := r.closed
+	db, ok := r.dbs[tenant]
+	r.mu.Unlock()
+
+	if closed {
+		return nil, ErrRouterClosed
+	}
+	if ok {
+		return db, nil
+	}
+
+	info, err := r.tenants(tenant)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tenant %q", tenant)
+	}
+	if info.Database == "" {
+		return nil, errors.Errorf("tenant %q: no database", tenant)
+	}
+
+	db = sql.OpenDB(tenantConnector{driver: r.driver, database: info.Database})
+	if info.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(info.MaxOpenConns)
+	}
+	if info.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(info.MaxIdleConns)
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		db.Close()
+		return nil, ErrRouterClosed
+	}
+	if existing, ok := r.dbs[tenant]; ok {
+		// Another call won the race.
+		r.mu.Unlock()
+		db.Close()
+		return existing, nil
+	}
+	r.dbs[tenant] = db
+	r.mu.Unlock()
+
+	return db, nil
+}
+
+// Forget closes the handle of the given tenant, if any, for example after
+// the tenant was removed or its limits changed. A new handle is created by the
+// next call to DB.
+func (r *Router) Forget(tenant string) error {
+	r.mu.Lock()
+	db, ok := r.dbs[tenant]
+	delete(r.dbs, tenant)
+	r.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return db.Close()
+}
+
+// Close closes the handles of all tenants. The driver itself is left open.
+func (r *Router) Close() error {
+	r.mu.Lock()
+	dbs := r.dbs
+	r.dbs = map[string]*sql.DB{}
+	r.closed = true
+	r.mu.Unlock()
+
+	var first error
+	for _, db := range dbs {
+		if err := db.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Connector of the database of a tenant. Unlike Connector, it doesn't close
+// the standby connections of the shared driver when the handle is closed.
+type tenantConnector struct {
+	driver   *Driver
+	database string
+}
+
+func (c tenantConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector := &Connector{uri: c.database, driver: c.driver}
+	return connector.Connect(ctx)
+}
+
+func (c tenantConnector) Driver() driver.Driver {
+	return c.driver
+}
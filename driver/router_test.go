@@ -0,0 +1,127 @@
+package driver_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Each tenant gets its own handle, bound to its database and limits.
+func TestRouter(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	databases := []string{}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		databases = append(databases, database)
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	drv, err := dqlitedriver.New(newStore(t, server.Address()))
+	require.NoError(t, err)
+
+	errUnknown := errors.New("unknown tenant")
+	router := dqlitedriver.NewRouter(drv, func(tenant string) (dqlitedriver.Tenant, error) {
+		switch tenant {
+		case "a":
+			return dqlitedriver.Tenant{Database: "a.db", MaxOpenConns: 2}, nil
+		case "b":
+			return dqlitedriver.Tenant{Database: "b.db"}, nil
+		case "c":
+			return dqlitedriver.Tenant{}, nil
+		}
+		return dqlitedriver.Tenant{}, errUnknown
+	})
+	defer router.Close()
+
+	a, err := router.DB("a")
+	require.NoError(t, err)
+	b, err := router.DB("b")
+	require.NoError(t, err)
+
+	again, err := router.DB("a")
+	require.NoError(t, err)
+	assert.True(t, a == again)
+	assert.Equal(t, 2, a.Stats().MaxOpenConnections)
+	assert.Equal(t, 0, b.Stats().MaxOpenConnections)
+
+	var n int64
+	require.NoError(t, a.QueryRow("SELECT n").Scan(&n))
+	require.NoError(t, b.QueryRow("SELECT n").Scan(&n))
+	assert.Equal(t, []string{"a.db", "b.db"}, databases)
+
+	_, err = router.DB("x")
+	assert.True(t, errors.Is(err, errUnknown))
+	assert.EqualError(t, err, `tenant "x": unknown tenant`)
+	_, err = router.DB("c")
+	assert.EqualError(t, err, `tenant "c": no database`)
+
+	// Forgetting a tenant closes its handle only.
+	require.NoError(t, router.Forget("a"))
+	assert.Error(t, a.QueryRow("SELECT n").Scan(&n))
+	require.NoError(t, b.QueryRow("SELECT n").Scan(&n))
+	again, err = router.DB("a")
+	require.NoError(t, err)
+	assert.False(t, a == again)
+
+	require.NoError(t, router.Close())
+	_, err = router.DB("a")
+	assert.Equal(t, dqlitedriver.ErrRouterClosed, err)
+}
+
+// Tenant lookups don't block the other tenants, and can use the router.
+func TestRouter_Lookup(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	drv, err := dqlitedriver.New(newStore(t, server.Address()))
+	require.NoError(t, err)
+
+	var router *dqlitedriver.Router
+	release := make(chan struct{})
+	router = dqlitedriver.NewRouter(drv, func(tenant string) (dqlitedriver.Tenant, error) {
+		switch tenant {
+		case "slow":
+			<-release
+		case "nested":
+			if _, err := router.DB("a"); err != nil {
+				return dqlitedriver.Tenant{}, err
+			}
+		}
+		return dqlitedriver.Tenant{Database: tenant + ".db"}, nil
+	})
+	defer router.Close()
+
+	a, err := router.DB("a")
+	require.NoError(t, err)
+
+	// Concurrent lookups of the same tenant end up with the same handle.
+	dbs := make(chan *sql.DB, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			db, err := router.DB("slow")
+			assert.NoError(t, err)
+			dbs <- db
+		}()
+	}
+
+	again, err := router.DB("a")
+	require.NoError(t, err)
+	assert.True(t, a == again)
+	_, err = router.DB("nested")
+	require.NoError(t, err)
+
+	close(release)
+	first, second := <-dbs, <-dbs
+	assert.True(t, first == second)
+}
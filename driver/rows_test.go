@@ -0,0 +1,185 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Result sets sent in multiple responses are fetched as rows are consumed.
+func TestRows_Parts(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	values := make([][]driver.Value, 1000)
+	for i := range values {
+		values[i] = []driver.Value{int64(i)}
+	}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: values, PageSize: 100}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	rows, err := db.QueryContext(ctx, "SELECT n FROM t")
+	require.NoError(t, err)
+	i := int64(0)
+	for rows.Next() {
+		var n int64
+		require.NoError(t, rows.Scan(&n))
+		assert.Equal(t, i, n)
+		i++
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, int64(1000), i)
+
+	// Closing the rows early interrupts the query, and the connection can
+	// still be used.
+	rows, err = db.QueryContext(ctx, "SELECT n FROM t")
+	require.NoError(t, err)
+	for i := 0; i < 150; i++ {
+		require.True(t, rows.Next())
+	}
+	require.NoError(t, rows.Close())
+
+	var n int64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n FROM t").Scan(&n))
+	assert.Equal(t, int64(0), n)
+}
+
+// BLOB values can be scanned into sql.RawBytes.
+func TestRows_RawBytes(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns: []string{"data", "name"},
+			Values: [][]driver.Value{
+				{[]byte("hello"), "a"},
+				{[]byte("world!"), "b"},
+			},
+		}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.QueryContext(context.Background(), "SELECT data, name FROM t")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	blobs := []string{}
+	names := []string{}
+	for rows.Next() {
+		var data, name sql.RawBytes
+		require.NoError(t, rows.Scan(&data, &name))
+		blobs = append(blobs, string(data))
+		names = append(names, string(name))
+	}
+	require.NoError(t, rows.Err())
+
+	assert.Equal(t, []string{"hello", "world!"}, blobs)
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+// If the context of a query is done while its rows are being fetched, the
+// query is interrupted and the connection can still be used.
+func TestRows_ContextDone(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	values := make([][]driver.Value, 1000)
+	for i := range values {
+		values[i] = []driver.Value{int64(i)}
+	}
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{Columns: []string{"n"}, Values: values, PageSize: 100}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rows, err := conn.QueryContext(ctx, "SELECT n FROM t")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.True(t, rows.Next())
+	}
+	<-ctx.Done()
+	for rows.Next() {
+	}
+	assert.Equal(t, context.DeadlineExceeded, rows.Err())
+	require.NoError(t, rows.Close())
+
+	// The connection is still in sync with the server.
+	var n int64
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT n FROM t").Scan(&n))
+	assert.Equal(t, int64(0), n)
+}
+
+// If the context of a query is cancelled while waiting for its first
+// response, the query is interrupted and the connection can still be used.
+func TestConn_QueryCancelled(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		if sql == "SELECT slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	var dials int
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		dials++
+		return client.DefaultDialFunc(ctx, address)
+	}
+	connector, err := dqlitedriver.NewConnector(newStore(t, server.Address()), "test.db", dqlitedriver.WithDialFunc(dial))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = conn.QueryContext(ctx, "SELECT slow")
+	assert.Equal(t, context.Canceled, err)
+
+	// The connection is still in sync with the server.
+	var n int64
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT n").Scan(&n))
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 1, dials)
+}
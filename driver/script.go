@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// ScriptResult is the outcome of a single statement of a script executed with
+// Conn.ExecScript.
+type ScriptResult struct {
+	SQL    string        // Text of the statement.
+	Offset int           // Byte offset of the statement in the script.
+	Result driver.Result // Rows affected and last insert ID.
+}
+
+// ScriptError is returned by Conn.ExecScript when a statement of the script
+// fails.
+type ScriptError struct {
+	Index  int    // Index of the failed statement, starting from 0.
+	Offset int    // Byte offset of the failed statement in the script.
+	SQL    string // Text of the failed statement.
+	Err    error  // Error returned by the statement.
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+// Unwrap returns the error returned by the failed statement.
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecScript executes the statements of the given SQL text one at a time, and
+// returns the result of each of them, unlike ExecContext which only returns
+// the result of the last statement.
+//
+// Arguments are distributed among the statements as for queries made of
+// multiple statements: positional arguments are consumed in order, named ones
+// are passed to every statement using them.
+//
+// Execution stops at the first failing statement, and a *ScriptError telling
+// which statement failed is returned along with the results of the statements
+// executed before it. To make the script atomic, execute it within a
+// transaction.
+//
+// ExecScript can be reached from database/sql through sql.Conn.Raw:
+//
+//	conn.Raw(func(c interface{}) error {
+//		results, err = c.(*driver.Conn).ExecScript(ctx, script)
+//		return err
+//	})
+func (c *Conn) ExecScript(ctx context.Context, script string, args ...interface{}) ([]ScriptResult, error) {
+	values, err := c.batchArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := splitStatements(script)
+	pending, err := splitArgs(statements, values)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ScriptResult, 0, len(pending))
+	offset := 0
+	for i, statement := range pending {
+		// Statements are trimmed substrings of the script, in order.
+		offset += strings.Index(script[offset:], statement.sql)
+
+		result, err := c.exec(ctx, statement.sql, statement.args, false)
+		if err != nil {
+			return results, &ScriptError{Index: i, Offset: offset, SQL: statement.sql, Err: err}
+		}
+		results = append(results, ScriptResult{SQL: statement.sql, Offset: offset, Result: result})
+
+		offset += len(statement.sql)
+	}
+
+	return results, nil
+}
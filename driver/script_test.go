@@ -0,0 +1,73 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Each statement of a script is executed on its own, until one fails.
+func TestConn_ExecScript(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(sql, "BAD") {
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "syntax error"}
+		}
+		statements = append(statements, fmt.Sprintf("%s %v", sql, args))
+		return clienttest.Result{LastInsertID: uint64(len(statements)), RowsAffected: 1}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	script := "INSERT INTO t VALUES(?);\n  INSERT INTO t VALUES(:n);\nBAD;\nINSERT INTO t VALUES(3);"
+	var results []dqlitedriver.ScriptResult
+	err = conn.Raw(func(c interface{}) error {
+		results, err = c.(*dqlitedriver.Conn).ExecScript(ctx, script, int64(1), sql.Named("n", int64(2)))
+		return err
+	})
+
+	var scriptErr *dqlitedriver.ScriptError
+	require.True(t, errors.As(err, &scriptErr))
+	assert.Equal(t, 2, scriptErr.Index)
+	assert.Equal(t, 53, scriptErr.Offset)
+	assert.Equal(t, "BAD;", scriptErr.SQL)
+	assert.EqualError(t, err, "statement 2 at offset 53: syntax error")
+
+	require.Len(t, results, 2)
+	assert.Equal(t, "INSERT INTO t VALUES(?);", results[0].SQL)
+	assert.Equal(t, 0, results[0].Offset)
+	assert.Equal(t, "INSERT INTO t VALUES(:n);", results[1].SQL)
+	assert.Equal(t, 27, results[1].Offset)
+	for i, result := range results {
+		id, err := result.Result.LastInsertId()
+		require.NoError(t, err)
+		assert.Equal(t, int64(i+1), id)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"INSERT INTO t VALUES(?); [1]", "INSERT INTO t VALUES(:n); [2]"}, statements)
+}
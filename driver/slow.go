@@ -0,0 +1,45 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// SlowQueryFunc is invoked with the statements that took longer than the
+// threshold set with WithSlowQueryHook, along with the address of the node
+// that ran them and the error they failed with, if any. Database errors are
+// reported as Error values.
+type SlowQueryFunc func(sql string, duration time.Duration, address string, err error)
+
+// WithSlowQueryHook sets a function to invoke for each statement or query
+// taking longer than the given threshold, including the time spent retrying
+// it because the database was busy. For queries, only the time until the first
+// rows are received is measured.
+func WithSlowQueryHook(threshold time.Duration, hook SlowQueryFunc) Option {
+	return func(options *options) {
+		options.SlowQueryThreshold = threshold
+		options.SlowQueryHook = hook
+	}
+}
+
+// Run a request of the given kind for the given SQL text, retrying it if the
+// database is busy, recording its timing and reporting it if it's slow.
+func (c *Conn) call(ctx context.Context, request, sql string, f func() error) error {
+	done := c.instrument(ctx, request, sql)
+
+	start := time.Now()
+	err := c.retryBusy(ctx, f)
+	done(err)
+	c.timing = Timing{Duration: time.Since(start), Network: c.protocol.NetworkLatency()}
+	if c.timingHook != nil {
+		c.timingHook(sql, c.timing, c.address, reportedError(err))
+	}
+	if c.slowQueryHook == nil {
+		return err
+	}
+	if c.timing.Duration >= c.slowQueryTime {
+		c.slowQueryHook(sql, c.timing.Duration, c.address, reportedError(err))
+	}
+
+	return err
+}
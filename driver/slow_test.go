@@ -0,0 +1,60 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The slow query hook is invoked for statements exceeding the threshold.
+func TestSlowQueryHook(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		if sql == "SLOW" {
+			time.Sleep(50 * time.Millisecond)
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "failed"}
+		}
+		return clienttest.Result{}, nil
+	})
+
+	type slowQuery struct {
+		sql     string
+		address string
+		err     string
+	}
+	var mu sync.Mutex
+	slow := []slowQuery{}
+	hook := func(sql string, duration time.Duration, address string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.True(t, duration >= 50*time.Millisecond)
+		slow = append(slow, slowQuery{sql: sql, address: address, err: err.Error()})
+	}
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db",
+		dqlitedriver.WithSlowQueryHook(40*time.Millisecond, hook))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "FAST")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "SLOW")
+	require.Error(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []slowQuery{{sql: "SLOW", address: server.Address(), err: "failed"}}, slow)
+}
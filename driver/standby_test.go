@@ -0,0 +1,142 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// After a leader election, the standby connection to the new leader is used
+// instead of dialing it.
+func TestWithStandbyConnections(t *testing.T) {
+	servers := make([]*clienttest.Server, 3)
+	nodes := make([]client.NodeInfo, 3)
+	for i := range servers {
+		servers[i] = clienttest.NewServer(uint64(i + 1))
+		defer servers[i].Close()
+		servers[i].HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+			return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+		})
+		nodes[i] = client.NodeInfo{ID: uint64(i + 1), Address: servers[i].Address(), Role: client.Voter}
+	}
+	for _, server := range servers {
+		server.SetLeader(&nodes[0])
+		server.SetCluster(nodes)
+	}
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), nodes))
+
+	var mu sync.Mutex
+	dials := map[string]int{}
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		mu.Lock()
+		dials[address]++
+		mu.Unlock()
+		return client.DefaultDialFunc(ctx, address)
+	}
+	count := func(address string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return dials[address]
+	}
+
+	drv, err := dqlitedriver.New(store,
+		dqlitedriver.WithDialFunc(dial),
+		dqlitedriver.WithStandbyConnections(2),
+		dqlitedriver.WithConnectionBackoffFactor(10*time.Millisecond))
+	require.NoError(t, err)
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+
+	ctx := context.Background()
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+
+	// The first connection probes all nodes, then the standby connections
+	// are established.
+	require.Eventually(t, func() bool {
+		return count(nodes[1].Address) == 2 && count(nodes[2].Address) == 2
+	}, time.Second, time.Millisecond)
+
+	// Node 2 becomes the leader, and node 1 goes away.
+	servers[0].Close()
+	for _, server := range servers[1:] {
+		server.SetLeader(&nodes[1])
+	}
+
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	assert.Equal(t, 2, count(nodes[1].Address))
+}
+
+// Standby connections older than the max lifetime are replaced.
+func TestWithStandbyConnections_MaxLifetime(t *testing.T) {
+	servers := make([]*clienttest.Server, 3)
+	nodes := make([]client.NodeInfo, 3)
+	for i := range servers {
+		servers[i] = clienttest.NewServer(uint64(i + 1))
+		defer servers[i].Close()
+		servers[i].HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+			return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+		})
+		nodes[i] = client.NodeInfo{ID: uint64(i + 1), Address: servers[i].Address(), Role: client.Voter}
+	}
+	for _, server := range servers {
+		server.SetLeader(&nodes[0])
+	}
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), nodes))
+
+	var mu sync.Mutex
+	dials := map[string]int{}
+	dial := func(ctx context.Context, address string) (net.Conn, error) {
+		mu.Lock()
+		dials[address]++
+		mu.Unlock()
+		return client.DefaultDialFunc(ctx, address)
+	}
+	count := func(address string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return dials[address]
+	}
+
+	drv, err := dqlitedriver.New(store,
+		dqlitedriver.WithDialFunc(dial),
+		dqlitedriver.WithStandbyConnections(2),
+		dqlitedriver.WithMaxLifetime(50*time.Millisecond))
+	require.NoError(t, err)
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+
+	ctx := context.Background()
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	require.Eventually(t, func() bool {
+		return count(nodes[1].Address) == 2 && count(nodes[2].Address) == 2
+	}, time.Second, time.Millisecond)
+
+	// The next connection replaces the expired standby connections.
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT n").Scan(&n))
+	require.Eventually(t, func() bool {
+		return count(nodes[1].Address) == 3 && count(nodes[2].Address) == 3
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"sync/atomic"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// Stats holds counters of the activity of a driver, since it was created.
+type Stats struct {
+	LeaderChanges        uint64 // Times a different leader was connected to.
+	FailoverRetries      uint64 // Operations left to database/sql to retry on a new connection.
+	BusyErrors           uint64 // Statement attempts failed because the database was busy.
+	BusyRetries          uint64 // Statement attempts made again after a busy error.
+	RoundTrips           uint64 // Requests sent to nodes and awaited for a response.
+	BytesSent            uint64 // Bytes written to the network connections.
+	BytesReceived        uint64 // Bytes read from the network connections.
+	StatementCacheHits   uint64 // Statements found in the prepared statements cache.
+	StatementCacheMisses uint64 // Statements prepared because they were not cached.
+	QueryCacheHits       uint64 // Queries answered from the query cache, see WithQueryCache.
+	QueryCacheMisses     uint64 // Cacheable queries sent to the leader because they were not cached.
+	InFlightRequests     uint64 // Requests currently awaiting a response.
+	OpenConnections      uint64 // Connections currently open.
+	BufferedBytes        uint64 // Bytes currently held by response buffers, see WithBufferBudget.
+}
+
+// Counters shared by a driver and its connections, accessed atomically.
+type stats struct {
+	protocol         protocol.Stats // First field, to be 64-bit aligned.
+	failoverRetries  uint64
+	busyErrors       uint64
+	busyRetries      uint64
+	cacheHits        uint64
+	cacheMisses      uint64
+	queryCacheHits   uint64
+	queryCacheMisses uint64
+	openConns        uint64
+}
+
+// Stats returns the current values of the driver counters.
+func (d *Driver) Stats() Stats {
+	s := d.stats
+	return Stats{
+		LeaderChanges:        atomic.LoadUint64(&s.protocol.LeaderChanges),
+		FailoverRetries:      atomic.LoadUint64(&s.failoverRetries),
+		BusyErrors:           atomic.LoadUint64(&s.busyErrors),
+		BusyRetries:          atomic.LoadUint64(&s.busyRetries),
+		RoundTrips:           atomic.LoadUint64(&s.protocol.RoundTrips),
+		BytesSent:            atomic.LoadUint64(&s.protocol.BytesSent),
+		BytesReceived:        atomic.LoadUint64(&s.protocol.BytesReceived),
+		StatementCacheHits:   atomic.LoadUint64(&s.cacheHits),
+		StatementCacheMisses: atomic.LoadUint64(&s.cacheMisses),
+		QueryCacheHits:       atomic.LoadUint64(&s.queryCacheHits),
+		QueryCacheMisses:     atomic.LoadUint64(&s.queryCacheMisses),
+		InFlightRequests:     atomic.LoadUint64(&s.protocol.InFlight),
+		OpenConnections:      atomic.LoadUint64(&s.openConns),
+		BufferedBytes:        uint64(d.budget.Used()),
+	}
+}
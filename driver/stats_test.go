@@ -0,0 +1,67 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The driver counts round trips, busy errors and statement cache lookups.
+func TestDriver_Stats(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	busy := 1
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if busy > 0 {
+			busy--
+			return clienttest.Result{}, clienttest.Error{Code: dqlitedriver.ErrBusy, Message: "database is locked"}
+		}
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	store := newStore(t, server.Address())
+	drv, err := dqlitedriver.New(
+		store,
+		dqlitedriver.WithStatementCacheSize(4),
+		dqlitedriver.WithBusyRetry(time.Second))
+	require.NoError(t, err)
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		stmt, err := db.PrepareContext(ctx, "INSERT INTO t VALUES(?)")
+		require.NoError(t, err)
+		_, err = stmt.ExecContext(ctx, int64(i))
+		require.NoError(t, err)
+		require.NoError(t, stmt.Close())
+	}
+
+	stats := drv.Stats()
+	assert.Equal(t, uint64(1), stats.StatementCacheMisses)
+	assert.Equal(t, uint64(2), stats.StatementCacheHits)
+	assert.Equal(t, uint64(1), stats.BusyErrors)
+	assert.Equal(t, uint64(1), stats.BusyRetries)
+	assert.Equal(t, uint64(0), stats.FailoverRetries)
+	assert.Equal(t, uint64(0), stats.LeaderChanges)
+
+	// Leader, client registration, open, prepare and four executions.
+	assert.Equal(t, uint64(8), stats.RoundTrips)
+	assert.True(t, stats.BytesSent > 0)
+	assert.True(t, stats.BytesReceived > 0)
+}
@@ -0,0 +1,31 @@
+package driver
+
+import (
+	"context"
+	"strings"
+)
+
+type tagKey struct{}
+
+// ContextWithTag returns a context making the statements and queries run with
+// it carry the given tag, for example the name of the application endpoint
+// issuing them.
+//
+// The tag is prepended to the SQL text as a comment, like "/* tag */ SELECT
+// 1", so it shows up in the driver logs, in the slow query hook and on the
+// server. Statements prepared with a tagged context carry the tag too, while
+// the context used to execute a prepared statement can't change its text.
+func ContextWithTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, tagKey{}, tag)
+}
+
+// Prepend the tag of the given context to the SQL text, if any.
+func tagSQL(ctx context.Context, sql string) string {
+	tag, ok := ctx.Value(tagKey{}).(string)
+	if !ok || tag == "" {
+		return sql
+	}
+	// Make sure the tag can't end the comment.
+	tag = strings.Replace(tag, "*/", "* /", -1)
+	return "/* " + tag + " */ " + sql
+}
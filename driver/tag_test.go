@@ -0,0 +1,56 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The tag of the context is prepended to the SQL text as a comment.
+func TestContextWithTag(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	received := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, sql)
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, sql)
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := dqlitedriver.ContextWithTag(context.Background(), "GET /users */ DROP")
+
+	_, err = db.ExecContext(ctx, "DELETE FROM t WHERE a = :a", sql.Named("a", int64(1)))
+	require.NoError(t, err)
+
+	var n int64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT 2").Scan(&n))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"/* GET /users * / DROP */ DELETE FROM t WHERE a = :a",
+		"/* GET /users * / DROP */ SELECT 1",
+		"SELECT 2",
+	}, received)
+}
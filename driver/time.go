@@ -0,0 +1,33 @@
+package driver
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// TimeFormat tells how time.Time arguments are stored in the database.
+type TimeFormat int
+
+// Time formats.
+const (
+	// TimeISO8601 stores times as text, like "2006-01-02 15:04:05-07:00",
+	// keeping their time zone.
+	TimeISO8601 TimeFormat = iota
+
+	// TimeUnix stores times as integers, counting the seconds elapsed
+	// since January 1, 1970 UTC. Fractions of a second are dropped.
+	TimeUnix
+)
+
+// Convert a statement argument to a driver value, as per the registered
+// codecs and the time format of the connection.
+func (c *Conn) convertArg(arg interface{}) (driver.Value, error) {
+	value, err := convertValue(arg)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := value.(time.Time); ok && c.timeFormat == TimeUnix {
+		value = t.Unix()
+	}
+	return value, nil
+}
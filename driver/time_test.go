@@ -0,0 +1,70 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Times are stored as Unix timestamps with TimeUnix.
+func TestTimeFormat_Unix(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var bound []driver.Value
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bound = args
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?time_format=unix")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(), "INSERT INTO t VALUES(?)", time.Unix(1600000000, 500).UTC())
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []driver.Value{int64(1600000000)}, bound)
+}
+
+// Text timestamps without a time zone are interpreted in the configured
+// location.
+func TestTimeLocation(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		// The test server sends time values as ISO8601 text with a zone.
+		return &clienttest.Rows{
+			Columns: []string{"t"},
+			Values:  [][]driver.Value{{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		}, nil
+	})
+
+	location := time.FixedZone("UTC+2", 2*60*60)
+	store := newStore(t, server.Address())
+	drv, err := dqlitedriver.New(store, dqlitedriver.WithTimeLocation(location))
+	require.NoError(t, err)
+	connector, err := drv.OpenConnector("test.db")
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	var value time.Time
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT t FROM t").Scan(&value))
+	assert.True(t, value.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	assert.Equal(t, location, value.Location())
+}
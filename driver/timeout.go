@@ -0,0 +1,32 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// WithStatementTimeout sets a default timeout for executing statements and
+// queries whose context has no deadline. For queries, the timeout covers
+// receiving all of their rows, until the rows are closed.
+//
+// Unlike WithContextTimeout, the timeout applies to all operations performed
+// through database/sql, not only to DB.Begin(). It also applies to each batch
+// executed with Conn.ExecBatch or Conn.ExecMany, as a whole, and to each
+// statement of a script executed with Conn.ExecScript.
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(options *options) {
+		options.StatementTimeout = timeout
+	}
+}
+
+// Return a context for running a statement, applying the default statement
+// timeout if the given context has no deadline.
+func (c *Conn) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.statementTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, c.statementTimeout)
+}
@@ -0,0 +1,92 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Statements whose context has no deadline time out after the default
+// statement timeout.
+func TestWithStatementTimeout(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		if sql == "SELECT slow()" {
+			<-release
+		}
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db",
+		dqlitedriver.WithStatementTimeout(100*time.Millisecond),
+		dqlitedriver.WithFailoverRetry(dqlitedriver.RetryNone))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(), "DELETE FROM t")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = db.ExecContext(context.Background(), "SELECT slow()")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+// Batches are bound by the default statement timeout too.
+func TestWithStatementTimeout_Batch(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		if sql == "SELECT slow()" {
+			<-release
+		}
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db",
+		dqlitedriver.WithStatementTimeout(100*time.Millisecond),
+		dqlitedriver.WithFailoverRetry(dqlitedriver.RetryNone))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, slow := range []func(*dqlitedriver.Conn) error{
+		func(c *dqlitedriver.Conn) error {
+			_, err := c.ExecBatch(ctx, []dqlitedriver.BatchStatement{{SQL: "DELETE FROM t"}, {SQL: "SELECT slow()"}})
+			return err
+		},
+		func(c *dqlitedriver.Conn) error {
+			_, err := c.ExecMany(ctx, "SELECT slow()", [][]interface{}{{}, {}})
+			return err
+		},
+	} {
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+
+		start := time.Now()
+		err = conn.Raw(func(c interface{}) error {
+			return slow(c.(*dqlitedriver.Conn))
+		})
+		assert.Error(t, err)
+		assert.True(t, time.Since(start) < 5*time.Second)
+		conn.Close()
+	}
+}
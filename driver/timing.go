@@ -0,0 +1,67 @@
+package driver
+
+import (
+	"time"
+)
+
+// Timing tells apart the network latency from the execution cost of a
+// statement or query.
+type Timing struct {
+	// Time from sending the statement to receiving its result, or the
+	// first rows for queries, including the time spent retrying it if the
+	// database was busy.
+	Duration time.Duration
+
+	// Network round trip time of the connection, estimated from the
+	// requests that the leader answers without touching the database, or
+	// zero if unknown.
+	Network time.Duration
+}
+
+// Execution returns an estimate of the time the leader spent running the
+// statement, which is the duration minus the network round trip time. dqlite
+// nodes don't report their execution time.
+func (t Timing) Execution() time.Duration {
+	if t.Network >= t.Duration {
+		return 0
+	}
+	return t.Duration - t.Network
+}
+
+// TimingFunc is invoked with the timing of each statement or query, along
+// with the address of the node that ran it and the error it failed with, if
+// any. Database errors are reported as Error values.
+type TimingFunc func(sql string, timing Timing, address string, err error)
+
+// WithTimingHook sets a function to invoke with the timing of each statement
+// or query, to tell whether slow ones are slow because of the network or of
+// the SQL they run.
+//
+// The timing of a single statement can also be read from its result or rows,
+// see Result.Timing and Rows.Timing.
+func WithTimingHook(hook TimingFunc) Option {
+	return func(options *options) {
+		options.TimingHook = hook
+	}
+}
+
+// Timing returns the timing of the statement that returned the result.
+//
+// It can be reached from database/sql through sql.Conn.Raw:
+//
+//	conn.Raw(func(c interface{}) error {
+//		result, err := c.(*driver.Conn).ExecContext(ctx, "UPDATE t SET n = 1", nil)
+//		...
+//		timing = result.(*driver.Result).Timing()
+//		return nil
+//	})
+func (r *Result) Timing() Timing {
+	return r.timing
+}
+
+// Timing returns the timing of the query that returned the rows, up to the
+// first rows received. For queries made of multiple statements, it's the one
+// of the statement of the current result set.
+func (r *Rows) Timing() Timing {
+	return r.timing
+}
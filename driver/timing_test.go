@@ -0,0 +1,66 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The network latency measured when connecting is told apart from the time
+// spent executing queries.
+func TestWithTimingHook(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	delay := 50 * time.Millisecond
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		time.Sleep(delay)
+		return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{int64(1)}}}, nil
+	})
+
+	var mu sync.Mutex
+	timings := map[string]dqlitedriver.Timing{}
+	hook := func(sql string, timing dqlitedriver.Timing, address string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, server.Address(), address)
+		assert.NoError(t, err)
+		timings[sql] = timing
+	}
+
+	connector, err := dqlitedriver.NewConnector(
+		newStore(t, server.Address()), "test.db", dqlitedriver.WithTimingHook(hook))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var timing dqlitedriver.Timing
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		rows, err := c.(*dqlitedriver.Conn).QueryContext(ctx, "SELECT n", nil)
+		require.NoError(t, err)
+		defer rows.Close()
+		timing = rows.(*dqlitedriver.Rows).Timing()
+		return nil
+	}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, timing, timings["SELECT n"])
+	assert.True(t, timing.Duration >= delay)
+	assert.True(t, timing.Network > 0)
+	assert.True(t, timing.Network < delay)
+	assert.Equal(t, timing.Duration-timing.Network, timing.Execution())
+}
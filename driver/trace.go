@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"context"
+)
+
+// Span describes a request traced with the function set by WithTraceSpans.
+type Span struct {
+	Request  string // Kind of request: "exec", "query", "prepare" or "exec batch".
+	SQL      string // SQL text of the request.
+	Address  string // Address of the leader serving the request.
+	Database string // Name of the database.
+}
+
+// SpanFunc starts a trace span for the given request, as a child of the span
+// in the given context, if any, and returns a function ending it with the
+// outcome of the request. Database errors are reported as Error values.
+//
+// For example, with OpenTelemetry:
+//
+//	func(ctx context.Context, s driver.Span) func(error) {
+//		_, span := tracer.Start(ctx, "dqlite."+s.Request, trace.WithAttributes(
+//			attribute.String("db.statement", s.SQL),
+//			attribute.String("db.name", s.Database),
+//			attribute.String("net.peer.name", s.Address)))
+//		return func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//			}
+//			span.End()
+//		}
+//	}
+type SpanFunc func(ctx context.Context, span Span) func(err error)
+
+// WithTraceSpans sets a function to start a trace span for each request sent
+// to the leader, so dqlite calls show up in distributed traces. The span
+// covers the time spent retrying the request if the database was busy. For
+// queries, it ends when the first rows are received.
+func WithTraceSpans(start SpanFunc) Option {
+	return func(options *options) {
+		options.Spans = start
+	}
+}
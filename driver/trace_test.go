@@ -0,0 +1,70 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+// A span is started for each request, as a child of the span of the context.
+func TestWithTraceSpans(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		if sql == "FAIL" {
+			return clienttest.Result{}, clienttest.Error{Code: 1, Message: "failed"}
+		}
+		return clienttest.Result{RowsAffected: 1}, nil
+	})
+
+	type span struct {
+		parent string
+		span   dqlitedriver.Span
+		err    string
+	}
+	var mu sync.Mutex
+	spans := []span{}
+	start := func(ctx context.Context, s dqlitedriver.Span) func(error) {
+		parent, _ := ctx.Value(spanKey{}).(string)
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ended := span{parent: parent, span: s}
+			if err != nil {
+				ended.err = err.Error()
+			}
+			spans = append(spans, ended)
+		}
+	}
+
+	connector, err := dqlitedriver.NewConnector(newStore(t, server.Address()), "test.db", dqlitedriver.WithTraceSpans(start))
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.WithValue(context.Background(), spanKey{}, "handler")
+	_, err = db.ExecContext(ctx, "DELETE FROM t")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "FAIL")
+	require.Error(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	request := func(sql string) dqlitedriver.Span {
+		return dqlitedriver.Span{Request: "exec", SQL: sql, Address: server.Address(), Database: "test.db"}
+	}
+	assert.Equal(t, []span{
+		{parent: "handler", span: request("DELETE FROM t")},
+		{parent: "handler", span: request("FAIL"), err: "failed"},
+	}, spans)
+}
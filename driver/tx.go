@@ -0,0 +1,125 @@
+package driver
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/canonical/go-dqlite/client"
+)
+
+// TxLock is the lock mode of a transaction, which tells when it acquires the
+// database locks.
+type TxLock int
+
+// Transaction lock modes.
+const (
+	// TxDeferred transactions acquire locks when they first read or write
+	// the database. A transaction that reads before writing can fail with
+	// SQLITE_BUSY when upgrading its lock, if another one wrote meanwhile.
+	TxDeferred TxLock = iota
+
+	// TxImmediate transactions acquire the write lock right away, so they
+	// can't fail because of another transaction once they have started.
+	TxImmediate
+
+	// TxExclusive transactions are like TxImmediate ones, and additionally
+	// prevent other connections from reading when not in WAL mode.
+	TxExclusive
+)
+
+// Return the statement beginning a transaction with this lock mode.
+func (l TxLock) begin() string {
+	switch l {
+	case TxImmediate:
+		return "BEGIN IMMEDIATE"
+	case TxExclusive:
+		return "BEGIN EXCLUSIVE"
+	default:
+		return "BEGIN"
+	}
+}
+
+type txLockKey struct{}
+
+// ContextWithTxLock returns a context making the transactions started with it
+// use the given lock mode, unless they are read-only.
+func ContextWithTxLock(ctx context.Context, lock TxLock) context.Context {
+	return context.WithValue(ctx, txLockKey{}, lock)
+}
+
+// Tx is a transaction.
+//
+// Beginning a transaction on a connection which already has one open starts a
+// nested transaction, backed by a SAVEPOINT. Committing a nested transaction
+// releases its savepoint, and rolling it back undoes its changes only. The
+// changes of nested transactions are applied only when the outermost
+// transaction commits.
+//
+// With database/sql, nested transactions can be started by calling BeginTx
+// multiple times on the same sql.Conn.
+type Tx struct {
+	conn      *Conn
+	log       client.LogFunc
+	savepoint string // Name of the savepoint of a nested transaction.
+	depth     int    // Number of open transactions this one is nested in.
+}
+
+// Start a transaction nested in the ones already open.
+func (c *Conn) beginSavepoint(ctx context.Context) (driver.Tx, error) {
+	tx := &Tx{
+		conn:      c,
+		log:       c.log,
+		savepoint: fmt.Sprintf("dqlite_savepoint_%d", c.txDepth),
+		depth:     c.txDepth,
+	}
+
+	if _, err := c.exec(ctx, "SAVEPOINT "+tx.savepoint, nil, false); err != nil {
+		return nil, err
+	}
+	c.txDepth++
+
+	return tx, nil
+}
+
+// Commit the transaction.
+func (tx *Tx) Commit() error {
+	sql := "COMMIT"
+	if tx.savepoint != "" {
+		sql = "RELEASE " + tx.savepoint
+	}
+
+	return tx.end(sql)
+}
+
+// Rollback the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.savepoint != "" {
+		// Rolling back to a savepoint leaves it open.
+		if err := tx.exec("ROLLBACK TO " + tx.savepoint); err != nil {
+			return err
+		}
+		return tx.end("RELEASE " + tx.savepoint)
+	}
+
+	return tx.end("ROLLBACK")
+}
+
+// Execute the statement ending the transaction, closing the transactions
+// nested in it too.
+func (tx *Tx) end(sql string) error {
+	// The statement is still part of the transaction, so it's not retried
+	// if the connection is lost.
+	defer func() { tx.conn.txDepth = tx.depth }()
+	return tx.exec(sql)
+}
+
+func (tx *Tx) exec(sql string) error {
+	ctx := context.Background()
+
+	if _, err := tx.conn.ExecContext(ctx, sql, nil); err != nil {
+		return driverError(tx.log, err)
+	}
+
+	return nil
+}
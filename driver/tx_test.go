@@ -0,0 +1,112 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Transactions are started with the configured lock mode.
+func TestBeginTx_Lock(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	begins := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(sql, "BEGIN") {
+			begins = append(begins, sql)
+		}
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db?tx_lock=immediate")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	begin := func(ctx context.Context, opts *sql.TxOptions) {
+		tx, err := db.BeginTx(ctx, opts)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+
+	begin(ctx, nil)
+	begin(ctx, &sql.TxOptions{ReadOnly: true})
+	begin(dqlitedriver.ContextWithTxLock(ctx, dqlitedriver.TxExclusive), nil)
+	begin(dqlitedriver.ContextWithTxLock(ctx, dqlitedriver.TxDeferred), nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"BEGIN IMMEDIATE", "BEGIN", "BEGIN EXCLUSIVE", "BEGIN"}, begins)
+}
+
+// Transactions started while another one is open use savepoints.
+func TestBeginTx_Nested(t *testing.T) {
+	server := clienttest.NewServer(1)
+	defer server.Close()
+
+	var mu sync.Mutex
+	statements := []string{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, sql)
+		return clienttest.Result{}, nil
+	})
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	outer, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+
+	inner, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	innermost, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, innermost.Commit())
+	require.NoError(t, inner.Rollback())
+
+	inner, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, inner.Commit())
+
+	require.NoError(t, outer.Commit())
+
+	tx, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT dqlite_savepoint_1",
+		"SAVEPOINT dqlite_savepoint_2",
+		"RELEASE dqlite_savepoint_2",
+		"ROLLBACK TO dqlite_savepoint_1",
+		"RELEASE dqlite_savepoint_1",
+		"SAVEPOINT dqlite_savepoint_1",
+		"RELEASE dqlite_savepoint_1",
+		"COMMIT",
+		"BEGIN",
+		"ROLLBACK",
+	}, statements)
+}
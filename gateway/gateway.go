@@ -0,0 +1,341 @@
+// Package gateway exposes dqlite databases over HTTP, with JSON requests and
+// responses, so that programs written in other languages can use a dqlite
+// cluster through a thin Go process.
+//
+// The handler serves the following endpoints:
+//
+//	POST /exec    execute a statement: {"database": "db", "sql": "...", "args": [...]}
+//	POST /query   run a query, with the same request body as /exec
+//	GET  /leader  return the current leader
+//	GET  /cluster return the nodes of the cluster
+//
+// Executing a statement returns {"last_insert_id": N, "rows_affected": N},
+// and running a query returns {"columns": [...], "rows": [[...], ...]}. BLOB
+// values are encoded in base64. Failures return {"error": "...", "code": N},
+// where code is the SQLite extended result code, if any.
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/driver"
+	"github.com/pkg/errors"
+)
+
+// Option can be used to tweak gateway parameters.
+type Option func(*options)
+
+// WithDialFunc sets a custom dial function for connecting to dqlite nodes.
+func WithDialFunc(dial client.DialFunc) Option {
+	return func(options *options) {
+		options.DialFunc = dial
+	}
+}
+
+// WithLogFunc sets a custom log function.
+func WithLogFunc(log client.LogFunc) Option {
+	return func(options *options) {
+		options.LogFunc = log
+	}
+}
+
+// WithMaxBodySize sets the maximum size of request bodies, 1 MiB by default.
+func WithMaxBodySize(size int64) Option {
+	return func(options *options) {
+		options.MaxBodySize = size
+	}
+}
+
+type options struct {
+	DialFunc    client.DialFunc
+	LogFunc     client.LogFunc
+	MaxBodySize int64
+}
+
+func defaultOptions() *options {
+	return &options{
+		DialFunc:    client.DefaultDialFunc,
+		LogFunc:     client.DefaultLogFunc,
+		MaxBodySize: 1 << 20,
+	}
+}
+
+// Handler is an http.Handler serving the databases of a dqlite cluster.
+type Handler struct {
+	store   client.NodeStore
+	options *options
+	mux     *http.ServeMux
+
+	mu  sync.Mutex
+	dbs map[string]*sql.DB // Open databases, by name.
+}
+
+// New returns a handler serving the databases of the cluster whose nodes are
+// in the given store.
+func New(store client.NodeStore, options ...Option) *Handler {
+	o := defaultOptions()
+
+	for _, option := range options {
+		option(o)
+	}
+
+	h := &Handler{
+		store:   store,
+		options: o,
+		mux:     http.NewServeMux(),
+		dbs:     map[string]*sql.DB{},
+	}
+
+	h.mux.HandleFunc("/exec", h.handleExec)
+	h.mux.HandleFunc("/query", h.handleQuery)
+	h.mux.HandleFunc("/leader", h.handleLeader)
+	h.mux.HandleFunc("/cluster", h.handleCluster)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Close the connections to all databases.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	for name, db := range h.dbs {
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(h.dbs, name)
+	}
+	return err
+}
+
+// Statement is the body of exec and query requests.
+type Statement struct {
+	Database string        `json:"database"`
+	SQL      string        `json:"sql"`
+	Args     []interface{} `json:"args,omitempty"`
+}
+
+// Result is the response to an exec request.
+type Result struct {
+	LastInsertID int64 `json:"last_insert_id"`
+	RowsAffected int64 `json:"rows_affected"`
+}
+
+// Rows is the response to a query request.
+type Rows struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Failure is the response to failed requests.
+type Failure struct {
+	Error string `json:"error"`
+	Code  int    `json:"code,omitempty"`
+}
+
+func (h *Handler) handleExec(w http.ResponseWriter, r *http.Request) {
+	statement, db, ok := h.statement(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := db.ExecContext(r.Context(), statement.SQL, statement.Args...)
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := Result{}
+	if response.LastInsertID, err = result.LastInsertId(); err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	if response.RowsAffected, err = result.RowsAffected(); err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.reply(w, response)
+}
+
+func (h *Handler) handleQuery(w http.ResponseWriter, r *http.Request) {
+	statement, db, ok := h.statement(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(), statement.SQL, statement.Args...)
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	response := Rows{Rows: [][]interface{}{}}
+	if response.Columns, err = rows.Columns(); err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for rows.Next() {
+		row := make([]interface{}, len(response.Columns))
+		rowPointers := make([]interface{}, len(row))
+		for i := range row {
+			rowPointers[i] = &row[i]
+		}
+		if err := rows.Scan(rowPointers...); err != nil {
+			h.fail(w, http.StatusInternalServerError, err)
+			return
+		}
+		response.Rows = append(response.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.reply(w, response)
+}
+
+func (h *Handler) handleLeader(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.fail(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	cli, err := h.leader(r.Context())
+	if err != nil {
+		h.fail(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer cli.Close()
+
+	leader, err := cli.Leader(r.Context())
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.reply(w, leader)
+}
+
+func (h *Handler) handleCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.fail(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	cli, err := h.leader(r.Context())
+	if err != nil {
+		h.fail(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer cli.Close()
+
+	nodes, err := cli.Cluster(r.Context())
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.reply(w, nodes)
+}
+
+func (h *Handler) leader(ctx context.Context) (*client.Client, error) {
+	return client.FindLeader(ctx, h.store,
+		client.WithDialFunc(h.options.DialFunc), client.WithLogFunc(h.options.LogFunc))
+}
+
+// Decode the statement in the body of the given request, and return it along
+// with its database. If that fails, the failure is sent back.
+func (h *Handler) statement(w http.ResponseWriter, r *http.Request) (*Statement, *sql.DB, bool) {
+	if r.Method != http.MethodPost {
+		h.fail(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return nil, nil, false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.options.MaxBodySize))
+	decoder.UseNumber()
+
+	statement := &Statement{}
+	if err := decoder.Decode(statement); err != nil {
+		h.fail(w, http.StatusBadRequest, errors.Wrap(err, "decode request"))
+		return nil, nil, false
+	}
+	if statement.Database == "" {
+		h.fail(w, http.StatusBadRequest, errors.New("no database given"))
+		return nil, nil, false
+	}
+
+	for i, arg := range statement.Args {
+		number, ok := arg.(json.Number)
+		if !ok {
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			statement.Args[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			statement.Args[i] = f
+		}
+	}
+
+	db, err := h.db(statement.Database)
+	if err != nil {
+		h.fail(w, http.StatusInternalServerError, err)
+		return nil, nil, false
+	}
+
+	return statement, db, true
+}
+
+// Return the database with the given name, opening it if needed.
+func (h *Handler) db(name string) (*sql.DB, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if db, ok := h.dbs[name]; ok {
+		return db, nil
+	}
+
+	connector, err := driver.NewConnector(h.store, name,
+		driver.WithDialFunc(h.options.DialFunc), driver.WithLogFunc(h.options.LogFunc))
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	h.dbs[name] = db
+
+	return db, nil
+}
+
+func (h *Handler) reply(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.options.LogFunc(client.LogWarn, "send response: %v", err)
+	}
+}
+
+func (h *Handler) fail(w http.ResponseWriter, status int, err error) {
+	failure := Failure{Error: err.Error()}
+	var e driver.Error
+	if errors.As(err, &e) {
+		failure.Code = e.Code
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(failure); err != nil {
+		h.options.LogFunc(client.LogWarn, "send response: %v", err)
+	}
+}
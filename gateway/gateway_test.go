@@ -0,0 +1,138 @@
+package gateway_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client"
+	"github.com/canonical/go-dqlite/client/clienttest"
+	"github.com/canonical/go-dqlite/gateway"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_Exec(t *testing.T) {
+	server, handler := newHandler(t)
+
+	var mu sync.Mutex
+	calls := [][]driver.Value{}
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, append([]driver.Value{database, sql}, args...))
+		return clienttest.Result{LastInsertID: 3, RowsAffected: 1}, nil
+	})
+
+	body := `{"database": "test.db", "sql": "INSERT INTO t VALUES(?, ?, ?)", "args": [1, 2.5, "x"]}`
+	response := do(t, handler, "POST", "/exec", body)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	result := gateway.Result{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, gateway.Result{LastInsertID: 3, RowsAffected: 1}, result)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]driver.Value{{"test.db", "INSERT INTO t VALUES(?, ?, ?)", int64(1), 2.5, "x"}}, calls)
+}
+
+func TestHandler_Query(t *testing.T) {
+	server, handler := newHandler(t)
+
+	server.HandleQuery(func(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+		return &clienttest.Rows{
+			Columns: []string{"n", "s"},
+			Values:  [][]driver.Value{{int64(1), "a"}, {nil, "b"}},
+		}, nil
+	})
+
+	response := do(t, handler, "POST", "/query", `{"database": "test.db", "sql": "SELECT n, s FROM t"}`)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"columns": ["n", "s"], "rows": [[1, "a"], [null, "b"]]}`, response.Body.String())
+}
+
+func TestHandler_Error(t *testing.T) {
+	server, handler := newHandler(t)
+
+	server.HandleExec(func(database, sql string, args []driver.Value) (clienttest.Result, error) {
+		return clienttest.Result{}, clienttest.Error{Code: 19 | 8<<8, Message: "UNIQUE constraint failed"}
+	})
+
+	response := do(t, handler, "POST", "/exec", `{"database": "test.db", "sql": "INSERT INTO t VALUES(1)"}`)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+
+	failure := gateway.Failure{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &failure))
+	assert.Equal(t, 19|8<<8, failure.Code)
+	assert.Contains(t, failure.Error, "UNIQUE constraint failed")
+}
+
+func TestHandler_BadRequest(t *testing.T) {
+	_, handler := newHandler(t)
+
+	cases := map[string]struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		"bad json":    {"POST", "/exec", "{", http.StatusBadRequest},
+		"no database": {"POST", "/query", `{"sql": "SELECT 1"}`, http.StatusBadRequest},
+		"bad method":  {"GET", "/exec", "", http.StatusMethodNotAllowed},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			response := do(t, handler, c.method, c.path, c.body)
+			assert.Equal(t, c.status, response.Code)
+		})
+	}
+}
+
+func TestHandler_Cluster(t *testing.T) {
+	server, handler := newHandler(t)
+
+	response := do(t, handler, "GET", "/leader", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	leader := client.NodeInfo{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &leader))
+	assert.Equal(t, server.Address(), leader.Address)
+
+	response = do(t, handler, "GET", "/cluster", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	nodes := []client.NodeInfo{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &nodes))
+	require.Len(t, nodes, 1)
+	assert.Equal(t, server.Address(), nodes[0].Address)
+}
+
+func newHandler(t *testing.T) (*clienttest.Server, *gateway.Handler) {
+	t.Helper()
+
+	server := clienttest.NewServer(1)
+	t.Cleanup(func() { server.Close() })
+
+	store := client.NewInmemNodeStore()
+	require.NoError(t, store.Set(context.Background(), []client.NodeInfo{{ID: 1, Address: server.Address()}}))
+
+	handler := gateway.New(store)
+	t.Cleanup(func() { handler.Close() })
+
+	return server, handler
+}
+
+func do(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	return response
+}
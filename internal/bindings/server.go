@@ -162,6 +162,30 @@ func (s *Node) SetFailureDomain(code uint64) error {
 	return nil
 }
 
+// SnapshotParams exposes bindings for raft snapshot configuration.
+type SnapshotParams struct {
+	Threshold uint64
+	Trailing  uint64
+}
+
+func (s *Node) SetSnapshotParams(params SnapshotParams) error {
+	server := (*C.dqlite_node)(unsafe.Pointer(s))
+	cthreshold := C.unsigned(params.Threshold)
+	ctrailing := C.unsigned(params.Trailing)
+	if rc := C.dqlite_node_set_snapshot_params(server, cthreshold, ctrailing); rc != 0 {
+		return fmt.Errorf("set snapshot params: %d", rc)
+	}
+	return nil
+}
+
+func (s *Node) EnableDiskMode() error {
+	server := (*C.dqlite_node)(unsafe.Pointer(s))
+	if rc := C.dqlite_node_enable_disk_mode(server); rc != 0 {
+		return fmt.Errorf("enable disk mode: %d", rc)
+	}
+	return nil
+}
+
 func (s *Node) GetBindAddress() string {
 	server := (*C.dqlite_node)(unsafe.Pointer(s))
 	return C.GoString(C.dqlite_node_get_bind_address(server))
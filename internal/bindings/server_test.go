@@ -89,6 +89,32 @@ func TestNode_Start_Inet(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestNode_DiskMode(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	server, err := bindings.NewNode(1, "1", dir)
+	require.NoError(t, err)
+	defer server.Close()
+
+	require.NoError(t, server.SetBindAddress("@"))
+	require.NoError(t, server.EnableDiskMode())
+	require.NoError(t, server.Start())
+	require.NoError(t, server.Stop())
+}
+
+func TestNode_SnapshotParams(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	server, err := bindings.NewNode(1, "1", dir)
+	require.NoError(t, err)
+	defer server.Close()
+
+	params := bindings.SnapshotParams{Threshold: 1024, Trailing: 512}
+	require.NoError(t, server.SetSnapshotParams(params))
+}
+
 func TestNode_Leader(t *testing.T) {
 	_, cleanup := newNode(t)
 	defer cleanup()
@@ -0,0 +1,13 @@
+// Package clientbridge lets the driver package build clients on top of its
+// own connections, without exporting such a constructor from the client
+// package, whose API can't refer to internal types.
+package clientbridge
+
+import (
+	"github.com/canonical/go-dqlite/internal/protocol"
+)
+
+// NewClient returns a *client.Client sending requests through the given
+// protocol object, which stays owned by the caller and is not closed by the
+// client. It's set by the client package when it's initialized.
+var NewClient func(protocol *protocol.Protocol) interface{}
@@ -0,0 +1,89 @@
+package protocol
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of latencies remembered for each kind of request, and number of
+// them needed before the timeout adapts.
+const (
+	adaptiveWindow  = 128
+	adaptiveSamples = 16
+)
+
+// Factor applied to the 99th percentile of the recent latencies.
+const adaptiveFactor = 4
+
+// AdaptiveTimeout computes the timeout of calls whose context has no deadline
+// from the latency of the recent calls made by a set of connections, so that
+// it grows when the cluster is temporarily slow instead of making requests
+// fail in cascade.
+//
+// The timeout of a request is a multiple of the 99th percentile of the
+// latencies of the latest requests of the same kind, bounded by a minimum and
+// a maximum. Until enough requests were observed, the maximum is used. Bulk
+// requests, like Dump, are not affected.
+type AdaptiveTimeout struct {
+	mu      sync.Mutex
+	min     time.Duration
+	max     time.Duration
+	windows [kinds]latencyWindow
+}
+
+// Latest latencies of a kind of requests, in a circular buffer.
+type latencyWindow struct {
+	samples [adaptiveWindow]time.Duration
+	next    int
+	count   int
+}
+
+// NewAdaptiveTimeout returns an adaptive timeout bounded by the given values.
+func NewAdaptiveTimeout(min, max time.Duration) *AdaptiveTimeout {
+	return &AdaptiveTimeout{min: min, max: max}
+}
+
+// SetAdaptiveTimeout sets the adaptive timeout applied to calls whose context
+// has no deadline and fed with their latencies, nil to disable it. It takes
+// precedence over the call timeouts, except for bulk requests.
+func (p *Protocol) SetAdaptiveTimeout(adaptive *AdaptiveTimeout) {
+	p.adaptive = adaptive
+}
+
+// Record the latency of a call with a request of the given type.
+func (a *AdaptiveTimeout) observe(mtype uint8, latency time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w := &a.windows[callKind(mtype)]
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % adaptiveWindow
+	if w.count < adaptiveWindow {
+		w.count++
+	}
+}
+
+// Return the timeout of a call with a request of the given type.
+func (a *AdaptiveTimeout) timeout(mtype uint8) time.Duration {
+	a.mu.Lock()
+	w := &a.windows[callKind(mtype)]
+	if w.count < adaptiveSamples {
+		a.mu.Unlock()
+		return a.max
+	}
+	samples := make([]time.Duration, w.count)
+	copy(samples, w.samples[:w.count])
+	a.mu.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	timeout := adaptiveFactor * samples[(len(samples)*99-1)/100]
+
+	if timeout < a.min {
+		return a.min
+	}
+	if timeout > a.max {
+		return a.max
+	}
+	return timeout
+}
@@ -0,0 +1,91 @@
+package protocol
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AuthFunc is invoked right after the protocol version has been sent during
+// the handshake, and can exchange arbitrary data over the connection in order
+// to authenticate against a proxy fronting the dqlite node.
+//
+// The deadline of the given context is set on the connection before the
+// function is called.
+type AuthFunc func(ctx context.Context, conn net.Conn) error
+
+// ErrAuthentication is returned when authentication fails during the
+// handshake.
+type ErrAuthentication struct {
+	Err error
+}
+
+func (e ErrAuthentication) Error() string {
+	return fmt.Sprintf("authentication failed: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ErrAuthentication) Unwrap() error {
+	return e.Err
+}
+
+// Authenticate runs the given authentication function against the underlying
+// connection. It must be called right after the handshake, before any request
+// is sent. Any error is returned as ErrAuthentication.
+func (p *Protocol) Authenticate(ctx context.Context, auth AuthFunc) error {
+	if auth == nil {
+		return nil
+	}
+	conn := p.conn
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if err := auth(ctx, conn); err != nil {
+		if _, ok := err.(ErrAuthentication); ok {
+			return err
+		}
+		return ErrAuthentication{Err: err}
+	}
+
+	return nil
+}
+
+// TokenAuth returns an authentication function sending the given token.
+//
+// The token is sent as a word holding its length, followed by its bytes
+// padded to a word boundary. The peer replies with a single word, which is
+// zero if the token was accepted and an error code otherwise.
+func TokenAuth(token string) AuthFunc {
+	return func(ctx context.Context, conn net.Conn) error {
+		size := len(token)
+		if pad := size % messageWordSize; pad != 0 {
+			size += messageWordSize - pad
+		}
+		buf := make([]byte, messageWordSize+size)
+		binary.LittleEndian.PutUint64(buf, uint64(len(token)))
+		copy(buf[messageWordSize:], token)
+
+		if _, err := conn.Write(buf); err != nil {
+			return errors.Wrap(err, "write token")
+		}
+
+		status := make([]byte, messageWordSize)
+		if _, err := io.ReadFull(conn, status); err != nil {
+			return errors.Wrap(err, "read token status")
+		}
+
+		if code := binary.LittleEndian.Uint64(status); code != 0 {
+			return fmt.Errorf("token rejected with code %d", code)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffFunc returns how long to wait before the given retry attempt,
+// starting from 1.
+type BackoffFunc func(attempt uint) time.Duration
+
+// ExponentialBackoff waits factor times 2^attempt, up to cap.
+func ExponentialBackoff(factor, cap time.Duration) BackoffFunc {
+	return func(attempt uint) time.Duration {
+		duration := factor << attempt
+		// Duration might be negative in case of integer overflow.
+		if duration > cap || duration <= 0 || attempt > 62 {
+			duration = cap
+		}
+		return duration
+	}
+}
+
+// JitteredBackoff waits a random duration between zero and the one of
+// ExponentialBackoff, so clients retrying at the same time spread out.
+func JitteredBackoff(factor, cap time.Duration) BackoffFunc {
+	exponential := ExponentialBackoff(factor, cap)
+	return func(attempt uint) time.Duration {
+		return time.Duration(rand.Int63n(int64(exponential(attempt)) + 1))
+	}
+}
+
+// ConstantBackoff always waits the given duration.
+func ConstantBackoff(duration time.Duration) BackoffFunc {
+	return func(attempt uint) time.Duration {
+		return duration
+	}
+}
@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBudgetTimeout is returned when the deadline of a call expires while
+// waiting for the buffer budget to hold a response. The connection is aborted,
+// since the response can't be consumed.
+var ErrBudgetTimeout = errors.New("timed out waiting for buffer budget")
+
+// Budget bounds the total size of the buffers holding the responses received
+// by a set of connections. Only buffers grown beyond the initial size of their
+// message are accounted for.
+//
+// When receiving a response would exceed the budget, the connection waits for
+// other connections to release their buffers, which applies backpressure on
+// the server, until the deadline of the call expires.
+type Budget struct {
+	mu       sync.Mutex
+	limit    int           // Maximum number of bytes, or 0 for unlimited.
+	used     int           // Bytes currently held.
+	released chan struct{} // Closed when some bytes are released.
+}
+
+// NewBudget returns a budget of the given number of bytes. A zero limit means
+// no limit, with buffers still being accounted for.
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: limit, released: make(chan struct{})}
+}
+
+// Used returns the number of bytes currently held.
+func (b *Budget) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// SetBudget sets the budget that buffers of the responses received by this
+// protocol object are charged to, nil to disable it.
+func (p *Protocol) SetBudget(budget *Budget) {
+	p.budget = budget
+}
+
+// Grow the body buffer of the given response to the given size, charging it
+// to the budget, if any. If that fails, the connection is aborted.
+func (p *Protocol) growResponse(res *Message, size int) error {
+	if p.budget == nil {
+		res.growBody(size, false)
+		return nil
+	}
+
+	if err := p.budget.acquire(size, p.deadline, p.closeCh); err != nil {
+		p.conn.Close()
+		return err
+	}
+	res.growBody(size, false)
+	res.budget = p.budget
+	res.charged = size
+
+	return nil
+}
+
+// Give back the bytes of the body buffer of the given message charged to its
+// budget, if any.
+func (m *Message) uncharge() {
+	if m.charged > 0 {
+		m.budget.release(m.charged)
+		m.charged = 0
+	}
+}
+
+// Wait until the given number of bytes fits in the budget, and charge them.
+func (b *Budget) acquire(n int, deadline time.Time, closeCh <-chan struct{}) error {
+	if b.limit > 0 && n > b.limit {
+		return ErrMessageTooLarge{Size: n, Limit: b.limit}
+	}
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		b.mu.Lock()
+		if b.limit == 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		released := b.released
+		b.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timeout:
+			return ErrBudgetTimeout
+		case <-closeCh:
+			return ErrBudgetTimeout
+		}
+	}
+}
+
+// Give back the given number of bytes, waking up waiting connections.
+func (b *Budget) release(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	if b.limit > 0 {
+		close(b.released)
+		b.released = make(chan struct{})
+	}
+}
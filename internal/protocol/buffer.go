@@ -1,5 +1,9 @@
 package protocol
 
+import (
+	"sync"
+)
+
 // Buffer for reading responses or writing requests.
 type buffer struct {
 	Bytes  []byte
@@ -9,3 +13,44 @@ type buffer struct {
 func (b *buffer) Advance(amount int) {
 	b.Offset += amount
 }
+
+// Size classes of the pooled buffers used when a message body outgrows its
+// initial buffer. Each class holds buffers whose size is a power of two.
+const (
+	bufferPoolMinShift = 12 // 4 KiB
+	bufferPoolMaxShift = 26 // 64 MiB
+)
+
+var bufferPools [bufferPoolMaxShift - bufferPoolMinShift + 1]sync.Pool
+
+// Return the index of the smallest size class able to hold the given amount
+// of bytes, or -1 if the size is too big to be pooled.
+func bufferSizeClass(size int) int {
+	for shift := bufferPoolMinShift; shift <= bufferPoolMaxShift; shift++ {
+		if size <= 1<<shift {
+			return shift - bufferPoolMinShift
+		}
+	}
+	return -1
+}
+
+// Return a buffer of at least the given size, possibly taken from the pool.
+func getBuffer(size int) []byte {
+	class := bufferSizeClass(size)
+	if class == -1 {
+		return make([]byte, size)
+	}
+	if b, ok := bufferPools[class].Get().(*[]byte); ok {
+		return *b
+	}
+	return make([]byte, 1<<(class+bufferPoolMinShift))
+}
+
+// Return the given buffer to the pool, if its size matches a size class.
+func putBuffer(b []byte) {
+	class := bufferSizeClass(len(b))
+	if class == -1 || len(b) != 1<<(class+bufferPoolMinShift) {
+		return
+	}
+	bufferPools[class].Put(&b)
+}
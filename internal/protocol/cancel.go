@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Maximum time to wait for the node to acknowledge the interruption of a
+// query whose context is done.
+const interruptTimeout = 5 * time.Second
+
+// A read deadline making reads fail right away.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Whether requests of the given type run a query, which can be interrupted.
+func isQuery(mtype uint8) bool {
+	return mtype == RequestQuery || mtype == RequestQuerySQL
+}
+
+// Receive the first response of a query.
+//
+// If the context of the query is cancelled before any byte of the response
+// was received, the query is interrupted and the response drained, so the
+// connection stays in sync with the node and can be used again, and the
+// context error is returned. If its deadline expires instead, the node might
+// be unresponsive, so the call fails right away and the connection is marked
+// as dead.
+func (p *Protocol) recvQuery(ctx context.Context, request, response *Message) error {
+	if ctx.Done() == nil {
+		return p.recv(response)
+	}
+
+	received := atomic.LoadUint64(&p.received)
+	stop := p.watchContext(ctx)
+	err := p.recv(response)
+	cancelled := stop()
+
+	if err == nil || !cancelled || ctx.Err() != context.Canceled {
+		return err
+	}
+	if atomic.LoadUint64(&p.received) != received {
+		return err
+	}
+
+	interruptCtx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+	defer cancel()
+	p.deadline = time.Time{}
+	p.setContextDeadline(interruptCtx)
+
+	if err := p.interrupt(request, response); err != nil {
+		return p.broken(errors.Wrap(err, "interrupt query"))
+	}
+
+	return ctx.Err()
+}
+
+// Make reads fail as soon as the given context is done. The returned function
+// stops watching the context and tells whether it was done meanwhile.
+func (p *Protocol) watchContext(ctx context.Context) func() bool {
+	stop := make(chan struct{})
+	done := make(chan bool, 1)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			atomic.StoreInt32(&p.cancelled, 1)
+			p.conn.SetReadDeadline(aLongTimeAgo)
+			done <- true
+		case <-stop:
+			done <- false
+		}
+	}()
+
+	return func() bool {
+		close(stop)
+		cancelled := <-done
+		atomic.StoreInt32(&p.cancelled, 0)
+		return cancelled
+	}
+}
@@ -0,0 +1,328 @@
+package protocol
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Magic bytes at the beginning of a capture file.
+const captureMagic = "DQLCAP01"
+
+// Size of the header of each record in a capture file: timestamp, connection
+// ID, direction and data length.
+const captureRecordHeaderSize = 8 + 4 + 1 + 4
+
+// CaptureDirection tells whether captured data was sent to or received from
+// the server.
+type CaptureDirection uint8
+
+// Capture directions.
+const (
+	CaptureSent     = CaptureDirection(0)
+	CaptureReceived = CaptureDirection(1)
+)
+
+func (d CaptureDirection) String() string {
+	if d == CaptureSent {
+		return ">"
+	}
+	return "<"
+}
+
+// Capture records the raw data exchanged over one or more connections,
+// along with timestamps and directions, so it can be replayed later with
+// ReadCapture.
+//
+// Failing to write to the capture never affects the captured connections:
+// the first error is saved and returned by Err, and recording stops.
+type Capture struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started bool
+	nextID  uint32
+	err     error
+}
+
+// NewCapture returns a new capture writing records to the given writer.
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{w: w}
+}
+
+// Conn wraps the given connection so that all data read from or written to
+// it is recorded.
+func (c *Capture) Conn(conn net.Conn) net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return &captureConn{Conn: conn, capture: c, id: c.nextID}
+}
+
+// Err returns the error hit while writing the capture, if any.
+func (c *Capture) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Capture) record(id uint32, direction CaptureDirection, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+
+	if !c.started {
+		if _, c.err = io.WriteString(c.w, captureMagic); c.err != nil {
+			return
+		}
+		c.started = true
+	}
+
+	header := make([]byte, captureRecordHeaderSize)
+	binary.LittleEndian.PutUint64(header[0:], uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint32(header[8:], id)
+	header[12] = uint8(direction)
+	binary.LittleEndian.PutUint32(header[13:], uint32(len(data)))
+
+	if _, c.err = c.w.Write(header); c.err != nil {
+		return
+	}
+	_, c.err = c.w.Write(data)
+}
+
+type captureConn struct {
+	net.Conn
+	capture *Capture
+	id      uint32
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.capture.record(c.id, CaptureReceived, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.capture.record(c.id, CaptureSent, b[:n])
+	}
+	return n, err
+}
+
+// CaptureFrame is a single protocol frame reassembled from a capture.
+type CaptureFrame struct {
+	Time      time.Time        // Time at which the last byte of the frame was captured.
+	Conn      uint32           // ID of the connection the frame belongs to.
+	Direction CaptureDirection // Whether the frame was sent or received.
+	Handshake bool             // Whether this is the initial protocol version.
+	Version   uint64           // Protocol version, for handshake frames.
+	Type      uint8            // Message type.
+	Flags     uint8            // Message flags.
+	Extra     uint16           // Extra header field.
+	Body      []byte           // Message body.
+}
+
+// Stream of data for a single connection and direction.
+type captureStream struct {
+	buf       []byte
+	handshake bool
+}
+
+// ReadCapture reads a capture written by Capture and reassembles the frames
+// it contains, in the order they were completed.
+func ReadCapture(r io.Reader) ([]CaptureFrame, error) {
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "read magic")
+	}
+	if string(magic) != captureMagic {
+		return nil, fmt.Errorf("not a capture file")
+	}
+
+	type key struct {
+		id        uint32
+		direction CaptureDirection
+	}
+	streams := map[key]*captureStream{}
+	frames := []CaptureFrame{}
+	header := make([]byte, captureRecordHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, "read record header")
+		}
+		timestamp := time.Unix(0, int64(binary.LittleEndian.Uint64(header[0:])))
+		id := binary.LittleEndian.Uint32(header[8:])
+		direction := CaptureDirection(header[12])
+		data := make([]byte, binary.LittleEndian.Uint32(header[13:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, errors.Wrap(err, "read record data")
+		}
+
+		k := key{id: id, direction: direction}
+		stream, ok := streams[k]
+		if !ok {
+			stream = &captureStream{handshake: direction == CaptureSent}
+			streams[k] = stream
+		}
+		stream.buf = append(stream.buf, data...)
+
+		for {
+			frame := CaptureFrame{Time: timestamp, Conn: id, Direction: direction}
+			if stream.handshake {
+				if len(stream.buf) < 8 {
+					break
+				}
+				frame.Handshake = true
+				frame.Version = binary.LittleEndian.Uint64(stream.buf)
+				stream.buf = stream.buf[8:]
+				stream.handshake = false
+				frames = append(frames, frame)
+				continue
+			}
+			if len(stream.buf) < messageHeaderSize {
+				break
+			}
+			n := messageHeaderSize + int(binary.LittleEndian.Uint32(stream.buf))*messageWordSize
+			if len(stream.buf) < n {
+				break
+			}
+			frame.Type = stream.buf[4]
+			frame.Flags = stream.buf[5]
+			frame.Extra = binary.LittleEndian.Uint16(stream.buf[6:])
+			frame.Body = append([]byte{}, stream.buf[messageHeaderSize:n]...)
+			stream.buf = stream.buf[n:]
+			frames = append(frames, frame)
+		}
+	}
+
+	return frames, nil
+}
+
+// String returns a one-line description of the frame. Received frames are
+// fed through the response decoders.
+func (f *CaptureFrame) String() string {
+	prefix := fmt.Sprintf("%s [%d] %s", f.Time.Format(time.RFC3339Nano), f.Conn, f.Direction)
+
+	if f.Handshake {
+		return fmt.Sprintf("%s handshake version=%#x", prefix, f.Version)
+	}
+
+	if f.Direction == CaptureSent {
+		return fmt.Sprintf("%s %s (%d bytes)", prefix, requestDesc(f.Type), len(f.Body))
+	}
+
+	desc, err := f.decode()
+	if err != nil {
+		desc = fmt.Sprintf("%s: %v", responseDesc(f.Type), err)
+	}
+
+	return fmt.Sprintf("%s %s", prefix, desc)
+}
+
+// Decode the frame as a response.
+func (f *CaptureFrame) decode() (string, error) {
+	message := Message{}
+	message.Init(len(f.Body))
+	copy(message.body.Bytes, f.Body)
+	message.mtype = f.Type
+	message.flags = f.Flags
+	message.extra = f.Extra
+	message.words = uint32(len(f.Body) / messageWordSize)
+
+	desc := responseDesc(f.Type)
+
+	switch f.Type {
+	case ResponseFailure:
+		code, text, err := DecodeFailure(&message)
+		return fmt.Sprintf("%s code=%d message=%q", desc, code, text), err
+	case ResponseNode:
+		id, address, err := DecodeNode(&message)
+		return fmt.Sprintf("%s id=%d address=%q", desc, id, address), err
+	case ResponseWelcome:
+		timeout, err := DecodeWelcome(&message)
+		return fmt.Sprintf("%s heartbeat=%d", desc, timeout), err
+	case ResponseNodes:
+		servers, err := DecodeNodes(&message)
+		return fmt.Sprintf("%s %v", desc, servers), err
+	case ResponseDb:
+		id, err := DecodeDb(&message)
+		return fmt.Sprintf("%s id=%d", desc, id), err
+	case ResponseStmt:
+		db, id, params, err := DecodeStmt(&message)
+		return fmt.Sprintf("%s db=%d id=%d params=%d", desc, db, id, params), err
+	case ResponseEmpty:
+		return desc, DecodeEmpty(&message)
+	case ResponseResult:
+		result, err := DecodeResult(&message)
+		return fmt.Sprintf("%s last_insert_id=%d rows_affected=%d", desc, result.LastInsertID, result.RowsAffected), err
+	case ResponseRows:
+		return decodeCaptureRows(&message)
+	case ResponseFiles:
+		files, err := DecodeFiles(&message)
+		if err != nil {
+			return desc, err
+		}
+		names := []string{}
+		for {
+			name, data := files.Next()
+			if name == "" {
+				break
+			}
+			names = append(names, fmt.Sprintf("%s:%d", name, len(data)))
+		}
+		return fmt.Sprintf("%s %s", desc, strings.Join(names, " ")), files.Err()
+	case ResponseMetadata:
+		domain, weight, err := DecodeMetadata(&message)
+		return fmt.Sprintf("%s failure_domain=%d weight=%d", desc, domain, weight), err
+	}
+
+	return fmt.Sprintf("%s type=%d (%d bytes)", desc, f.Type, len(f.Body)), nil
+}
+
+func decodeCaptureRows(message *Message) (string, error) {
+	rows, err := DecodeRows(message)
+	if err != nil {
+		return "rows", err
+	}
+
+	b := bytes.Buffer{}
+	fmt.Fprintf(&b, "rows columns=%v", rows.Columns)
+
+	values := make([]driver.Value, len(rows.Columns))
+	for {
+		err := rows.Next(values)
+		if err == io.EOF {
+			break
+		}
+		if err == ErrRowsPart {
+			b.WriteString(" (partial)")
+			break
+		}
+		if err != nil {
+			return b.String(), err
+		}
+		fmt.Fprintf(&b, " %v", values)
+	}
+
+	return b.String(), nil
+}
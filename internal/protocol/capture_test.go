@@ -0,0 +1,77 @@
+package protocol_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Frames exchanged over a captured connection can be read back and decoded.
+func TestCapture(t *testing.T) {
+	buf := bytes.Buffer{}
+	capture := protocol.NewCapture(&buf)
+
+	client, server := net.Pipe()
+
+	go func() {
+		handshake := make([]byte, 8)
+		io.ReadFull(server, handshake)
+		readMessage(server)
+		body := make([]byte, 16)
+		body[0] = 1
+		body[8] = 2
+		writeMessage(server, protocol.ResponseResult, body)
+	}()
+
+	p, err := protocol.Handshake(context.Background(), capture.Conn(client), protocol.VersionOne)
+	require.NoError(t, err)
+	defer p.Close()
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeExecSQL(&request, 0, "INSERT INTO test VALUES(1)", nil)
+	require.NoError(t, p.Call(context.Background(), &request, &response))
+	require.NoError(t, capture.Err())
+
+	frames, err := protocol.ReadCapture(&buf)
+	require.NoError(t, err)
+	require.Len(t, frames, 3)
+
+	assert.True(t, frames[0].Handshake)
+	assert.Equal(t, protocol.VersionOne, frames[0].Version)
+
+	assert.Equal(t, protocol.CaptureSent, frames[1].Direction)
+	assert.Equal(t, uint8(protocol.RequestExecSQL), frames[1].Type)
+	assert.Contains(t, frames[1].String(), "exec-sql")
+
+	assert.Equal(t, protocol.CaptureReceived, frames[2].Direction)
+	assert.Contains(t, frames[2].String(), "result last_insert_id=1 rows_affected=2")
+}
+
+func TestReadCapture_NotACapture(t *testing.T) {
+	_, err := protocol.ReadCapture(bytes.NewBufferString("garbage!"))
+	assert.EqualError(t, err, "not a capture file")
+}
+
+// Read a full message from the given connection.
+func readMessage(conn net.Conn) ([]byte, []byte) {
+	header := make([]byte, 8)
+	io.ReadFull(conn, header)
+	body := make([]byte, binary.LittleEndian.Uint32(header)*8)
+	io.ReadFull(conn, body)
+	return header, body
+}
+
+// Write a message with the given type and body.
+func writeMessage(conn net.Conn, mtype uint8, body []byte) {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header, uint32(len(body)/8))
+	header[4] = mtype
+	conn.Write(append(header, body...))
+}
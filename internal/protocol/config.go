@@ -6,10 +6,30 @@ import (
 
 // Config holds various configuration parameters for a dqlite client.
 type Config struct {
-	Dial           DialFunc      // Network dialer.
-	DialTimeout    time.Duration // Timeout for establishing a network connection .
-	AttemptTimeout time.Duration // Timeout for each individual attempt to probe a server's leadership.
-	BackoffFactor  time.Duration // Exponential backoff factor for retries.
-	BackoffCap     time.Duration // Maximum connection retry backoff value,
-	RetryLimit     uint          // Maximum number of retries, or 0 for unlimited.
+	Dial             DialFunc                // Network dialer.
+	DialTimeout      time.Duration           // Timeout for establishing a network connection .
+	HandshakeTimeout time.Duration           // Timeout for the handshake and the authentication, or 0 for none.
+	AttemptTimeout   time.Duration           // Timeout for each individual attempt to probe a server's leadership.
+	BackoffFactor    time.Duration           // Exponential backoff factor for retries.
+	BackoffCap       time.Duration           // Maximum connection retry backoff value,
+	Backoff          BackoffFunc             // Backoff between retries, overriding BackoffFactor and BackoffCap, if set.
+	RetryLimit       uint                    // Maximum number of retries, or 0 for unlimited.
+	ReadTimeout      time.Duration           // Timeout for each individual read from a connection, or 0 for none.
+	WriteTimeout     time.Duration           // Timeout for writing a request to a connection, or 0 for none.
+	Limits           Limits                  // Limits of the decoded responses.
+	StrictDecoding   bool                    // Reject responses with unexpected trailing data.
+	Auth             AuthFunc                // Authentication to perform right after the handshake, if any.
+	LeaderChange     func(old, new NodeInfo) // Invoked when a connection is made to a different leader.
+	UpdateStore      bool                    // Save the cluster configuration fetched from the leader in the store.
+	Stats            *Stats                  // Counters to update, if any.
+	Budget           *Budget                 // Budget of response buffers, if any.
+	Interceptor      Interceptor             // Wraps each call made on the connections to the leader, if set.
+	Standby          int                     // Number of idle connections to keep to voters other than the leader.
+	CallTimeouts     CallTimeouts            // Timeouts of calls without deadline.
+	Translate        AddressTranslator       // Maps node addresses learned from the cluster, if set.
+	CallStats        CallStatsFunc           // Invoked after each call made on the connections, if set.
+	AdaptiveTimeout  *AdaptiveTimeout        // Timeout of calls without deadline, adapting to their latency, if set.
+	KeepAlive        time.Duration           // Idle time after which connections are pinged, or 0 for never.
+	MaxLifetime      time.Duration           // Age after which standby connections are replaced, or 0 for never.
+	MaxIdleTime      time.Duration           // Idle time after which standby connections are replaced, or 0 for never.
 }
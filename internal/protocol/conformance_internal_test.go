@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/canonical/go-dqlite/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Our encoders produce the conformance request vectors byte for byte.
+func TestConformance_Requests(t *testing.T) {
+	params := NamedValues{{Ordinal: 1, Value: int64(1)}}
+	encoders := map[string]func(*Message){
+		"leader":   func(m *Message) { EncodeLeader(m) },
+		"client":   func(m *Message) { EncodeClient(m, 1) },
+		"open":     func(m *Message) { EncodeOpen(m, "conformance.db", 0, "volatile") },
+		"prepare":  func(m *Message) { EncodePrepare(m, 0, "SELECT ?") },
+		"exec":     func(m *Message) { EncodeExec(m, 0, 1, params) },
+		"query":    func(m *Message) { EncodeQuery(m, 0, 1, params) },
+		"finalize": func(m *Message) { EncodeFinalize(m, 0, 1) },
+		"exec_sql": func(m *Message) {
+			EncodeExecSQL(m, 0, "CREATE TABLE IF NOT EXISTS conformance (n INT, f REAL, t TEXT, b BLOB, z)", nil)
+		},
+		"exec_sql_params": func(m *Message) {
+			EncodeExecSQL(m, 0, "INSERT INTO conformance VALUES(?, ?, ?, ?, ?)", NamedValues{
+				{Ordinal: 1, Value: int64(-1)},
+				{Ordinal: 2, Value: 1.5},
+				{Ordinal: 3, Value: "text"},
+				{Ordinal: 4, Value: []byte{1, 2, 3}},
+				{Ordinal: 5, Value: nil},
+			})
+		},
+		"query_sql": func(m *Message) { EncodeQuerySQL(m, 0, "SELECT n, f, t, b, z FROM conformance", nil) },
+		"interrupt": func(m *Message) { EncodeInterrupt(m, 0) },
+		"add":       func(m *Message) { EncodeAdd(m, 2, "127.0.0.1:9002") },
+		"assign":    func(m *Message) { EncodeAssign(m, 2, 1) },
+		"remove":    func(m *Message) { EncodeRemove(m, 2) },
+		"dump":      func(m *Message) { EncodeDump(m, "conformance.db") },
+		"cluster":   func(m *Message) { EncodeCluster(m, 1) },
+		"transfer":  func(m *Message) { EncodeTransfer(m, 2) },
+		"describe":  func(m *Message) { EncodeDescribe(m, 0) },
+		"weight":    func(m *Message) { EncodeWeight(m, 5) },
+	}
+
+	require.Len(t, encoders, len(conformance.Requests))
+
+	// Encode all requests in the same message, so leftovers of a previous
+	// request would show up.
+	message := Message{}
+	message.Init(4096)
+	for _, vector := range conformance.Requests {
+		encode, ok := encoders[vector.Name]
+		require.True(t, ok, vector.Name)
+		encode(&message)
+
+		mtype, schema := message.getHeader()
+		assert.Equal(t, vector.Type, mtype, vector.Name)
+		assert.Equal(t, vector.Schema, schema, vector.Name)
+		assert.Equal(t, vector.Body, message.body.Bytes[:message.body.Offset], vector.Name)
+	}
+}
+
+// Our decoders accept the conformance response vectors.
+func TestConformance_Responses(t *testing.T) {
+	decoders := map[string]func(*testing.T, *Message){
+		"failure": func(t *testing.T, m *Message) {
+			// Failures are returned as errors.
+			_, _, err := DecodeFailure(m)
+			var e ErrRequest
+			require.True(t, errors.As(err, &e), err)
+			assert.Equal(t, uint64(1), e.Code)
+			assert.Equal(t, "error", e.Description)
+		},
+		"node": func(t *testing.T, m *Message) {
+			id, address, err := DecodeNode(m)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(1), id)
+			assert.Equal(t, "127.0.0.1:9001", address)
+		},
+		"nodes": func(t *testing.T, m *Message) {
+			nodes, err := DecodeNodes(m)
+			require.NoError(t, err)
+			assert.Equal(t, Nodes{{ID: 1, Address: "127.0.0.1:9001", Role: Voter}}, nodes)
+		},
+		"db": func(t *testing.T, m *Message) {
+			id, err := DecodeDb(m)
+			require.NoError(t, err)
+			assert.Equal(t, uint32(0), id)
+		},
+		"stmt": func(t *testing.T, m *Message) {
+			db, id, params, err := DecodeStmt(m)
+			require.NoError(t, err)
+			assert.Equal(t, []uint64{0, 1, 1}, []uint64{uint64(db), uint64(id), params})
+		},
+		"result": func(t *testing.T, m *Message) {
+			result, err := DecodeResult(m)
+			require.NoError(t, err)
+			assert.Equal(t, Result{LastInsertID: 1, RowsAffected: 1}, result)
+		},
+		"rows": func(t *testing.T, m *Message) {
+			rows, err := DecodeRows(m)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"n", "t"}, rows.Columns)
+			row := make([]driver.Value, 2)
+			require.NoError(t, rows.Next(row))
+			assert.Equal(t, []driver.Value{int64(1), "x"}, row)
+			assert.Equal(t, io.EOF, rows.Next(row))
+		},
+		"empty": func(t *testing.T, m *Message) {
+			require.NoError(t, DecodeEmpty(m))
+		},
+		"metadata": func(t *testing.T, m *Message) {
+			domain, weight, err := DecodeMetadata(m)
+			require.NoError(t, err)
+			assert.Equal(t, []uint64{1, 2}, []uint64{domain, weight})
+		},
+	}
+
+	require.Len(t, decoders, len(conformance.Responses))
+
+	for _, vector := range conformance.Responses {
+		decode, ok := decoders[vector.Name]
+		require.True(t, ok, vector.Name)
+
+		message := Message{}
+		message.Init(4096)
+		copy(message.body.Bytes, vector.Body)
+		message.mtype = vector.Type
+		message.flags = vector.Schema
+		message.words = uint32(len(vector.Body) / messageWordSize)
+		message.strict = true
+		message.Rewind()
+
+		t.Run(vector.Name, func(t *testing.T) { decode(t, &message) })
+	}
+}
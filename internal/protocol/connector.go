@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Rican7/retry"
-	"github.com/Rican7/retry/backoff"
 	"github.com/Rican7/retry/strategy"
 	"github.com/canonical/go-dqlite/internal/logging"
 	"github.com/pkg/errors"
@@ -25,6 +27,15 @@ type Connector struct {
 	store  NodeStore    // Used to get and update current cluster servers.
 	config Config       // Connection parameters.
 	log    logging.Func // Logging function.
+
+	mu     sync.Mutex // Serialize access to the fields below.
+	leader NodeInfo   // Last known leader, if still believed to be the leader.
+	last   NodeInfo   // Last leader connected to, for change notifications.
+
+	standby    map[string]*Protocol // Idle connections to voters other than the leader.
+	warming    bool                 // Whether standby connections are being established.
+	generation uint64               // Incremented when standby connections are closed.
+	voters     map[string]*Protocol // Shared connections used to confirm the leader.
 }
 
 // NewConnector returns a new connector that can be used by a dqlite driver to
@@ -60,13 +71,61 @@ func NewConnector(id uint64, store NodeStore, config Config, log logging.Func) *
 	return connector
 }
 
+// Leader returns the address of the leader that the last successful call to
+// Connect connected to, or an empty string.
+func (c *Connector) Leader() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader.Address
+}
+
+// Update the last known leader, invoking the LeaderChange callback if it's a
+// different node than the one previously connected to.
+func (c *Connector) setLeader(leader NodeInfo) {
+	c.mu.Lock()
+	c.leader = leader
+	if leader.Address == "" {
+		c.mu.Unlock()
+		return
+	}
+	last := c.last
+	c.last = leader
+	c.mu.Unlock()
+
+	if last.Address == "" || last.Address == leader.Address {
+		return
+	}
+	c.log(logging.Info, "leader changed from %s to %s", last.Address, leader.Address)
+	if c.config.Stats != nil {
+		atomic.AddUint64(&c.config.Stats.LeaderChanges, 1)
+	}
+	if c.config.LeaderChange != nil {
+		c.config.LeaderChange(last, leader)
+	}
+}
+
 // Connect finds the leader server and returns a connection to it.
 //
-// If the connector is stopped before a leader is found, nil is returned.
+// If the connector is stopped before a leader is found, nil is returned. If
+// authentication against a server fails, no further attempt is made and
+// ErrAuthentication is returned.
 func (c *Connector) Connect(ctx context.Context) (*Protocol, error) {
 	var protocol *Protocol
+	var authErr error
 
-	strategies := makeRetryStrategies(c.config.BackoffFactor, c.config.BackoffCap, c.config.RetryLimit)
+	// Retry right away if the list of servers changes while waiting.
+	var wake <-chan struct{}
+	if store, ok := c.store.(WatchableNodeStore); ok {
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		wake = store.Watch(watchCtx)
+	}
+
+	backoff := c.config.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(c.config.BackoffFactor, c.config.BackoffCap)
+	}
+	strategies := makeRetryStrategies(ctx, backoff, c.config.RetryLimit, wake)
 
 	// The retry strategy should be configured to retry indefinitely, until
 	// the given context is done.
@@ -86,6 +145,11 @@ func (c *Connector) Connect(ctx context.Context) (*Protocol, error) {
 		var err error
 		protocol, err = c.connectAttemptAll(ctx, log)
 		if err != nil {
+			if _, ok := err.(ErrAuthentication); ok {
+				// Stop retrying
+				authErr = err
+				return nil
+			}
 			return err
 		}
 
@@ -98,6 +162,10 @@ func (c *Connector) Connect(ctx context.Context) (*Protocol, error) {
 		return nil, ErrNoAvailableLeader
 	}
 
+	if authErr != nil {
+		return nil, authErr
+	}
+
 	if ctx.Err() != nil {
 		return nil, ErrNoAvailableLeader
 	}
@@ -109,77 +177,262 @@ func (c *Connector) Connect(ctx context.Context) (*Protocol, error) {
 		panic("no protocol object")
 	}
 
+	protocol.SetLogFunc(c.log)
+	protocol.SetInterceptor(c.config.Interceptor)
+
+	if c.config.UpdateStore {
+		c.updateStore(ctx, protocol)
+	}
+
+	c.warmStandby()
+
 	return protocol, nil
 }
 
+// Fetch the cluster configuration from the leader and save it in the store,
+// so the store keeps up with membership changes. The metadata of the servers
+// already in the store is preserved, and failures are only logged.
+func (c *Connector) updateStore(ctx context.Context, protocol *Protocol) {
+	if protocol.version == VersionLegacy {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	request := Message{}
+	request.Init(16)
+	response := Message{}
+	response.Init(512)
+
+	EncodeCluster(&request, ClusterFormatV1)
+
+	if err := protocol.Call(ctx, &request, &response); err != nil {
+		c.log(logging.Warn, "update store: fetch cluster: %v", err)
+		return
+	}
+
+	servers, err := DecodeNodes(&response)
+	if err != nil {
+		c.log(logging.Warn, "update store: fetch cluster: %v", err)
+		return
+	}
+	for i := range servers {
+		servers[i].Address = inheritScheme(c.config.Translate.Translate(servers[i].Address), c.Leader())
+	}
+
+	current, err := c.store.Get(ctx)
+	if err != nil {
+		c.log(logging.Warn, "update store: get servers: %v", err)
+		return
+	}
+
+	known := make(map[string]NodeInfo, len(current))
+	for _, server := range current {
+		known[server.Address] = server
+	}
+	for i, server := range servers {
+		if info, ok := known[server.Address]; ok {
+			servers[i].Weight = info.Weight
+			servers[i].FailureDomain = info.FailureDomain
+			servers[i].TLS = info.TLS
+		}
+	}
+
+	if reflect.DeepEqual(servers, current) {
+		return
+	}
+
+	if err := c.store.Set(ctx, servers); err != nil {
+		c.log(logging.Warn, "update store: set servers: %v", err)
+	}
+}
+
 // Make a single attempt to establish a connection to the leader server trying
 // all addresses available in the store.
+//
+// The last known leader is tried first. If it's not the leader anymore, all
+// servers are tried concurrently, and the connection to the leader obtained
+// through the fastest responder is returned. Messages logged by each
+// concurrent attempt are emitted together once that attempt completes.
 func (c *Connector) connectAttemptAll(ctx context.Context, log logging.Func) (*Protocol, error) {
+	if address := c.Leader(); address != "" {
+		protocol, leader, err := c.connectAttemptLeader(ctx, address, log)
+		if err != nil {
+			return nil, err
+		}
+		if protocol != nil {
+			c.setLeader(leader)
+			return protocol, nil
+		}
+		c.setLeader(NodeInfo{})
+	}
+
+	if protocol, leader := c.connectAttemptStandby(ctx, log); protocol != nil {
+		c.setLeader(leader)
+		return protocol, nil
+	}
+
 	servers, err := c.store.Get(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "get servers")
 	}
 
-	// Make an attempt for each address until we find the leader.
+	type result struct {
+		protocol *Protocol
+		leader   NodeInfo
+		err      error
+		entries  []logEntry
+	}
+
+	// Abort the attempts still pending once a leader is found.
+	attemptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(servers))
 	for _, server := range servers {
-		log := func(l logging.Level, format string, a ...interface{}) {
-			format = fmt.Sprintf("server %s: ", server.Address) + format
-			log(l, format, a...)
-		}
+		go func(address string) {
+			entries := []logEntry{}
+			log := func(l logging.Level, format string, a ...interface{}) {
+				format = fmt.Sprintf("server %s: ", address) + format
+				entries = append(entries, logEntry{level: l, format: format, args: a})
+			}
+			protocol, leader, err := c.connectAttemptServer(attemptCtx, address, log)
+			results <- result{protocol: protocol, leader: leader, err: err, entries: entries}
+		}(server.Address)
+	}
 
-		ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
-		defer cancel()
+	// Close the connections established by the attempts still pending.
+	drain := func(pending int) {
+		go func() {
+			for i := 0; i < pending; i++ {
+				if r := <-results; r.protocol != nil {
+					r.protocol.Close()
+				}
+			}
+		}()
+	}
 
-		version := VersionOne
-		protocol, leader, err := c.connectAttemptOne(ctx, server.Address, version)
-		if err == errBadProtocol {
-			log(logging.Warn, "unsupported protocol %d, attempt with legacy", version)
-			version = VersionLegacy
-			protocol, leader, err = c.connectAttemptOne(ctx, server.Address, version)
+	for i := range servers {
+		r := <-results
+		for _, entry := range r.entries {
+			log(entry.level, entry.format, entry.args...)
 		}
-		if err != nil {
-			// This server is unavailable, try with the next target.
-			log(logging.Warn, err.Error())
-			continue
-		}
-		if protocol != nil {
-			// We found the leader
-			log(logging.Debug, "connected")
-			return protocol, nil
+		if _, ok := r.err.(ErrAuthentication); ok {
+			cancel()
+			drain(len(servers) - i - 1)
+			return nil, r.err
 		}
-		if leader == "" {
-			// This server does not know who the current leader is,
-			// try with the next target.
-			log(logging.Warn, "no known leader")
-			continue
+		if r.protocol != nil {
+			cancel()
+			drain(len(servers) - i - 1)
+			c.setLeader(r.leader)
+			return r.protocol, nil
 		}
+	}
 
-		// If we get here, it means this server reported that another
-		// server is the leader, let's close the connection to this
-		// server and try with the suggested one.
-		log(logging.Debug, "connect to reported leader %s", leader)
+	return nil, ErrNoAvailableLeader
+}
 
-		ctx, cancel = context.WithTimeout(ctx, c.config.AttemptTimeout)
-		defer cancel()
+// A log message buffered until a concurrent connection attempt completes.
+type logEntry struct {
+	level  logging.Level
+	format string
+	args   []interface{}
+}
 
-		protocol, leader, err = c.connectAttemptOne(ctx, leader, version)
-		if err != nil {
-			// The leader reported by the previous server is
-			// unavailable, try with the next target.
-			log(logging.Warn, "reported leader unavailable err=%v", err)
-			continue
-		}
-		if protocol == nil {
-			// The leader reported by the target server does not consider itself
-			// the leader, try with the next target.
-			log(logging.Warn, "reported leader server is not the leader")
-			continue
-		}
+// Try to establish a connection to the leader through the server with the
+// given address, following its redirect if it reports another server as
+// leader.
+//
+// Return the connection along with the leader information, or a nil protocol
+// and a nil error if no leader could be reached.
+func (c *Connector) connectAttemptServer(ctx context.Context, address string, log logging.Func) (*Protocol, NodeInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	version := VersionOne
+	protocol, leader, err := c.connectAttemptOne(ctx, address, version)
+	if err == errBadProtocol {
+		log(logging.Warn, "unsupported protocol %d, attempt with legacy", version)
+		version = VersionLegacy
+		protocol, leader, err = c.connectAttemptOne(ctx, address, version)
+	}
+	if _, ok := err.(ErrAuthentication); ok {
+		log(logging.Warn, err.Error())
+		return nil, NodeInfo{}, err
+	}
+	if err != nil {
+		// This server is unavailable.
+		log(logging.Warn, err.Error())
+		return nil, NodeInfo{}, nil
+	}
+	if protocol != nil {
+		// We found the leader
 		log(logging.Debug, "connected")
-		return protocol, nil
+		return protocol, leader, nil
+	}
+	if leader.Address == "" {
+		// This server does not know who the current leader is.
+		log(logging.Warn, "no known leader")
+		return nil, NodeInfo{}, nil
 	}
 
-	return nil, ErrNoAvailableLeader
+	// If we get here, it means this server reported that another server is
+	// the leader, let's close the connection to this server and try with
+	// the suggested one.
+	log(logging.Debug, "connect to reported leader %s", leader.Address)
+
+	ctx, cancel = context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	protocol, leader, err = c.connectAttemptOne(ctx, leader.Address, version)
+	if _, ok := err.(ErrAuthentication); ok {
+		log(logging.Warn, err.Error())
+		return nil, NodeInfo{}, err
+	}
+	if err != nil {
+		// The leader reported by the previous server is unavailable.
+		log(logging.Warn, "reported leader unavailable err=%v", err)
+		return nil, NodeInfo{}, nil
+	}
+	if protocol == nil {
+		// The leader reported by the target server does not consider
+		// itself the leader.
+		log(logging.Warn, "reported leader server is not the leader")
+		return nil, NodeInfo{}, nil
+	}
+	log(logging.Debug, "connected")
+	return protocol, leader, nil
+}
+
+// Try to connect directly to the last known leader.
+//
+// Return a nil protocol and a nil error if it's not the leader anymore.
+func (c *Connector) connectAttemptLeader(ctx context.Context, address string, parent logging.Func) (*Protocol, NodeInfo, error) {
+	log := func(l logging.Level, format string, a ...interface{}) {
+		format = fmt.Sprintf("last known leader %s: ", address) + format
+		parent(l, format, a...)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	protocol, leader, err := c.connectAttemptOne(ctx, address, VersionOne)
+	if _, ok := err.(ErrAuthentication); ok {
+		log(logging.Warn, err.Error())
+		return nil, NodeInfo{}, err
+	}
+	if err != nil {
+		log(logging.Debug, err.Error())
+		return nil, NodeInfo{}, nil
+	}
+	if protocol == nil {
+		log(logging.Debug, "not the leader anymore")
+		return nil, NodeInfo{}, nil
+	}
+	log(logging.Debug, "connected")
+	return protocol, leader, nil
 }
 
 // Perform the initial handshake using the given protocol version.
@@ -210,25 +463,15 @@ func Handshake(ctx context.Context, conn net.Conn, version uint64) (*Protocol, e
 //
 // Return values:
 //
-// - Any failure is hit:                     -> nil, "", err
-// - Target not leader and no leader known:  -> nil, "", nil
+// - Any failure is hit:                     -> nil, {}, err
+// - Target not leader and no leader known:  -> nil, {}, nil
 // - Target not leader and leader known:     -> nil, leader, nil
-// - Target is the leader:                   -> server, "", nil
+// - Target is the leader:                   -> server, leader, nil
 //
-func (c *Connector) connectAttemptOne(ctx context.Context, address string, version uint64) (*Protocol, string, error) {
-	dialCtx, cancel := context.WithTimeout(ctx, c.config.DialTimeout)
-	defer cancel()
-
-	// Establish the connection.
-	conn, err := c.config.Dial(dialCtx, address)
+func (c *Connector) connectAttemptOne(ctx context.Context, address string, version uint64) (*Protocol, NodeInfo, error) {
+	protocol, err := c.open(ctx, address, version)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "dial")
-	}
-
-	protocol, err := Handshake(ctx, conn, version)
-	if err != nil {
-		conn.Close()
-		return nil, "", err
+		return nil, NodeInfo{}, err
 	}
 
 	// Send the initial Leader request.
@@ -245,58 +488,107 @@ func (c *Connector) connectAttemptOne(ctx context.Context, address string, versi
 		// Best-effort detection of a pre-1.0 dqlite node: when sent
 		// version 1 it should close the connection immediately.
 		if err, ok := cause.(*net.OpError); ok && !err.Timeout() || cause == io.EOF {
-			return nil, "", errBadProtocol
+			return nil, NodeInfo{}, errBadProtocol
 		}
 
-		return nil, "", err
+		return nil, NodeInfo{}, err
 	}
 
-	_, leader, err := DecodeNodeCompat(protocol, &response)
+	id, leader, err := DecodeNodeCompat(protocol, &response)
 	if err != nil {
 		protocol.Close()
-		return nil, "", err
+		return nil, NodeInfo{}, err
 	}
+	leader = inheritScheme(c.config.Translate.Translate(leader), address)
+	info := NodeInfo{ID: id, Address: leader}
 
 	switch leader {
 	case "":
 		// Currently this server does not know about any leader.
 		protocol.Close()
-		return nil, "", nil
+		return nil, NodeInfo{}, nil
 	case address:
 		// This server is the leader, register ourselves and return.
-		request.reset()
-		response.reset()
-
-		EncodeClient(&request, c.id)
-
-		if err := protocol.Call(ctx, &request, &response); err != nil {
-			protocol.Close()
-			return nil, "", err
-		}
-
-		_, err := DecodeWelcome(&response)
-		if err != nil {
+		if err := c.register(ctx, protocol); err != nil {
 			protocol.Close()
-			return nil, "", err
+			return nil, NodeInfo{}, err
 		}
 
 		// TODO: enable heartbeat
 		// protocol.heartbeatTimeout = time.Duration(heartbeatTimeout) * time.Millisecond
 		//go protocol.heartbeat()
 
-		return protocol, "", nil
+		return protocol, info, nil
 	default:
 		// This server claims to know who the current leader is.
 		protocol.Close()
-		return nil, leader, nil
+		return nil, info, nil
 	}
 }
 
-// Return a retry strategy with exponential backoff, capped at the given amount
-// of time and possibly with a maximum number of retries.
-func makeRetryStrategies(factor, cap time.Duration, limit uint) []strategy.Strategy {
-	backoff := backoff.BinaryExponential(factor)
+// Establish a connection to the given dqlite server and perform the
+// handshake and the authentication.
+func (c *Connector) open(ctx context.Context, address string, version uint64) (*Protocol, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, c.config.DialTimeout)
+	defer cancel()
+
+	// Establish the connection.
+	conn, err := c.config.Dial(dialCtx, address)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial")
+	}
+
+	handshakeCtx := ctx
+	if c.config.HandshakeTimeout != 0 {
+		var cancel context.CancelFunc
+		handshakeCtx, cancel = context.WithTimeout(ctx, c.config.HandshakeTimeout)
+		defer cancel()
+	}
+
+	protocol, err := Handshake(handshakeCtx, conn, version)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	protocol.address = address
+	if err := protocol.Authenticate(handshakeCtx, c.config.Auth); err != nil {
+		protocol.Close()
+		return nil, err
+	}
+	protocol.SetTimeouts(c.config.ReadTimeout, c.config.WriteTimeout)
+	protocol.SetLimits(c.config.Limits)
+	protocol.SetStrictDecoding(c.config.StrictDecoding)
+	protocol.SetStats(c.config.Stats)
+	protocol.SetBudget(c.config.Budget)
+	protocol.SetCallTimeouts(c.config.CallTimeouts)
+	protocol.SetCallStats(c.config.CallStats)
+	protocol.SetAdaptiveTimeout(c.config.AdaptiveTimeout)
+	protocol.SetKeepAlive(c.config.KeepAlive)
+
+	return protocol, nil
+}
+
+// Register this client against the server.
+func (c *Connector) register(ctx context.Context, protocol *Protocol) error {
+	request := Message{}
+	request.Init(16)
+	response := Message{}
+	response.Init(512)
+
+	EncodeClient(&request, c.id)
+
+	if err := protocol.Call(ctx, &request, &response); err != nil {
+		return err
+	}
 
+	_, err := DecodeWelcome(&response)
+	return err
+}
+
+// Return a retry strategy with exponential backoff, capped at the given amount
+// of time and possibly with a maximum number of retries. Waiting for the next
+// retry stops early if the given channel is notified.
+func makeRetryStrategies(ctx context.Context, backoff BackoffFunc, limit uint, wake <-chan struct{}) []strategy.Strategy {
 	strategies := []strategy.Strategy{}
 
 	if limit > 0 {
@@ -306,12 +598,14 @@ func makeRetryStrategies(factor, cap time.Duration, limit uint) []strategy.Strat
 	strategies = append(strategies,
 		func(attempt uint) bool {
 			if attempt > 0 {
-				duration := backoff(attempt)
-				// Duration might be negative in case of integer overflow.
-				if duration > cap || duration <= 0 {
-					duration = cap
+				timer := time.NewTimer(backoff(attempt))
+				select {
+				case <-timer.C:
+				case <-wake:
+					timer.Stop()
+				case <-ctx.Done():
+					timer.Stop()
 				}
-				time.Sleep(duration)
 			}
 
 			return true
@@ -3,18 +3,171 @@ package protocol
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"strings"
+	"time"
 )
 
-// Dial function handling plain TCP and Unix socket endpoints.
+// Schemes of the node addresses understood by Dial.
+const (
+	schemeTCP  = "tcp://"
+	schemeTLS  = "tls://"
+	schemeUnix = "unix://"
+)
+
+// Endpoint is a node address broken down into its parts.
+type Endpoint struct {
+	Network string // Either "tcp" or "unix".
+	Address string // Host and port, socket path, or abstract socket name starting with "@".
+	TLS     bool   // Whether the address has the tls:// scheme.
+}
+
+// ParseAddress parses a node address, which is either a "host:port" TCP
+// address, an abstract Unix socket name starting with "@", or an address
+// with an explicit scheme: "tcp://host:port", "tls://host:port",
+// "unix:///path/to/socket" or "unix://@name".
+func ParseAddress(address string) (Endpoint, error) {
+	endpoint := Endpoint{Network: "tcp", Address: address}
+
+	switch {
+	case strings.HasPrefix(address, schemeTCP):
+		endpoint.Address = address[len(schemeTCP):]
+	case strings.HasPrefix(address, schemeTLS):
+		endpoint.Address = address[len(schemeTLS):]
+		endpoint.TLS = true
+	case strings.HasPrefix(address, schemeUnix):
+		endpoint.Network = "unix"
+		endpoint.Address = address[len(schemeUnix):]
+	case strings.HasPrefix(address, "@"):
+		endpoint.Network = "unix"
+	case strings.Contains(address, "://"):
+		return Endpoint{}, fmt.Errorf("unsupported scheme in address %q", address)
+	}
+
+	if endpoint.Address == "" {
+		return Endpoint{}, fmt.Errorf("empty address %q", address)
+	}
+
+	return endpoint, nil
+}
+
+// Return the given address reported by a node, with the scheme of the
+// address that the node was reached at, if any, since nodes report bare
+// addresses. The unix:// scheme is only restored for socket paths, the other
+// Unix addresses being told apart without it.
+func inheritScheme(reported, reachedAt string) string {
+	if reported == "" || strings.Contains(reported, "://") {
+		return reported
+	}
+	for _, scheme := range []string{schemeTCP, schemeTLS} {
+		if strings.HasPrefix(reachedAt, scheme) {
+			return scheme + reported
+		}
+	}
+	if strings.HasPrefix(reachedAt, schemeUnix) && strings.HasPrefix(reported, "/") {
+		return schemeUnix + reported
+	}
+	return reported
+}
+
+// Dial function handling plain TCP and Unix socket endpoints, as well as
+// TLS endpoints verified against the system roots, see ParseAddress.
 func Dial(ctx context.Context, address string) (net.Conn, error) {
-	family := "tcp"
-	if strings.HasPrefix(address, "@") {
-		family = "unix"
+	return SocketOptions{}.Dial(ctx, address)
+}
+
+// SocketOptions holds tuning parameters for the sockets created by Dial.
+type SocketOptions struct {
+	// Nagle enables Nagle's algorithm on TCP sockets, by clearing the
+	// TCP_NODELAY flag that Go sets by default.
+	Nagle bool
+
+	// KeepAlive is the period between TCP keep-alive probes. If zero, the
+	// Go default is used. If negative, keep-alive probes are disabled.
+	KeepAlive time.Duration
+
+	// ReadBuffer and WriteBuffer set the size of the socket receive and
+	// send buffers (SO_RCVBUF and SO_SNDBUF). If zero, the operating system
+	// default is used.
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// Dial is like the Dial function, but applies the socket options to the
+// established connection.
+func (o SocketOptions) Dial(ctx context.Context, address string) (net.Conn, error) {
+	endpoint, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
 	}
-	dialer := net.Dialer{}
-	return dialer.DialContext(ctx, family, address)
+	dialer := net.Dialer{KeepAlive: o.KeepAlive}
+	conn, err := dialer.DialContext(ctx, endpoint.Network, endpoint.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if endpoint.TLS {
+		return handshakeTLS(ctx, conn, endpoint.Address)
+	}
+
+	return conn, nil
+}
+
+// Establish a TLS session with the default configuration over the given
+// connection, honoring the deadline of the given context.
+func handshakeTLS(ctx context.Context, conn net.Conn, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	if deadline, ok := ctx.Deadline(); ok {
+		tlsConn.SetDeadline(deadline)
+		defer tlsConn.SetDeadline(time.Time{})
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// Apply the socket options to the given connection.
+func (o SocketOptions) apply(conn net.Conn) error {
+	if tcpConn, ok := conn.(*net.TCPConn); ok && o.Nagle {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			return err
+		}
+	}
+
+	buffered, ok := conn.(interface {
+		SetReadBuffer(int) error
+		SetWriteBuffer(int) error
+	})
+	if !ok {
+		return nil
+	}
+	if o.ReadBuffer > 0 {
+		if err := buffered.SetReadBuffer(o.ReadBuffer); err != nil {
+			return err
+		}
+	}
+	if o.WriteBuffer > 0 {
+		if err := buffered.SetWriteBuffer(o.WriteBuffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // TLSCipherSuites are the cipher suites by the go-dqlite TLS helpers.
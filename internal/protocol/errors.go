@@ -14,6 +14,65 @@ var (
 	errMessageEOF        = fmt.Errorf("message eof")
 )
 
+// Failure modes that errors returned by requests can be matched against with
+// errors.Is.
+var (
+	// ErrNotLeader matches failures of requests sent to a node that is not
+	// the leader or lost leadership while serving them.
+	ErrNotLeader = fmt.Errorf("node is not the leader")
+
+	// ErrNodeBusy matches failures of statements because the database is
+	// busy or locked.
+	ErrNodeBusy = fmt.Errorf("database is busy")
+
+	// ErrConnectionDead matches failures caused by the network connection
+	// to a node being lost, including those of requests attempted on a
+	// connection that was previously lost.
+	ErrConnectionDead = fmt.Errorf("connection is dead")
+)
+
+// Result codes of the failures matching ErrNotLeader and ErrNodeBusy.
+const (
+	codeBusy                 = 5
+	codeLocked               = 6
+	codeNotLeader            = 10 | 40<<8
+	codeLeadershipLost       = 10 | 41<<8
+	codeNotLeaderLegacy      = 10 | 32<<8
+	codeLeadershipLostLegacy = 10 | 33<<8
+)
+
+// Tell whether a failure with the given result code matches the given
+// sentinel error.
+func codeIs(code uint64, target error) bool {
+	switch target {
+	case ErrNotLeader:
+		switch code {
+		case codeNotLeader, codeLeadershipLost, codeNotLeaderLegacy, codeLeadershipLostLegacy:
+			return true
+		}
+	case ErrNodeBusy:
+		switch code & 0xff {
+		case codeBusy, codeLocked:
+			return true
+		}
+	}
+	return false
+}
+
+// An error that made a connection unusable, matching ErrConnectionDead.
+type deadError struct {
+	err error
+}
+
+func (e deadError) Error() string        { return e.err.Error() }
+func (e deadError) Cause() error         { return e.err }
+func (e deadError) Unwrap() error        { return e.err }
+func (e deadError) Is(target error) bool { return target == ErrConnectionDead }
+
+// ErrMalformedMessage is returned when decoding a message that is truncated or
+// otherwise not well-formed.
+var ErrMalformedMessage = fmt.Errorf("malformed message")
+
 // ErrRequest is returned in case of request failure.
 type ErrRequest struct {
 	Code        uint64
@@ -24,16 +83,59 @@ func (e ErrRequest) Error() string {
 	return fmt.Sprintf("%s (%d)", e.Description, e.Code)
 }
 
+// Is tells whether the failure matches ErrNotLeader or ErrNodeBusy.
+func (e ErrRequest) Is(target error) bool {
+	return codeIs(e.Code, target)
+}
+
+// As makes the failure match an Error target with errors.As.
+func (e ErrRequest) As(target interface{}) bool {
+	if target, ok := target.(*Error); ok {
+		*target = Error{Code: int(e.Code), Message: e.Description}
+		return true
+	}
+	return false
+}
+
+// ErrMessageTooLarge is returned when the server sends a message whose body
+// exceeds the configured maximum size. The connection is aborted, since the
+// rest of the message can't be consumed.
+type ErrMessageTooLarge struct {
+	Size  int // Size of the message body announced by the server.
+	Limit int // Maximum allowed size.
+}
+
+func (e ErrMessageTooLarge) Error() string {
+	return fmt.Sprintf("message size %d exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
 // ErrRowsPart is returned when the first batch of a multi-response result
 // batch is done.
 var ErrRowsPart = fmt.Errorf("not all rows were returned in this response")
 
 // Error holds information about a SQLite error.
 type Error struct {
-	Code    int
-	Message string
+	Code    int    // Extended result code.
+	Message string // Error message provided by the server.
+}
+
+// PrimaryCode returns the primary result code of the error, like 19 for
+// SQLITE_CONSTRAINT, stripping the extended information.
+func (e Error) PrimaryCode() int {
+	return e.Code & 0xff
+}
+
+// ExtendedCode returns the extended result code of the error, like 2067 for
+// SQLITE_CONSTRAINT_UNIQUE. It's the same as Code.
+func (e Error) ExtendedCode() int {
+	return e.Code
 }
 
 func (e Error) Error() string {
 	return e.Message
 }
+
+// Is tells whether the error matches ErrNodeBusy.
+func (e Error) Is(target error) bool {
+	return codeIs(uint64(e.Code), target)
+}
@@ -0,0 +1,40 @@
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/canonical/go-dqlite/internal/protocol"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrRequest_Is(t *testing.T) {
+	cases := []struct {
+		code      uint64
+		notLeader bool
+		busy      bool
+	}{
+		{10 | 40<<8, true, false},
+		{10 | 41<<8, true, false},
+		{10 | 32<<8, true, false},
+		{5, false, true},
+		{6 | 1<<8, false, true},
+		{19, false, false},
+	}
+
+	for _, c := range cases {
+		err := pkgerrors.Wrap(protocol.ErrRequest{Code: c.code, Description: "failed"}, "call")
+		assert.Equal(t, c.notLeader, errors.Is(err, protocol.ErrNotLeader), c.code)
+		assert.Equal(t, c.busy, errors.Is(err, protocol.ErrNodeBusy), c.code)
+		assert.False(t, errors.Is(err, protocol.ErrConnectionDead), c.code)
+	}
+
+	assert.True(t, errors.Is(protocol.Error{Code: 5, Message: "database is locked"}, protocol.ErrNodeBusy))
+}
+
+func TestError_Codes(t *testing.T) {
+	err := protocol.Error{Code: 19 | 8<<8, Message: "UNIQUE constraint failed: t.n"}
+	assert.Equal(t, 19, err.PrimaryCode())
+	assert.Equal(t, 2067, err.ExtendedCode())
+}
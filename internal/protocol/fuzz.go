@@ -0,0 +1,89 @@
+//go:build gofuzz
+// +build gofuzz
+
+package protocol
+
+import (
+	"database/sql/driver"
+)
+
+// Limits enforced when fuzzing, tight enough to be hit by small inputs.
+var fuzzLimits = Limits{
+	MessageSize:  1 << 16,
+	StringLength: 64,
+	BlobSize:     256,
+	TupleSize:    16,
+	DumpFiles:    4,
+	DumpFileSize: 256,
+}
+
+// Fuzz is the entry point for go-fuzz. The first byte of the data is the type
+// of a response and the rest is its body, which is decoded with the decoder
+// of every response type, under tight limits. Decoding must fail with an
+// error rather than panicking or allocating without bounds.
+func Fuzz(data []byte) int {
+	if len(data) < 1 {
+		return -1
+	}
+	body := data[1:]
+	size := (len(body) + messageWordSize - 1) / messageWordSize * messageWordSize
+	if size == 0 || size > fuzzLimits.MessageSize {
+		return -1
+	}
+
+	decoded := 0
+	for _, decode := range fuzzDecoders {
+		message := Message{}
+		message.Init(size)
+		copy(message.body.Bytes, body)
+		message.mtype = data[0]
+		message.words = uint32(size / messageWordSize)
+		message.limits = fuzzLimits
+
+		if decode(&message) == nil {
+			decoded = 1
+		}
+	}
+
+	return decoded
+}
+
+var fuzzDecoders = []func(*Message) error{
+	func(m *Message) error { _, _, err := DecodeFailure(m); return err },
+	func(m *Message) error { _, err := DecodeWelcome(m); return err },
+	func(m *Message) error { _, err := DecodeNodeLegacy(m); return err },
+	func(m *Message) error { _, _, err := DecodeNode(m); return err },
+	func(m *Message) error { _, err := DecodeNodes(m); return err },
+	func(m *Message) error { _, err := DecodeDb(m); return err },
+	func(m *Message) error { _, _, _, err := DecodeStmt(m); return err },
+	func(m *Message) error { return DecodeEmpty(m) },
+	func(m *Message) error { _, err := DecodeResult(m); return err },
+	func(m *Message) error { _, _, err := DecodeMetadata(m); return err },
+	func(m *Message) error {
+		rows, err := DecodeRows(m)
+		if err != nil {
+			return err
+		}
+		dest := make([]driver.Value, len(rows.Columns))
+		// Result sets without columns have no row headers, so bound
+		// the number of rows.
+		for i := 0; i < fuzzLimits.MessageSize; i++ {
+			if err := rows.Next(dest); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	func(m *Message) error {
+		files, err := DecodeFiles(m)
+		if err != nil {
+			return err
+		}
+		for {
+			name, _ := files.Next()
+			if name == "" {
+				return files.Err()
+			}
+		}
+	},
+}
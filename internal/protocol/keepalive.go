@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SetKeepAlive makes the connection ping the node with a Leader request each
+// time it stays idle for the given interval, so that a connection silently
+// dropped by a NAT, a load balancer or a dead node is detected before it gets
+// used again. Once a ping fails, the connection is marked as dead and all
+// calls fail right away with ErrConnectionDead.
+//
+// Pings are serialized with the calls and are not sent while the rows of a
+// query are still pending. A zero interval, the default, disables them. It
+// must be set at most once, before using the connection.
+func (p *Protocol) SetKeepAlive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go p.keepAlive(interval)
+}
+
+// Dead tells whether the connection was found to be unusable, for example
+// because a call or a keep-alive ping failed.
+func (p *Protocol) Dead() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.netErr != nil
+}
+
+// Ping the node each time the connection stays idle for the given interval,
+// until it gets closed or found dead.
+func (p *Protocol) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	request := Message{}
+	request.Init(16)
+	response := Message{}
+	response.Init(512)
+
+	last := p.traffic()
+	for {
+		select {
+		case <-p.closeCh:
+			return
+		case <-ticker.C:
+		}
+
+		if err := p.keepAlivePing(&last, interval, &request, &response); err != nil {
+			return
+		}
+	}
+}
+
+// Send a ping if nothing was exchanged on the connection since the traffic
+// seen last time. Return an error if the connection is dead.
+func (p *Protocol) keepAlivePing(last *uint64, interval time.Duration, request, response *Message) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.netErr != nil {
+		return p.netErr
+	}
+
+	traffic := p.traffic()
+	if traffic != *last || p.partial {
+		*last = traffic
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+
+	EncodeLeader(request)
+	err = p.roundTrip(ctx, request, response)
+	if err == nil {
+		_, _, err = DecodeNodeCompat(p, response)
+	}
+	if err != nil && p.netErr == nil {
+		err = p.broken(errors.Wrap(err, "keep-alive"))
+	}
+	*last = p.traffic()
+
+	return err
+}
+
+// Return the number of bytes exchanged on the connection so far.
+func (p *Protocol) traffic() uint64 {
+	return atomic.LoadUint64(&p.sent) + atomic.LoadUint64(&p.received)
+}
+
+// Tell whether the given response holds the first rows of a result set whose
+// remaining rows are still to be received with More.
+func hasMoreRows(response *Message) bool {
+	return response.mtype == ResponseRows && response.lastByte() == 0xee
+}
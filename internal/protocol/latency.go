@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Whether requests of the given type are answered by the node without
+// touching the database, so that their round trip measures the latency of the
+// network.
+func isProbe(mtype uint8) bool {
+	switch mtype {
+	case RequestLeader, RequestClient, RequestHeartbeat:
+		return true
+	default:
+		return false
+	}
+}
+
+// NetworkLatency returns an estimate of the network round trip time of the
+// connection, smoothing the durations of the calls that the node answers
+// without touching the database, like the Leader request sent when connecting
+// and the keep-alive pings. It's zero if no such call was made yet.
+func (p *Protocol) NetworkLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.latency))
+}
+
+// Account for the round trip of a probe call, as TCP does for its smoothed
+// round trip time.
+func (p *Protocol) observeLatency(duration time.Duration) {
+	latency := time.Duration(atomic.LoadInt64(&p.latency))
+	if latency == 0 {
+		latency = duration
+	} else {
+		latency += (duration - latency) / 8
+	}
+	atomic.StoreInt64(&p.latency, int64(latency))
+}
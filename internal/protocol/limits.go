@@ -0,0 +1,18 @@
+package protocol
+
+// Limits bounds the data that the decoders accept from responses, so the
+// memory that a misbehaving node can make a client allocate is bounded too.
+// Responses exceeding a limit are malformed. A zero value means no limit.
+type Limits struct {
+	MessageSize  int // Size of a response message body, in bytes.
+	StringLength int // Length of a string, such as a text value or a column name, in bytes.
+	BlobSize     int // Size of a blob value, in bytes.
+	TupleSize    int // Number of columns of a result set.
+	DumpFiles    int // Number of files of a Dump response.
+	DumpFileSize int // Size of a file of a Dump response, in bytes.
+}
+
+// SetLimits sets the limits enforced when receiving and decoding responses.
+func (p *Protocol) SetLimits(limits Limits) {
+	p.limits = limits
+}
@@ -9,6 +9,8 @@ import (
 	"math"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // NamedValues is a type alias of a slice of driver.NamedValue. It's used by
@@ -27,6 +29,19 @@ type Message struct {
 	extra  uint16
 	header []byte // Statically allocated header buffer
 	body   buffer // Message body data.
+	static []byte // Initial body buffer, restored when a pooled one is released.
+	pooled bool   // Whether the body buffer was taken from the pool.
+	strict bool   // Whether trailing data makes a decoded response malformed.
+	limits Limits // Limits enforced when decoding.
+	err    error  // Set if the message body was found to be malformed.
+
+	// Budget the body buffer is charged to, if any, and bytes charged.
+	budget  *Budget
+	charged int
+
+	// Scratch space re-used when decoding result sets.
+	columns []string // Column names of the last decoded result set.
+	types   []uint8  // Column types of the result set being decoded.
 }
 
 // Init initializes the message using the given initial size for the data
@@ -37,7 +52,9 @@ func (m *Message) Init(initialBufferSize int) {
 		panic("initial buffer size is not aligned to word boundary")
 	}
 	m.header = make([]byte, messageHeaderSize)
-	m.body.Bytes = make([]byte, initialBufferSize)
+	m.static = make([]byte, initialBufferSize)
+	m.body.Bytes = m.static
+	m.pooled = false
 	m.reset()
 }
 
@@ -51,6 +68,35 @@ func (m *Message) reset() {
 		m.header[i] = 0
 	}
 	m.body.Offset = 0
+	m.err = nil
+	m.Release()
+}
+
+// Release returns the body buffer of the message to the pool, if it had been
+// grown beyond its initial size, and gives back its memory to the budget it
+// was charged to.
+func (m *Message) Release() {
+	if m.pooled {
+		m.uncharge()
+		putBuffer(m.body.Bytes)
+		m.body.Bytes = m.static
+		m.pooled = false
+	}
+}
+
+// Replace the body buffer with a pooled one of at least the given size. If
+// preserve is true, the current content of the body is copied over.
+func (m *Message) growBody(size int, preserve bool) {
+	bytes := getBuffer(size)
+	if preserve {
+		copy(bytes, m.body.Bytes[:m.body.Offset])
+	}
+	if m.pooled {
+		m.uncharge()
+		putBuffer(m.body.Bytes)
+	}
+	m.body.Bytes = bytes
+	m.pooled = true
 }
 
 // Append a byte slice to the message.
@@ -158,13 +204,27 @@ func (m *Message) putFloat64(v float64) {
 }
 
 // Encode the given driver values as binding parameters.
+//
+// The original format of the parameters holds their number in a single byte.
+// If there are more than 255, the parameters are encoded in the format of
+// schema version 1, which holds their number in 32 bits, and the message
+// header is marked with that schema version. Servers older than dqlite 1.11
+// reject such messages.
 func (m *Message) putNamedValues(values NamedValues) {
-	n := uint8(len(values)) // N of params
+	n := len(values) // N of params
 	if n == 0 {
 		return
 	}
 
-	m.putUint8(n)
+	if n > math.MaxUint8 {
+		if uint64(n) > math.MaxUint32 {
+			panic("too many parameters")
+		}
+		m.flags = 1
+		m.putUint32(uint32(n))
+	} else {
+		m.putUint8(uint8(n))
+	}
 
 	for i := range values {
 		if values[i].Ordinal != i+1 {
@@ -191,11 +251,15 @@ func (m *Message) putNamedValues(values NamedValues) {
 		}
 	}
 
-	b := m.bufferForPut(1)
-
-	if trailing := b.Offset % messageWordSize; trailing != 0 {
-		// Skip padding bytes
-		b.Advance(messageWordSize - trailing)
+	if trailing := m.body.Offset % messageWordSize; trailing != 0 {
+		// Zero the padding bytes, which may hold data of a previous
+		// message encoded in the same buffer.
+		pad := messageWordSize - trailing
+		b := m.bufferForPut(pad)
+		for i := 0; i < pad; i++ {
+			b.Bytes[b.Offset+i] = 0
+		}
+		b.Advance(pad)
 	}
 
 	for i := range values {
@@ -238,10 +302,9 @@ func (m *Message) putHeader(mtype uint8) {
 	}
 
 	m.mtype = mtype
-	m.flags = 0
 	m.extra = 0
 
-	m.words = uint32(m.body.Offset) / messageWordSize
+	m.words = uint32(m.body.Offset / messageWordSize)
 
 	m.finalize()
 }
@@ -258,11 +321,9 @@ func (m *Message) finalize() {
 }
 
 func (m *Message) bufferForPut(size int) *buffer {
-	for (m.body.Offset + size) > len(m.body.Bytes) {
+	if (m.body.Offset + size) > len(m.body.Bytes) {
 		// Grow message buffer.
-		bytes := make([]byte, len(m.body.Bytes)*2)
-		copy(bytes, m.body.Bytes)
-		m.body.Bytes = bytes
+		m.growBody(m.body.Offset+size, true)
 	}
 
 	return &m.body
@@ -275,95 +336,141 @@ func (m *Message) getHeader() (uint8, uint8) {
 
 // Read a string from the message body.
 func (m *Message) getString() string {
-	b := m.bufferForGet()
+	return string(m.getStringBytes())
+}
 
-	index := bytes.IndexByte(b.Bytes[b.Offset:], 0)
-	if index == -1 {
-		panic("no string found")
+// Read a string from the message body, returning its bytes without copying
+// them. The returned slice is valid only until the message is reset.
+func (m *Message) getStringBytes() []byte {
+	if m.err != nil {
+		return nil
 	}
-	s := string(b.Bytes[b.Offset : b.Offset+index])
 
-	index++
+	index := bytes.IndexByte(m.body.Bytes[m.body.Offset:m.size()], 0)
+	if index == -1 {
+		m.malformed("string is not terminated")
+		return nil
+	}
+	if limit := m.limits.StringLength; limit > 0 && index > limit {
+		m.malformed("string length %d exceeds limit of %d", index, limit)
+		return nil
+	}
 
-	if trailing := index % messageWordSize; trailing != 0 {
-		// Account for padding, moving index to the next word boundary.
-		index += messageWordSize - trailing
+	size := index + 1
+	if trailing := size % messageWordSize; trailing != 0 {
+		// Account for padding, moving to the next word boundary.
+		size += messageWordSize - trailing
 	}
 
-	b.Advance(index)
+	b := m.consume(size)
+	if b == nil {
+		return nil
+	}
 
-	return s
+	return b[:index]
 }
 
 func (m *Message) getBlob() []byte {
+	b := m.getBlobBytes()
+	if b == nil {
+		return nil
+	}
+
+	data := make([]byte, len(b))
+	copy(data, b)
+
+	return data
+}
+
+// Read a blob from the message body, returning its bytes without copying
+// them. The returned slice is valid only until the message is reset.
+func (m *Message) getBlobBytes() []byte {
 	size := m.getUint64()
-	data := make([]byte, size)
-	for i := range data {
-		data[i] = m.getUint8()
+	if size > uint64(m.remaining()) {
+		m.malformed("blob size %d exceeds remaining %d bytes", size, m.remaining())
+		return nil
 	}
-	pad := 0
+	if limit := m.limits.BlobSize; limit > 0 && size > uint64(limit) {
+		m.malformed("blob size %d exceeds limit of %d", size, limit)
+		return nil
+	}
+
+	padded := int(size)
 	if (size % messageWordSize) != 0 {
 		// Account for padding
-		pad = int(messageWordSize - (size % messageWordSize))
+		padded += int(messageWordSize - (size % messageWordSize))
 	}
-	// Consume padding
-	for i := 0; i < pad; i++ {
-		m.getUint8()
+
+	b := m.consume(padded)
+	if b == nil {
+		return nil
 	}
-	return data
+
+	// Limit the capacity, so appending to the blob can't overwrite the
+	// rest of the message.
+	return b[:size:size]
 }
 
 // Read a byte from the message body.
 func (m *Message) getUint8() uint8 {
-	b := m.bufferForGet()
-	defer b.Advance(1)
+	b := m.consume(1)
+	if b == nil {
+		return 0
+	}
 
-	return b.Bytes[b.Offset]
+	return b[0]
 }
 
 // Read a 2-byte word from the message body.
 func (m *Message) getUint16() uint16 {
-	b := m.bufferForGet()
-	defer b.Advance(2)
+	b := m.consume(2)
+	if b == nil {
+		return 0
+	}
 
-	return binary.LittleEndian.Uint16(b.Bytes[b.Offset:])
+	return binary.LittleEndian.Uint16(b)
 }
 
 // Read a 4-byte word from the message body.
 func (m *Message) getUint32() uint32 {
-	b := m.bufferForGet()
-	defer b.Advance(4)
+	b := m.consume(4)
+	if b == nil {
+		return 0
+	}
 
-	return binary.LittleEndian.Uint32(b.Bytes[b.Offset:])
+	return binary.LittleEndian.Uint32(b)
 }
 
 // Read reads an 8-byte word from the message body.
 func (m *Message) getUint64() uint64 {
-	b := m.bufferForGet()
-	defer b.Advance(8)
+	b := m.consume(8)
+	if b == nil {
+		return 0
+	}
 
-	return binary.LittleEndian.Uint64(b.Bytes[b.Offset:])
+	return binary.LittleEndian.Uint64(b)
 }
 
 // Read a signed 8-byte word from the message body.
 func (m *Message) getInt64() int64 {
-	b := m.bufferForGet()
-	defer b.Advance(8)
-
-	return int64(binary.LittleEndian.Uint64(b.Bytes[b.Offset:]))
+	return int64(m.getUint64())
 }
 
 // Read a floating point number from the message body.
 func (m *Message) getFloat64() float64 {
-	b := m.bufferForGet()
-	defer b.Advance(8)
-
-	return math.Float64frombits(binary.LittleEndian.Uint64(b.Bytes[b.Offset:]))
+	return math.Float64frombits(m.getUint64())
 }
 
 // Decode a list of server objects from the message body.
 func (m *Message) getNodes() Nodes {
 	n := m.getUint64()
+
+	// Each server takes at least three words: ID, address and role.
+	if n > uint64(m.remaining()/(3*messageWordSize)) {
+		m.malformed("server count %d exceeds message size", n)
+		return nil
+	}
+
 	servers := make(Nodes, n)
 
 	for i := 0; i < int(n); i++ {
@@ -386,10 +493,45 @@ func (m *Message) getResult() Result {
 // Decode a query result set object from the message body.
 func (m *Message) getRows() Rows {
 	// Read the column count and column names.
-	columns := make([]string, m.getUint64())
+	count := m.getUint64()
+
+	// Each column name takes at least one word.
+	if count > uint64(m.remaining()/messageWordSize) {
+		m.malformed("column count %d exceeds message size", count)
+		return Rows{message: m}
+	}
+	if limit := m.limits.TupleSize; limit > 0 && count > uint64(limit) {
+		m.malformed("column count %d exceeds limit of %d", count, limit)
+		return Rows{message: m}
+	}
+	n := int(count)
+
+	// Re-use the column names of the last result set decoded with this
+	// message if they match, so no allocation is needed when the same
+	// query is run repeatedly. The cached slice is never modified, since
+	// it might still be referenced by previously decoded rows.
+	columns := m.columns
+	cached := len(columns) == n
+	if !cached {
+		columns = make([]string, n)
+	}
 
 	for i := range columns {
-		columns[i] = m.getString()
+		name := m.getStringBytes()
+		if cached {
+			if string(name) == columns[i] {
+				continue
+			}
+			fresh := make([]string, n)
+			copy(fresh, columns[:i])
+			columns = fresh
+			cached = false
+		}
+		columns[i] = string(name)
+	}
+
+	if m.err == nil {
+		m.columns = columns
 	}
 
 	rows := Rows{
@@ -404,28 +546,82 @@ func (m *Message) getFiles() Files {
 		n:       m.getUint64(),
 		message: m,
 	}
+	if limit := m.limits.DumpFiles; limit > 0 && files.n > uint64(limit) {
+		m.malformed("file count %d exceeds limit of %d", files.n, limit)
+		files.n = 0
+	}
 	return files
 }
 
+// Return a buffer for holding the given number of column types, re-using the
+// one of the last decoded result set if possible.
+func (m *Message) typesBuffer(n int) []uint8 {
+	if cap(m.types) < n {
+		m.types = make([]uint8, n)
+	}
+	return m.types[:n]
+}
+
 func (m *Message) hasBeenConsumed() bool {
-	size := int(m.words * messageWordSize)
-	return m.body.Offset == size
+	return m.body.Offset == m.size()
 }
 
 func (m *Message) lastByte() byte {
-	size := int(m.words * messageWordSize)
+	size := m.size()
+	if size == 0 {
+		return 0
+	}
 	return m.body.Bytes[size-1]
 }
 
-func (m *Message) bufferForGet() *buffer {
-	size := int(m.words * messageWordSize)
-	// The static body has been exahusted, use the dynamic one.
-	if m.body.Offset == size {
-		err := fmt.Errorf("short message: type=%d words=%d off=%d", m.mtype, m.words, m.body.Offset)
-		panic(err)
+// Return the size of the message body.
+func (m *Message) size() int {
+	return int(m.words) * messageWordSize
+}
+
+// Return the number of body bytes that haven't been consumed yet.
+func (m *Message) remaining() int {
+	return m.size() - m.body.Offset
+}
+
+// Consume the given number of bytes from the message body, returning them.
+//
+// If the body is too short the message is marked as malformed and nil is
+// returned. Once a message is malformed, nothing else can be consumed.
+func (m *Message) consume(n int) []byte {
+	if m.err != nil {
+		return nil
+	}
+	if n > m.remaining() {
+		m.malformed("short message: type=%d words=%d off=%d need=%d", m.mtype, m.words, m.body.Offset, n)
+		return nil
 	}
 
-	return &m.body
+	b := m.body.Bytes[m.body.Offset : m.body.Offset+n]
+	m.body.Advance(n)
+
+	return b
+}
+
+// Mark the message as malformed, unless it was already.
+func (m *Message) malformed(format string, a ...interface{}) {
+	if m.err == nil {
+		m.err = errors.Wrapf(ErrMalformedMessage, format, a...)
+	}
+}
+
+// Return the error that should be returned by a decoder, which is either the
+// given one or an error describing why the message is malformed. If whole is
+// true and the message is in strict mode, data left in the message body after
+// decoding is considered malformed too.
+func (m *Message) decodeError(err error, whole bool) error {
+	if m.err == nil && whole && m.strict && !m.hasBeenConsumed() {
+		m.malformed("%d trailing bytes", m.remaining())
+	}
+	if m.err != nil {
+		return m.err
+	}
+	return err
 }
 
 // Result holds the result of a statement.
@@ -437,6 +633,12 @@ type Result struct {
 // Rows holds a result set encoded in a message body.
 type Rows struct {
 	Columns []string
+
+	// Location of the returned timestamps, which is also used for text
+	// timestamps without a time zone. If nil, those are considered UTC
+	// and returned in local time.
+	Location *time.Location
+
 	message *Message
 	types   []uint8
 }
@@ -451,7 +653,7 @@ func (r *Rows) columnTypes(save bool) ([]uint8, error) {
 	// column types should never change between rows
 	// use cached copy to allow getting types when no more rows
 	if r.types == nil {
-		r.types = make([]uint8, len(r.Columns))
+		r.types = r.message.typesBuffer(len(r.Columns))
 	}
 
 	// Each column needs a 4 byte slot to store the column type. The row
@@ -466,11 +668,14 @@ func (r *Rows) columnTypes(save bool) ([]uint8, error) {
 
 	for i := 0; i < headerSize; i++ {
 		slot := r.message.getUint8()
+		if r.message.err != nil {
+			return r.types, r.message.err
+		}
 
 		if slot == 0xee {
 			// More rows are available.
 			if save {
-				r.message.bufferForGet().Advance(-(i + 1))
+				r.message.body.Advance(-(i + 1))
 			}
 			return r.types, ErrRowsPart
 		}
@@ -478,7 +683,7 @@ func (r *Rows) columnTypes(save bool) ([]uint8, error) {
 		if slot == 0xff {
 			// Rows EOF marker
 			if save {
-				r.message.bufferForGet().Advance(-(i + 1))
+				r.message.body.Advance(-(i + 1))
 			}
 			return r.types, io.EOF
 		}
@@ -500,12 +705,15 @@ func (r *Rows) columnTypes(save bool) ([]uint8, error) {
 		r.types[index] = slot >> 4
 	}
 	if save {
-		r.message.bufferForGet().Advance(-headerSize)
+		r.message.body.Advance(-headerSize)
 	}
 	return r.types, nil
 }
 
 // Next returns the next row in the result set.
+//
+// BLOB values are not copied: they point into the message buffer, so they are
+// valid only until the next call to Next or Close.
 func (r *Rows) Next(dest []driver.Value) error {
 	types, err := r.columnTypes(false)
 	if err != nil {
@@ -519,7 +727,7 @@ func (r *Rows) Next(dest []driver.Value) error {
 		case Float:
 			dest[i] = r.message.getFloat64()
 		case Blob:
-			dest[i] = r.message.getBlob()
+			dest[i] = r.message.getBlobBytes()
 		case Text:
 			dest[i] = r.message.getString()
 		case Null:
@@ -527,6 +735,9 @@ func (r *Rows) Next(dest []driver.Value) error {
 			dest[i] = nil
 		case UnixTime:
 			timestamp := time.Unix(r.message.getInt64(), 0)
+			if r.Location != nil {
+				timestamp = timestamp.In(r.Location)
+			}
 			dest[i] = timestamp
 		case ISO8601:
 			value := r.message.getString()
@@ -534,12 +745,16 @@ func (r *Rows) Next(dest []driver.Value) error {
 				dest[i] = time.Time{}
 				break
 			}
+			in, out := time.UTC, time.Local
+			if r.Location != nil {
+				in, out = r.Location, r.Location
+			}
 			var t time.Time
 			var timeVal time.Time
 			var err error
 			value = strings.TrimSuffix(value, "Z")
 			for _, format := range iso8601Formats {
-				if timeVal, err = time.ParseInLocation(format, value, time.UTC); err == nil {
+				if timeVal, err = time.ParseInLocation(format, value, in); err == nil {
 					t = timeVal
 					break
 				}
@@ -547,16 +762,16 @@ func (r *Rows) Next(dest []driver.Value) error {
 			if err != nil {
 				return err
 			}
-			t = t.In(time.Local)
+			t = t.In(out)
 			dest[i] = t
 		case Boolean:
 			dest[i] = r.message.getInt64() != 0
 		default:
-			panic("unknown data type")
+			r.message.malformed("unknown data type %d", types[i])
 		}
 	}
 
-	return nil
+	return r.message.err
 }
 
 // Close the result set and reset the underlying message.
@@ -592,13 +807,26 @@ func (f *Files) Next() (string, []byte) {
 	f.n--
 	name := f.message.getString()
 	length := f.message.getUint64()
-	data := make([]byte, length)
-	for i := 0; i < int(length); i++ {
-		data[i] = f.message.getUint8()
+	if length > uint64(f.message.remaining()) {
+		f.message.malformed("file size %d exceeds remaining %d bytes", length, f.message.remaining())
+	} else if limit := f.message.limits.DumpFileSize; limit > 0 && length > uint64(limit) {
+		f.message.malformed("file size %d exceeds limit of %d", length, limit)
+	}
+	b := f.message.consume(int(length))
+	if b == nil {
+		f.n = 0
+		return "", nil
 	}
+	data := make([]byte, length)
+	copy(data, b)
 	return name, data
 }
 
+// Err returns the error hit while decoding files, if any.
+func (f *Files) Err() error {
+	return f.message.err
+}
+
 func (f *Files) Close() {
 	f.message.reset()
 }
@@ -607,6 +835,7 @@ const (
 	messageWordSize                 = 8
 	messageWordBits                 = messageWordSize * 8
 	messageHeaderSize               = messageWordSize
+	messageMaxWords                 = math.MaxUint32 // The header holds the body size in 32 bits.
 	messageMaxConsecutiveEmptyReads = 100
 )
 
@@ -1,7 +1,13 @@
 package protocol
 
 import (
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
 	"testing"
 	"time"
 	"unsafe"
@@ -182,6 +188,25 @@ func TestMessage_putHeader(t *testing.T) {
 	message.putHeader(RequestExec)
 }
 
+// The word count of bodies larger than 4 GiB is not truncated.
+func TestMessage_putHeader_LargeBody(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("needs 64-bit ints")
+	}
+
+	message := Message{}
+	message.Init(64)
+
+	// Pretend the body was filled, without allocating it.
+	size := 5
+	size <<= 30
+	message.body.Offset = size
+	message.putHeader(RequestExec)
+
+	assert.Equal(t, uint32(size/messageWordSize), message.words)
+	assert.Equal(t, uint32(size/messageWordSize), binary.LittleEndian.Uint32(message.header))
+}
+
 func BenchmarkMessage_putString(b *testing.B) {
 	message := Message{}
 	message.Init(4096)
@@ -267,6 +292,26 @@ func TestMessage_getBlob(t *testing.T) {
 	}
 }
 
+// Blobs read without copying point into the message body.
+func TestMessage_getBlobBytes(t *testing.T) {
+	message := Message{}
+	message.Init(64)
+
+	message.putBlob([]byte{1, 2, 3, 4, 5})
+	message.putUint64(7)
+	message.putHeader(0)
+
+	message.Rewind()
+
+	b := message.getBlobBytes()
+	assert.Equal(t, []byte{1, 2, 3, 4, 5}, b)
+	assert.Equal(t, 5, cap(b))
+	assert.Equal(t, uint64(7), message.getUint64())
+
+	message.body.Bytes[8] = 9
+	assert.Equal(t, byte(9), b[0])
+}
+
 // The overflowing string ends exactly at word boundary.
 func TestMessage_getString_Overflow_WordBoundary(t *testing.T) {
 	message := Message{}
@@ -287,3 +332,356 @@ func TestMessage_getString_Overflow_WordBoundary(t *testing.T) {
 
 	assert.Equal(t, 32, message.body.Offset)
 }
+
+// When the body outgrows its initial buffer a pooled one is used, which gets
+// released when the message is reset.
+func TestMessage_PooledBody(t *testing.T) {
+	message := Message{}
+	message.Init(16)
+
+	blob := make([]byte, 5000)
+	blob[4999] = 1
+	message.putBlob(blob)
+	message.putHeader(0)
+
+	assert.True(t, message.pooled)
+	assert.Equal(t, 8192, len(message.body.Bytes))
+
+	message.Rewind()
+	assert.Equal(t, blob, message.getBlob())
+
+	message.reset()
+
+	assert.False(t, message.pooled)
+	assert.Equal(t, 16, len(message.body.Bytes))
+}
+
+func TestBufferSizeClass(t *testing.T) {
+	cases := []struct {
+		Size  int
+		Class int
+	}{
+		{1, 0},
+		{4096, 0},
+		{4097, 1},
+		{1 << 26, bufferPoolMaxShift - bufferPoolMinShift},
+		{1<<26 + 1, -1},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%d", c.Size), func(t *testing.T) {
+			assert.Equal(t, c.Class, bufferSizeClass(c.Size))
+		})
+	}
+}
+
+// Column names are re-used across result sets only if they match.
+func TestMessage_getRows_ColumnsCache(t *testing.T) {
+	message := Message{}
+	message.Init(64)
+
+	encode := func(columns ...string) {
+		message.reset()
+		message.putUint64(uint64(len(columns)))
+		for _, column := range columns {
+			message.putString(column)
+		}
+		message.putHeader(ResponseRows)
+		message.Rewind()
+	}
+
+	encode("a", "b")
+	rows1 := message.getRows()
+
+	encode("a", "b")
+	rows2 := message.getRows()
+
+	encode("a", "c")
+	rows3 := message.getRows()
+
+	assert.Equal(t, []string{"a", "b"}, rows1.Columns)
+	assert.Equal(t, []string{"a", "b"}, rows2.Columns)
+	assert.Equal(t, []string{"a", "c"}, rows3.Columns)
+	assert.Equal(t, &rows1.Columns[0], &rows2.Columns[0])
+}
+
+// Decoding truncated or malformed messages returns an error instead of
+// panicking.
+func TestMessage_Malformed(t *testing.T) {
+	cases := []struct {
+		Title  string
+		Type   uint8
+		Words  []uint64
+		Decode func(*Message) error
+	}{
+		{
+			"empty body",
+			ResponseNode,
+			nil,
+			func(m *Message) error { _, _, err := DecodeNode(m); return err },
+		},
+		{
+			"unterminated string",
+			ResponseNode,
+			[]uint64{1, 0x6161616161616161},
+			func(m *Message) error { _, _, err := DecodeNode(m); return err },
+		},
+		{
+			"huge server count",
+			ResponseNodes,
+			[]uint64{1 << 62},
+			func(m *Message) error { _, err := DecodeNodes(m); return err },
+		},
+		{
+			"truncated server",
+			ResponseNodes,
+			[]uint64{1, 1, 0x6161616161616161, 0x61},
+			func(m *Message) error { _, err := DecodeNodes(m); return err },
+		},
+		{
+			"huge column count",
+			ResponseRows,
+			[]uint64{1 << 62},
+			func(m *Message) error { _, err := DecodeRows(m); return err },
+		},
+		{
+			"failure without message",
+			ResponseFailure,
+			[]uint64{1},
+			func(m *Message) error { _, err := DecodeDb(m); return err },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Title, func(t *testing.T) {
+			message := newResponse(c.Type, c.Words)
+			err := c.Decode(&message)
+			assert.True(t, errors.Is(err, ErrMalformedMessage), err)
+		})
+	}
+}
+
+// Blobs whose declared size exceeds the message are rejected.
+func TestRows_Next_MalformedBlob(t *testing.T) {
+	message := newResponse(ResponseRows, []uint64{1, 0x61, Blob, 1 << 40})
+
+	rows, err := DecodeRows(&message)
+	require.NoError(t, err)
+
+	dest := make([]driver.Value, 1)
+	err = rows.Next(dest)
+	assert.True(t, errors.Is(err, ErrMalformedMessage), err)
+}
+
+// Text timestamps without a time zone are parsed in the rows location, if
+// set, or else in UTC.
+func TestRows_Next_Location(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+
+	for _, loc := range []*time.Location{nil, location} {
+		message := Message{}
+		message.Init(64)
+		message.putUint64(1)
+		message.putString("t")
+		message.putUint64(ISO8601)
+		message.putString("2020-01-02 03:04:05")
+		message.putUint64(math.MaxUint64)
+		message.putHeader(ResponseRows)
+		message.Rewind()
+
+		rows, err := DecodeRows(&message)
+		require.NoError(t, err)
+		rows.Location = loc
+
+		dest := make([]driver.Value, 1)
+		require.NoError(t, rows.Next(dest))
+
+		value := dest[0].(time.Time)
+		if loc == nil {
+			assert.True(t, value.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+			assert.Equal(t, time.Local, value.Location())
+		} else {
+			assert.True(t, value.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, location)))
+			assert.Equal(t, location, value.Location())
+		}
+	}
+}
+
+// In strict mode, trailing data is considered malformed.
+func TestMessage_StrictTrailingData(t *testing.T) {
+	message := newResponse(ResponseEmpty, []uint64{0, 0})
+	assert.NoError(t, DecodeEmpty(&message))
+
+	message = newResponse(ResponseEmpty, []uint64{0, 0})
+	message.strict = true
+	err := DecodeEmpty(&message)
+	assert.True(t, errors.Is(err, ErrMalformedMessage), err)
+}
+
+// Responses exceeding the decoder limits are malformed.
+func TestMessage_Limits(t *testing.T) {
+	limits := Limits{StringLength: 8, BlobSize: 8, TupleSize: 2, DumpFiles: 1, DumpFileSize: 8}
+	cases := []struct {
+		Title  string
+		Type   uint8
+		Words  []uint64
+		Decode func(*Message) error
+	}{
+		{
+			"long string",
+			ResponseNode,
+			[]uint64{1, 0x6161616161616161, 0x61},
+			func(m *Message) error { _, _, err := DecodeNode(m); return err },
+		},
+		{
+			"long failure description",
+			ResponseFailure,
+			[]uint64{1, 0x6161616161616161, 0x61},
+			func(m *Message) error { return DecodeEmpty(m) },
+		},
+		{
+			"many columns",
+			ResponseRows,
+			[]uint64{3, 0x61, 0x62, 0x63},
+			func(m *Message) error { _, err := DecodeRows(m); return err },
+		},
+		{
+			"many files",
+			ResponseFiles,
+			[]uint64{2, 0x61, 0, 0x62, 0},
+			func(m *Message) error { _, err := DecodeFiles(m); return err },
+		},
+		{
+			"large blob",
+			ResponseRows,
+			[]uint64{1, 0x61, Blob, 9, 0, 0},
+			func(m *Message) error {
+				rows, err := DecodeRows(m)
+				if err != nil {
+					return err
+				}
+				return rows.Next(make([]driver.Value, 1))
+			},
+		},
+		{
+			"large file",
+			ResponseFiles,
+			[]uint64{1, 0x61, 9, 0, 0},
+			func(m *Message) error {
+				files, err := DecodeFiles(m)
+				if err != nil {
+					return err
+				}
+				files.Next()
+				return files.Err()
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Title, func(t *testing.T) {
+			message := newResponse(c.Type, c.Words)
+			err := c.Decode(&message)
+			assert.False(t, errors.Is(err, ErrMalformedMessage), err)
+
+			message = newResponse(c.Type, c.Words)
+			message.limits = limits
+			err = c.Decode(&message)
+			assert.True(t, errors.Is(err, ErrMalformedMessage), err)
+		})
+	}
+
+	// Within the limits, responses are decoded.
+	message := newResponse(ResponseNode, []uint64{1, 0x6161616161616161, 0})
+	message.limits = limits
+	_, address, err := DecodeNode(&message)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaaa", address)
+}
+
+// Decoding random data fails gracefully, whatever the response type.
+func TestMessage_RandomData(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	limits := Limits{StringLength: 64, BlobSize: 256, TupleSize: 16, DumpFiles: 4, DumpFileSize: 256}
+	decoders := []func(*Message){
+		func(m *Message) { DecodeNode(m) },
+		func(m *Message) { DecodeNodes(m) },
+		func(m *Message) { DecodeStmt(m) },
+		func(m *Message) { DecodeMetadata(m) },
+		func(m *Message) {
+			rows, err := DecodeRows(m)
+			if err != nil || len(rows.Columns) == 0 {
+				return
+			}
+			dest := make([]driver.Value, len(rows.Columns))
+			for rows.Next(dest) == nil {
+			}
+		},
+		func(m *Message) {
+			files, err := DecodeFiles(m)
+			if err != nil {
+				return
+			}
+			for name, _ := files.Next(); name != ""; name, _ = files.Next() {
+			}
+		},
+	}
+
+	for i := 0; i < 1000; i++ {
+		words := make([]uint64, random.Intn(16))
+		for j := range words {
+			// Favor small values, which make plausible counts
+			// and sizes.
+			if random.Intn(2) == 0 {
+				words[j] = uint64(random.Intn(8))
+			} else {
+				words[j] = random.Uint64()
+			}
+		}
+		for _, mtype := range []uint8{ResponseFailure, ResponseNode, ResponseNodes, ResponseStmt, ResponseRows, ResponseFiles, ResponseMetadata} {
+			for _, decode := range decoders {
+				message := newResponse(mtype, words)
+				message.limits = limits
+				decode(&message)
+			}
+		}
+	}
+}
+
+// Return a response message of the given type with the given body words.
+func newResponse(mtype uint8, words []uint64) Message {
+	message := Message{}
+	message.Init(64)
+	for _, word := range words {
+		message.putUint64(word)
+	}
+	message.mtype = mtype
+	message.words = uint32(len(words))
+	message.Rewind()
+	return message
+}
+
+// More than 255 parameters are encoded with a 32-bit count, and the message is
+// marked with schema version 1.
+func TestMessage_putNamedValues_Many(t *testing.T) {
+	message := Message{}
+	message.Init(4096)
+
+	values := make(NamedValues, 300)
+	for i := range values {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: int64(i)}
+	}
+
+	message.putNamedValues(values)
+	message.putHeader(RequestExecSQL)
+
+	bytes, offset := message.Body()
+
+	assert.Equal(t, 304+300*8, offset)
+	assert.Equal(t, uint32(300), binary.LittleEndian.Uint32(bytes[0:]))
+	assert.Equal(t, byte(Integer), bytes[4])
+	assert.Equal(t, byte(Integer), bytes[303])
+
+	_, flags := message.getHeader()
+	assert.Equal(t, uint8(1), flags)
+}
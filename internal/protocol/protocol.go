@@ -6,33 +6,124 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/canonical/go-dqlite/internal/logging"
 )
 
 // Protocol sends and receive the dqlite message on the wire.
 type Protocol struct {
-	version uint64        // Protocol version
-	conn    net.Conn      // Underlying network connection.
-	closeCh chan struct{} // Stops the heartbeat when the connection gets closed
-	mu      sync.Mutex    // Serialize requests
-	netErr  error         // A network error occurred
+	sent         uint64           // Bytes written to the connection, first for atomic alignment.
+	received     uint64           // Bytes read from the connection.
+	latency      int64            // Smoothed network round trip time, in nanoseconds.
+	version      uint64           // Protocol version
+	conn         net.Conn         // Underlying network connection.
+	address      string           // Address the connection was opened to, if known.
+	closeCh      chan struct{}    // Stops the heartbeat when the connection gets closed
+	mu           sync.Mutex       // Serialize requests
+	netErr       error            // A network error occurred
+	partial      bool             // Whether rows of the last query are still to be received.
+	cancelled    int32            // Set when the context of the current query is done.
+	readTimeout  time.Duration    // Max time to wait for a single read, 0 for no limit.
+	writeTimeout time.Duration    // Max time to wait for a request to be written, 0 for no limit.
+	limits       Limits           // Limits of the decoded responses.
+	strict       bool             // Whether to decode responses in strict mode.
+	deadline     time.Time        // Deadline of the context of the current call, if any.
+	iovecs       [2][]byte        // Header and body of the request being sent.
+	writev       net.Buffers      // Re-usable writev buffers, pointing to iovecs.
+	stats        *Stats           // Activity counters, if enabled.
+	budget       *Budget          // Budget of response buffers, if set.
+	log          logging.Func     // Log function, if set.
+	interceptor  Interceptor      // Wraps each call, if set.
+	timeouts     CallTimeouts     // Timeouts of calls without deadline.
+	callStats    CallStatsFunc    // Invoked after each call, if set.
+	adaptive     *AdaptiveTimeout // Timeout of calls without deadline, if set.
+	created      time.Time        // When the connection was established.
+	pooled       time.Time        // When the connection was last put among the standby ones.
 }
 
+// Interceptor is invoked for each call made with Protocol.Call, with the
+// description of the request, like "leader" or "exec-sql", and a function
+// performing the call, which it must invoke to send the request. It can be
+// invoked again to retry the call.
+type Interceptor func(ctx context.Context, request string, invoke func(ctx context.Context) error) error
+
 func newProtocol(version uint64, conn net.Conn) *Protocol {
 	protocol := &Protocol{
 		version: version,
 		conn:    conn,
 		closeCh: make(chan struct{}),
+		created: time.Now(),
 	}
 
 	return protocol
 }
 
+// Address returns the address of the node that the connection was opened to
+// by a Connector, as found in its node store, or an empty string if the
+// connection was established in another way.
+func (p *Protocol) Address() string {
+	return p.address
+}
+
+// SetTimeouts sets the maximum amount of time to wait for a single read from
+// the connection to complete and for a request to be fully written to it.
+//
+// The read timeout is refreshed after every successful read, so a response
+// that keeps streaming in is never interrupted as long as data keeps
+// arriving. In any case the deadline of the context passed to Call() is
+// always honored. A zero value means no timeout.
+func (p *Protocol) SetTimeouts(read, write time.Duration) {
+	p.readTimeout = read
+	p.writeTimeout = write
+}
+
+// SetMaxMessageSize sets the maximum size of the body of a response message
+// that the server is allowed to send. If a message exceeds it, the
+// connection is aborted and ErrMessageTooLarge is returned. A zero value
+// means no limit.
+func (p *Protocol) SetMaxMessageSize(size int) {
+	p.limits.MessageSize = size
+}
+
+// SetLogFunc sets the function used to log events of the connection, such as
+// the connection being lost.
+func (p *Protocol) SetLogFunc(log logging.Func) {
+	p.log = log
+}
+
+// SetStrictDecoding enables or disables strict decoding of response messages.
+//
+// Decoders always validate lengths and return ErrMalformedMessage if a
+// message is truncated. In strict mode, a response that contains data beyond
+// the fields expected by its decoder is considered malformed as well.
+func (p *Protocol) SetStrictDecoding(strict bool) {
+	p.strict = strict
+}
+
+// SetInterceptor sets a function wrapping each call, nil to disable it.
+func (p *Protocol) SetInterceptor(interceptor Interceptor) {
+	p.interceptor = interceptor
+}
+
 // Call invokes a dqlite RPC, sending a request message and receiving a
 // response message.
-func (p *Protocol) Call(ctx context.Context, request, response *Message) (err error) {
+func (p *Protocol) Call(ctx context.Context, request, response *Message) error {
+	ctx, cancel := p.callContext(ctx, request.mtype)
+	defer cancel()
+
+	if p.interceptor == nil {
+		return p.call(ctx, request, response)
+	}
+	return p.interceptor(ctx, requestDesc(request.mtype), func(ctx context.Context) error {
+		return p.call(ctx, request, response)
+	})
+}
+
+func (p *Protocol) call(ctx context.Context, request, response *Message) (err error) {
 	// We need to take a lock since the dqlite server currently does not
 	// support concurrent requests.
 	p.mu.Lock()
@@ -42,65 +133,199 @@ func (p *Protocol) Call(ctx context.Context, request, response *Message) (err er
 		return p.netErr
 	}
 
-	defer func() {
-		if err == nil {
-			return
-		}
-		switch errors.Cause(err).(type) {
-		case *net.OpError:
-			p.netErr = err
-		}
-	}()
+	return p.roundTrip(ctx, request, response)
+}
+
+// Send the given request and receive its response. The lock must be held.
+func (p *Protocol) roundTrip(ctx context.Context, request, response *Message) (err error) {
+	report := p.measureCall(request.mtype, 1)
+	defer func() { report(err) }()
+
+	defer func() { err = p.check(err) }()
 
 	var budget time.Duration
 
 	// Honor the ctx deadline, if present.
 	if deadline, ok := ctx.Deadline(); ok {
-		p.conn.SetDeadline(deadline)
 		budget = time.Until(deadline)
-		defer p.conn.SetDeadline(time.Time{})
 	}
+	p.setContextDeadline(ctx)
+	defer p.resetDeadline()
 
 	desc := requestDesc(request.mtype)
+	p.countRoundTrip()
+	defer p.countInFlight()()
 
 	if err = p.send(request); err != nil {
 		return errors.Wrapf(err, "call %s (budget %s): send", desc, budget)
 	}
 
-	if err = p.recv(response); err != nil {
+	if isQuery(request.mtype) {
+		err = p.recvQuery(ctx, request, response)
+	} else {
+		err = p.recv(response)
+	}
+	if err != nil {
+		if err == ctx.Err() {
+			return err
+		}
 		return errors.Wrapf(err, "call %s (budget %s): receive", desc, budget)
 	}
+	p.partial = hasMoreRows(response)
 
 	return
 }
 
+// CallBatch sends all the given requests and receives their responses in
+// order, without waiting for a response before sending the next request, so
+// the whole batch takes a single network round trip.
+//
+// Requests are sent from a separate goroutine while responses are received,
+// so large batches can't fill both socket buffers and deadlock. If any
+// request can't be sent or any response can't be received, the connection is
+// closed, since it's not in sync anymore. An empty batch is a no-op.
+func (p *Protocol) CallBatch(ctx context.Context, requests, responses []*Message) (err error) {
+	if len(requests) != len(responses) {
+		panic("requests and responses have different lengths")
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	ctx, cancel := p.callContext(ctx, requests[0].mtype)
+	defer cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.netErr != nil {
+		return p.netErr
+	}
+
+	report := p.measureCall(requests[0].mtype, len(requests))
+	defer func() { report(err) }()
+
+	p.setContextDeadline(ctx)
+	defer p.resetDeadline()
+	p.countRoundTrip()
+	defer p.countInFlight()()
+
+	sent := make(chan error, 1)
+	go func() {
+		for _, request := range requests {
+			if err := p.send(request); err != nil {
+				p.conn.Close()
+				sent <- errors.Wrapf(err, "call batch %s: send", requestDesc(request.mtype))
+				return
+			}
+		}
+		sent <- nil
+	}()
+
+	var recvErr error
+	for _, response := range responses {
+		if recvErr = p.recv(response); recvErr != nil {
+			p.conn.Close()
+			break
+		}
+	}
+
+	if err = <-sent; err == nil && recvErr != nil {
+		err = errors.Wrap(recvErr, "call batch: receive")
+	}
+	if err != nil {
+		err = p.broken(err)
+	} else {
+		p.partial = hasMoreRows(responses[len(responses)-1])
+	}
+
+	return err
+}
+
+// Mark the connection as unusable because of the given error, returning it
+// wrapped so it matches ErrConnectionDead.
+func (p *Protocol) broken(err error) error {
+	p.netErr = deadError{err: err}
+	if p.log != nil {
+		p.log(logging.Warn, "connection to %s lost: %v", p.conn.RemoteAddr(), err)
+	}
+	return p.netErr
+}
+
+// Mark the connection as unusable if the given error of a call left it out
+// of sync with the server.
+func (p *Protocol) check(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch errors.Cause(err).(type) {
+	case *net.OpError:
+		return p.broken(err)
+	case ErrMessageTooLarge:
+		return p.broken(err)
+	}
+	if errors.Cause(err) == ErrBudgetTimeout {
+		return p.broken(err)
+	}
+	return err
+}
+
 // More is used when a request maps to multiple responses.
 func (p *Protocol) More(ctx context.Context, response *Message) error {
-	return p.recv(response)
+	ctx, cancel := p.callContext(ctx, RequestQuery)
+	defer cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.netErr != nil {
+		return p.netErr
+	}
+
+	p.setContextDeadline(ctx)
+	defer p.resetDeadline()
+
+	report := p.measureCall(RequestQuery, 0)
+	err := p.check(p.recv(response))
+	report(err)
+	if err == nil {
+		p.partial = hasMoreRows(response)
+	}
+
+	return err
 }
 
 // Interrupt sends an interrupt request and awaits for the server's empty
 // response.
-func (p *Protocol) Interrupt(ctx context.Context, request *Message, response *Message) error {
+func (p *Protocol) Interrupt(ctx context.Context, request *Message, response *Message) (err error) {
+	ctx, cancel := p.callContext(ctx, RequestInterrupt)
+	defer cancel()
+
 	// We need to take a lock since the dqlite server currently does not
 	// support concurrent requests.
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Honor the ctx deadline, if present.
-	if deadline, ok := ctx.Deadline(); ok {
-		p.conn.SetDeadline(deadline)
-		defer p.conn.SetDeadline(time.Time{})
-	}
+	p.setContextDeadline(ctx)
+	defer p.resetDeadline()
 
+	return p.interrupt(request, response)
+}
+
+// Send an interrupt request and drain the responses until the empty one. The
+// lock must be held.
+func (p *Protocol) interrupt(request *Message, response *Message) (err error) {
 	EncodeInterrupt(request, 0)
+	p.countRoundTrip()
+	report := p.measureCall(RequestInterrupt, 1)
+	defer func() { report(err) }()
 
-	if err := p.send(request); err != nil {
+	if err = p.send(request); err != nil {
 		return errors.Wrap(err, "failed to send interrupt request")
 	}
 
 	for {
-		if err := p.recv(response); err != nil {
+		if err = p.recv(response); err != nil {
 			return errors.Wrap(err, "failed to receive response")
 		}
 
@@ -110,6 +335,7 @@ func (p *Protocol) Interrupt(ctx context.Context, request *Message, response *Me
 			break
 		}
 	}
+	p.partial = false
 
 	return nil
 }
@@ -120,40 +346,56 @@ func (p *Protocol) Close() error {
 	return p.conn.Close()
 }
 
-func (p *Protocol) send(req *Message) error {
-	if err := p.sendHeader(req); err != nil {
-		return errors.Wrap(err, "header")
+// Honor the ctx deadline, if present.
+func (p *Protocol) setContextDeadline(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		p.deadline = deadline
 	}
+}
 
-	if err := p.sendBody(req); err != nil {
-		return errors.Wrap(err, "body")
-	}
+// Clear any deadline set on the connection.
+func (p *Protocol) resetDeadline() {
+	p.deadline = time.Time{}
+	p.conn.SetDeadline(time.Time{})
+}
 
-	return nil
+// Return the deadline that a single I/O operation should honor, given its
+// timeout and the deadline of the current call.
+func (p *Protocol) ioDeadline(timeout time.Duration) time.Time {
+	if timeout == 0 {
+		return p.deadline
+	}
+	deadline := time.Now().Add(timeout)
+	if !p.deadline.IsZero() && p.deadline.Before(deadline) {
+		return p.deadline
+	}
+	return deadline
 }
 
-func (p *Protocol) sendHeader(req *Message) error {
-	n, err := p.conn.Write(req.header[:])
-	if err != nil {
-		return err
+func (p *Protocol) send(req *Message) error {
+	if uint64(req.body.Offset/messageWordSize) > messageMaxWords {
+		return errors.Errorf("request body of %d bytes exceeds the maximum message size", req.body.Offset)
 	}
 
-	if n != messageHeaderSize {
-		return io.ErrShortWrite
+	if err := p.conn.SetWriteDeadline(p.ioDeadline(p.writeTimeout)); err != nil {
+		return errors.Wrap(err, "set write deadline")
 	}
 
-	return nil
-}
+	// Write the header and the body with a single writev call. The
+	// buffers are stored in the protocol object to avoid allocations.
+	p.iovecs[0] = req.header
+	p.iovecs[1] = req.body.Bytes[:req.body.Offset]
+	p.writev = p.iovecs[:]
+	size := int64(messageHeaderSize + req.body.Offset)
 
-func (p *Protocol) sendBody(req *Message) error {
-	buf := req.body.Bytes[:req.body.Offset]
-	n, err := p.conn.Write(buf)
+	n, err := p.writev.WriteTo(p.conn)
+	p.countSent(n)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "write")
 	}
 
-	if n != len(buf) {
-		return io.ErrShortWrite
+	if n != size {
+		return errors.Wrap(io.ErrShortWrite, "write")
 	}
 
 	return nil
@@ -182,6 +424,8 @@ func (p *Protocol) recvHeader(res *Message) error {
 	res.mtype = res.header[4]
 	res.flags = res.header[5]
 	res.extra = binary.LittleEndian.Uint16(res.header[6:])
+	res.strict = p.strict
+	res.limits = p.limits
 
 	return nil
 }
@@ -189,10 +433,18 @@ func (p *Protocol) recvHeader(res *Message) error {
 func (p *Protocol) recvBody(res *Message) error {
 	n := int(res.words) * messageWordSize
 
-	for n > len(res.body.Bytes) {
+	if limit := p.limits.MessageSize; limit > 0 && n > limit {
+		// We can't consume the rest of the message, so the connection
+		// is not usable anymore.
+		p.conn.Close()
+		return ErrMessageTooLarge{Size: n, Limit: limit}
+	}
+
+	if n > len(res.body.Bytes) {
 		// Grow message buffer.
-		bytes := make([]byte, len(res.body.Bytes)*2)
-		res.body.Bytes = bytes
+		if err := p.growResponse(res, n); err != nil {
+			return err
+		}
 	}
 
 	buf := res.body.Bytes[:n]
@@ -223,10 +475,20 @@ func (p *Protocol) recvFill(buf []byte) (int, error) {
 	//
 	// This technique is copied from bufio.Reader.
 	for i := messageMaxConsecutiveEmptyReads; i > 0; i-- {
+		// Refresh the read deadline, so the read timeout applies to
+		// each individual read.
+		if err := p.conn.SetReadDeadline(p.ioDeadline(p.readTimeout)); err != nil {
+			return -1, err
+		}
+		if atomic.LoadInt32(&p.cancelled) != 0 {
+			// Don't undo the deadline set by watchContext.
+			p.conn.SetReadDeadline(aLongTimeAgo)
+		}
 		n, err := p.conn.Read(buf)
 		if n < 0 {
 			panic(errNegativeRead)
 		}
+		p.countReceived(n)
 		if err != nil {
 			return -1, err
 		}
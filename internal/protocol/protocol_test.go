@@ -2,11 +2,16 @@ package protocol_test
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/canonical/go-dqlite/internal/logging"
 	"github.com/canonical/go-dqlite/internal/protocol"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -80,6 +85,245 @@ func TestProtocol_Prepare(t *testing.T) {
 	assert.Equal(t, uint64(0), params)
 }
 
+// The read timeout applies to each individual read, so a response that keeps
+// streaming in slowly is not aborted.
+func TestProtocol_ReadTimeoutRefreshed(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	p.SetTimeouts(100*time.Millisecond, 0)
+
+	go func() {
+		readRequest(t, server)
+		// Send the header and the body of an empty response separately,
+		// with a total delay greater than the read timeout.
+		time.Sleep(60 * time.Millisecond)
+		server.Write([]byte{1, 0, 0, 0, protocol.ResponseEmpty, 0, 0, 0})
+		time.Sleep(60 * time.Millisecond)
+		server.Write(make([]byte, 8))
+	}()
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+
+	require.NoError(t, p.Call(context.Background(), &request, &response))
+	require.NoError(t, protocol.DecodeEmpty(&response))
+}
+
+// If no data is received within the read timeout, the call fails.
+func TestProtocol_ReadTimeout(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	p.SetTimeouts(50*time.Millisecond, 0)
+
+	go readRequest(t, server)
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+
+	err := p.Call(context.Background(), &request, &response)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "call leader (budget 0s): receive: header")
+}
+
+// If the request can't be written within the write timeout, the call fails.
+func TestProtocol_WriteTimeout(t *testing.T) {
+	p, _ := newPipeProtocol(t)
+	defer p.Close()
+
+	p.SetTimeouts(0, 50*time.Millisecond)
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+
+	err := p.Call(context.Background(), &request, &response)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "call leader (budget 0s): send: write")
+}
+
+// If the server sends a message bigger than the maximum size, the connection
+// is aborted.
+func TestProtocol_MaxMessageSize(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	p.SetMaxMessageSize(1024)
+
+	go func() {
+		readRequest(t, server)
+		server.Write([]byte{0, 0, 0, 1, protocol.ResponseRows, 0, 0, 0})
+	}()
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+
+	err := p.Call(context.Background(), &request, &response)
+	require.Error(t, err)
+
+	expected := protocol.ErrMessageTooLarge{Size: 1 << 27, Limit: 1024}
+	assert.Equal(t, expected, errors.Cause(err))
+
+	// Subsequent calls fail immediately.
+	err = p.Call(context.Background(), &request, &response)
+	assert.Equal(t, expected, errors.Cause(err))
+}
+
+// If the server sends a part of a result set bigger than the maximum size,
+// the connection is aborted as well.
+func TestProtocol_MoreMaxMessageSize(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	p.SetMaxMessageSize(1024)
+
+	go func() {
+		server.Write([]byte{0, 0, 0, 1, protocol.ResponseRows, 0, 0, 0})
+	}()
+
+	_, response := newMessagePair(64, 64)
+
+	err := p.More(context.Background(), &response)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, protocol.ErrConnectionDead), err.Error())
+
+	// Subsequent calls fail immediately.
+	request, _ := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+	err = p.Call(context.Background(), &request, &response)
+	assert.True(t, errors.Is(err, protocol.ErrConnectionDead), err.Error())
+}
+
+// Idle connections are pinged, and marked as dead once a ping fails.
+func TestProtocol_KeepAlive(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	pinged := make(chan struct{})
+	go func() {
+		readRequest(t, server)
+		server.Write([]byte{2, 0, 0, 0, protocol.ResponseNode, 0, 0, 0})
+		server.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 'a', 0, 0, 0, 0, 0, 0, 0})
+		close(pinged)
+
+		readRequest(t, server)
+		server.Close()
+	}()
+
+	p.SetKeepAlive(10 * time.Millisecond)
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("no ping sent")
+	}
+	assert.False(t, p.Dead())
+
+	for i := 0; i < 100 && !p.Dead(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, p.Dead())
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeLeader(&request)
+	err := p.Call(context.Background(), &request, &response)
+	assert.True(t, errors.Is(err, protocol.ErrConnectionDead))
+}
+
+// No ping is sent while the rows of a query are still pending.
+func TestProtocol_KeepAlivePendingRows(t *testing.T) {
+	p, server := newPipeProtocol(t)
+	defer p.Close()
+
+	go func() {
+		readMessage(server)
+		server.Write([]byte{3, 0, 0, 0, protocol.ResponseRows, 0, 0, 0})
+		server.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 'n', 0, 0, 0, 0, 0, 0, 0})
+		server.Write([]byte{0xee, 0xee, 0xee, 0xee, 0xee, 0xee, 0xee, 0xee})
+	}()
+
+	request, response := newMessagePair(64, 64)
+	protocol.EncodeQuerySQL(&request, 0, "SELECT n FROM t", nil)
+	makeCall(t, p, &request, &response)
+
+	p.SetKeepAlive(5 * time.Millisecond)
+
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := server.Read(make([]byte, 8))
+	netErr, ok := err.(net.Error)
+	assert.True(t, ok && netErr.Timeout(), "unexpected read: %v", err)
+	assert.False(t, p.Dead())
+}
+
+// Steady-state exec round trips don't allocate.
+func BenchmarkProtocol_Exec(b *testing.B) {
+	// Result response with last insert ID 1 and rows affected 1.
+	response := []byte{
+		2, 0, 0, 0, protocol.ResponseResult, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+	}
+	p, cleanup := newBenchmarkProtocol(b, response)
+	defer cleanup()
+
+	request, res := newMessagePair(64, 64)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		protocol.EncodeExecSQL(&request, 0, "INSERT INTO test VALUES(1)", nil)
+		if err := p.Call(ctx, &request, &res); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := protocol.DecodeResult(&res); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Steady-state query round trips don't allocate.
+func BenchmarkProtocol_Query(b *testing.B) {
+	// Rows response with a single integer column "n" and a single row
+	// holding the value 1.
+	response := []byte{
+		5, 0, 0, 0, protocol.ResponseRows, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		'n', 0, 0, 0, 0, 0, 0, 0,
+		protocol.Integer, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+	p, cleanup := newBenchmarkProtocol(b, response)
+	defer cleanup()
+
+	request, res := newMessagePair(64, 64)
+	ctx := context.Background()
+	dest := make([]driver.Value, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		protocol.EncodeQuerySQL(&request, 0, "SELECT n FROM test", nil)
+		if err := p.Call(ctx, &request, &res); err != nil {
+			b.Fatal(err)
+		}
+		rows, err := protocol.DecodeRows(&res)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := rows.Next(dest); err != nil {
+			b.Fatal(err)
+		}
+		if err := rows.Next(dest); err != io.EOF {
+			b.Fatal(err)
+		}
+		rows.Close()
+	}
+}
+
 /*
 func TestProtocol_Exec(t *testing.T) {
 	client, cleanup := newProtocol(t)
@@ -168,6 +412,72 @@ func newProtocol(t *testing.T) (*protocol.Protocol, func()) {
 	return client, cleanup
 }
 
+// Return a protocol object connected to an in-memory pipe, along with the
+// server end of the pipe.
+func newPipeProtocol(t *testing.T) (*protocol.Protocol, net.Conn) {
+	t.Helper()
+
+	client, server := net.Pipe()
+
+	go func() {
+		handshake := make([]byte, 8)
+		io.ReadFull(server, handshake)
+	}()
+
+	p, err := protocol.Handshake(context.Background(), client, protocol.VersionOne)
+	require.NoError(t, err)
+
+	return p, server
+}
+
+// Return a protocol object connected to an in-memory server which replies to
+// every request with the given canned response.
+func newBenchmarkProtocol(b *testing.B, response []byte) (*protocol.Protocol, func()) {
+	b.Helper()
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		buf := make([]byte, 4096)
+		if _, err := io.ReadFull(server, buf[:8]); err != nil {
+			return
+		}
+		for {
+			if _, err := io.ReadFull(server, buf[:8]); err != nil {
+				return
+			}
+			n := int(binary.LittleEndian.Uint32(buf)) * 8
+			if _, err := io.ReadFull(server, buf[:n]); err != nil {
+				return
+			}
+			if _, err := server.Write(response); err != nil {
+				return
+			}
+		}
+	}()
+
+	p, err := protocol.Handshake(context.Background(), client, protocol.VersionOne)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	cleanup := func() {
+		p.Close()
+		server.Close()
+		<-done
+	}
+
+	return p, cleanup
+}
+
+// Read a single request with an 8-byte body from the server end of a pipe.
+func readRequest(t *testing.T, server net.Conn) {
+	buf := make([]byte, 16)
+	io.ReadFull(server, buf)
+}
+
 // Perform a client call.
 func makeCall(t *testing.T, p *protocol.Protocol, request, response *protocol.Message) {
 	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
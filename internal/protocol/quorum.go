@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLeaderNotConfirmed is returned by VerifyLeader when a majority of the
+// voters doesn't confirm the leader.
+var ErrLeaderNotConfirmed = errors.New("leadership not confirmed by a majority of voters")
+
+// VerifyLeader checks that the node with the given address is still the
+// leader, by asking the other voters in the store who the leader is and
+// checking that, counting the leader itself, a majority of them agree.
+//
+// Since a new leader can't commit anything without a majority of voters
+// following it, a read served by the leader before a successful check can't
+// miss writes committed by a newer leader.
+//
+// The connections to the voters are kept open and shared by concurrent
+// checks, so a check normally costs a single Leader request to each voter.
+func (c *Connector) VerifyLeader(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	servers, err := c.store.Get(ctx)
+	if err != nil {
+		return errors.Wrap(err, "get servers")
+	}
+
+	voters := 1 // The leader itself.
+	others := []string{}
+	for _, server := range servers {
+		if server.Role != Voter || server.Address == address {
+			continue
+		}
+		voters++
+		others = append(others, server.Address)
+	}
+	c.pruneVoters(others)
+
+	needed := voters / 2 // Confirmations needed besides the leader's own.
+	if needed == 0 {
+		return nil
+	}
+
+	results := make(chan error, len(others))
+	for _, other := range others {
+		go func(other string) {
+			results <- c.confirmLeader(ctx, other, address)
+		}(other)
+	}
+
+	var last error
+	pending := len(others)
+	for pending > 0 {
+		err := <-results
+		pending--
+		if err == nil {
+			needed--
+			if needed == 0 {
+				return nil
+			}
+			continue
+		}
+		last = err
+		if pending < needed {
+			break
+		}
+	}
+
+	return errors.Wrapf(ErrLeaderNotConfirmed, "%v", last)
+}
+
+// Ask the node with the given address who the leader is, failing unless it's
+// the expected one.
+func (c *Connector) confirmLeader(ctx context.Context, address, leader string) error {
+	protocol, shared, err := c.voter(ctx, address)
+	if err != nil {
+		return errors.Wrapf(err, "server %s", address)
+	}
+	if !shared {
+		defer protocol.Close()
+	}
+
+	request := Message{}
+	request.Init(16)
+	response := Message{}
+	response.Init(512)
+
+	EncodeLeader(&request)
+
+	if err := protocol.Call(ctx, &request, &response); err != nil {
+		c.dropVoter(address, protocol)
+		return errors.Wrapf(err, "server %s", address)
+	}
+
+	_, reported, err := DecodeNodeCompat(protocol, &response)
+	if err != nil {
+		c.dropVoter(address, protocol)
+		return errors.Wrapf(err, "server %s", address)
+	}
+	reported = inheritScheme(c.config.Translate.Translate(reported), address)
+	if reported != leader {
+		return errors.Errorf("server %s: reports %q as leader", address, reported)
+	}
+
+	return nil
+}
+
+// Return the connection to the voter with the given address, opening it if
+// there's none or if it's dead or older than the max lifetime. The returned
+// flag tells whether the connection is shared, otherwise it must be closed
+// after use, because the connector was closed in the meantime.
+func (c *Connector) voter(ctx context.Context, address string) (*Protocol, bool, error) {
+	c.mu.Lock()
+	protocol := c.voters[address]
+	generation := c.generation
+	c.mu.Unlock()
+
+	if protocol != nil {
+		expired := c.config.MaxLifetime > 0 && time.Since(protocol.created) >= c.config.MaxLifetime
+		if !expired && !protocol.Dead() {
+			return protocol, true, nil
+		}
+		c.dropVoter(address, protocol)
+	}
+
+	protocol, err := c.open(ctx, address, VersionOne)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return protocol, false, nil
+	}
+	if existing := c.voters[address]; existing != nil {
+		// Opened concurrently by another check.
+		protocol.Close()
+		return existing, true, nil
+	}
+	if c.voters == nil {
+		c.voters = map[string]*Protocol{}
+	}
+	c.voters[address] = protocol
+
+	return protocol, true, nil
+}
+
+// Forget and close the given connection to the voter with the given address,
+// unless it was dropped already. Connections are closed by whoever removes
+// them from the shared ones.
+func (c *Connector) dropVoter(address string, protocol *Protocol) {
+	c.mu.Lock()
+	owned := c.voters[address] == protocol
+	if owned {
+		delete(c.voters, address)
+	}
+	c.mu.Unlock()
+
+	if owned {
+		protocol.Close()
+	}
+}
+
+// Close the connections to nodes that are not among the given voters
+// anymore.
+func (c *Connector) pruneVoters(voters []string) {
+	keep := make(map[string]bool, len(voters))
+	for _, address := range voters {
+		keep[address] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, protocol := range c.voters {
+		if !keep[address] {
+			delete(c.voters, address)
+			protocol.Close()
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package protocol
+
+// EncodeRaw encodes a request of the given type with a body encoded by the
+// caller, whose size must be a positive multiple of the word size.
+func EncodeRaw(request *Message, mtype uint8, body []byte) {
+	request.reset()
+	b := request.bufferForPut(len(body))
+	copy(b.Bytes[b.Offset:], body)
+	b.Advance(len(body))
+
+	request.putHeader(mtype)
+}
+
+// DecodeRaw returns the type of a response and a copy of its body. Failure
+// responses are decoded and returned as ErrRequest.
+func DecodeRaw(response *Message) (uint8, []byte, error) {
+	mtype, _ := response.getHeader()
+
+	if mtype == ResponseFailure {
+		e := ErrRequest{}
+		e.Code = response.getUint64()
+		e.Description = response.getString()
+		return mtype, nil, response.decodeError(e, false)
+	}
+
+	body := make([]byte, response.remaining())
+	copy(body, response.consume(len(body)))
+
+	return mtype, body, nil
+}
@@ -15,7 +15,7 @@ func DecodeFailure(response *Message) (code uint64, message string, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -27,6 +27,8 @@ func DecodeFailure(response *Message) (code uint64, message string, err error) {
 	code = response.getUint64()
 	message = response.getString()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -38,7 +40,7 @@ func DecodeWelcome(response *Message) (heartbeatTimeout uint64, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -49,6 +51,8 @@ func DecodeWelcome(response *Message) (heartbeatTimeout uint64, err error) {
 
 	heartbeatTimeout = response.getUint64()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -60,7 +64,7 @@ func DecodeNodeLegacy(response *Message) (address string, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -71,6 +75,8 @@ func DecodeNodeLegacy(response *Message) (address string, err error) {
 
 	address = response.getString()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -82,7 +88,7 @@ func DecodeNode(response *Message) (id uint64, address string, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -94,6 +100,8 @@ func DecodeNode(response *Message) (id uint64, address string, err error) {
 	id = response.getUint64()
 	address = response.getString()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -105,7 +113,7 @@ func DecodeNodes(response *Message) (servers Nodes, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -116,6 +124,8 @@ func DecodeNodes(response *Message) (servers Nodes, err error) {
 
 	servers = response.getNodes()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -127,7 +137,7 @@ func DecodeDb(response *Message) (id uint32, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -139,6 +149,8 @@ func DecodeDb(response *Message) (id uint32, err error) {
 	id = response.getUint32()
 	response.getUint32()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -150,7 +162,7 @@ func DecodeStmt(response *Message) (db uint32, id uint32, params uint64, err err
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -163,6 +175,8 @@ func DecodeStmt(response *Message) (db uint32, id uint32, params uint64, err err
 	id = response.getUint32()
 	params = response.getUint64()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -174,7 +188,7 @@ func DecodeEmpty(response *Message) (err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -185,6 +199,8 @@ func DecodeEmpty(response *Message) (err error) {
 
 	response.getUint64()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -196,7 +212,7 @@ func DecodeResult(response *Message) (result Result, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -207,6 +223,8 @@ func DecodeResult(response *Message) (result Result, err error) {
 
 	result = response.getResult()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
 
@@ -218,7 +236,7 @@ func DecodeRows(response *Message) (rows Rows, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -229,6 +247,8 @@ func DecodeRows(response *Message) (rows Rows, err error) {
 
 	rows = response.getRows()
 
+	err = response.decodeError(nil, false)
+
 	return
 }
 
@@ -240,7 +260,7 @@ func DecodeFiles(response *Message) (files Files, err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -251,6 +271,8 @@ func DecodeFiles(response *Message) (files Files, err error) {
 
 	files = response.getFiles()
 
+	err = response.decodeError(nil, false)
+
 	return
 }
 
@@ -262,7 +284,7 @@ func DecodeMetadata(response *Message) (failureDomain uint64, weight uint64, err
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -274,5 +296,7 @@ func DecodeMetadata(response *Message) (failureDomain uint64, weight uint64, err
 	failureDomain = response.getUint64()
 	weight = response.getUint64()
 
+	err = response.decodeError(nil, true)
+
 	return
 }
@@ -109,7 +109,7 @@ func Decode${cmd}(response *Message) (${returns}err error) {
 		e := ErrRequest{}
 		e.Code = response.getUint64()
 		e.Description = response.getString()
-                err = e
+                err = response.decodeError(e, false)
                 return
 	}
 
@@ -136,8 +136,20 @@ EOF
 EOF
     done
 
+    # Result sets and files are consumed incrementally after decoding.
+    whole="true"
+    for i in "${@}"
+    do
+	type=$(echo "$i" | cut -f 2 -d :)
+	if [ "$type" = "Rows" ] || [ "$type" = "Files" ]; then
+	    whole="false"
+	fi
+    done
+
     cat >> response.go <<EOF
 
+	err = response.decodeError(nil, ${whole})
+
 	return
 }
 EOF
@@ -0,0 +1,225 @@
+package protocol
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/canonical/go-dqlite/internal/logging"
+)
+
+// Try the standby connections, returning the one to the leader along with
+// the leader information, or nil if none of them is connected to the leader.
+//
+// Each connection is probed with a single Leader request. The connections to
+// nodes that are not the leader are kept, and the broken ones are closed.
+func (c *Connector) connectAttemptStandby(ctx context.Context, log logging.Func) (*Protocol, NodeInfo) {
+	c.mu.Lock()
+	standby := c.standby
+	generation := c.generation
+	c.standby = nil
+	c.mu.Unlock()
+
+	addresses := make([]string, 0, len(standby))
+	for address := range standby {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	var found *Protocol
+	var leader NodeInfo
+	keep := map[string]*Protocol{}
+	for _, address := range addresses {
+		protocol := standby[address]
+		if found != nil {
+			keep[address] = protocol
+			continue
+		}
+		if c.expiredStandby(protocol) {
+			log(logging.Debug, "standby %s: expired", address)
+			protocol.Close()
+			continue
+		}
+		info, err := c.probeStandby(ctx, address, protocol)
+		if err != nil {
+			log(logging.Debug, "standby %s: %v", address, err)
+			protocol.Close()
+			continue
+		}
+		if info.Address != address {
+			protocol.pooled = time.Now()
+			keep[address] = protocol
+			continue
+		}
+		log(logging.Debug, "standby %s: connected", address)
+		found = protocol
+		leader = info
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for address, protocol := range keep {
+		if c.generation != generation || c.standby[address] != nil {
+			protocol.Close()
+			continue
+		}
+		c.putStandby(address, protocol)
+	}
+
+	return found, leader
+}
+
+// Ask the node at the other end of a standby connection with the given
+// address who the leader is.
+func (c *Connector) probeStandby(ctx context.Context, address string, protocol *Protocol) (NodeInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.AttemptTimeout)
+	defer cancel()
+
+	request := Message{}
+	request.Init(16)
+	response := Message{}
+	response.Init(512)
+
+	EncodeLeader(&request)
+
+	if err := protocol.Call(ctx, &request, &response); err != nil {
+		return NodeInfo{}, err
+	}
+
+	id, leader, err := DecodeNodeCompat(protocol, &response)
+	if err != nil {
+		return NodeInfo{}, err
+	}
+
+	return NodeInfo{ID: id, Address: inheritScheme(c.config.Translate.Translate(leader), address)}, nil
+}
+
+// Establish standby connections in the background, up to the configured
+// number, unless it's already being done.
+func (c *Connector) warmStandby() {
+	if c.config.Standby <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.warming {
+		return
+	}
+	c.warming = true
+
+	go func(generation uint64) {
+		c.fillStandby(generation)
+
+		c.mu.Lock()
+		c.warming = false
+		c.mu.Unlock()
+	}(c.generation)
+}
+
+// Connect to voters other than the leader, until there are as many standby
+// connections as configured. The connection to a node that became the leader
+// is closed, since it's not a standby anymore.
+func (c *Connector) fillStandby(generation uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.AttemptTimeout)
+	defer cancel()
+
+	servers, err := c.store.Get(ctx)
+	if err != nil {
+		c.log(logging.Debug, "standby: get servers: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	leader := c.leader.Address
+	if protocol, ok := c.standby[leader]; ok {
+		delete(c.standby, leader)
+		protocol.Close()
+	}
+	for address, protocol := range c.standby {
+		if c.expiredStandby(protocol) {
+			c.log(logging.Debug, "standby %s: expired", address)
+			delete(c.standby, address)
+			protocol.Close()
+		}
+	}
+	needed := c.config.Standby - len(c.standby)
+	candidates := []string{}
+	for _, server := range servers {
+		if server.Role != Voter || server.Address == leader || c.standby[server.Address] != nil {
+			continue
+		}
+		candidates = append(candidates, server.Address)
+	}
+	c.mu.Unlock()
+
+	for _, address := range candidates {
+		if needed <= 0 {
+			return
+		}
+
+		protocol, err := c.open(ctx, address, VersionOne)
+		if err != nil {
+			c.log(logging.Debug, "standby %s: %v", address, err)
+			continue
+		}
+		if err := c.register(ctx, protocol); err != nil {
+			c.log(logging.Debug, "standby %s: %v", address, err)
+			protocol.Close()
+			continue
+		}
+		protocol.pooled = time.Now()
+
+		c.mu.Lock()
+		if c.generation != generation || c.standby[address] != nil {
+			c.mu.Unlock()
+			protocol.Close()
+			continue
+		}
+		c.putStandby(address, protocol)
+		c.mu.Unlock()
+
+		needed--
+	}
+}
+
+// Add a connection to the standby ones. The lock must be held.
+func (c *Connector) putStandby(address string, protocol *Protocol) {
+	if c.standby == nil {
+		c.standby = map[string]*Protocol{}
+	}
+	c.standby[address] = protocol
+}
+
+// Tell whether a standby connection should be replaced, because it's older
+// than the max lifetime or has been idle for longer than the max idle time.
+func (c *Connector) expiredStandby(protocol *Protocol) bool {
+	now := time.Now()
+	if c.config.MaxLifetime > 0 && now.Sub(protocol.created) >= c.config.MaxLifetime {
+		return true
+	}
+	if c.config.MaxIdleTime > 0 && now.Sub(protocol.pooled) >= c.config.MaxIdleTime {
+		return true
+	}
+	return false
+}
+
+// Close closes the standby connections and the ones used to confirm the
+// leader. New ones are established after the next call to Connect or
+// VerifyLeader.
+func (c *Connector) Close() {
+	c.mu.Lock()
+	standby := c.standby
+	voters := c.voters
+	c.standby = nil
+	c.voters = nil
+	c.generation++
+	c.mu.Unlock()
+
+	for _, protocol := range standby {
+		protocol.Close()
+	}
+	for _, protocol := range voters {
+		protocol.Close()
+	}
+}
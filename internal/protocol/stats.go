@@ -0,0 +1,106 @@
+package protocol
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds counters of the activity of the connections created by a
+// Connector. Fields must be accessed atomically.
+type Stats struct {
+	LeaderChanges uint64 // Times a different leader was connected to.
+	RoundTrips    uint64 // Requests sent and awaited for a response.
+	BytesSent     uint64 // Bytes written to the network connections.
+	BytesReceived uint64 // Bytes read from the network connections.
+	InFlight      uint64 // Requests currently awaiting a response.
+}
+
+// SetStats sets the counters updated by this protocol object, nil to disable
+// them.
+func (p *Protocol) SetStats(stats *Stats) {
+	p.stats = stats
+}
+
+func (p *Protocol) countRoundTrip() {
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.RoundTrips, 1)
+	}
+}
+
+// Count a call as in flight, returning a function to invoke when it ends.
+func (p *Protocol) countInFlight() func() {
+	if p.stats == nil {
+		return func() {}
+	}
+	atomic.AddUint64(&p.stats.InFlight, 1)
+	return func() { atomic.AddUint64(&p.stats.InFlight, ^uint64(0)) }
+}
+
+// CallStats describes a completed call, as reported to a CallStatsFunc.
+type CallStats struct {
+	Type          uint8         // Type of the request, or of the first request of a batch.
+	Request       string        // Description of the request, like "exec-sql".
+	Requests      int           // Requests sent, more than one for batches and zero for More.
+	BytesSent     int           // Bytes written to the connection, headers included.
+	BytesReceived int           // Bytes read from the connection, headers included.
+	Duration      time.Duration // Time from sending the request to receiving the response.
+	Err           error         // Failure of the call, if any.
+}
+
+// CallStatsFunc is invoked after each call made on a connection, from the
+// goroutine that made the call, so it must not block.
+type CallStatsFunc func(stats CallStats)
+
+// SetCallStats sets the function invoked after each call, nil to disable it.
+func (p *Protocol) SetCallStats(fn CallStatsFunc) {
+	p.callStats = fn
+}
+
+// Start measuring a call made of the given number of requests, returning a
+// function to invoke with the outcome of the call once it ends.
+func (p *Protocol) measureCall(mtype uint8, requests int) func(err error) {
+	probe := isProbe(mtype)
+	if p.callStats == nil && p.adaptive == nil && !probe {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	sent := atomic.LoadUint64(&p.sent)
+	received := atomic.LoadUint64(&p.received)
+
+	return func(err error) {
+		duration := time.Since(start)
+		if probe && err == nil {
+			p.observeLatency(duration)
+		}
+		if p.adaptive != nil {
+			p.adaptive.observe(mtype, duration)
+		}
+		if p.callStats == nil {
+			return
+		}
+		p.callStats(CallStats{
+			Type:          mtype,
+			Request:       requestDesc(mtype),
+			Requests:      requests,
+			BytesSent:     int(atomic.LoadUint64(&p.sent) - sent),
+			BytesReceived: int(atomic.LoadUint64(&p.received) - received),
+			Duration:      duration,
+			Err:           err,
+		})
+	}
+}
+
+func (p *Protocol) countSent(n int64) {
+	atomic.AddUint64(&p.sent, uint64(n))
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.BytesSent, uint64(n))
+	}
+}
+
+func (p *Protocol) countReceived(n int) {
+	atomic.AddUint64(&p.received, uint64(n))
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.BytesReceived, uint64(n))
+	}
+}
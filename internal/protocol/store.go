@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"context"
+	"sync"
 )
 
 // NodeRole identifies the role of a node.
@@ -26,6 +27,19 @@ type NodeInfo struct {
 	ID      uint64
 	Address string
 	Role    NodeRole
+
+	// Optional metadata about the node, as configured in the node store.
+	// It's not part of the cluster configuration, so it's not filled when
+	// fetching the nodes from the cluster.
+	Weight        uint64   `json:",omitempty"`
+	FailureDomain uint64   `json:",omitempty"`
+	TLS           *NodeTLS `json:",omitempty"`
+}
+
+// NodeTLS holds the TLS parameters to use when connecting to a node.
+type NodeTLS struct {
+	ServerName string `json:",omitempty"` // Name to verify the node certificate against.
+	CACert     string `json:",omitempty"` // PEM-encoded certificates of the CAs to trust.
 }
 
 // NodeStore is used by a dqlite client to get an initial list of candidate
@@ -43,9 +57,67 @@ type NodeStore interface {
 	Set(context.Context, []NodeInfo) error
 }
 
+// WatchableNodeStore is a NodeStore that can notify when its list of servers
+// changes.
+//
+// When connecting to the leader, the connector subscribes to such stores, so
+// it can try new servers right away instead of waiting for the next retry.
+type WatchableNodeStore interface {
+	NodeStore
+
+	// Watch returns a channel receiving a value whenever the list of
+	// servers changes, until the given context is done. Notifications are
+	// coalesced: a single value may stand for several changes.
+	Watch(context.Context) <-chan struct{}
+}
+
+// NodeStoreWatchers keeps track of the channels returned by the Watch method
+// of a WatchableNodeStore. The zero value is ready to use.
+type NodeStoreWatchers struct {
+	mu       sync.Mutex
+	channels map[chan struct{}]struct{}
+}
+
+// Watch returns a new channel which is notified until the context is done,
+// and then closed.
+func (w *NodeStoreWatchers) Watch(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	w.mu.Lock()
+	if w.channels == nil {
+		w.channels = map[chan struct{}]struct{}{}
+	}
+	w.channels[ch] = struct{}{}
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mu.Lock()
+		delete(w.channels, ch)
+		close(ch)
+		w.mu.Unlock()
+	}()
+
+	return ch
+}
+
+// Notify all channels, without blocking.
+func (w *NodeStoreWatchers) Notify() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.channels {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
 // InmemNodeStore keeps the list of servers in memory.
 type InmemNodeStore struct {
-	servers []NodeInfo
+	mu       sync.RWMutex
+	servers  []NodeInfo
+	watchers NodeStoreWatchers
 }
 
 // NewInmemNodeStore creates NodeStore which stores its data in-memory.
@@ -57,11 +129,21 @@ func NewInmemNodeStore() *InmemNodeStore {
 
 // Get the current servers.
 func (i *InmemNodeStore) Get(ctx context.Context) ([]NodeInfo, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.servers, nil
 }
 
 // Set the servers.
 func (i *InmemNodeStore) Set(ctx context.Context, servers []NodeInfo) error {
+	i.mu.Lock()
 	i.servers = servers
+	i.mu.Unlock()
+	i.watchers.Notify()
 	return nil
 }
+
+// Watch returns a channel notified whenever Set is called.
+func (i *InmemNodeStore) Watch(ctx context.Context) <-chan struct{} {
+	return i.watchers.Watch(ctx)
+}
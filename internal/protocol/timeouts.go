@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"context"
+	"time"
+)
+
+// CallTimeouts holds the timeouts applied to calls whose context has no
+// deadline, by kind of request. A zero value means no timeout.
+type CallTimeouts struct {
+	Statement time.Duration // Opening databases, executing statements and queries, and fetching rows.
+	Bulk      time.Duration // Transferring whole databases, such as Dump.
+	Cluster   time.Duration // Querying and changing the cluster, such as Leader, Cluster, Add and Transfer.
+}
+
+// Kinds of requests, sharing the same default timeout.
+const (
+	kindStatement = iota
+	kindBulk
+	kindCluster
+	kinds
+)
+
+// Return the kind of requests of the given type.
+func callKind(mtype uint8) int {
+	switch mtype {
+	case RequestDump:
+		return kindBulk
+	case RequestLeader, RequestClient, RequestHeartbeat, RequestAdd, RequestAssign, RequestRemove,
+		RequestCluster, RequestTransfer, RequestDescribe, RequestWeight:
+		return kindCluster
+	default:
+		return kindStatement
+	}
+}
+
+// Return the timeout of requests of the given type.
+func (t CallTimeouts) timeout(mtype uint8) time.Duration {
+	switch callKind(mtype) {
+	case kindBulk:
+		return t.Bulk
+	case kindCluster:
+		return t.Cluster
+	default:
+		return t.Statement
+	}
+}
+
+// SetCallTimeouts sets the timeouts applied to calls whose context has no
+// deadline. The deadline of the context passed to a call always takes
+// precedence.
+func (p *Protocol) SetCallTimeouts(timeouts CallTimeouts) {
+	p.timeouts = timeouts
+}
+
+// Return the context of a call with a request of the given type, applying its
+// default timeout if the given context has no deadline.
+func (p *Protocol) callContext(ctx context.Context, mtype uint8) (context.Context, context.CancelFunc) {
+	timeout := p.timeouts.timeout(mtype)
+	if p.adaptive != nil && callKind(mtype) != kindBulk {
+		timeout = p.adaptive.timeout(mtype)
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,14 @@
+package protocol
+
+// AddressTranslator maps the address of a node, as advertised by the cluster,
+// to the address that should be dialed to reach it. Addresses that need no
+// translation must be returned unchanged.
+type AddressTranslator func(address string) string
+
+// Translate a node address learned from the cluster, if a translator is set.
+func (t AddressTranslator) Translate(address string) string {
+	if t == nil || address == "" {
+		return address
+	}
+	return t(address)
+}
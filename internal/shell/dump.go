@@ -0,0 +1,202 @@
+package shell
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (s *Shell) processTables(ctx context.Context, line string) (string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT name FROM sqlite_master
+WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'
+ORDER BY name`)
+	if err != nil {
+		return "", fmt.Errorf("query: %w", err)
+	}
+	names, err := scanStrings(rows)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(names, "\n"), nil
+}
+
+func (s *Shell) processSchema(ctx context.Context, line string) (string, error) {
+	parts := strings.Fields(line)
+	if len(parts) > 2 {
+		return "", fmt.Errorf("bad command format, should be: .schema [<table>]")
+	}
+
+	query := "SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'"
+	args := []interface{}{}
+	if len(parts) == 2 {
+		query += " AND tbl_name = ?"
+		args = append(args, parts[1])
+	}
+	query += " ORDER BY rowid"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", fmt.Errorf("query: %w", err)
+	}
+	statements, err := scanStrings(rows)
+	if err != nil {
+		return "", err
+	}
+
+	result := ""
+	for i, statement := range statements {
+		if i > 0 {
+			result += "\n"
+		}
+		result += statement + ";"
+	}
+
+	return result, nil
+}
+
+// Print the content of the database as SQL text, like the sqlite3 shell.
+func (s *Shell) processDump(ctx context.Context, line string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+SELECT name, sql FROM sqlite_master
+WHERE type = 'table' AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+ORDER BY rowid`)
+	if err != nil {
+		return "", fmt.Errorf("query: %w", err)
+	}
+	type table struct{ name, sql string }
+	tables := []table{}
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.name, &t.sql); err != nil {
+			rows.Close()
+			return "", fmt.Errorf("scan: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("rows: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("BEGIN TRANSACTION;\n")
+	for _, t := range tables {
+		b.WriteString(t.sql + ";\n")
+		if err := dumpTable(ctx, tx, &b, t.name); err != nil {
+			return "", err
+		}
+	}
+
+	// Indexes, views and triggers go after the data.
+	rows, err = tx.QueryContext(ctx, `
+SELECT sql FROM sqlite_master
+WHERE type != 'table' AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+ORDER BY rowid`)
+	if err != nil {
+		return "", fmt.Errorf("query: %w", err)
+	}
+	statements, err := scanStrings(rows)
+	if err != nil {
+		return "", err
+	}
+	for _, statement := range statements {
+		b.WriteString(statement + ";\n")
+	}
+	b.WriteString("COMMIT;")
+
+	return b.String(), nil
+}
+
+// Write an INSERT statement for each row of the given table.
+func dumpTable(ctx context.Context, tx *sql.Tx, b *strings.Builder, name string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT * FROM "+quoteIdentifier(name))
+	if err != nil {
+		return fmt.Errorf("query %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("columns: %w", err)
+	}
+
+	row := make([]interface{}, len(columns))
+	rowPointers := make([]interface{}, len(columns))
+	for i := range row {
+		rowPointers[i] = &row[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(rowPointers...); err != nil {
+			return fmt.Errorf("scan: %w", err)
+		}
+		values := make([]string, len(row))
+		for i, value := range row {
+			values[i] = quoteValue(value)
+		}
+		fmt.Fprintf(b, "INSERT INTO %s VALUES(%s);\n", quoteIdentifier(name), strings.Join(values, ","))
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows: %w", err)
+	}
+
+	return nil
+}
+
+// Return the values of a single text column.
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return values, nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Format a value as an SQL literal.
+func quoteValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case bool:
+		if value {
+			return "1"
+		}
+		return "0"
+	case []byte:
+		return "X'" + hex.EncodeToString(value) + "'"
+	case time.Time:
+		return "'" + value.Format(time.RFC3339Nano) + "'"
+	default:
+		return "'" + strings.ReplaceAll(fmt.Sprintf("%v", value), "'", "''") + "'"
+	}
+}
@@ -45,4 +45,6 @@ func defaultOptions() *options {
 const (
 	formatTabular = "tabular"
 	formatJson    = "json"
+	formatCSV     = "csv"
+	formatNDJSON  = "ndjson"
 )
@@ -33,6 +33,8 @@ func New(database string, store client.NodeStore, options ...Option) (*Shell, er
 	switch o.Format {
 	case formatTabular:
 	case formatJson:
+	case formatCSV:
+	case formatNDJSON:
 	default:
 		return nil, fmt.Errorf("unknown format %s", o.Format)
 	}
@@ -65,6 +67,19 @@ func (s *Shell) Process(ctx context.Context, line string) (string, error) {
 		return s.processCluster(ctx, line)
 	case ".leader":
 		return s.processLeader(ctx, line)
+	case ".tables":
+		return s.processTables(ctx, line)
+	case ".dump":
+		return s.processDump(ctx, line)
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimLeft(line, " ")), ".schema") {
+		return s.processSchema(ctx, line)
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimLeft(line, " ")), ".add") {
+		return s.processAdd(ctx, line)
+	}
+	if strings.HasPrefix(strings.ToLower(strings.TrimLeft(line, " ")), ".transfer") {
+		return s.processTransfer(ctx, line)
 	}
 	if strings.HasPrefix(strings.ToLower(strings.TrimLeft(line, " ")), ".remove") {
 		return s.processRemove(ctx, line)
@@ -93,14 +108,14 @@ func (s *Shell) processCluster(ctx context.Context, line string) (string, error)
 	}
 	result := ""
 	switch s.format {
-	case formatTabular:
+	case formatTabular, formatCSV:
 		for i, server := range cluster {
 			if i > 0 {
 				result += "\n"
 			}
 			result += fmt.Sprintf("%x|%s|%s", server.ID, server.Address, server.Role)
 		}
-	case formatJson:
+	case formatJson, formatNDJSON:
 		data, err := json.Marshal(cluster)
 		if err != nil {
 			return "", err
@@ -155,6 +170,57 @@ func (s *Shell) processRemove(ctx context.Context, line string) (string, error)
 	return "", fmt.Errorf("no node has address %q", address)
 }
 
+func (s *Shell) processAdd(ctx context.Context, line string) (string, error) {
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("bad command format, should be: .add <id> <address>")
+	}
+	id, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil || id == 0 {
+		return "", fmt.Errorf("bad id %q, should be a non-zero hex number", parts[1])
+	}
+	address := parts[2]
+	cli, err := client.FindLeader(ctx, s.store, client.WithDialFunc(s.dial))
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+	info := client.NodeInfo{ID: id, Address: address, Role: client.Spare}
+	if err := cli.Add(ctx, info); err != nil {
+		return "", fmt.Errorf("add node %q: %w", address, err)
+	}
+
+	return "", nil
+}
+
+func (s *Shell) processTransfer(ctx context.Context, line string) (string, error) {
+	parts := strings.Split(line, " ")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("bad command format, should be: .transfer <address>")
+	}
+	address := parts[1]
+	cli, err := client.FindLeader(ctx, s.store, client.WithDialFunc(s.dial))
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+	cluster, err := cli.Cluster(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, node := range cluster {
+		if node.Address != address {
+			continue
+		}
+		if err := cli.Transfer(ctx, node.ID); err != nil {
+			return "", fmt.Errorf("transfer leadership to %q: %w", address, err)
+		}
+		return "", nil
+	}
+
+	return "", fmt.Errorf("no node has address %q", address)
+}
+
 func (s *Shell) processDescribe(ctx context.Context, line string) (string, error) {
 	parts := strings.Split(line, " ")
 	if len(parts) != 2 {
@@ -172,9 +238,9 @@ func (s *Shell) processDescribe(ctx context.Context, line string) (string, error
 
 	result := ""
 	switch s.format {
-	case formatTabular:
+	case formatTabular, formatCSV:
 		result += fmt.Sprintf("%s|%d|%d", address, metadata.FailureDomain, metadata.Weight)
-	case formatJson:
+	case formatJson, formatNDJSON:
 		data, err := json.Marshal(metadata)
 		if err != nil {
 			return "", err
@@ -221,6 +287,22 @@ func (s *Shell) processSelect(ctx context.Context, line string) (string, error)
 	}
 	defer rows.Close()
 
+	switch s.format {
+	case formatCSV, formatNDJSON:
+		var b bytes.Buffer
+		write := driver.WriteCSV
+		if s.format == formatNDJSON {
+			write = driver.WriteNDJSON
+		}
+		if err := write(&b, rows); err != nil {
+			return "", fmt.Errorf("rows: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return "", fmt.Errorf("commit: %w", err)
+		}
+		return strings.TrimRight(b.String(), "\n"), nil
+	}
+
 	columns, err := rows.Columns()
 	if err != nil {
 		return "", fmt.Errorf("columns: %w", err)
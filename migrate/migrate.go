@@ -0,0 +1,219 @@
+// Package migrate applies ordered schema migrations to a dqlite database,
+// recording the version of the schema in a table of the database itself.
+//
+// Each migration is applied in its own transaction, together with the update
+// of the version table. Transactions take the write lock of the database on
+// the leader right away, so concurrent processes applying the same migrations
+// are serialized, and each migration is applied exactly once. If the
+// connection to the leader is lost while a migration is committed, it's safe
+// to apply the migrations again: a migration whose version was recorded is
+// skipped.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/canonical/go-dqlite/driver"
+	"github.com/pkg/errors"
+)
+
+// Migration is a single schema change.
+type Migration struct {
+	Version int    // Version of the schema after the migration, greater than zero.
+	Name    string // Description of the migration, recorded with its version.
+	SQL     string // Statements to execute, separated by semicolons.
+}
+
+// Option can be used to tweak migration parameters.
+type Option func(*options)
+
+// WithTable sets the name of the table recording the schema version,
+// "schema_version" by default.
+func WithTable(name string) Option {
+	return func(options *options) {
+		options.Table = name
+	}
+}
+
+// WithDryRun makes Apply execute the pending migrations in a single
+// transaction which is then rolled back, so that they're checked against the
+// current schema without changing it.
+func WithDryRun() Option {
+	return func(options *options) {
+		options.DryRun = true
+	}
+}
+
+type options struct {
+	Table  string
+	DryRun bool
+}
+
+func defaultOptions() *options {
+	return &options{
+		Table: "schema_version",
+	}
+}
+
+// Apply applies to the given database, which must have been opened with the
+// dqlite driver, the migrations whose version is greater than the current
+// version of its schema, in order. It returns the migrations that were
+// applied, or that would have been in dry-run mode.
+//
+// Migrations must be sorted by strictly increasing version. If a migration
+// fails, the migrations applied before it are returned along with the error.
+func Apply(ctx context.Context, db *sql.DB, migrations []Migration, options ...Option) ([]Migration, error) {
+	o := defaultOptions()
+
+	for _, option := range options {
+		option(o)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version <= 0 {
+			return nil, errors.Errorf("migration %q has non-positive version %d", migration.Name, migration.Version)
+		}
+		if i > 0 && migration.Version <= migrations[i-1].Version {
+			return nil, errors.Errorf("migration %d is not sorted by version", migration.Version)
+		}
+	}
+
+	ctx = driver.ContextWithTxLock(ctx, driver.TxImmediate)
+	table := quoteIdentifier(o.Table)
+
+	if o.DryRun {
+		return dryRun(ctx, db, table, migrations)
+	}
+
+	applied := []Migration{}
+	for _, migration := range migrations {
+		ok, err := apply(ctx, db, table, migration)
+		if err != nil {
+			return applied, errors.Wrapf(err, "migration %d (%s)", migration.Version, migration.Name)
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Version returns the current version of the schema of the given database, 0
+// if no migration was applied.
+func Version(ctx context.Context, db *sql.DB, options ...Option) (int, error) {
+	o := defaultOptions()
+
+	for _, option := range options {
+		option(o)
+	}
+
+	var exists int
+	row := db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", o.Table)
+	if err := row.Scan(&exists); err != nil {
+		return 0, errors.Wrap(err, "check version table")
+	}
+	if exists == 0 {
+		return 0, nil
+	}
+
+	var version int
+	row = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM "+quoteIdentifier(o.Table))
+	if err := row.Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "get version")
+	}
+
+	return version, nil
+}
+
+// Apply the given migration in its own transaction, unless the schema is
+// already at its version or later. Return whether it was applied.
+func apply(ctx context.Context, db *sql.DB, table string, migration Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	version, err := currentVersion(ctx, tx, table)
+	if err != nil {
+		return false, err
+	}
+	if version >= migration.Version {
+		return false, nil
+	}
+
+	if err := execute(ctx, tx, table, migration); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, errors.Wrap(err, "commit")
+	}
+
+	return true, nil
+}
+
+// Apply the pending migrations in a single transaction, and roll it back.
+func dryRun(ctx context.Context, db *sql.DB, table string, migrations []Migration) ([]Migration, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	version, err := currentVersion(ctx, tx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := execute(ctx, tx, table, migration); err != nil {
+			return pending, errors.Wrapf(err, "migration %d (%s)", migration.Version, migration.Name)
+		}
+		pending = append(pending, migration)
+	}
+
+	return pending, nil
+}
+
+// Create the version table if needed, and return the current version.
+func currentVersion(ctx context.Context, tx *sql.Tx, table string) (int, error) {
+	create := "CREATE TABLE IF NOT EXISTS " + table +
+		" (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+	if _, err := tx.ExecContext(ctx, create); err != nil {
+		return 0, errors.Wrap(err, "create version table")
+	}
+
+	var version int
+	row := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM "+table)
+	if err := row.Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "get version")
+	}
+
+	return version, nil
+}
+
+// Execute the statements of the given migration and record its version.
+func execute(ctx context.Context, tx *sql.Tx, table string, migration Migration) error {
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return errors.Wrap(err, "execute")
+	}
+
+	insert := "INSERT INTO " + table + " (version, name) VALUES (?, ?)"
+	if _, err := tx.ExecContext(ctx, insert, int64(migration.Version), migration.Name); err != nil {
+		return errors.Wrap(err, "record version")
+	}
+
+	return nil
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,187 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/go-dqlite/client/clienttest"
+	dqlitedriver "github.com/canonical/go-dqlite/driver"
+	"github.com/canonical/go-dqlite/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	dqlitedriver.RegisterDSN(dqlitedriver.DriverName)
+}
+
+var migrations = []migrate.Migration{
+	{Version: 1, Name: "create t", SQL: "CREATE TABLE t (n INT)"},
+	{Version: 2, Name: "index t", SQL: "CREATE INDEX t_n ON t (n)"},
+	{Version: 3, Name: "create u", SQL: "CREATE TABLE u (s TEXT); INSERT INTO u VALUES ('x')"},
+}
+
+func TestApply(t *testing.T) {
+	db, schema := newDatabase(t)
+	ctx := context.Background()
+
+	applied, err := migrate.Apply(ctx, db, migrations[:2])
+	require.NoError(t, err)
+	assert.Equal(t, migrations[:2], applied)
+
+	// Applied migrations are skipped.
+	applied, err = migrate.Apply(ctx, db, migrations)
+	require.NoError(t, err)
+	assert.Equal(t, migrations[2:], applied)
+
+	version, err := migrate.Version(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+
+	assert.Equal(t, []string{
+		"BEGIN IMMEDIATE",
+		"CREATE TABLE t (n INT)",
+		"COMMIT",
+		"BEGIN IMMEDIATE",
+		"CREATE INDEX t_n ON t (n)",
+		"COMMIT",
+		"BEGIN IMMEDIATE",
+		"ROLLBACK",
+		"BEGIN IMMEDIATE",
+		"ROLLBACK",
+		"BEGIN IMMEDIATE",
+		"CREATE TABLE u (s TEXT); INSERT INTO u VALUES ('x')",
+		"COMMIT",
+	}, schema.statements())
+}
+
+// A failed migration is rolled back, and the ones before it are kept.
+func TestApply_Failure(t *testing.T) {
+	db, schema := newDatabase(t)
+	ctx := context.Background()
+
+	bad := append([]migrate.Migration{}, migrations[:1]...)
+	bad = append(bad, migrate.Migration{Version: 2, Name: "broken", SQL: "BAD"})
+	applied, err := migrate.Apply(ctx, db, bad)
+	assert.Equal(t, migrations[:1], applied)
+	assert.EqualError(t, err, "migration 2 (broken): execute: syntax error")
+
+	version, err := migrate.Version(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, "ROLLBACK", schema.statements()[len(schema.statements())-1])
+}
+
+// A dry run applies the pending migrations and rolls them back.
+func TestApply_DryRun(t *testing.T) {
+	db, schema := newDatabase(t)
+	ctx := context.Background()
+
+	_, err := migrate.Apply(ctx, db, migrations[:1])
+	require.NoError(t, err)
+
+	pending, err := migrate.Apply(ctx, db, migrations, migrate.WithDryRun())
+	require.NoError(t, err)
+	assert.Equal(t, migrations[1:], pending)
+
+	version, err := migrate.Version(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	assert.Equal(t, []string{
+		"BEGIN IMMEDIATE",
+		"CREATE TABLE t (n INT)",
+		"COMMIT",
+		"BEGIN IMMEDIATE",
+		"CREATE INDEX t_n ON t (n)",
+		"CREATE TABLE u (s TEXT); INSERT INTO u VALUES ('x')",
+		"ROLLBACK",
+	}, schema.statements())
+}
+
+func TestApply_NotSorted(t *testing.T) {
+	db, _ := newDatabase(t)
+
+	_, err := migrate.Apply(context.Background(), db, []migrate.Migration{migrations[1], migrations[0]})
+	assert.EqualError(t, err, "migration 1 is not sorted by version")
+
+	_, err = migrate.Apply(context.Background(), db, []migrate.Migration{{Name: "zero"}})
+	assert.EqualError(t, err, `migration "zero" has non-positive version 0`)
+}
+
+// Fake database schema, tracking the version table through transactions.
+type schema struct {
+	mu        sync.Mutex
+	exists    bool     // Whether the version table was created.
+	committed int      // Committed version.
+	pending   int      // Version recorded by the current transaction.
+	log       []string // Statements executed, except the ones on the version table.
+}
+
+func (s *schema) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.log...)
+}
+
+func (s *schema) exec(database, sql string, args []driver.Value) (clienttest.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(sql, `CREATE TABLE IF NOT EXISTS "schema_version"`):
+		s.exists = true
+		return clienttest.Result{}, nil
+	case strings.HasPrefix(sql, `INSERT INTO "schema_version"`):
+		s.pending = int(args[0].(int64))
+		return clienttest.Result{RowsAffected: 1}, nil
+	case sql == "BAD":
+		return clienttest.Result{}, clienttest.Error{Code: 1, Message: "syntax error"}
+	case sql == "BEGIN IMMEDIATE":
+		s.pending = s.committed
+	case sql == "COMMIT":
+		s.committed = s.pending
+	case sql == "ROLLBACK":
+		s.pending = s.committed
+	}
+	s.log = append(s.log, sql)
+
+	return clienttest.Result{}, nil
+}
+
+func (s *schema) query(database, sql string, args []driver.Value) (*clienttest.Rows, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var value int64
+	switch {
+	case strings.HasPrefix(sql, "SELECT count(*) FROM sqlite_master"):
+		if s.exists {
+			value = 1
+		}
+	default:
+		value = int64(s.pending)
+	}
+
+	return &clienttest.Rows{Columns: []string{"n"}, Values: [][]driver.Value{{value}}}, nil
+}
+
+func newDatabase(t *testing.T) (*sql.DB, *schema) {
+	server := clienttest.NewServer(1)
+	t.Cleanup(func() { server.Close() })
+
+	s := &schema{}
+	server.HandleExec(s.exec)
+	server.HandleQuery(s.query)
+
+	db, err := sql.Open(dqlitedriver.DriverName, "dqlite://"+server.Address()+"/test.db")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	return db, s
+}
@@ -52,6 +52,35 @@ func WithFailureDomain(code uint64) Option {
 	}
 }
 
+// SnapshotParams tunes raft snapshots. Threshold is the number of log entries
+// after which a snapshot is taken, and Trailing is the number of entries kept
+// in the log after a snapshot, so that followers lagging slightly behind can
+// catch up without receiving the whole snapshot.
+//
+// A lower threshold uses less disk space and makes restarts faster, at the
+// cost of taking snapshots more often.
+type SnapshotParams = bindings.SnapshotParams
+
+// WithSnapshotParams sets the raft snapshot parameters. If not set, the
+// defaults of dqlite are used.
+func WithSnapshotParams(params SnapshotParams) Option {
+	return func(options *options) {
+		options.SnapshotParams = params
+	}
+}
+
+// WithDiskMode makes the node store databases on disk instead of keeping them
+// fully in memory, for datasets larger than the available RAM.
+//
+// All nodes of a cluster must use the same mode, and the mode of a node can't
+// be changed once its data directory has been created. This is not checked by
+// dqlite.
+func WithDiskMode(disk bool) Option {
+	return func(options *options) {
+		options.DiskMode = disk
+	}
+}
+
 // New creates a new Node instance.
 func New(id uint64, address string, dir string, options ...Option) (*Node, error) {
 	o := defaultOptions()
@@ -84,6 +113,16 @@ func New(id uint64, address string, dir string, options ...Option) (*Node, error
 			return nil, err
 		}
 	}
+	if o.SnapshotParams.Threshold != 0 || o.SnapshotParams.Trailing != 0 {
+		if err := server.SetSnapshotParams(o.SnapshotParams); err != nil {
+			return nil, err
+		}
+	}
+	if o.DiskMode {
+		if err := server.EnableDiskMode(); err != nil {
+			return nil, err
+		}
+	}
 	s := &Node{
 		server:      server,
 		acceptCh:    make(chan error, 1),
@@ -120,6 +159,8 @@ type options struct {
 	BindAddress    string
 	NetworkLatency uint64
 	FailureDomain  uint64
+	DiskMode       bool
+	SnapshotParams SnapshotParams
 }
 
 // Close the server, releasing all resources it created.
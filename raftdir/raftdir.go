@@ -0,0 +1,430 @@
+// Package raftdir inspects the raft data directory of a dqlite node offline,
+// reporting its segments and snapshots and how they fit together, to help
+// deciding how to recover a node after a crash.
+//
+// The directory must not be in use: the node owning it must be stopped, since
+// open segments and snapshots are rewritten while it runs. Nothing in the
+// directory is ever modified.
+package raftdir
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The only on-disk format version of raft files.
+const formatVersion = 1
+
+// Metadata holds the content of the raft metadata files.
+type Metadata struct {
+	Filename string // The most recent of metadata1 and metadata2.
+	Version  uint64 // Incremented every time the metadata is written.
+	Term     uint64 // Current term of the node.
+	VotedFor uint64 // ID of the node voted for in the current term, if any.
+}
+
+// Segment describes a segment file holding raft log entries.
+type Segment struct {
+	Filename   string
+	Size       int64
+	Open       bool   // Whether the segment was still being written.
+	FirstIndex uint64 // Index of the first entry.
+	LastIndex  uint64 // Index of the last entry, or FirstIndex-1 if empty.
+	FirstTerm  uint64 // Term of the first entry, zero if empty.
+	LastTerm   uint64 // Term of the last entry, zero if empty.
+	Entries    int    // Number of entries found in the file.
+	Err        error  // Corruption found in the file, if any.
+}
+
+// Snapshot describes a snapshot and its metadata file.
+type Snapshot struct {
+	Filename           string
+	Size               int64
+	Term               uint64 // Term of the last entry included in the snapshot.
+	Index              uint64 // Index of the last entry included in the snapshot.
+	Timestamp          uint64 // Creation time, in milliseconds since the epoch.
+	ConfigurationIndex uint64 // Index of the configuration stored in the snapshot.
+	Err                error  // Corruption found in the snapshot, if any.
+}
+
+// Inventory is the content of a raft data directory.
+type Inventory struct {
+	Metadata  *Metadata  // Nil if there's no valid metadata file.
+	Segments  []Segment  // Closed segments by index, then open segments.
+	Snapshots []Snapshot // By index.
+}
+
+// Inspect reads the raft data directory at the given path.
+//
+// Corruption found in individual files doesn't make it fail: it's reported in
+// the Err field of segments and snapshots, and by Inventory.Problems. The
+// first index of an open segment isn't recorded in its name, it's assumed to
+// follow the last index of the segment before it, or the index of the latest
+// snapshot.
+func Inspect(dir string) (*Inventory, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read directory")
+	}
+
+	inventory := &Inventory{}
+	type openSegment struct {
+		counter uint64
+		info    os.FileInfo
+	}
+	opens := []openSegment{}
+
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, name)
+
+		switch {
+		case name == "metadata1" || name == "metadata2":
+			metadata, err := readMetadata(path)
+			if err != nil {
+				continue
+			}
+			if inventory.Metadata == nil || metadata.Version > inventory.Metadata.Version {
+				inventory.Metadata = metadata
+			}
+		case strings.HasPrefix(name, "open-"):
+			counter, err := strconv.ParseUint(strings.TrimPrefix(name, "open-"), 10, 64)
+			if err != nil {
+				continue
+			}
+			opens = append(opens, openSegment{counter: counter, info: info})
+		case strings.HasPrefix(name, "snapshot-") && !strings.HasSuffix(name, ".meta"):
+			snapshot, ok := parseSnapshotName(name)
+			if !ok {
+				continue
+			}
+			snapshot.Size = info.Size()
+			snapshot.Err = readSnapshotMeta(path+".meta", snapshot)
+			inventory.Snapshots = append(inventory.Snapshots, *snapshot)
+		default:
+			segment, ok := parseSegmentName(name)
+			if !ok {
+				continue
+			}
+			segment.Size = info.Size()
+			segment.Err = readSegment(path, segment)
+			inventory.Segments = append(inventory.Segments, *segment)
+		}
+	}
+
+	sort.Slice(inventory.Segments, func(i, j int) bool {
+		return inventory.Segments[i].FirstIndex < inventory.Segments[j].FirstIndex
+	})
+	sort.Slice(inventory.Snapshots, func(i, j int) bool {
+		return inventory.Snapshots[i].Index < inventory.Snapshots[j].Index
+	})
+	sort.Slice(opens, func(i, j int) bool { return opens[i].counter < opens[j].counter })
+
+	next := uint64(1)
+	if n := len(inventory.Segments); n > 0 {
+		next = inventory.Segments[n-1].LastIndex + 1
+	} else if n := len(inventory.Snapshots); n > 0 {
+		next = inventory.Snapshots[n-1].Index + 1
+	}
+	for _, open := range opens {
+		segment := &Segment{
+			Filename:   open.info.Name(),
+			Size:       open.info.Size(),
+			Open:       true,
+			FirstIndex: next,
+		}
+		segment.Err = readSegment(filepath.Join(dir, segment.Filename), segment)
+		inventory.Segments = append(inventory.Segments, *segment)
+		next = segment.LastIndex + 1
+	}
+
+	return inventory, nil
+}
+
+// FirstIndex returns the index of the first entry of the log, zero if there
+// are no segments.
+func (i *Inventory) FirstIndex() uint64 {
+	if len(i.Segments) == 0 {
+		return 0
+	}
+	return i.Segments[0].FirstIndex
+}
+
+// LastIndex returns the index of the last entry of the log, or of the latest
+// snapshot if there are no entries after it.
+func (i *Inventory) LastIndex() uint64 {
+	last := uint64(0)
+	if n := len(i.Snapshots); n > 0 {
+		last = i.Snapshots[n-1].Index
+	}
+	for _, segment := range i.Segments {
+		if segment.LastIndex > last {
+			last = segment.LastIndex
+		}
+	}
+	return last
+}
+
+// Problems returns a description of each corruption or inconsistency found:
+// corrupt files, gaps or overlaps between segments, a gap between the latest
+// snapshot and the log, and terms going backwards. Nil means that the
+// directory looks sane.
+func (i *Inventory) Problems() []string {
+	var problems []string
+
+	if i.Metadata == nil {
+		problems = append(problems, "no valid metadata file")
+	}
+
+	for _, snapshot := range i.Snapshots {
+		if snapshot.Err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", snapshot.Filename, snapshot.Err))
+		}
+	}
+
+	term := uint64(0)
+	for j, segment := range i.Segments {
+		if segment.Err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", segment.Filename, segment.Err))
+		}
+		if j > 0 {
+			previous := i.Segments[j-1]
+			if segment.FirstIndex != previous.LastIndex+1 {
+				problems = append(problems, fmt.Sprintf(
+					"%s: starts at index %d, but %s ends at index %d",
+					segment.Filename, segment.FirstIndex, previous.Filename, previous.LastIndex))
+			}
+		}
+		if segment.Entries > 0 {
+			if segment.FirstTerm < term {
+				problems = append(problems, fmt.Sprintf(
+					"%s: term %d is lower than previous term %d", segment.Filename, segment.FirstTerm, term))
+			}
+			term = segment.LastTerm
+		}
+	}
+
+	if n := len(i.Snapshots); n > 0 {
+		snapshot := i.Snapshots[n-1]
+		if len(i.Segments) > 0 && i.FirstIndex() > snapshot.Index+1 {
+			problems = append(problems, fmt.Sprintf(
+				"log starts at index %d, but the latest snapshot ends at index %d",
+				i.FirstIndex(), snapshot.Index))
+		}
+	}
+
+	if i.Metadata != nil && term > i.Metadata.Term {
+		problems = append(problems, fmt.Sprintf(
+			"log has entries of term %d, but the current term is %d", term, i.Metadata.Term))
+	}
+
+	return problems
+}
+
+// Read a metadata file, which holds the format version, the metadata version,
+// the term and the vote.
+func readMetadata(path string) (*Metadata, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 32 {
+		return nil, errors.Errorf("size is %d bytes instead of 32", len(data))
+	}
+	if format := binary.LittleEndian.Uint64(data); format != formatVersion {
+		return nil, errors.Errorf("unknown format version %d", format)
+	}
+	metadata := &Metadata{
+		Filename: filepath.Base(path),
+		Version:  binary.LittleEndian.Uint64(data[8:]),
+		Term:     binary.LittleEndian.Uint64(data[16:]),
+		VotedFor: binary.LittleEndian.Uint64(data[24:]),
+	}
+	if metadata.Version == 0 {
+		return nil, errors.New("version is zero")
+	}
+	return metadata, nil
+}
+
+// Parse the name of a closed segment, made of its first and last index.
+func parseSegmentName(name string) (*Segment, bool) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 2 || len(parts[0]) != 16 || len(parts[1]) != 16 {
+		return nil, false
+	}
+	first, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	last, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &Segment{Filename: name, FirstIndex: first, LastIndex: last}, true
+}
+
+// Read the entries of a segment, filling in its terms and entry count. The
+// last index of open segments is set from the number of entries, the one of
+// closed segments is checked against it.
+//
+// A segment starts with the format version, followed by batches of entries.
+// Each batch starts with the checksums of its header and of its data, then
+// the header holds the number of entries and the term, type and size of each
+// entry, and the data holds the content of the entries, padded to 8 bytes.
+// Open segments are preallocated, so they end with zeros.
+func readSegment(path string, segment *Segment) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if segment.Open && isZero(data) {
+		segment.LastIndex = segment.FirstIndex - 1
+		return nil
+	}
+	if len(data) < 8 {
+		return errors.New("file is too short")
+	}
+	if format := binary.LittleEndian.Uint64(data); format != formatVersion {
+		return errors.Errorf("unknown format version %d", format)
+	}
+
+	offset := 8
+	for offset < len(data) {
+		if segment.Open && isZero(data[offset:]) {
+			break
+		}
+		var n int
+		n, err = readBatch(data[offset:], segment)
+		if err != nil {
+			err = errors.Wrapf(err, "batch at offset %d", offset)
+			break
+		}
+		offset += n
+	}
+
+	entries := uint64(segment.Entries)
+	if segment.Open {
+		segment.LastIndex = segment.FirstIndex + entries - 1
+	} else if err == nil && entries != segment.LastIndex-segment.FirstIndex+1 {
+		err = errors.Errorf("has %d entries, but its name spans %d", entries, segment.LastIndex-segment.FirstIndex+1)
+	}
+
+	return err
+}
+
+// Read the batch at the start of the given data, returning its size.
+func readBatch(data []byte, segment *Segment) (int, error) {
+	if len(data) < 16 {
+		return 0, errors.New("truncated header")
+	}
+	headerChecksum := binary.LittleEndian.Uint32(data)
+	dataChecksum := binary.LittleEndian.Uint32(data[4:])
+
+	n := binary.LittleEndian.Uint64(data[8:])
+	if n == 0 {
+		return 0, errors.New("no entries")
+	}
+	if n > uint64(len(data)-16)/16 {
+		return 0, errors.New("truncated header")
+	}
+	headerSize := 8 + 16*int(n)
+	header := data[8 : 8+headerSize]
+	if crc32.ChecksumIEEE(header) != headerChecksum {
+		return 0, errors.New("header checksum mismatch")
+	}
+
+	size := 0
+	terms := make([]uint64, n)
+	for i := range terms {
+		entry := header[8+16*i:]
+		terms[i] = binary.LittleEndian.Uint64(entry)
+		length := int(binary.LittleEndian.Uint32(entry[12:]))
+		size += (length + 7) / 8 * 8
+	}
+
+	start := 8 + headerSize
+	if size > len(data)-start {
+		return 0, errors.New("truncated data")
+	}
+	if crc32.ChecksumIEEE(data[start:start+size]) != dataChecksum {
+		return 0, errors.New("data checksum mismatch")
+	}
+
+	if segment.Entries == 0 {
+		segment.FirstTerm = terms[0]
+	}
+	segment.LastTerm = terms[n-1]
+	segment.Entries += int(n)
+
+	return start + size, nil
+}
+
+// Parse the name of a snapshot, made of its term, index and timestamp.
+func parseSnapshotName(name string) (*Snapshot, bool) {
+	parts := strings.Split(strings.TrimPrefix(name, "snapshot-"), "-")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return &Snapshot{Filename: name, Term: values[0], Index: values[1], Timestamp: values[2]}, true
+}
+
+// Read the metadata file of a snapshot, which holds the format version, a
+// checksum, and the index and content of the configuration.
+func readSnapshotMeta(path string, snapshot *Snapshot) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("no metadata file")
+		}
+		return err
+	}
+	if len(data) < 32 {
+		return errors.New("metadata file is too short")
+	}
+	if format := binary.LittleEndian.Uint64(data); format != formatVersion {
+		return errors.Errorf("unknown metadata format version %d", format)
+	}
+	checksum := uint32(binary.LittleEndian.Uint64(data[8:]))
+	snapshot.ConfigurationIndex = binary.LittleEndian.Uint64(data[16:])
+	length := binary.LittleEndian.Uint64(data[24:])
+	if length > uint64(len(data)-32) {
+		return errors.New("truncated configuration in metadata file")
+	}
+	if crc32.ChecksumIEEE(data[16:32+length]) != checksum {
+		return errors.New("metadata checksum mismatch")
+	}
+	if snapshot.ConfigurationIndex > snapshot.Index {
+		return errors.Errorf("configuration index %d is past the snapshot", snapshot.ConfigurationIndex)
+	}
+	return nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package raftdir_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/canonical/go-dqlite/raftdir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	writeMetadata(t, dir, "metadata1", 3, 2)
+	writeMetadata(t, dir, "metadata2", 4, 3)
+	writeSnapshot(t, dir, "snapshot-2-4-1000", 3)
+	writeFile(t, dir, "0000000000000001-0000000000000003", segment(batch(1, 1), batch(2)))
+	writeFile(t, dir, "0000000000000004-0000000000000005", segment(batch(2, 2)))
+	writeFile(t, dir, "open-1", append(segment(batch(3)), make([]byte, 64)...))
+	writeFile(t, dir, "open-2", make([]byte, 64))
+
+	inventory, err := raftdir.Inspect(dir)
+	require.NoError(t, err)
+
+	require.NotNil(t, inventory.Metadata)
+	assert.Equal(t, "metadata2", inventory.Metadata.Filename)
+	assert.Equal(t, uint64(3), inventory.Metadata.Term)
+
+	require.Len(t, inventory.Snapshots, 1)
+	snapshot := inventory.Snapshots[0]
+	assert.Equal(t, uint64(2), snapshot.Term)
+	assert.Equal(t, uint64(4), snapshot.Index)
+	assert.Equal(t, uint64(3), snapshot.ConfigurationIndex)
+	assert.NoError(t, snapshot.Err)
+
+	require.Len(t, inventory.Segments, 4)
+	for _, segment := range inventory.Segments {
+		assert.NoError(t, segment.Err, segment.Filename)
+	}
+	assert.Equal(t, 3, inventory.Segments[0].Entries)
+	assert.Equal(t, uint64(1), inventory.Segments[0].FirstTerm)
+	assert.Equal(t, uint64(2), inventory.Segments[0].LastTerm)
+
+	open := inventory.Segments[2]
+	assert.True(t, open.Open)
+	assert.Equal(t, uint64(6), open.FirstIndex)
+	assert.Equal(t, uint64(6), open.LastIndex)
+	assert.Equal(t, 0, inventory.Segments[3].Entries)
+
+	assert.Equal(t, uint64(1), inventory.FirstIndex())
+	assert.Equal(t, uint64(6), inventory.LastIndex())
+	assert.Empty(t, inventory.Problems())
+}
+
+func TestInspect_Problems(t *testing.T) {
+	dir, cleanup := newDir(t)
+	defer cleanup()
+
+	corrupt := segment(batch(1, 1))
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	writeMetadata(t, dir, "metadata1", 1, 1)
+	writeFile(t, dir, "snapshot-1-2-1000", []byte("data"))
+	writeFile(t, dir, "0000000000000005-0000000000000006", corrupt)
+	writeFile(t, dir, "0000000000000008-0000000000000008", segment(batch(2)))
+
+	inventory, err := raftdir.Inspect(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"snapshot-1-2-1000: no metadata file",
+		"0000000000000005-0000000000000006: batch at offset 8: data checksum mismatch",
+		"0000000000000008-0000000000000008: starts at index 8, but 0000000000000005-0000000000000006 ends at index 6",
+		"log starts at index 5, but the latest snapshot ends at index 2",
+		"log has entries of term 2, but the current term is 1",
+	}, inventory.Problems())
+}
+
+func newDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "dqlite-raftdir-test-")
+	require.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+}
+
+func writeMetadata(t *testing.T, dir, name string, version, term uint64) {
+	writeFile(t, dir, name, words(1, version, term, 1))
+}
+
+func writeSnapshot(t *testing.T, dir, name string, configurationIndex uint64) {
+	writeFile(t, dir, name, []byte("data"))
+
+	configuration := []byte("12345678")
+	meta := words(1, 0, configurationIndex, uint64(len(configuration)))
+	meta = append(meta, configuration...)
+	binary.LittleEndian.PutUint64(meta[8:], uint64(crc32.ChecksumIEEE(meta[16:])))
+	writeFile(t, dir, name+".meta", meta)
+}
+
+// Return the content of a segment holding the given batches.
+func segment(batches ...[]byte) []byte {
+	data := words(1)
+	for _, batch := range batches {
+		data = append(data, batch...)
+	}
+	return data
+}
+
+// Return a batch holding an entry with 8 bytes of data for each given term.
+func batch(terms ...uint64) []byte {
+	header := words(uint64(len(terms)))
+	data := []byte{}
+	for i, term := range terms {
+		header = append(header, words(term, 8<<32)...)
+		data = append(data, words(uint64(i))...)
+	}
+	checksums := make([]byte, 8)
+	binary.LittleEndian.PutUint32(checksums, crc32.ChecksumIEEE(header))
+	binary.LittleEndian.PutUint32(checksums[4:], crc32.ChecksumIEEE(data))
+	return append(append(checksums, header...), data...)
+}
+
+func words(values ...uint64) []byte {
+	data := make([]byte, 8*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint64(data[8*i:], value)
+	}
+	return data
+}